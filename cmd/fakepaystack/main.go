@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/config"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway/fakepaystack"
+)
+
+// Runs the fake Paystack API for local development. Start the app with
+// PAYSTACK_BASE_URL=http://localhost:8089 and the same PAYSTACK_SECRET_KEY.
+func main() {
+	addr := flag.String("addr", ":8089", "address to listen on")
+	webhook := flag.String("webhook", "http://localhost:8080/api/v1/webhooks/paystack", "where signed webhooks are sent")
+	flag.Parse()
+
+	cfg := config.Load()
+	if cfg.PAYSTACK_SECRET_KEY == "" {
+		log.Fatal("PAYSTACK_SECRET_KEY must be set so webhooks can be signed")
+	}
+
+	fake := fakepaystack.New(cfg.PAYSTACK_SECRET_KEY)
+	fake.WebhookURL = *webhook
+
+	log.Printf("Fake Paystack listening on %s, sending webhooks to %s", *addr, *webhook)
+	log.Fatal(http.ListenAndServe(*addr, fake))
+}
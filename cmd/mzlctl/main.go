@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/config"
+)
+
+const usage = `usage: mzlctl <command> [args]
+
+  dlq depth                              jobs waiting in each dead-letter queue
+  dlq list <queue> [-offset N] [-limit N] show failed jobs with their payload and last error
+  dlq requeue <queue> (<id>... | -all)   put jobs back on their queue
+  dlq purge <queue> (<id>... | -all)     delete jobs for good
+  jobs                                   per job type stats from the server
+  audit [-limit N]                       recent admin actions
+
+MZL_API_URL (default http://localhost:8080) picks the server, ADMIN_API_KEY
+authenticates and $USER is recorded as the actor.`
+
+// Talks to the admin API, so it works against any running server without
+// Redis or database access of its own.
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	cfg := config.Load()
+	c := &client{
+		baseURL: getenv("MZL_API_URL", "http://localhost:8080"),
+		key:     cfg.AdminAPIKey,
+		actor:   getenv("USER", "mzlctl"),
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "dlq":
+		err = runDLQ(c, os.Args[2:])
+	case "jobs":
+		err = c.print("GET", "/api/v1/admin/jobs", nil)
+	case "audit":
+		fs := flag.NewFlagSet("audit", flag.ExitOnError)
+		limit := fs.Int("limit", 20, "entries to show")
+		fs.Parse(os.Args[2:])
+		err = c.print("GET", "/api/v1/admin/audit?limit="+fmt.Sprint(*limit), nil)
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runDLQ(c *client, args []string) error {
+	if len(args) < 1 {
+		return errors.New(usage)
+	}
+	if args[0] == "depth" {
+		return c.print("GET", "/api/v1/admin/dlq", nil)
+	}
+	if len(args) < 2 {
+		return errors.New(usage)
+	}
+	cmd, queue := args[0], url.PathEscape(args[1])
+
+	fs := flag.NewFlagSet("dlq "+cmd, flag.ExitOnError)
+	offset := fs.Int("offset", 0, "jobs to skip")
+	limit := fs.Int("limit", 20, "jobs to show")
+	all := fs.Bool("all", false, "act on every job in the queue")
+	fs.Parse(args[2:])
+
+	switch cmd {
+	case "list":
+		return c.print("GET", fmt.Sprintf("/api/v1/admin/dlq/%s?offset=%d&limit=%d", queue, *offset, *limit), nil)
+	case "requeue", "purge":
+		body := map[string]interface{}{"ids": fs.Args(), "all": *all}
+		return c.print("POST", fmt.Sprintf("/api/v1/admin/dlq/%s/%s", queue, cmd), body)
+	default:
+		return errors.New(usage)
+	}
+}
+
+type client struct {
+	baseURL string
+	key     string
+	actor   string
+	http    *http.Client
+}
+
+// print sends the request and writes the response's data, indented, to stdout.
+func (c *client) print(method, path string, body interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Admin-Key", c.key)
+	req.Header.Set("X-Admin-Actor", c.actor)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Error   string          `json:"error"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("%s: unreadable response: %w", resp.Status, err)
+	}
+	if resp.StatusCode >= 300 {
+		msg := out.Message
+		if out.Error != "" {
+			msg = out.Error
+		}
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, out.Data, "", "  "); err != nil {
+		return err
+	}
+	fmt.Println(pretty.String())
+	return nil
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/shopspring/decimal"
 )
 
 type Config struct {
@@ -12,7 +15,83 @@ type Config struct {
 	DatabaseURL         string
 	JWTSecret           string
 	PAYSTACK_SECRET_KEY string
+	PaystackBaseURL     string
+	PaystackCallbackURL string
 	REDIS_URL string
+
+	// PaymentProvider is the gateway used when a request doesn't name one.
+	// Flutterwave is only registered when FlutterwaveSecretKey is set.
+	PaymentProvider        string
+	FlutterwaveSecretKey   string
+	FlutterwaveWebhookHash string
+	FlutterwaveBaseURL     string
+	FlutterwaveRedirectURL string
+
+	// AdminAPIKey guards /api/v1/admin routes. Admin routes are disabled when empty.
+	AdminAPIKey string
+
+	// ReconciliationInterval is how often the balance reconciliation job runs;
+	// zero disables the schedule. ReconciliationFreeze freezes wallets it flags.
+	ReconciliationInterval time.Duration
+	ReconciliationFreeze   bool
+
+	// SettlementInterval is how often Paystack is reconciled against local
+	// transactions, looking back SettlementLookback each time. Safe fixes are
+	// applied automatically when SettlementAutoFix is set.
+	SettlementInterval time.Duration
+	SettlementLookback time.Duration
+	SettlementAutoFix  bool
+
+	// WithdrawalPollInterval is how often withdrawals unfinished for longer
+	// than WithdrawalStuckAfter are checked with their gateway; zero disables it.
+	WithdrawalPollInterval time.Duration
+	WithdrawalStuckAfter   time.Duration
+
+	// QueueConcurrency is the number of workers per queue. Jobs whose worker
+	// stops renewing its claim for QueueVisibilityTimeout are redelivered, and
+	// on shutdown running jobs get QueueDrainTimeout to finish.
+	QueueConcurrency       int
+	QueueVisibilityTimeout time.Duration
+	QueueDrainTimeout      time.Duration
+
+	// QueueRetryPolicies overrides the default retry policy per queue, as
+	// "queue=attempts:base:max,...", e.g. "withdrawals=5:1m:30m".
+	QueueRetryPolicies string
+
+	// OutboxRelayInterval is how often unpublished outbox events are moved
+	// to the job queue; zero disables the relay.
+	OutboxRelayInterval time.Duration
+
+	// Email goes through SMTP when SMTPHost is set. Other notifications, and
+	// email without SMTP, are written to NotificationLogFile, or stdout when
+	// that is empty, until a real provider is configured.
+	SMTPHost            string
+	SMTPPort            string
+	SMTPUsername        string
+	SMTPPassword        string
+	SMTPFrom            string
+	NotificationLogFile string
+
+	// ReceiptSigningKey signs receipt verification codes; it falls back to
+	// the JWT secret. PublicURL is where the API is reached from outside,
+	// used for the verify links printed on receipts.
+	ReceiptSigningKey string
+	PublicURL         string
+
+	// VaultBreakPenalty is the percent of a vault's balance kept as a fee
+	// when it is broken before its lock date.
+	VaultBreakPenalty decimal.Decimal
+
+	// InterestRates are annual rates in percent as "currency:product=percent,...",
+	// e.g. "NGN:WALLET=2.5,NGN:VAULT=10". InterestInterval is how often days
+	// that have ended are accrued and last month is paid; zero disables it.
+	InterestRates    string
+	InterestInterval time.Duration
+
+	// TransferReviewLimits are the amounts from which a transfer to someone
+	// else is held for an admin to review, as "currency=amount,...", e.g.
+	// "NGN=5000000,USD=5000". Empty means no transfer is held.
+	TransferReviewLimits string
 }
 
 func Load() *Config {
@@ -25,7 +104,46 @@ func Load() *Config {
 		DatabaseURL:         getEnv("DATABASE_URL", ""),
 		JWTSecret:           getEnv("JWT_ACCESS_SECRET", "super-secret"),
 		PAYSTACK_SECRET_KEY: getEnv("PAYSTACK_SECRET_KEY", ""),
+		PaystackBaseURL:     getEnv("PAYSTACK_BASE_URL", "https://api.paystack.co"),
+		PaystackCallbackURL: getEnv("PAYSTACK_CALLBACK_URL", ""),
 		REDIS_URL:getEnv("REDIS_URL",""),
+
+		PaymentProvider:        getEnv("PAYMENT_PROVIDER", "PAYSTACK"),
+		FlutterwaveSecretKey:   getEnv("FLUTTERWAVE_SECRET_KEY", ""),
+		FlutterwaveWebhookHash: getEnv("FLUTTERWAVE_WEBHOOK_HASH", ""),
+		FlutterwaveBaseURL:     getEnv("FLUTTERWAVE_BASE_URL", "https://api.flutterwave.com/v3"),
+		FlutterwaveRedirectURL: getEnv("FLUTTERWAVE_REDIRECT_URL", ""),
+
+		AdminAPIKey:            getEnv("ADMIN_API_KEY", ""),
+		ReconciliationInterval: getEnvDuration("RECONCILIATION_INTERVAL", time.Hour),
+		ReconciliationFreeze:   getEnvBool("RECONCILIATION_FREEZE", false),
+		SettlementInterval:     getEnvDuration("SETTLEMENT_INTERVAL", 6*time.Hour),
+		SettlementLookback:     getEnvDuration("SETTLEMENT_LOOKBACK", 48*time.Hour),
+		SettlementAutoFix:      getEnvBool("SETTLEMENT_AUTO_FIX", true),
+		WithdrawalPollInterval: getEnvDuration("WITHDRAWAL_POLL_INTERVAL", 5*time.Minute),
+		WithdrawalStuckAfter:   getEnvDuration("WITHDRAWAL_STUCK_AFTER", 15*time.Minute),
+		QueueConcurrency:       getEnvInt("QUEUE_CONCURRENCY", 4),
+		QueueVisibilityTimeout: getEnvDuration("QUEUE_VISIBILITY_TIMEOUT", 2*time.Minute),
+		QueueDrainTimeout:      getEnvDuration("QUEUE_DRAIN_TIMEOUT", 30*time.Second),
+		QueueRetryPolicies:     getEnv("QUEUE_RETRY_POLICIES", ""),
+		OutboxRelayInterval:    getEnvDuration("OUTBOX_RELAY_INTERVAL", time.Second),
+
+		SMTPHost:            getEnv("SMTP_HOST", ""),
+		SMTPPort:            getEnv("SMTP_PORT", "587"),
+		SMTPUsername:        getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:        getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:            getEnv("SMTP_FROM", ""),
+		NotificationLogFile: getEnv("NOTIFICATION_LOG_FILE", ""),
+
+		ReceiptSigningKey: getEnv("RECEIPT_SIGNING_KEY", ""),
+		PublicURL:         getEnv("PUBLIC_URL", ""),
+
+		VaultBreakPenalty: getEnvDecimal("VAULT_BREAK_PENALTY", decimal.NewFromInt(1)),
+
+		InterestRates:    getEnv("INTEREST_RATES", ""),
+		InterestInterval: getEnvDuration("INTEREST_INTERVAL", time.Hour),
+
+		TransferReviewLimits: getEnv("TRANSFER_REVIEW_LIMITS", ""),
 	}
 }
 
@@ -35,3 +153,55 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %t", key, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+func getEnvDecimal(key string, fallback decimal.Decimal) decimal.Decimal {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
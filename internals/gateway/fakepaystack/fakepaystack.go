@@ -0,0 +1,652 @@
+// Package fakepaystack is a stand-in for the Paystack API covering the
+// endpoints the gateway adapter calls. It keeps charges, recipients and
+// transfers in memory and sends signed webhooks back to the app, so deposits
+// and withdrawals can be exercised end to end without api.paystack.co.
+//
+// Point PAYSTACK_BASE_URL (or gateway.NewPaystack) at it and set WebhookURL
+// to the app's /api/v1/webhooks/paystack route.
+package fakepaystack
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	ErrUnknownCharge   = errors.New("fakepaystack: unknown charge")
+	ErrUnknownTransfer = errors.New("fakepaystack: unknown transfer")
+)
+
+// Charge is a transaction created through /transaction/initialize.
+type Charge struct {
+	ID          int64
+	Reference   string
+	Email       string
+	Amount      int64 // subunits
+	Currency    string
+	Status      string // abandoned until paid, then success or failed
+	CallbackURL string
+	CreatedAt   time.Time
+	PaidAt      time.Time
+}
+
+type Recipient struct {
+	Code          string
+	Name          string
+	AccountNumber string
+	BankCode      string
+	Currency      string
+}
+
+// Transfer is a payout created through /transfer. It stays pending until
+// SettleTransfer is called.
+type Transfer struct {
+	ID        int64
+	Code      string
+	Reference string
+	Recipient string
+	Amount    int64 // subunits
+	Currency  string
+	Reason    string
+	Status    string // pending, success, failed or reversed
+	CreatedAt time.Time
+}
+
+type Bank struct {
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+	Currency string `json:"currency"`
+}
+
+// DefaultBanks is what /bank returns unless Banks is replaced.
+var DefaultBanks = []Bank{
+	{Name: "Access Bank", Code: "044", Currency: "NGN"},
+	{Name: "First Bank of Nigeria", Code: "011", Currency: "NGN"},
+	{Name: "Guaranty Trust Bank", Code: "058", Currency: "NGN"},
+	{Name: "United Bank For Africa", Code: "033", Currency: "NGN"},
+	{Name: "Zenith Bank", Code: "057", Currency: "NGN"},
+	{Name: "MTN Mobile Money", Code: "MTN", Currency: "GHS"},
+}
+
+var nuban = regexp.MustCompile(`^[0-9]{10}$`)
+
+type Fake struct {
+	SecretKey string
+	// WebhookURL receives the signed charge.* and transfer.* events. Nothing
+	// is sent while it is empty.
+	WebhookURL string
+	Banks      []Bank
+
+	mu         sync.Mutex
+	nextID     int64
+	accounts   map[string]string // bankCode/accountNumber -> account name
+	charges    map[string]*Charge
+	recipients map[string]*Recipient
+	transfers  map[string]*Transfer // by reference
+
+	router http.Handler
+	client *http.Client
+}
+
+func New(secretKey string) *Fake {
+	f := &Fake{
+		SecretKey:  secretKey,
+		Banks:      DefaultBanks,
+		accounts:   make(map[string]string),
+		charges:    make(map[string]*Charge),
+		recipients: make(map[string]*Recipient),
+		transfers:  make(map[string]*Transfer),
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	f.router = f.routes()
+	return f
+}
+
+// Start serves the fake on a loopback httptest server. The caller closes it.
+func (f *Fake) Start() *httptest.Server {
+	return httptest.NewServer(f)
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.router.ServeHTTP(w, r)
+}
+
+func (f *Fake) routes() http.Handler {
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(f.requireSecretKey)
+		r.Post("/transaction/initialize", f.initializeTransaction)
+		r.Get("/transaction/verify/{reference}", f.verifyTransaction)
+		r.Get("/transaction", f.listTransactions)
+		r.Get("/bank", f.listBanks)
+		r.Get("/bank/resolve", f.resolveAccount)
+		r.Post("/transferrecipient", f.createRecipient)
+		r.Post("/transfer", f.initiateTransfer)
+		r.Get("/transfer/verify/{reference}", f.verifyTransfer)
+		r.Get("/transfer/{code}", f.fetchTransfer)
+		r.Get("/transfer", f.listTransfers)
+	})
+
+	// Controls for local use: the checkout page pays the charge, and the
+	// transfer route settles a payout.
+	r.Get("/_fake/checkout/{reference}", f.checkout)
+	r.Post("/_fake/transfers/{reference}/{status}", f.settleTransfer)
+	return r
+}
+
+func (f *Fake) requireSecretKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+f.SecretKey {
+			writeError(w, http.StatusUnauthorized, "Invalid key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AddAccount makes /bank/resolve return name for the account. Unregistered
+// 10-digit account numbers resolve to a placeholder name.
+func (f *Fake) AddAccount(bankCode, accountNumber, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accounts[bankCode+"/"+accountNumber] = name
+}
+
+// Charge returns a copy of the charge with this reference.
+func (f *Fake) Charge(reference string) (Charge, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.charges[reference]
+	if !ok {
+		return Charge{}, false
+	}
+	return *c, true
+}
+
+// Transfer returns a copy of the transfer with this reference.
+func (f *Fake) Transfer(reference string) (Transfer, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.transfers[reference]
+	if !ok {
+		return Transfer{}, false
+	}
+	return *t, true
+}
+
+// CompleteCharge marks the charge paid and sends charge.success.
+func (f *Fake) CompleteCharge(reference string) error {
+	f.mu.Lock()
+	c, ok := f.charges[reference]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownCharge, reference)
+	}
+	c.Status = "success"
+	c.PaidAt = time.Now().UTC()
+	data := chargeJSON(c)
+	f.mu.Unlock()
+
+	return f.SendWebhook("charge.success", data)
+}
+
+// SettleTransfer moves a transfer to success, failed or reversed and sends
+// the matching transfer.* event.
+func (f *Fake) SettleTransfer(reference, status string) error {
+	switch status {
+	case "success", "failed", "reversed":
+	default:
+		return fmt.Errorf("fakepaystack: can't settle a transfer as %q", status)
+	}
+
+	f.mu.Lock()
+	t, ok := f.transfers[reference]
+	if !ok {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownTransfer, reference)
+	}
+	t.Status = status
+	data := transferJSON(t)
+	f.mu.Unlock()
+
+	return f.SendWebhook("transfer."+status, data)
+}
+
+// OpenDispute sends charge.dispute.create for a paid charge.
+func (f *Fake) OpenDispute(reference string) error {
+	return f.sendDispute("charge.dispute.create", reference, "")
+}
+
+// ResolveDispute sends charge.dispute.resolve with resolution
+// "merchant-accepted" (the customer is refunded) or "declined".
+func (f *Fake) ResolveDispute(reference, resolution string) error {
+	switch resolution {
+	case "merchant-accepted", "declined":
+	default:
+		return fmt.Errorf("fakepaystack: can't resolve a dispute as %q", resolution)
+	}
+	return f.sendDispute("charge.dispute.resolve", reference, resolution)
+}
+
+func (f *Fake) sendDispute(event, reference, resolution string) error {
+	f.mu.Lock()
+	c, ok := f.charges[reference]
+	if !ok || c.Status != "success" {
+		f.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownCharge, reference)
+	}
+	data := map[string]interface{}{
+		"id":          f.id(),
+		"status":      "awaiting-merchant-feedback",
+		"resolution":  resolution,
+		"currency":    c.Currency,
+		"transaction": chargeJSON(c),
+	}
+	f.mu.Unlock()
+
+	return f.SendWebhook(event, data)
+}
+
+// SendWebhook posts {"event": event, "data": data} to WebhookURL, signed the
+// way Paystack signs it: an HMAC-SHA512 of the body keyed with the secret key.
+func (f *Fake) SendWebhook(event string, data interface{}) error {
+	if f.WebhookURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"event": event, "data": data})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", f.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-paystack-signature", Sign(f.SecretKey, body))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fakepaystack: webhook failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("fakepaystack: webhook %s returned %d", event, resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the x-paystack-signature for body.
+func Sign(secretKey string, body []byte) string {
+	h := hmac.New(sha512.New, []byte(secretKey))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (f *Fake) id() int64 {
+	f.nextID++
+	return f.nextID
+}
+
+func (f *Fake) initializeTransaction(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email       string `json:"email"`
+		Amount      int64  `json:"amount"`
+		Currency    string `json:"currency"`
+		Reference   string `json:"reference"`
+		CallbackURL string `json:"callback_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.Email == "" || req.Amount <= 0 {
+		writeError(w, http.StatusBadRequest, "Email and a positive amount are required")
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = "NGN"
+	}
+	if req.Reference == "" {
+		req.Reference = randomCode(10)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, exists := f.charges[req.Reference]; exists {
+		writeError(w, http.StatusBadRequest, "Duplicate Transaction Reference")
+		return
+	}
+	f.charges[req.Reference] = &Charge{
+		ID:          f.id(),
+		Reference:   req.Reference,
+		Email:       req.Email,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Status:      "abandoned",
+		CallbackURL: req.CallbackURL,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	writeData(w, "Authorization URL created", map[string]string{
+		"authorization_url": fmt.Sprintf("http://%s/_fake/checkout/%s", r.Host, url.PathEscape(req.Reference)),
+		"access_code":       randomCode(8),
+		"reference":         req.Reference,
+	})
+}
+
+func (f *Fake) verifyTransaction(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.charges[chi.URLParam(r, "reference")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Transaction reference not found")
+		return
+	}
+	writeData(w, "Verification successful", chargeJSON(c))
+}
+
+func (f *Fake) listTransactions(w http.ResponseWriter, r *http.Request) {
+	from, to, page := listParams(r)
+
+	f.mu.Lock()
+	var matched []map[string]interface{}
+	for _, c := range f.charges {
+		if !c.CreatedAt.Before(from) && !c.CreatedAt.After(to) {
+			matched = append(matched, chargeJSON(c))
+		}
+	}
+	f.mu.Unlock()
+
+	writePage(w, matched, page)
+}
+
+func (f *Fake) listBanks(w http.ResponseWriter, r *http.Request) {
+	currency := r.URL.Query().Get("currency")
+	banks := []Bank{}
+	for _, b := range f.Banks {
+		if currency == "" || b.Currency == currency {
+			banks = append(banks, b)
+		}
+	}
+	writeData(w, "Banks retrieved", banks)
+}
+
+func (f *Fake) resolveAccount(w http.ResponseWriter, r *http.Request) {
+	accountNumber := r.URL.Query().Get("account_number")
+	bankCode := r.URL.Query().Get("bank_code")
+
+	f.mu.Lock()
+	name, ok := f.accounts[bankCode+"/"+accountNumber]
+	f.mu.Unlock()
+	if !ok && nuban.MatchString(accountNumber) {
+		name, ok = "TEST ACCOUNT "+accountNumber[6:], true
+	}
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Could not resolve account name. Check parameters or try again.")
+		return
+	}
+
+	writeData(w, "Account number resolved", map[string]string{
+		"account_number": accountNumber,
+		"account_name":   name,
+	})
+}
+
+func (f *Fake) createRecipient(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type          string `json:"type"`
+		Name          string `json:"name"`
+		AccountNumber string `json:"account_number"`
+		BankCode      string `json:"bank_code"`
+		Currency      string `json:"currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.Name == "" || req.AccountNumber == "" || req.BankCode == "" {
+		writeError(w, http.StatusBadRequest, "Name, account number and bank code are required")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	recipient := &Recipient{
+		Code:          "RCP_" + randomCode(10),
+		Name:          req.Name,
+		AccountNumber: req.AccountNumber,
+		BankCode:      req.BankCode,
+		Currency:      req.Currency,
+	}
+	f.recipients[recipient.Code] = recipient
+
+	writeData(w, "Transfer recipient created successfully", map[string]interface{}{
+		"recipient_code": recipient.Code,
+		"name":           recipient.Name,
+		"type":           req.Type,
+		"currency":       recipient.Currency,
+	})
+}
+
+func (f *Fake) initiateTransfer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Amount    int64  `json:"amount"`
+		Recipient string `json:"recipient"`
+		Reference string `json:"reference"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.Amount <= 0 {
+		writeError(w, http.StatusBadRequest, "Amount must be positive")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	recipient, ok := f.recipients[req.Recipient]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Recipient specified is invalid")
+		return
+	}
+	if req.Reference == "" {
+		req.Reference = randomCode(16)
+	}
+	if _, exists := f.transfers[req.Reference]; exists {
+		writeError(w, http.StatusBadRequest, "Transfer reference already exists")
+		return
+	}
+
+	t := &Transfer{
+		ID:        f.id(),
+		Code:      "TRF_" + randomCode(10),
+		Reference: req.Reference,
+		Recipient: recipient.Code,
+		Amount:    req.Amount,
+		Currency:  recipient.Currency,
+		Reason:    req.Reason,
+		Status:    "pending",
+		CreatedAt: time.Now().UTC(),
+	}
+	f.transfers[t.Reference] = t
+
+	writeData(w, "Transfer has been queued", transferJSON(t))
+}
+
+func (f *Fake) verifyTransfer(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.transfers[chi.URLParam(r, "reference")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Transfer not found")
+		return
+	}
+	writeData(w, "Transfer retrieved", transferJSON(t))
+}
+
+func (f *Fake) fetchTransfer(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code := chi.URLParam(r, "code")
+	for _, t := range f.transfers {
+		if t.Code == code {
+			writeData(w, "Transfer retrieved", transferJSON(t))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Transfer not found")
+}
+
+func (f *Fake) listTransfers(w http.ResponseWriter, r *http.Request) {
+	from, to, page := listParams(r)
+
+	f.mu.Lock()
+	var matched []map[string]interface{}
+	for _, t := range f.transfers {
+		if !t.CreatedAt.Before(from) && !t.CreatedAt.After(to) {
+			matched = append(matched, transferJSON(t))
+		}
+	}
+	f.mu.Unlock()
+
+	writePage(w, matched, page)
+}
+
+// checkout stands in for Paystack's payment page: opening it pays the charge
+// and redirects to the callback URL, like a customer completing payment.
+func (f *Fake) checkout(w http.ResponseWriter, r *http.Request) {
+	reference := chi.URLParam(r, "reference")
+	if err := f.CompleteCharge(reference); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	c, _ := f.Charge(reference)
+	if c.CallbackURL == "" {
+		writeData(w, "Charge paid", map[string]string{"reference": reference})
+		return
+	}
+	http.Redirect(w, r, c.CallbackURL+"?reference="+url.QueryEscape(reference), http.StatusFound)
+}
+
+func (f *Fake) settleTransfer(w http.ResponseWriter, r *http.Request) {
+	reference := chi.URLParam(r, "reference")
+	if err := f.SettleTransfer(reference, chi.URLParam(r, "status")); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrUnknownTransfer) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	t, _ := f.Transfer(reference)
+	writeData(w, "Transfer settled", transferJSON(&t))
+}
+
+func chargeJSON(c *Charge) map[string]interface{} {
+	paidAt := ""
+	if !c.PaidAt.IsZero() {
+		paidAt = c.PaidAt.Format(time.RFC3339)
+	}
+	return map[string]interface{}{
+		"id":        c.ID,
+		"status":    c.Status,
+		"reference": c.Reference,
+		"amount":    c.Amount,
+		"currency":  c.Currency,
+		"paid_at":   paidAt,
+		"customer":  map[string]string{"email": c.Email},
+	}
+}
+
+func transferJSON(t *Transfer) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            t.ID,
+		"status":        t.Status,
+		"reference":     t.Reference,
+		"transfer_code": t.Code,
+		"amount":        t.Amount,
+		"currency":      t.Currency,
+		"reason":        t.Reason,
+		"recipient":     t.Recipient,
+	}
+}
+
+const perPage = 100
+
+func listParams(r *http.Request) (from, to time.Time, page int) {
+	q := r.URL.Query()
+	from, _ = time.Parse(time.RFC3339, q.Get("from"))
+	to, err := time.Parse(time.RFC3339, q.Get("to"))
+	if err != nil {
+		to = time.Now().Add(time.Hour)
+	}
+	page, _ = strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	return from, to, page
+}
+
+// writePage sorts items by id so pages are stable between requests.
+func writePage(w http.ResponseWriter, items []map[string]interface{}, page int) {
+	sort.Slice(items, func(i, j int) bool { return items[i]["id"].(int64) < items[j]["id"].(int64) })
+
+	start := (page - 1) * perPage
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + perPage
+	if end > len(items) {
+		end = len(items)
+	}
+	pageCount := (len(items) + perPage - 1) / perPage
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  true,
+		"message": "Retrieved",
+		"data":    append([]map[string]interface{}{}, items[start:end]...),
+		"meta":    map[string]int{"total": len(items), "page": page, "pageCount": pageCount},
+	})
+}
+
+func writeData(w http.ResponseWriter, message string, data interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": true, "message": message, "data": data})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"status": false, "message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomCode(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)[:n]
+}
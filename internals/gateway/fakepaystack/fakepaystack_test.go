@@ -0,0 +1,137 @@
+package fakepaystack
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+)
+
+func TestChargeLifecycle(t *testing.T) {
+	fake := New("sk_test")
+	srv := fake.Start()
+	defer srv.Close()
+	paystack := gateway.NewPaystack("sk_test", srv.URL, "")
+
+	var received *gateway.WebhookEvent
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !paystack.VerifyWebhook(r.Header, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received, _ = paystack.ParseWebhook(body)
+	}))
+	defer receiver.Close()
+	fake.WebhookURL = receiver.URL
+
+	ctx := context.Background()
+	amount, _ := money.New(decimal.RequireFromString("1500.50"), "NGN")
+	charge, err := paystack.InitializeCharge(ctx, gateway.ChargeRequest{Email: "a@b.com", Amount: amount, Reference: "dep-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charge.Reference != "dep-1" || charge.AuthorizationURL == "" {
+		t.Fatalf("unexpected charge %+v", charge)
+	}
+
+	result, err := paystack.VerifyCharge(ctx, "dep-1")
+	if err != nil || result.Status == gateway.StatusSuccess {
+		t.Fatalf("expected an unpaid charge, got %+v, %v", result, err)
+	}
+
+	if err := fake.CompleteCharge("dep-1"); err != nil {
+		t.Fatal(err)
+	}
+	if received == nil || received.Type != gateway.EventChargeSuccess || !received.Amount.Equal(amount) {
+		t.Fatalf("expected a signed charge.success for %s, got %+v", amount.StringFixed(), received)
+	}
+
+	result, err = paystack.VerifyCharge(ctx, "dep-1")
+	if err != nil || result.Status != gateway.StatusSuccess || result.Email != "a@b.com" {
+		t.Fatalf("expected a paid charge, got %+v, %v", result, err)
+	}
+
+	if _, err := paystack.VerifyCharge(ctx, "nope"); !errors.Is(err, gateway.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTransferLifecycle(t *testing.T) {
+	fake := New("sk_test")
+	srv := fake.Start()
+	defer srv.Close()
+	paystack := gateway.NewPaystack("sk_test", srv.URL, "")
+
+	ctx := context.Background()
+	fake.AddAccount("058", "0123456789", "ADA LOVELACE")
+	name, err := paystack.ResolveAccount(ctx, "0123456789", "058")
+	if err != nil || name != "ADA LOVELACE" {
+		t.Fatalf("expected ADA LOVELACE, got %q, %v", name, err)
+	}
+	if _, err := paystack.ResolveAccount(ctx, "12", "058"); err == nil {
+		t.Fatal("expected a short account number not to resolve")
+	}
+
+	recipient, err := paystack.CreateRecipient(ctx, gateway.Recipient{Name: name, AccountNumber: "0123456789", BankCode: "058", Currency: "NGN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amount, _ := money.New(decimal.NewFromInt(200), "NGN")
+	payout := gateway.PayoutRequest{Amount: amount, RecipientCode: recipient, Reference: "wdr-0001-0002-0003"}
+	transfer, err := paystack.Payout(ctx, payout)
+	if err != nil || transfer.Status != gateway.StatusPending || transfer.Code == "" {
+		t.Fatalf("expected a pending transfer, got %+v, %v", transfer, err)
+	}
+	if _, err := paystack.Payout(ctx, payout); err == nil {
+		t.Fatal("expected a second transfer with the same reference to be refused")
+	}
+
+	if err := fake.SettleTransfer("wdr-0001-0002-0003", "failed"); err != nil {
+		t.Fatal(err)
+	}
+	verified, err := paystack.VerifyPayout(ctx, "wdr-0001-0002-0003", "")
+	if err != nil || verified.Status != gateway.StatusFailed || verified.Code != transfer.Code {
+		t.Fatalf("expected the transfer to have failed, got %+v, %v", verified, err)
+	}
+
+	// A reference Paystack doesn't know falls back to the transfer code.
+	byCode, err := paystack.VerifyPayout(ctx, "wdr-unknown", transfer.Code)
+	if err != nil || byCode.Reference != "wdr-0001-0002-0003" {
+		t.Fatalf("expected the transfer found by its code, got %+v, %v", byCode, err)
+	}
+	if _, err := paystack.VerifyPayout(ctx, "wdr-unknown", ""); !errors.Is(err, gateway.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound without a code, got %v", err)
+	}
+}
+
+func TestRequestsFollowContext(t *testing.T) {
+	fake := New("sk_test")
+	srv := fake.Start()
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	banks, err := gateway.NewPaystack("sk_test", srv.URL, "").ListBanks(ctx, "NGN")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled context to stop the request, got %v, %v", banks, err)
+	}
+}
+
+func TestRejectsWrongSecretKey(t *testing.T) {
+	fake := New("sk_test")
+	srv := fake.Start()
+	defer srv.Close()
+
+	banks, err := gateway.NewPaystack("sk_other", srv.URL, "").ListBanks(context.Background(), "NGN")
+	if err == nil {
+		t.Fatalf("expected the request to be refused, got %v", banks)
+	}
+}
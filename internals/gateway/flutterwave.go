@@ -0,0 +1,341 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+)
+
+const (
+	ProviderFlutterwave       = "FLUTTERWAVE"
+	DefaultFlutterwaveBaseURL = "https://api.flutterwave.com/v3"
+)
+
+// Flutterwave talks to the v3 API. Unlike Paystack it quotes amounts in major
+// units and signs webhooks with a static secret hash.
+type Flutterwave struct {
+	SecretKey   string
+	WebhookHash string
+	BaseURL     string
+	RedirectURL string
+	HttpClient  *http.Client
+}
+
+func NewFlutterwave(secretKey, webhookHash, baseURL, redirectURL string) *Flutterwave {
+	if baseURL == "" {
+		baseURL = DefaultFlutterwaveBaseURL
+	}
+	return &Flutterwave{
+		SecretKey:   secretKey,
+		WebhookHash: webhookHash,
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		RedirectURL: redirectURL,
+		HttpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (c *Flutterwave) Name() string { return ProviderFlutterwave }
+
+type flutterwaveResponse struct {
+	Status  string          `json:"status"` // "success" or "error"
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (c *Flutterwave) InitializeCharge(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	reference := req.Reference
+	if reference == "" {
+		reference = fmt.Sprintf("FLW-%d", time.Now().UnixNano())
+	}
+
+	payload := map[string]interface{}{
+		"tx_ref":       reference,
+		"amount":       req.Amount.StringFixed(),
+		"currency":     req.Amount.Currency(),
+		"redirect_url": c.RedirectURL,
+		"customer":     map[string]string{"email": req.Email},
+	}
+
+	var data struct {
+		Link string `json:"link"`
+	}
+	if err := c.do(ctx, "POST", "/payments", nil, payload, &data); err != nil {
+		return nil, err
+	}
+	return &Charge{AuthorizationURL: data.Link, Reference: reference}, nil
+}
+
+type flutterwaveCharge struct {
+	TxRef    string          `json:"tx_ref"`
+	Status   string          `json:"status"`
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
+	Customer struct {
+		Email string `json:"email"`
+	} `json:"customer"`
+}
+
+func (c *Flutterwave) VerifyCharge(ctx context.Context, reference string) (*ChargeResult, error) {
+	q := url.Values{}
+	q.Set("tx_ref", reference)
+
+	var data flutterwaveCharge
+	if err := c.do(ctx, "GET", "/transactions/verify_by_reference", q, nil, &data); err != nil {
+		return nil, err
+	}
+	return &ChargeResult{
+		Reference: data.TxRef,
+		Status:    flutterwaveStatus(data.Status),
+		Amount:    money.Round(data.Amount, data.Currency),
+		Email:     data.Customer.Email,
+	}, nil
+}
+
+// flutterwaveCountries maps a currency to the country Flutterwave lists banks for.
+var flutterwaveCountries = map[string]string{
+	"NGN": "NG",
+	"GHS": "GH",
+	"KES": "KE",
+	"UGX": "UG",
+	"TZS": "TZ",
+	"ZAR": "ZA",
+}
+
+func (c *Flutterwave) ListBanks(ctx context.Context, currency string) ([]Bank, error) {
+	country, ok := flutterwaveCountries[currency]
+	if !ok {
+		return nil, fmt.Errorf("flutterwave does not list banks for %s", currency)
+	}
+
+	var data []Bank
+	if err := c.do(ctx, "GET", "/banks/"+country, nil, nil, &data); err != nil {
+		return nil, err
+	}
+	for i := range data {
+		data[i].Currency = currency
+	}
+	return data, nil
+}
+
+func (c *Flutterwave) ResolveAccount(ctx context.Context, accountNumber, bankCode string) (string, error) {
+	payload := map[string]string{
+		"account_number": accountNumber,
+		"account_bank":   bankCode,
+	}
+
+	var data struct {
+		AccountName string `json:"account_name"`
+	}
+	if err := c.do(ctx, "POST", "/accounts/resolve", nil, payload, &data); err != nil {
+		return "", fmt.Errorf("could not resolve account: %w", err)
+	}
+	return data.AccountName, nil
+}
+
+// CreateRecipient registers a beneficiary; its id is what Payout pays.
+func (c *Flutterwave) CreateRecipient(ctx context.Context, recipient Recipient) (string, error) {
+	payload := map[string]string{
+		"account_number":   recipient.AccountNumber,
+		"account_bank":     recipient.BankCode,
+		"beneficiary_name": recipient.Name,
+		"currency":         recipient.Currency,
+	}
+
+	var data struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.do(ctx, "POST", "/beneficiaries", nil, payload, &data); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(data.ID, 10), nil
+}
+
+type flutterwaveTransfer struct {
+	ID        int64           `json:"id"`
+	Reference string          `json:"reference"`
+	Status    string          `json:"status"` // NEW, PENDING, SUCCESSFUL, FAILED
+	Amount    decimal.Decimal `json:"amount"`
+	Currency  string          `json:"currency"`
+}
+
+func (t flutterwaveTransfer) transfer() *Transfer {
+	return &Transfer{
+		Reference: t.Reference,
+		Code:      strconv.FormatInt(t.ID, 10),
+		Status:    flutterwaveStatus(t.Status),
+		Amount:    money.Round(t.Amount, t.Currency),
+	}
+}
+
+func (c *Flutterwave) Payout(ctx context.Context, req PayoutRequest) (*Transfer, error) {
+	beneficiary, err := strconv.ParseInt(req.RecipientCode, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flutterwave beneficiary %q", req.RecipientCode)
+	}
+
+	payload := map[string]interface{}{
+		"beneficiary": beneficiary,
+		"amount":      req.Amount.StringFixed(),
+		"currency":    req.Amount.Currency(),
+		"reference":   req.Reference,
+		"narration":   req.Reason,
+	}
+
+	var data flutterwaveTransfer
+	if err := c.do(ctx, "POST", "/transfers", nil, payload, &data); err != nil {
+		return nil, err
+	}
+	return data.transfer(), nil
+}
+
+// VerifyPayout fetches the transfer by id when we have one. Otherwise it
+// searches the transfer list for our reference, which is all we know after a
+// Payout whose response never arrived.
+func (c *Flutterwave) VerifyPayout(ctx context.Context, reference, code string) (*Transfer, error) {
+	if code != "" {
+		var data flutterwaveTransfer
+		if err := c.do(ctx, "GET", "/transfers/"+url.PathEscape(code), nil, nil, &data); err != nil {
+			return nil, err
+		}
+		return data.transfer(), nil
+	}
+
+	q := url.Values{}
+	q.Set("reference", reference)
+
+	var data []flutterwaveTransfer
+	if err := c.do(ctx, "GET", "/transfers", q, nil, &data); err != nil {
+		return nil, err
+	}
+	for _, t := range data {
+		if t.Reference == reference {
+			return t.transfer(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// VerifyWebhook compares the verif-hash header with the secret hash set on
+// the Flutterwave dashboard.
+func (c *Flutterwave) VerifyWebhook(header http.Header, body []byte) bool {
+	signature := header.Get("verif-hash")
+	if c.WebhookHash == "" || signature == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(c.WebhookHash)) == 1
+}
+
+func (c *Flutterwave) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var event struct {
+		Event string `json:"event"`
+		Data  struct {
+			ID        int64           `json:"id"`
+			TxRef     string          `json:"tx_ref"`
+			Reference string          `json:"reference"`
+			Status    string          `json:"status"`
+			Amount    decimal.Decimal `json:"amount"`
+			Currency  string          `json:"currency"`
+			Customer  struct {
+				Email string `json:"email"`
+			} `json:"customer"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook body: %w", err)
+	}
+
+	parsed := &WebhookEvent{Type: EventIgnored, Name: event.Event}
+	if event.Data.ID != 0 {
+		parsed.ID = strconv.FormatInt(event.Data.ID, 10)
+	}
+	status := flutterwaveStatus(event.Data.Status)
+	switch event.Event {
+	case "charge.completed":
+		parsed.Reference = event.Data.TxRef
+		if status == StatusSuccess {
+			parsed.Type = EventChargeSuccess
+			parsed.Amount = money.Round(event.Data.Amount, event.Data.Currency)
+			parsed.Email = event.Data.Customer.Email
+		}
+	case "transfer.completed":
+		parsed.Reference = event.Data.Reference
+		parsed.TransferCode = strconv.FormatInt(event.Data.ID, 10)
+		switch status {
+		case StatusSuccess:
+			parsed.Type = EventTransferSuccess
+		case StatusFailed:
+			parsed.Type = EventTransferFailed
+		}
+	}
+	return parsed, nil
+}
+
+func flutterwaveStatus(status string) Status {
+	switch strings.ToLower(status) {
+	case "successful", "success":
+		return StatusSuccess
+	case "failed", "cancelled":
+		return StatusFailed
+	}
+	return StatusPending
+}
+
+// do calls the API and decodes the "data" field of the envelope into out.
+func (c *Flutterwave) do(ctx context.Context, method, path string, query url.Values, payload interface{}, out interface{}) error {
+	endpoint := c.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var body *bytes.Buffer
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(data)
+	} else {
+		body = &bytes.Buffer{}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.SecretKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	var envelope flutterwaveResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("flutterwave returned %d: failed to decode response: %w", resp.StatusCode, err)
+	}
+	if envelope.Status != "success" {
+		return fmt.Errorf("flutterwave error: %s", envelope.Message)
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("failed to decode flutterwave data: %w", err)
+	}
+	return nil
+}
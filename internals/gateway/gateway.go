@@ -0,0 +1,166 @@
+// Package gateway hides the payment providers behind one interface. Each
+// adapter speaks its provider's API and translates it into the types below;
+// the rest of the app only sees PaymentGateway.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+)
+
+var (
+	// ErrNotFound is returned when the provider has no record of what was asked for.
+	ErrNotFound        = errors.New("provider has no such record")
+	ErrUnknownProvider = errors.New("unknown payment provider")
+)
+
+// Status is a provider's outcome for a charge or payout, normalised.
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusSuccess  Status = "success"
+	StatusFailed   Status = "failed"
+	StatusReversed Status = "reversed"
+)
+
+type EventType string
+
+const (
+	EventChargeSuccess    EventType = "charge.success"
+	EventTransferSuccess  EventType = "transfer.success"
+	EventTransferFailed   EventType = "transfer.failed"
+	EventTransferReversed EventType = "transfer.reversed"
+	EventDisputeOpened    EventType = "dispute.opened"
+	EventDisputeResolved  EventType = "dispute.resolved"
+	EventIgnored          EventType = "ignored"
+)
+
+type ChargeRequest struct {
+	Email  string
+	Amount money.Money
+	// Reference is optional; adapters generate one when the provider needs it.
+	Reference string
+}
+
+type Charge struct {
+	AuthorizationURL string `json:"authorization_url"`
+	Reference        string `json:"reference"`
+}
+
+type ChargeResult struct {
+	Reference string
+	Status    Status
+	Amount    money.Money
+	Email     string
+}
+
+type Bank struct {
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+	Currency string `json:"currency,omitempty"`
+}
+
+type Recipient struct {
+	Name          string
+	AccountNumber string
+	BankCode      string
+	Currency      string
+}
+
+type PayoutRequest struct {
+	Amount        money.Money
+	RecipientCode string
+	Reference     string
+	Reason        string
+}
+
+type Transfer struct {
+	Reference string
+	// Code is the provider's own id for the transfer (Paystack's transfer_code).
+	Code   string
+	Status Status
+	Amount money.Money
+}
+
+// WebhookEvent is a provider webhook reduced to what the wallet acts on.
+// Name is the provider's own event name and ID the id of the charge or
+// transfer it is about; together they identify a delivery. Amount and Email
+// are only set for charges.
+type WebhookEvent struct {
+	Type         EventType
+	Name         string
+	ID           string
+	Reference    string
+	TransferCode string
+	Amount       money.Money
+	Email        string
+	// DisputeLost is set on EventDisputeResolved when the customer won and
+	// the deposit goes back to them.
+	DisputeLost bool
+}
+
+type PaymentGateway interface {
+	// Name is stored on every transaction the adapter handles, e.g. "PAYSTACK".
+	Name() string
+
+	InitializeCharge(ctx context.Context, req ChargeRequest) (*Charge, error)
+	VerifyCharge(ctx context.Context, reference string) (*ChargeResult, error)
+
+	ListBanks(ctx context.Context, currency string) ([]Bank, error)
+	ResolveAccount(ctx context.Context, accountNumber, bankCode string) (string, error)
+
+	CreateRecipient(ctx context.Context, recipient Recipient) (string, error)
+	Payout(ctx context.Context, req PayoutRequest) (*Transfer, error)
+	// VerifyPayout looks a payout up by our reference, or by the provider's
+	// code when it has one. ErrNotFound means the payout was never created.
+	VerifyPayout(ctx context.Context, reference, code string) (*Transfer, error)
+
+	VerifyWebhook(header http.Header, body []byte) bool
+	ParseWebhook(body []byte) (*WebhookEvent, error)
+}
+
+// Registry holds the configured adapters by name.
+type Registry struct {
+	gateways map[string]PaymentGateway
+	fallback string
+}
+
+// NewRegistry registers gateways and picks fallback as the provider used when
+// a caller doesn't name one.
+func NewRegistry(fallback string, gateways ...PaymentGateway) *Registry {
+	r := &Registry{gateways: map[string]PaymentGateway{}, fallback: strings.ToUpper(fallback)}
+	for _, gw := range gateways {
+		r.gateways[gw.Name()] = gw
+	}
+	return r
+}
+
+// Get returns the adapter for name, matched case-insensitively. An empty name
+// means the default provider.
+func (r *Registry) Get(name string) (PaymentGateway, error) {
+	if name == "" {
+		name = r.fallback
+	}
+	gw, ok := r.gateways[strings.ToUpper(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	return gw, nil
+}
+
+// Names lists the registered providers.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.gateways))
+	for name := range r.gateways {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
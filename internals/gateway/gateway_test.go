@@ -0,0 +1,104 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRegistryGet(t *testing.T) {
+	paystack := NewPaystack("sk_test", "", "")
+	flutterwave := NewFlutterwave("FLWSECK_TEST", "hash", "", "")
+	registry := NewRegistry(ProviderPaystack, paystack, flutterwave)
+
+	if gw, err := registry.Get(""); err != nil || gw.Name() != ProviderPaystack {
+		t.Fatalf("expected the fallback gateway, got %v, %v", gw, err)
+	}
+	if gw, err := registry.Get("flutterwave"); err != nil || gw.Name() != ProviderFlutterwave {
+		t.Fatalf("expected flutterwave, got %v, %v", gw, err)
+	}
+	if _, err := registry.Get("stripe"); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestPaystackWebhook(t *testing.T) {
+	paystack := NewPaystack("sk_test", "", "")
+	body := []byte(`{"event":"charge.success","data":{"reference":"ref-1","amount":150050,"currency":"NGN","status":"success","customer":{"email":"a@b.com"}}}`)
+
+	mac := hmac.New(sha512.New, []byte("sk_test"))
+	mac.Write(body)
+	header := http.Header{}
+	header.Set("x-paystack-signature", hex.EncodeToString(mac.Sum(nil)))
+
+	if !paystack.VerifyWebhook(header, body) {
+		t.Fatal("expected a valid signature")
+	}
+	header.Set("x-paystack-signature", "bad")
+	if paystack.VerifyWebhook(header, body) {
+		t.Fatal("expected a bad signature to be rejected")
+	}
+
+	event, err := paystack.ParseWebhook(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != EventChargeSuccess || event.Reference != "ref-1" || event.Email != "a@b.com" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if event.Amount.StringFixed() != "1500.50" || event.Amount.Currency() != "NGN" {
+		t.Fatalf("expected NGN 1500.50, got %s %s", event.Amount.Currency(), event.Amount.StringFixed())
+	}
+}
+
+func TestFlutterwaveWebhook(t *testing.T) {
+	flutterwave := NewFlutterwave("FLWSECK_TEST", "hash", "", "")
+
+	header := http.Header{}
+	header.Set("verif-hash", "hash")
+	if !flutterwave.VerifyWebhook(header, nil) {
+		t.Fatal("expected the secret hash to be accepted")
+	}
+	header.Set("verif-hash", "other")
+	if flutterwave.VerifyWebhook(header, nil) {
+		t.Fatal("expected a wrong hash to be rejected")
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want WebhookEvent
+	}{
+		{
+			name: "Charge Completed",
+			body: `{"event":"charge.completed","data":{"id":9,"tx_ref":"ref-1","status":"successful","amount":1500.5,"currency":"NGN","customer":{"email":"a@b.com"}}}`,
+			want: WebhookEvent{Type: EventChargeSuccess, Reference: "ref-1", Email: "a@b.com"},
+		},
+		{
+			name: "Charge Failed",
+			body: `{"event":"charge.completed","data":{"id":9,"tx_ref":"ref-1","status":"failed","amount":1500.5,"currency":"NGN"}}`,
+			want: WebhookEvent{Type: EventIgnored, Reference: "ref-1"},
+		},
+		{
+			name: "Transfer Failed",
+			body: `{"event":"transfer.completed","data":{"id":42,"reference":"wdr-1","status":"FAILED","amount":100,"currency":"NGN"}}`,
+			want: WebhookEvent{Type: EventTransferFailed, Reference: "wdr-1", TransferCode: "42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := flutterwave.ParseWebhook([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Type != tt.want.Type || event.Reference != tt.want.Reference ||
+				event.TransferCode != tt.want.TransferCode || event.Email != tt.want.Email {
+				t.Fatalf("expected %+v, got %+v", tt.want, event)
+			}
+		})
+	}
+}
@@ -0,0 +1,517 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+)
+
+const (
+	ProviderPaystack        = "PAYSTACK"
+	DefaultPaystackBaseURL  = "https://api.paystack.co"
+	defaultPaystackCallback = "http://localhost:8080/webhooks/paystack"
+)
+
+type Paystack struct {
+	SecretKey   string
+	BaseURL     string
+	CallbackURL string
+	HttpClient  *http.Client
+}
+
+// NewPaystack builds the Paystack adapter. baseURL may point at a local fake;
+// it defaults to the live API when empty.
+func NewPaystack(secretKey, baseURL, callbackURL string) *Paystack {
+	if baseURL == "" {
+		baseURL = DefaultPaystackBaseURL
+	}
+	if callbackURL == "" {
+		callbackURL = defaultPaystackCallback
+	}
+	return &Paystack{
+		SecretKey:   secretKey,
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		CallbackURL: callbackURL,
+		HttpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (c *Paystack) Name() string { return ProviderPaystack }
+
+type PaystackInitResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		AuthorizationURL string `json:"authorization_url"`
+		AccessCode       string `json:"access_code"`
+		Reference        string `json:"reference"`
+	} `json:"data"`
+}
+
+func (c *Paystack) InitializeCharge(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	currency := req.Amount.Currency()
+
+	var channels []string
+	switch currency {
+	case "NGN":
+		channels = []string{"card", "bank_transfer", "ussd", "bank", "qr", "mobile_money", "apple_pay"}
+	case "GHS":
+		channels = []string{"card", "mobile_money"}
+	case "ZAR":
+		channels = []string{"card", "eft"}
+	case "USD":
+		channels = []string{"card", "apple_pay"}
+	default:
+		channels = []string{"card"}
+	}
+
+	payload := map[string]interface{}{
+		"email": req.Email,
+		// Paystack takes integer subunits (kobo, pesewas, cents).
+		"amount":       req.Amount.MinorUnits(),
+		"currency":     currency,
+		"callback_url": c.CallbackURL,
+		"channels":     channels,
+	}
+	if req.Reference != "" {
+		payload["reference"] = req.Reference
+	}
+
+	var result PaystackInitResponse
+	if err := c.post(ctx, "/transaction/initialize", payload, &result); err != nil {
+		return nil, err
+	}
+	if !result.Status {
+		return nil, fmt.Errorf("paystack error: %s", result.Message)
+	}
+
+	return &Charge{AuthorizationURL: result.Data.AuthorizationURL, Reference: result.Data.Reference}, nil
+}
+
+type VerifyResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Status    string `json:"status"`
+		Reference string `json:"reference"`
+		Amount    int64  `json:"amount"` // subunits, e.g. kobo
+		Currency  string `json:"currency"`
+		Customer  struct {
+			Email string `json:"email"`
+		} `json:"customer"`
+	} `json:"data"`
+}
+
+func (c *Paystack) VerifyTransaction(ctx context.Context, reference string) (*VerifyResponse, error) {
+	var result VerifyResponse
+	if err := c.get(ctx, "/transaction/verify/"+url.PathEscape(reference), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Paystack) VerifyCharge(ctx context.Context, reference string) (*ChargeResult, error) {
+	result, err := c.VerifyTransaction(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Status {
+		return nil, fmt.Errorf("paystack error: %s", result.Message)
+	}
+	return &ChargeResult{
+		Reference: result.Data.Reference,
+		Status:    paystackChargeStatus(result.Data.Status),
+		Amount:    money.FromMinor(result.Data.Amount, result.Data.Currency),
+		Email:     result.Data.Customer.Email,
+	}, nil
+}
+
+func (c *Paystack) ListBanks(ctx context.Context, currency string) ([]Bank, error) {
+	var result struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+		Data    []Bank `json:"data"`
+	}
+	q := url.Values{}
+	q.Set("currency", currency)
+	if err := c.get(ctx, "/bank", q, &result); err != nil {
+		return nil, err
+	}
+	if !result.Status {
+		return nil, fmt.Errorf("paystack error: %s", result.Message)
+	}
+	return result.Data, nil
+}
+
+func (c *Paystack) ResolveAccount(ctx context.Context, accountNumber, bankCode string) (string, error) {
+	var result struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+		Data    struct {
+			AccountName   string `json:"account_name"`
+			AccountNumber string `json:"account_number"`
+		} `json:"data"`
+	}
+	q := url.Values{}
+	q.Set("account_number", accountNumber)
+	q.Set("bank_code", bankCode)
+	if err := c.get(ctx, "/bank/resolve", q, &result); err != nil {
+		return "", fmt.Errorf("could not resolve account: %w", err)
+	}
+	if !result.Status {
+		return "", fmt.Errorf("could not resolve account: %s", result.Message)
+	}
+	return result.Data.AccountName, nil
+}
+
+type PaystackRecipientResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		RecipientCode string `json:"recipient_code"`
+	} `json:"data"`
+}
+
+func (c *Paystack) CreateRecipient(ctx context.Context, recipient Recipient) (string, error) {
+	var recipientType string
+	switch recipient.Currency {
+	case "NGN":
+		recipientType = "nuban" // Nigeria Bank Account
+	case "GHS":
+		recipientType = "mobile_money" // Ghana Mobile Money
+	case "ZAR":
+		recipientType = "basa" // South Africa Bank Account
+	default:
+		recipientType = "nuban" // Default fallback
+	}
+
+	payload := map[string]interface{}{
+		"type":           recipientType,
+		"name":           recipient.Name,
+		"account_number": recipient.AccountNumber,
+		"bank_code":      recipient.BankCode,
+		"currency":       recipient.Currency,
+	}
+
+	var result PaystackRecipientResponse
+	if err := c.post(ctx, "/transferrecipient", payload, &result); err != nil {
+		return "", err
+	}
+	if !result.Status {
+		return "", fmt.Errorf("paystack recipient error: %s", result.Message)
+	}
+	return result.Data.RecipientCode, nil
+}
+
+type PaystackTransferResponse struct {
+	Status  bool             `json:"status"`
+	Message string           `json:"message"`
+	Data    PaystackTransfer `json:"data"`
+}
+
+func (c *Paystack) Payout(ctx context.Context, req PayoutRequest) (*Transfer, error) {
+	payload := map[string]interface{}{
+		"source":    "balance",
+		"amount":    req.Amount.MinorUnits(),
+		"recipient": req.RecipientCode,
+		"reference": req.Reference,
+		"reason":    req.Reason,
+	}
+
+	var result PaystackTransferResponse
+	if err := c.post(ctx, "/transfer", payload, &result); err != nil {
+		return nil, err
+	}
+	if !result.Status {
+		return nil, fmt.Errorf("transfer error: %s", result.Message)
+	}
+	return result.Data.transfer(req.Amount.Currency()), nil
+}
+
+// VerifyTransfer looks a transfer up by the reference we created it with.
+func (c *Paystack) VerifyTransfer(ctx context.Context, reference string) (*PaystackTransferResponse, error) {
+	return c.getTransfer(ctx, "/transfer/verify/"+url.PathEscape(reference))
+}
+
+// FetchTransfer looks a transfer up by the transfer code Paystack gave it.
+func (c *Paystack) FetchTransfer(ctx context.Context, code string) (*PaystackTransferResponse, error) {
+	return c.getTransfer(ctx, "/transfer/"+url.PathEscape(code))
+}
+
+func (c *Paystack) getTransfer(ctx context.Context, path string) (*PaystackTransferResponse, error) {
+	var result PaystackTransferResponse
+	if err := c.get(ctx, path, nil, &result); err != nil {
+		return nil, err
+	}
+	if !result.Status {
+		return nil, fmt.Errorf("paystack error: %s", result.Message)
+	}
+	return &result, nil
+}
+
+// VerifyPayout looks the payout up by reference and, when Paystack doesn't
+// know the reference, by its transfer code.
+func (c *Paystack) VerifyPayout(ctx context.Context, reference, code string) (*Transfer, error) {
+	result, err := c.VerifyTransfer(ctx, reference)
+	if errors.Is(err, ErrNotFound) && code != "" {
+		result, err = c.FetchTransfer(ctx, code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.Data.transfer(""), nil
+}
+
+// VerifyWebhook checks the x-paystack-signature header, an HMAC-SHA512 of the
+// body keyed with the secret key.
+func (c *Paystack) VerifyWebhook(header http.Header, body []byte) bool {
+	signature := header.Get("x-paystack-signature")
+	if c.SecretKey == "" || signature == "" {
+		return false
+	}
+
+	h := hmac.New(sha512.New, []byte(c.SecretKey))
+	h.Write(body)
+	expected := hex.EncodeToString(h.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func (c *Paystack) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var event struct {
+		Event string `json:"event"`
+		Data  struct {
+			ID           int64  `json:"id"`
+			Reference    string `json:"reference"`
+			TransferCode string `json:"transfer_code"`
+			Amount       int64  `json:"amount"`
+			Currency     string `json:"currency"`
+			Status       string `json:"status"`
+			Resolution   string `json:"resolution"`
+			Customer     struct {
+				Email string `json:"email"`
+			} `json:"customer"`
+			// Disputes carry the charge they are about.
+			Transaction struct {
+				Reference string `json:"reference"`
+			} `json:"transaction"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook body: %w", err)
+	}
+
+	parsed := &WebhookEvent{
+		Type:         EventIgnored,
+		Name:         event.Event,
+		Reference:    event.Data.Reference,
+		TransferCode: event.Data.TransferCode,
+	}
+	if event.Data.ID != 0 {
+		parsed.ID = strconv.FormatInt(event.Data.ID, 10)
+	}
+	switch event.Event {
+	case "charge.success":
+		if event.Data.Status == "success" {
+			parsed.Type = EventChargeSuccess
+			parsed.Amount = money.FromMinor(event.Data.Amount, event.Data.Currency)
+			parsed.Email = event.Data.Customer.Email
+		}
+	case "transfer.success":
+		parsed.Type = EventTransferSuccess
+	case "transfer.failed":
+		parsed.Type = EventTransferFailed
+	case "transfer.reversed":
+		parsed.Type = EventTransferReversed
+	case "charge.dispute.create":
+		parsed.Type = EventDisputeOpened
+		parsed.Reference = event.Data.Transaction.Reference
+	case "charge.dispute.resolve":
+		// The merchant either accepts the claim and refunds, or declines it.
+		parsed.Type = EventDisputeResolved
+		parsed.Reference = event.Data.Transaction.Reference
+		parsed.DisputeLost = event.Data.Resolution != "declined"
+	}
+	return parsed, nil
+}
+
+func paystackChargeStatus(status string) Status {
+	switch status {
+	case "success":
+		return StatusSuccess
+	case "failed", "abandoned", "reversed":
+		return StatusFailed
+	}
+	return StatusPending
+}
+
+// ListMeta is Paystack's pagination block on list endpoints.
+type ListMeta struct {
+	Total     int `json:"total"`
+	Page      int `json:"page"`
+	PageCount int `json:"pageCount"`
+}
+
+type PaystackTransaction struct {
+	ID        int64  `json:"id"`
+	Status    string `json:"status"`
+	Reference string `json:"reference"`
+	Amount    int64  `json:"amount"` // subunits
+	Currency  string `json:"currency"`
+	PaidAt    string `json:"paid_at"`
+	Customer  struct {
+		Email string `json:"email"`
+	} `json:"customer"`
+}
+
+type ListTransactionsResponse struct {
+	Status  bool                  `json:"status"`
+	Message string                `json:"message"`
+	Data    []PaystackTransaction `json:"data"`
+	Meta    ListMeta              `json:"meta"`
+}
+
+type PaystackTransfer struct {
+	ID           int64  `json:"id"`
+	Status       string `json:"status"` // pending, otp, success, failed, reversed, ...
+	Reference    string `json:"reference"`
+	TransferCode string `json:"transfer_code"`
+	Amount       int64  `json:"amount"` // subunits
+	Currency     string `json:"currency"`
+}
+
+func (t PaystackTransfer) transfer(currency string) *Transfer {
+	if t.Currency != "" {
+		currency = t.Currency
+	}
+	status := StatusPending
+	switch strings.ToLower(t.Status) {
+	case "success":
+		status = StatusSuccess
+	case "failed":
+		status = StatusFailed
+	case "reversed":
+		status = StatusReversed
+	}
+	return &Transfer{
+		Reference: t.Reference,
+		Code:      t.TransferCode,
+		Status:    status,
+		Amount:    money.FromMinor(t.Amount, currency),
+	}
+}
+
+type ListTransfersResponse struct {
+	Status  bool               `json:"status"`
+	Message string             `json:"message"`
+	Data    []PaystackTransfer `json:"data"`
+	Meta    ListMeta           `json:"meta"`
+}
+
+// ListTransactions returns one page of charges created between from and to.
+func (c *Paystack) ListTransactions(ctx context.Context, from, to time.Time, page int) (*ListTransactionsResponse, error) {
+	var result ListTransactionsResponse
+	if err := c.get(ctx, "/transaction", listQuery(from, to, page), &result); err != nil {
+		return nil, err
+	}
+	if !result.Status {
+		return nil, fmt.Errorf("paystack error: %s", result.Message)
+	}
+	return &result, nil
+}
+
+// ListTransfers returns one page of payouts created between from and to.
+func (c *Paystack) ListTransfers(ctx context.Context, from, to time.Time, page int) (*ListTransfersResponse, error) {
+	var result ListTransfersResponse
+	if err := c.get(ctx, "/transfer", listQuery(from, to, page), &result); err != nil {
+		return nil, err
+	}
+	if !result.Status {
+		return nil, fmt.Errorf("paystack error: %s", result.Message)
+	}
+	return &result, nil
+}
+
+func listQuery(from, to time.Time, page int) url.Values {
+	q := url.Values{}
+	q.Set("from", from.UTC().Format(time.RFC3339))
+	q.Set("to", to.UTC().Format(time.RFC3339))
+	q.Set("perPage", "100")
+	q.Set("page", strconv.Itoa(page))
+	return q
+}
+
+func (c *Paystack) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	endpoint := c.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.SecretKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("paystack returned non-200 status: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// post sends payload as JSON. Paystack explains most 4xx responses in the
+// body, so out is decoded whatever the status and callers check its Status.
+func (c *Paystack) post(ctx context.Context, path string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+path, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.SecretKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("paystack returned %d: failed to decode response: %w", resp.StatusCode, err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+)
+
+// AdminOnly lets a request through only when it carries the configured
+// X-Admin-Key. With no key configured every admin route is closed.
+func (m *AuthMiddleware) AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := m.Config.AdminAPIKey
+		if expected == "" {
+			utils.ErrorJSON(w, r, http.StatusForbidden, errors.New("admin api is disabled"))
+			return
+		}
+
+		key := r.Header.Get("X-Admin-Key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(expected)) != 1 {
+			utils.ErrorJSON(w, r, http.StatusUnauthorized, errors.New("invalid admin key"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
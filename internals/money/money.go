@@ -0,0 +1,169 @@
+// Package money holds exact, currency-aware amounts. Every balance and
+// transaction amount in the system is a Money rounded to the minor unit of
+// its currency; float64 is never used for value.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrTooPrecise       = errors.New("amount has more decimal places than the currency allows")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// defaultPrecision is the number of minor-unit digits for any currency not
+// listed in precisions (NGN, USD, GHS, ZAR, ...).
+const defaultPrecision int32 = 2
+
+var precisions = map[string]int32{
+	"JPY": 0,
+	"KRW": 0,
+	"UGX": 0,
+	"XAF": 0,
+	"XOF": 0,
+	"BHD": 3,
+	"JOD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+// Precision returns how many decimal places the currency's minor unit has.
+func Precision(currency string) int32 {
+	if p, ok := precisions[strings.ToUpper(currency)]; ok {
+		return p
+	}
+	return defaultPrecision
+}
+
+type Money struct {
+	amount   decimal.Decimal
+	currency string
+}
+
+// New builds a Money from a user-supplied amount. Amounts finer than the
+// currency's minor unit are rejected rather than silently rounded.
+func New(amount decimal.Decimal, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return Money{}, fmt.Errorf("%w: currency is required", ErrInvalidAmount)
+	}
+	p := Precision(currency)
+	if !amount.Equal(amount.Truncate(p)) {
+		return Money{}, fmt.Errorf("%w: %s allows %d", ErrTooPrecise, currency, p)
+	}
+	return Money{amount: amount.Truncate(p), currency: currency}, nil
+}
+
+// Round builds a Money from a derived amount (an FX conversion, an interest
+// accrual), rounding half-to-even to the currency's minor unit so repeated
+// conversions don't drift in one direction.
+func Round(amount decimal.Decimal, currency string) Money {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	return Money{amount: amount.RoundBank(Precision(currency)), currency: currency}
+}
+
+// FromMinor builds a Money from an integer count of minor units, as sent by
+// payment providers (kobo, cents).
+func FromMinor(minor int64, currency string) Money {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	return Money{amount: decimal.New(minor, -Precision(currency)), currency: currency}
+}
+
+// Zero returns an empty amount in the given currency.
+func Zero(currency string) Money {
+	return FromMinor(0, currency)
+}
+
+func (m Money) Amount() decimal.Decimal { return m.amount }
+func (m Money) Currency() string        { return m.currency }
+
+// MinorUnits returns the amount as an integer count of minor units.
+func (m Money) MinorUnits() int64 {
+	return m.amount.Shift(Precision(m.currency)).IntPart()
+}
+
+func (m Money) IsPositive() bool { return m.amount.IsPositive() }
+func (m Money) IsNegative() bool { return m.amount.IsNegative() }
+func (m Money) IsZero() bool     { return m.amount.IsZero() }
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.currency != o.currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	return Money{amount: m.amount.Add(o.amount), currency: m.currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if m.currency != o.currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	return Money{amount: m.amount.Sub(o.amount), currency: m.currency}, nil
+}
+
+func (m Money) Neg() Money {
+	return Money{amount: m.amount.Neg(), currency: m.currency}
+}
+
+// LessThan reports whether m is smaller than o. Amounts in different
+// currencies are never comparable.
+func (m Money) LessThan(o Money) (bool, error) {
+	if m.currency != o.currency {
+		return false, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	return m.amount.LessThan(o.amount), nil
+}
+
+// Equal reports whether both amount and currency match.
+func (m Money) Equal(o Money) bool {
+	return m.currency == o.currency && m.amount.Equal(o.amount)
+}
+
+// Convert applies an exchange rate and rounds to the target currency.
+func (m Money) Convert(rate decimal.Decimal, to string) Money {
+	return Round(m.amount.Mul(rate), to)
+}
+
+// StringFixed renders the amount with exactly the currency's minor digits, e.g. "1500.00".
+func (m Money) StringFixed() string {
+	return m.amount.StringFixed(Precision(m.currency))
+}
+
+func (m Money) String() string {
+	return m.StringFixed() + " " + m.currency
+}
+
+type jsonMoney struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a fixed-point string so clients never
+// round-trip it through a binary float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.StringFixed(), Currency: m.currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw jsonMoney
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	amount, err := decimal.NewFromString(raw.Amount)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, raw.Amount)
+	}
+	parsed, err := New(amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
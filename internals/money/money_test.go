@@ -0,0 +1,82 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestNewRejectsExcessPrecision(t *testing.T) {
+	if _, err := New(decimal.RequireFromString("10.005"), "NGN"); !errors.Is(err, ErrTooPrecise) {
+		t.Fatalf("expected ErrTooPrecise for NGN, got: %v", err)
+	}
+	if _, err := New(decimal.RequireFromString("10.5"), "JPY"); !errors.Is(err, ErrTooPrecise) {
+		t.Fatalf("expected ErrTooPrecise for JPY, got: %v", err)
+	}
+	if _, err := New(decimal.RequireFromString("10.005"), "KWD"); err != nil {
+		t.Fatalf("expected KWD to allow 3 decimals, got: %v", err)
+	}
+}
+
+func TestMinorUnits(t *testing.T) {
+	// 0.1 + 0.2 in float64 is 0.30000000000000004; in kobo it must be exactly 30.
+	a, _ := New(decimal.RequireFromString("0.1"), "NGN")
+	b, _ := New(decimal.RequireFromString("0.2"), "NGN")
+	sum, err := a.Add(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sum.MinorUnits(); got != 30 {
+		t.Fatalf("expected 30 kobo, got %d", got)
+	}
+
+	if got := FromMinor(123456, "NGN").StringFixed(); got != "1234.56" {
+		t.Fatalf("expected 1234.56, got %s", got)
+	}
+	if got := FromMinor(500, "JPY").MinorUnits(); got != 500 {
+		t.Fatalf("expected 500 yen, got %d", got)
+	}
+}
+
+func TestConvertRoundsHalfEven(t *testing.T) {
+	m, _ := New(decimal.RequireFromString("1"), "USD")
+
+	if got := m.Convert(decimal.RequireFromString("0.125"), "USD").StringFixed(); got != "0.12" {
+		t.Fatalf("expected 0.12, got %s", got)
+	}
+	if got := m.Convert(decimal.RequireFromString("0.135"), "USD").StringFixed(); got != "0.14" {
+		t.Fatalf("expected 0.14, got %s", got)
+	}
+}
+
+func TestCurrencyMismatch(t *testing.T) {
+	a := FromMinor(100, "NGN")
+	b := FromMinor(100, "USD")
+	if _, err := a.Add(b); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("expected ErrCurrencyMismatch, got: %v", err)
+	}
+	if _, err := a.LessThan(b); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("expected ErrCurrencyMismatch, got: %v", err)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	m := FromMinor(150000, "NGN")
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"amount":"1500.00","currency":"NGN"}` {
+		t.Fatalf("unexpected encoding: %s", data)
+	}
+
+	var back Money
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !back.Equal(m) {
+		t.Fatalf("expected %s, got %s", m, back)
+	}
+}
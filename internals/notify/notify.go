@@ -0,0 +1,146 @@
+// Package notify renders notification templates and delivers them over
+// email, SMS and push.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Channels a notification can go out on. The inbox is the in-app
+// notification list.
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelPush  = "push"
+	ChannelInbox = "inbox"
+)
+
+var Channels = []string{ChannelEmail, ChannelSMS, ChannelPush, ChannelInbox}
+
+// Events users are notified about. Each has a template per locale in
+// templates/<locale>/<event>.tmpl.
+const (
+	EventDepositReceived     = "deposit.received"
+	EventTransferReceived    = "transfer.received"
+	EventWithdrawalSucceeded = "withdrawal.succeeded"
+	EventWithdrawalFailed    = "withdrawal.failed"
+	EventPinChanged          = "pin.changed"
+)
+
+var Events = []string{EventDepositReceived, EventTransferReceived, EventWithdrawalSucceeded, EventWithdrawalFailed, EventPinChanged}
+
+const DefaultLocale = "en"
+
+var ErrNoTemplate = errors.New("no template for this event and channel")
+
+// Message is a rendered notification ready to send. To is an email address,
+// phone number, push token or user id depending on the channel.
+type Message struct {
+	Channel string `json:"channel"`
+	Event   string `json:"event"`
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+
+	// Data is what the templates were filled in with, for senders that keep
+	// more than the text. Key identifies the message for senders that can
+	// ignore a repeat.
+	Data map[string]string `json:"-"`
+	Key  string            `json:"-"`
+}
+
+// Sender delivers messages on one channel. SMTPSender covers email; SMS and
+// push providers plug in by implementing Sender, and LogSender stands in for
+// any of them locally.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+//go:embed templates
+var builtin embed.FS
+
+// Templates holds one template set per locale and event. A set defines
+// "<channel>.body" for each channel it supports, plus "<channel>.subject"
+// where the channel has one.
+type Templates struct {
+	sets map[string]map[string]*template.Template // locale -> event -> set
+}
+
+// DefaultTemplates are the templates shipped with the app.
+func DefaultTemplates() (*Templates, error) {
+	sub, err := fs.Sub(builtin, "templates")
+	if err != nil {
+		return nil, err
+	}
+	return LoadTemplates(sub)
+}
+
+// LoadTemplates reads <locale>/<event>.tmpl files from fsys.
+func LoadTemplates(fsys fs.FS) (*Templates, error) {
+	t := &Templates{sets: make(map[string]map[string]*template.Template)}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".tmpl" {
+			return err
+		}
+		locale, file := path.Split(p)
+		locale = strings.Trim(locale, "/")
+		if locale == "" || strings.Contains(locale, "/") {
+			return fmt.Errorf("template %s: want <locale>/<event>.tmpl", p)
+		}
+
+		set, err := template.New(file).Option("missingkey=zero").ParseFS(fsys, p)
+		if err != nil {
+			return err
+		}
+		if t.sets[locale] == nil {
+			t.sets[locale] = make(map[string]*template.Template)
+		}
+		t.sets[locale][strings.TrimSuffix(file, ".tmpl")] = set
+		return nil
+	})
+	return t, err
+}
+
+// Supports reports whether event has a template for channel in the default locale.
+func (t *Templates) Supports(event, channel string) bool {
+	set := t.sets[DefaultLocale][event]
+	return set != nil && set.Lookup(channel+".body") != nil
+}
+
+// Render fills in event's template for channel, in locale when there is one
+// and the default locale otherwise.
+func (t *Templates) Render(locale, event, channel string, data map[string]string) (subject, body string, err error) {
+	set := t.sets[locale][event]
+	if set == nil || set.Lookup(channel+".body") == nil {
+		set = t.sets[DefaultLocale][event]
+	}
+	if set == nil || set.Lookup(channel+".body") == nil {
+		return "", "", fmt.Errorf("%w: %s/%s", ErrNoTemplate, event, channel)
+	}
+
+	if body, err = execute(set, channel+".body", data); err != nil {
+		return "", "", err
+	}
+	if set.Lookup(channel+".subject") != nil {
+		if subject, err = execute(set, channel+".subject", data); err != nil {
+			return "", "", err
+		}
+	}
+	return subject, body, nil
+}
+
+func execute(set *template.Template, name string, data map[string]string) (string, error) {
+	var buf bytes.Buffer
+	if err := set.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDefaultTemplates(t *testing.T) {
+	templates, err := DefaultTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]string{"name": "Ada", "amount": "5000.00", "currency": "NGN", "reference": "ref-1", "sender": "Bola"}
+	for _, event := range Events {
+		for _, channel := range Channels {
+			if event == EventWithdrawalSucceeded && channel == ChannelSMS {
+				if templates.Supports(event, channel) {
+					t.Errorf("didn't expect an sms for %s", event)
+				}
+				continue
+			}
+			if !templates.Supports(event, channel) {
+				t.Errorf("expected a %s template for %s", channel, event)
+				continue
+			}
+			_, body, err := templates.Render("en", event, channel, data)
+			if err != nil || body == "" || strings.Contains(body, "<no value>") {
+				t.Errorf("%s/%s rendered %q, %v", event, channel, body, err)
+			}
+		}
+	}
+
+	subject, _, _ := templates.Render("en", EventTransferReceived, ChannelEmail, data)
+	if subject != "You received NGN 5000.00 from Bola" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+}
+
+func TestRenderLocaleFallback(t *testing.T) {
+	templates, err := LoadTemplates(fstest.MapFS{
+		"en/pin.changed.tmpl": {Data: []byte(`{{define "sms.body"}}PIN changed, {{.name}}{{end}}{{define "push.body"}}PIN changed{{end}}`)},
+		"fr/pin.changed.tmpl": {Data: []byte(`{{define "sms.body"}}PIN modifié, {{.name}}{{end}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct{ locale, channel, want string }{
+		{"fr", ChannelSMS, "PIN modifié, Ada"},
+		{"fr", ChannelPush, "PIN changed"}, // fr has no push template
+		{"de", ChannelSMS, "PIN changed, Ada"},
+	}
+	for _, tc := range cases {
+		_, body, err := templates.Render(tc.locale, EventPinChanged, tc.channel, map[string]string{"name": "Ada"})
+		if err != nil || body != tc.want {
+			t.Errorf("%s/%s: got %q, %v", tc.locale, tc.channel, body, err)
+		}
+	}
+
+	if _, _, err := templates.Render("en", EventPinChanged, ChannelEmail, nil); !errors.Is(err, ErrNoTemplate) {
+		t.Errorf("expected ErrNoTemplate, got %v", err)
+	}
+}
+
+func TestLogSender(t *testing.T) {
+	var buf bytes.Buffer
+	sender := NewLogSender(&buf)
+
+	if err := sender.Send(context.Background(), Message{Channel: ChannelSMS, To: "+2348000000000", Body: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got Message
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || got.To != "+2348000000000" || got.Body != "hello" {
+		t.Fatalf("unexpected log line %q, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,148 @@
+// Package pdf writes plain-text PDF documents: lines of Courier, optionally
+// bold, flowed onto as many pages as they need. Monospaced text keeps tables
+// aligned without any layout code.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Page sizes in points.
+var (
+	A4Portrait  = Size{Width: 595, Height: 842}
+	A4Landscape = Size{Width: 842, Height: 595}
+)
+
+type Size struct {
+	Width, Height float64
+}
+
+const margin = 36
+
+type line struct {
+	text string
+	bold bool
+}
+
+// Document is a PDF being written. The zero value is not usable; call New.
+type Document struct {
+	size     Size
+	fontSize float64
+	lines    []line
+}
+
+// New starts a document of the given page size with text set at fontSize.
+func New(size Size, fontSize float64) *Document {
+	return &Document{size: size, fontSize: fontSize}
+}
+
+// Line adds a line of text. Characters outside Latin-1 are replaced with "?".
+func (d *Document) Line(text string) { d.lines = append(d.lines, line{text: text}) }
+
+// Bold adds a line of bold text.
+func (d *Document) Bold(text string) { d.lines = append(d.lines, line{text: text, bold: true}) }
+
+// Blank adds an empty line.
+func (d *Document) Blank() { d.lines = append(d.lines, line{}) }
+
+// Columns is how many characters fit across a page.
+func (d *Document) Columns() int {
+	// Courier glyphs are 600/1000 of the font size wide.
+	return int((d.size.Width - 2*margin) / (d.fontSize * 0.6))
+}
+
+func (d *Document) linesPerPage() int {
+	return int((d.size.Height - 2*margin) / (d.fontSize * 1.25))
+}
+
+// Bytes renders the document.
+func (d *Document) Bytes() []byte {
+	perPage := d.linesPerPage()
+	var pages [][]line
+	for start := 0; start < len(d.lines) || start == 0; start += perPage {
+		end := min(start+perPage, len(d.lines))
+		pages = append(pages, d.lines[start:end])
+	}
+
+	// Objects 1 and 2 are the catalog and page tree, 3 and 4 the fonts, then
+	// a page and its content stream for each page.
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, page := range pages {
+		content := d.content(page)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				d.size.Width, d.size.Height, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func (d *Document) content(lines []line) string {
+	var b strings.Builder
+	leading := d.fontSize * 1.25
+	fmt.Fprintf(&b, "BT\n/F1 %g Tf\n%g TL\n%g %g Td\n", d.fontSize, leading, float64(margin), d.size.Height-margin-d.fontSize)
+	bold := false
+	for _, l := range lines {
+		if l.bold != bold {
+			font := "/F1"
+			if l.bold {
+				font = "/F2"
+			}
+			fmt.Fprintf(&b, "%s %g Tf\n", font, d.fontSize)
+			bold = l.bold
+		}
+		fmt.Fprintf(&b, "(%s) Tj T*\n", escape(l.text))
+	}
+	b.WriteString("ET")
+	return b.String()
+}
+
+// escape makes s safe inside a PDF string literal in WinAnsi encoding.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20:
+		case r < 0x80:
+			b.WriteRune(r)
+		case r <= 0xff:
+			// Latin-1 matches WinAnsi here; write the byte, not its UTF-8 form.
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestDocumentPagesAndXref(t *testing.T) {
+	doc := New(A4Portrait, 10)
+	perPage := doc.linesPerPage()
+	doc.Bold("Title (draft)")
+	for i := 0; i < perPage; i++ {
+		doc.Line(fmt.Sprintf("line %d café", i))
+	}
+	out := doc.Bytes()
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("missing header or trailer")
+	}
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Fatal("expected the lines to spill onto a second page")
+	}
+	if !bytes.Contains(out, []byte(`(Title \(draft\)) Tj`)) || !bytes.Contains(out, []byte(`caf\351`)) {
+		t.Fatal("expected escaped parentheses and Latin-1 text")
+	}
+
+	// Every xref entry must point at its object.
+	start, err := strconv.Atoi(string(regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)[1]))
+	if err != nil || !bytes.HasPrefix(out[start:], []byte("xref\n")) {
+		t.Fatalf("startxref doesn't point at the xref table")
+	}
+	for i, m := range regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(out, -1) {
+		off, _ := strconv.Atoi(string(m[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(out[off:], []byte(want)) {
+			t.Fatalf("xref entry %d points at %q", i+1, out[off:off+10])
+		}
+	}
+}
+
+func TestEmptyDocumentHasAPage(t *testing.T) {
+	if out := New(A4Landscape, 8).Bytes(); !bytes.Contains(out, []byte("/Count 1")) {
+		t.Fatal("expected one blank page")
+	}
+}
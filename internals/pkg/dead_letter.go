@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+)
+
+func deadLetterKey(queueName string) string {
+	return queueName + ":dead_letter"
+}
+
+// streamKey is where a queue's jobs live. The bare queue name was a list
+// before the queue moved to streams.
+func streamKey(queueName string) string {
+	return queueName + ":stream"
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// deadLetterID is the job's id, or for jobs queued before ids existed a hash
+// of the raw entry, which is just as stable while it sits in the list.
+func deadLetterID(j job, raw string) string {
+	if j.ID != "" {
+		return j.ID
+	}
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:8])
+}
+
+// requeueScript moves one exact entry from the dead-letter list back onto the
+// queue's stream, and does nothing if another caller got to it first.
+var requeueScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 1 then
+	redis.call('XADD', KEYS[2], '*', ARGV[3], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// migrateScript moves one job from a pre-streams list onto the stream.
+var migrateScript = redis.NewScript(`
+local raw = redis.call('LPOP', KEYS[1])
+if not raw then
+	return 0
+end
+redis.call('XADD', KEYS[2], '*', ARGV[1], raw)
+return 1
+`)
+
+func (q *RedisQueue) DeadLetterDepth(ctx context.Context, queueName string) (int64, error) {
+	return q.client.LLen(ctx, deadLetterKey(queueName)).Result()
+}
+
+func (q *RedisQueue) ListDeadLetters(ctx context.Context, queueName string, offset, limit int) ([]service.DeadLetter, error) {
+	raws, err := q.client.LRange(ctx, deadLetterKey(queueName), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]service.DeadLetter, 0, len(raws))
+	for _, raw := range raws {
+		var j job
+		if err := json.Unmarshal([]byte(raw), &j); err != nil {
+			letters = append(letters, service.DeadLetter{
+				ID:        deadLetterID(j, raw),
+				Queue:     queueName,
+				Payload:   rawPayload([]byte(raw)),
+				LastError: "corrupt entry: " + err.Error(),
+			})
+			continue
+		}
+
+		letters = append(letters, service.DeadLetter{
+			ID:        deadLetterID(j, raw),
+			Queue:     queueName,
+			Payload:   rawPayload(j.Payload),
+			Retries:   j.Retries,
+			LastError: j.LastError,
+			FailedAt:  j.FailedAt,
+		})
+	}
+	return letters, nil
+}
+
+// RequeueDeadLetters puts the selected jobs (all of them when ids is empty)
+// back on their queue with a fresh retry budget.
+func (q *RedisQueue) RequeueDeadLetters(ctx context.Context, queueName string, ids []string) (int, error) {
+	return q.eachDeadLetter(ctx, queueName, ids, func(j job, raw string) (bool, error) {
+		if j.Payload == nil {
+			return false, nil // corrupt; only purging gets rid of it
+		}
+		j.Retries, j.LastError, j.FailedAt = 0, "", nil
+		if j.ID == "" {
+			j.ID = newJobID()
+		}
+		data, err := json.Marshal(j)
+		if err != nil {
+			return false, err
+		}
+		moved, err := requeueScript.Run(ctx, q.client, []string{deadLetterKey(queueName), streamKey(queueName)}, raw, data, jobField).Int()
+		return moved == 1, err
+	})
+}
+
+// PurgeDeadLetters deletes the selected jobs, or all of them when ids is empty.
+func (q *RedisQueue) PurgeDeadLetters(ctx context.Context, queueName string, ids []string) (int, error) {
+	return q.eachDeadLetter(ctx, queueName, ids, func(j job, raw string) (bool, error) {
+		removed, err := q.client.LRem(ctx, deadLetterKey(queueName), 1, raw).Result()
+		return removed == 1, err
+	})
+}
+
+func (q *RedisQueue) eachDeadLetter(ctx context.Context, queueName string, ids []string, fn func(j job, raw string) (bool, error)) (int, error) {
+	raws, err := q.client.LRange(ctx, deadLetterKey(queueName), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	done := 0
+	for _, raw := range raws {
+		var j job
+		_ = json.Unmarshal([]byte(raw), &j)
+		if len(ids) > 0 && !wanted[deadLetterID(j, raw)] {
+			continue
+		}
+		ok, err := fn(j, raw)
+		if err != nil {
+			return done, err
+		}
+		if ok {
+			done++
+		}
+	}
+	return done, nil
+}
+
+// rawPayload returns JSON payloads as they are and anything else as a string,
+// so listings show the job rather than base64.
+func rawPayload(payload []byte) json.RawMessage {
+	if json.Valid(payload) {
+		return payload
+	}
+	quoted, _ := json.Marshal(string(payload))
+	return quoted
+}
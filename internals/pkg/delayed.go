@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	promoteInterval = time.Second
+	promoteBatch    = 100
+)
+
+// delayedKey is a sorted set of jobs scored by the unix milliseconds at
+// which they become due.
+func delayedKey(queueName string) string {
+	return queueName + ":delayed"
+}
+
+// promoteScript moves due jobs from the delayed set onto the stream. Running
+// it from several workers at once is safe since each job is removed and added
+// in one step.
+var promoteScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, raw in ipairs(due) do
+	redis.call('ZREM', KEYS[1], raw)
+	redis.call('XADD', KEYS[2], '*', ARGV[3], raw)
+end
+return #due
+`)
+
+// EnqueueAt queues a job that workers will only see once at has passed.
+func (q *RedisQueue) EnqueueAt(ctx context.Context, jobType string, payload []byte, at time.Time) error {
+	return q.schedule(ctx, q.client, jobType, job{ID: newJobID(), Type: jobType, Payload: payload}, at)
+}
+
+func (q *RedisQueue) schedule(ctx context.Context, c redis.Cmdable, queueName string, j job, at time.Time) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return c.ZAdd(ctx, delayedKey(queueName), redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: data,
+	}).Err()
+}
+
+func (q *RedisQueue) DelayedDepth(ctx context.Context, queueName string) (int64, error) {
+	return q.client.ZCard(ctx, delayedKey(queueName)).Result()
+}
+
+// promote moves due jobs onto the queue every second until ctx is cancelled.
+func (q *RedisQueue) promote(ctx context.Context, queueName string) {
+	ticker := time.NewTicker(promoteInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			moved, err := promoteScript.Run(ctx, q.client, []string{delayedKey(queueName), streamKey(queueName)}, now, promoteBatch, jobField).Int()
+			if err != nil {
+				if ctx.Err() == nil {
+					q.logger.Error("failed to promote delayed jobs", "queue", queueName, "error", err)
+				}
+				break
+			}
+			if moved < promoteBatch {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package pkg
+
+import (
+	"context"
+)
+
+func (q *RedisQueue) Publish(ctx context.Context, channel string, payload []byte) error {
+	return q.client.Publish(ctx, channel, payload).Err()
+}
+
+// Subscribe holds one Redis connection for the subscription, however many
+// streams it feeds. go-redis resubscribes by itself after a dropped
+// connection; messages published meanwhile are lost.
+func (q *RedisQueue) Subscribe(ctx context.Context, channel string, fn func(payload []byte)) {
+	sub := q.client.Subscribe(ctx, channel)
+	defer sub.Close()
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			fn([]byte(msg.Payload))
+		}
+	}
+}
@@ -3,120 +3,369 @@ package pkg
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/theabdullahishola/mzl-payment-app/internals/service"
 )
 
-const maxRetries = 3
+const (
+	consumerGroup            = "workers"
+	jobField                 = "job"
+	readBlock                = 2 * time.Second // also bounds how long shutdown waits on an idle worker
+	defaultVisibilityTimeout = 2 * time.Minute
+)
 
 type job struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type,omitempty"` // empty for jobs queued before types; the queue name stands in
 	Payload []byte `json:"payload"`
 	Retries int    `json:"retries"`
+
+	// Set when the job fails, so dead letters say why they died.
+	LastError string     `json:"last_error,omitempty"`
+	FailedAt  *time.Time `json:"failed_at,omitempty"`
 }
 
+// RedisQueue keeps each job type in its own stream, so types are retried,
+// scaled and dead-lettered independently.
 type RedisQueue struct {
-	client         *redis.Client
-	paymentService service.PaymentService
-	logger         *slog.Logger
+	client *redis.Client
+	jobs   *service.JobRegistry
+	logger *slog.Logger
 }
 
-
 func NewRedisQueue(url string, logger *slog.Logger) (*RedisQueue, error) {
-    
-    opts, err := redis.ParseURL(url)
-    if err != nil {
-        return nil, fmt.Errorf("invalid redis url: %w", err)
-    }
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
 
-    rdb := redis.NewClient(opts)
+	rdb := redis.NewClient(opts)
 
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-    defer cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-    if err := rdb.Ping(ctx).Err(); err != nil {
-        return nil, fmt.Errorf("failed to connect to redis: %w", err)
-    }
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
 
-    return &RedisQueue{
-        client: rdb,
-        logger: logger,
-    }, nil
+	return &RedisQueue{
+		client: rdb,
+		jobs:   service.NewJobRegistry(),
+		logger: logger,
+	}, nil
 }
 
+// RegisterHandler sets what runs jobs of jobType. Run starts workers for
+// every registered type.
+func (q *RedisQueue) RegisterHandler(jobType string, handler service.JobHandler, opts service.WorkerOptions) {
+	q.jobs.Register(jobType, handler, opts)
+}
 
-
-func (q *RedisQueue) SetPaymentService(svc service.PaymentService) {
-	q.paymentService = svc
+func (q *RedisQueue) JobStats() map[string]service.JobStats {
+	return q.jobs.Stats()
 }
 
 func (q *RedisQueue) Ping(ctx context.Context) error {
 	return q.client.Ping(ctx).Err()
 }
 
-func (q *RedisQueue) Enqueue(ctx context.Context, queueName string, payload []byte) error {
-	j := job{Payload: payload, Retries: 0}
+func (q *RedisQueue) Enqueue(ctx context.Context, jobType string, payload []byte) error {
+	return q.add(ctx, q.client, jobType, job{ID: newJobID(), Type: jobType, Payload: payload})
+}
 
+// add appends a job to the queue's stream.
+func (q *RedisQueue) add(ctx context.Context, c redis.Cmdable, queueName string, j job) error {
 	data, err := json.Marshal(j)
 	if err != nil {
 		return err
 	}
-	return q.client.RPush(ctx, queueName, data).Err()
-}
-
-func (q *RedisQueue) StartWorker(ctx context.Context, queueName string) {
-    q.logger.Info("Worker started", "queue", queueName)
-
-    for {
-        select {
-        case <-ctx.Done():
-            q.logger.Info("Worker shutting down...")
-            return
-        default:
-            result, err := q.client.BLPop(ctx, 0, queueName).Result()
-            if err != nil {
-                if err != context.Canceled {
-                    q.logger.Error("redis connection error", "error", err)
-                }
-                time.Sleep(time.Second)
-                continue
-            }
-
-            var j job
-            if err := json.Unmarshal([]byte(result[1]), &j); err != nil {
-                q.logger.Error("skipping corrupt job", "error", err)
-                continue
-            }
-            err = q.paymentService.ProcessPaystackEvent(context.Background(), j.Payload)
-
-            if err != nil {
-                q.logger.Warn("job failed", "retries", j.Retries, "error", err)
-                q.handleFailure(ctx, queueName, j)
-            } else {
-                q.logger.Info("Job processed successfully")
-            }
-        }
-    }
-}
-
-func (q *RedisQueue) handleFailure(ctx context.Context, queueName string, j job) {
+	return c.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey(queueName),
+		Values: map[string]interface{}{jobField: data},
+	}).Err()
+}
+
+// Run starts workers for every registered job type and blocks until ctx is
+// cancelled and they have drained.
+func (q *RedisQueue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, jobType := range q.jobs.Types() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.startWorker(ctx, jobType, q.jobs.Options(jobType))
+		}()
+	}
+	wg.Wait()
+}
+
+// startWorker runs opts.Concurrency consumers on the queue's stream and blocks
+// until ctx is cancelled and every job already picked up has finished. A job
+// is only acknowledged once it has been handled, and its claim is renewed
+// while it runs, so only if the process dies mid-job does another consumer
+// reclaim it after opts.VisibilityTimeout.
+func (q *RedisQueue) startWorker(ctx context.Context, queueName string, opts service.WorkerOptions) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = defaultVisibilityTimeout
+	}
+	if opts.Retry.MaxAttempts < 1 {
+		opts.Retry = service.DefaultRetryPolicy
+	}
+
+	if err := q.prepareStream(ctx, queueName); err != nil {
+		q.logger.Error("failed to prepare queue stream", "queue", queueName, "error", err)
+	}
+
+	host, _ := os.Hostname()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.promote(ctx, queueName)
+	}()
+	for i := 0; i < opts.Concurrency; i++ {
+		consumer := fmt.Sprintf("%s-%d-%d", host, os.Getpid(), i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.consume(ctx, queueName, consumer, opts)
+		}()
+	}
+	q.logger.Info("Worker started", "queue", queueName, "concurrency", opts.Concurrency)
+
+	wg.Wait()
+	q.logger.Info("Worker drained", "queue", queueName)
+}
+
+// prepareStream creates the consumer group and moves over anything still
+// sitting in the list the queue used before it was a stream.
+func (q *RedisQueue) prepareStream(ctx context.Context, queueName string) error {
+	err := q.client.XGroupCreateMkStream(ctx, streamKey(queueName), consumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	for {
+		moved, err := migrateScript.Run(ctx, q.client, []string{queueName, streamKey(queueName)}, jobField).Int()
+		if err != nil || moved == 0 {
+			return err
+		}
+	}
+}
+
+func (q *RedisQueue) consume(ctx context.Context, queueName, consumer string, opts service.WorkerOptions) {
+	failures := 0
+	for ctx.Err() == nil {
+		msg, reclaimed, err := q.next(ctx, queueName, consumer, opts.VisibilityTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				// The stream was deleted or never set up; recreate it.
+				if err := q.prepareStream(ctx, queueName); err == nil {
+					continue
+				}
+			}
+			failures++
+			wait := backoff(failures)
+			q.logger.Error("redis connection error", "queue", queueName, "error", err, "retry_in", wait)
+			sleep(ctx, wait)
+			continue
+		}
+		failures = 0
+		if msg == nil {
+			continue
+		}
+
+		q.handle(queueName, consumer, *msg, reclaimed, opts)
+	}
+}
+
+// next returns a job whose consumer went quiet for longer than the visibility
+// timeout, or failing that waits briefly for a new one. It returns a nil
+// message when there is nothing to do.
+func (q *RedisQueue) next(ctx context.Context, queueName, consumer string, visibility time.Duration) (*redis.XMessage, bool, error) {
+	stale, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   streamKey(queueName),
+		Group:    consumerGroup,
+		Consumer: consumer,
+		MinIdle:  visibility,
+		Start:    "0",
+		Count:    1,
+	}).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(stale) > 0 {
+		return &stale[0], true, nil
+	}
+
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    consumerGroup,
+		Consumer: consumer,
+		Streams:  []string{streamKey(queueName), ">"},
+		Count:    1,
+		Block:    readBlock,
+	}).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, false, nil
+	}
+	return &streams[0].Messages[0], false, nil
+}
+
+// handle runs one job and acknowledges it. It deliberately ignores the
+// worker's context so a job picked up before shutdown still completes.
+func (q *RedisQueue) handle(queueName, consumer string, msg redis.XMessage, reclaimed bool, opts service.WorkerOptions) {
+	ctx := context.Background()
+	policy := opts.Retry
+
+	raw, _ := msg.Values[jobField].(string)
+	var j job
+	if err := json.Unmarshal([]byte(raw), &j); err != nil {
+		q.logger.Error("skipping corrupt job", "queue", queueName, "message", msg.ID, "error", err)
+		q.ack(ctx, q.client, queueName, msg.ID)
+		return
+	}
+	if j.Type == "" {
+		j.Type = queueName
+	}
+	logger := q.logger.With("job_type", j.Type, "job", j.ID, "attempt", j.Retries+1)
+
+	// A reclaimed job counts as a failed attempt, so a job that keeps
+	// killing its worker still ends up in the dead-letter queue.
+	if reclaimed {
+		logger.Warn("reclaimed job after visibility timeout")
+		q.jobs.Record(j.Type, service.JobReclaimed)
+		q.handleFailure(ctx, queueName, msg.ID, j, errors.New("visibility timeout expired"), policy)
+		return
+	}
+
+	start := time.Now()
+	release := q.holdClaim(queueName, consumer, msg.ID, opts.VisibilityTimeout)
+	err := q.jobs.Run(ctx, j.Type, j.Payload)
+	release()
+	if err != nil {
+		logger.Warn("job failed", "duration", time.Since(start), "error", err)
+		q.handleFailure(ctx, queueName, msg.ID, j, err, policy)
+		return
+	}
+
+	q.ack(ctx, q.client, queueName, msg.ID)
+	logger.Info("Job processed successfully", "duration", time.Since(start))
+}
+
+// holdClaim keeps resetting a running job's idle time, so XAUTOCLAIM only
+// hands it to another consumer once this one has died, however long the job
+// takes. The returned func stops it.
+func (q *RedisQueue) holdClaim(queueName, consumer, messageID string, visibility time.Duration) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(visibility / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := q.client.XClaimJustID(ctx, &redis.XClaimArgs{
+					Stream:   streamKey(queueName),
+					Group:    consumerGroup,
+					Consumer: consumer,
+					Messages: []string{messageID},
+				}).Err()
+				if err != nil && ctx.Err() == nil {
+					q.logger.Warn("failed to renew job claim", "queue", queueName, "message", messageID, "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// handleFailure schedules the job's next attempt after the policy's backoff,
+// or dead-letters it once it is out of attempts, and acknowledges the
+// original delivery in the same transaction.
+func (q *RedisQueue) handleFailure(ctx context.Context, queueName, messageID string, j job, jobErr error, policy service.RetryPolicy) {
 	j.Retries++
+	j.LastError = jobErr.Error()
+	failedAt := time.Now().UTC()
+	j.FailedAt = &failedAt
 
 	data, _ := json.Marshal(j)
 
-	if j.Retries < maxRetries {
-		q.logger.Info("Re-queueing job for retry")
-		q.client.RPush(ctx, queueName, data)
-	} else {
-		dlqName := queueName + ":dead_letter"
-		q.logger.Error("Job moved to DLQ", "queue", dlqName)
-		q.client.RPush(ctx, dlqName, data)
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if j.Retries < policy.MaxAttempts {
+			due := time.Now().Add(policy.Delay(j.Retries))
+			q.logger.Info("Scheduling job retry", "job_type", j.Type, "job", j.ID, "attempt", j.Retries+1, "at", due)
+			q.jobs.Record(j.Type, service.JobRetried)
+			if err := q.schedule(ctx, pipe, queueName, j, due); err != nil {
+				return err
+			}
+		} else {
+			dlqName := deadLetterKey(queueName)
+			q.logger.Error("Job moved to DLQ", "queue", dlqName, "job_type", j.Type, "job", j.ID)
+			q.jobs.Record(j.Type, service.JobDeadLettered)
+			pipe.RPush(ctx, dlqName, data)
+		}
+		q.ack(ctx, pipe, queueName, messageID)
+		return nil
+	})
+	if err != nil {
+		q.logger.Error("failed to record job failure", "queue", queueName, "job", j.ID, "error", err)
 	}
 }
 
+// ack acknowledges a delivery and drops it from the stream so handled jobs
+// don't pile up.
+func (q *RedisQueue) ack(ctx context.Context, c redis.Cmdable, queueName, messageID string) {
+	c.XAck(ctx, streamKey(queueName), consumerGroup, messageID)
+	c.XDel(ctx, streamKey(queueName), messageID)
+}
+
+// backoff doubles from 100ms with each consecutive failure, up to 30s.
+func backoff(failures int) time.Duration {
+	wait := 100 * time.Millisecond
+	for i := 1; i < failures && wait < 30*time.Second; i++ {
+		wait *= 2
+	}
+	if wait > 30*time.Second {
+		wait = 30 * time.Second
+	}
+	return wait
+}
+
+// sleep waits for d, or less if ctx is cancelled first.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
 
 func (q *RedisQueue) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	data, err := json.Marshal(value)
@@ -141,13 +390,11 @@ func (q *RedisQueue) Delete(ctx context.Context, key string) error {
 	return q.client.Del(ctx, key).Err()
 }
 
-
 func (q *RedisQueue) TryLockIdempotencyKey(ctx context.Context, key string, ttl time.Duration) (bool, error) {
 	fullKey := fmt.Sprintf("idemp:%s", key)
 	return q.client.SetNX(ctx, fullKey, "processing", ttl).Result()
 }
 
-
 func (q *RedisQueue) SetIdempotencyResult(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	fullKey := fmt.Sprintf("idemp:%s", key)
 	return q.Set(ctx, fullKey, value, ttl)
@@ -169,4 +416,4 @@ func (q *RedisQueue) IsRateLimited(ctx context.Context, key string, limit int, w
 	}
 
 	return false, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type AuditEntry struct {
+	Actor  string
+	Action string
+	Target string
+	Detail interface{} // stored as JSON
+}
+
+type AuditRepository interface {
+	RecordAudit(ctx context.Context, entry AuditEntry) error
+	ListAuditLogs(ctx context.Context, limit int) ([]db.AuditLogModel, error)
+}
+
+type auditRepository struct {
+	client *db.PrismaClient
+}
+
+func NewAuditRepository(client *db.PrismaClient) AuditRepository {
+	return &auditRepository{client: client}
+}
+
+func (r *auditRepository) RecordAudit(ctx context.Context, entry AuditEntry) error {
+	var detail *string
+	if entry.Detail != nil {
+		data, err := json.Marshal(entry.Detail)
+		if err != nil {
+			return err
+		}
+		detail = optionalString(string(data))
+	}
+
+	_, err := r.client.AuditLog.CreateOne(
+		db.AuditLog.Actor.Set(entry.Actor),
+		db.AuditLog.Action.Set(entry.Action),
+		db.AuditLog.Target.SetIfPresent(optionalString(entry.Target)),
+		db.AuditLog.Detail.SetIfPresent(detail),
+	).Exec(ctx)
+	return err
+}
+
+func (r *auditRepository) ListAuditLogs(ctx context.Context, limit int) ([]db.AuditLogModel, error) {
+	return r.client.AuditLog.FindMany().OrderBy(
+		db.AuditLog.CreatedAt.Order(db.SortOrderDesc),
+	).Take(limit).Exec(ctx)
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// NewCategoryRule files a user's new transactions whose description contains
+// DescriptionContains under CategoryID. A non-empty Type limits it to
+// transactions of that type.
+type NewCategoryRule struct {
+	CategoryID          string
+	DescriptionContains string
+	Type                db.TransactionType
+	Priority            int
+}
+
+type CategoryRepository interface {
+	ListCategories(ctx context.Context, userID string) ([]db.CategoryModel, error)
+	CreateCategory(ctx context.Context, userID, name, color string) (*db.CategoryModel, error)
+	// GetCategory and DeleteCategory return db.ErrNotFound unless id is one
+	// of the user's categories.
+	GetCategory(ctx context.Context, userID, id string) (*db.CategoryModel, error)
+	DeleteCategory(ctx context.Context, userID, id string) error
+
+	ListCategoryRules(ctx context.Context, userID string) ([]db.CategoryRuleModel, error)
+	CreateCategoryRule(ctx context.Context, userID string, rule NewCategoryRule) (*db.CategoryRuleModel, error)
+	DeleteCategoryRule(ctx context.Context, userID, id string) error
+
+	// GetUserTransaction returns db.ErrNotFound unless the transaction with
+	// reference is in the user's wallet.
+	GetUserTransaction(ctx context.Context, userID, reference string) (*db.TransactionModel, error)
+	TagTransaction(ctx context.Context, transactionID string, categoryID, note *string) (*db.TransactionTagModel, error)
+	UntagTransaction(ctx context.Context, transactionID string) error
+}
+
+type categoryRepository struct {
+	client *db.PrismaClient
+}
+
+func NewCategoryRepository(client *db.PrismaClient) CategoryRepository {
+	return &categoryRepository{client: client}
+}
+
+func (r *categoryRepository) ListCategories(ctx context.Context, userID string) ([]db.CategoryModel, error) {
+	return r.client.Category.FindMany(
+		db.Category.UserID.Equals(userID),
+	).OrderBy(
+		db.Category.Name.Order(db.SortOrderAsc),
+	).Exec(ctx)
+}
+
+func (r *categoryRepository) CreateCategory(ctx context.Context, userID, name, color string) (*db.CategoryModel, error) {
+	return r.client.Category.CreateOne(
+		db.Category.User.Link(db.User.ID.Equals(userID)),
+		db.Category.Name.Set(name),
+		db.Category.Color.SetIfPresent(optionalString(color)),
+	).Exec(ctx)
+}
+
+func (r *categoryRepository) GetCategory(ctx context.Context, userID, id string) (*db.CategoryModel, error) {
+	return r.client.Category.FindFirst(
+		db.Category.ID.Equals(id),
+		db.Category.UserID.Equals(userID),
+	).Exec(ctx)
+}
+
+// DeleteCategory drops the category's rules with it; transactions filed
+// under it keep their notes but lose the category.
+func (r *categoryRepository) DeleteCategory(ctx context.Context, userID, id string) error {
+	if _, err := r.GetCategory(ctx, userID, id); err != nil {
+		return err
+	}
+	_, err := r.client.Category.FindUnique(db.Category.ID.Equals(id)).Delete().Exec(ctx)
+	return err
+}
+
+// ListCategoryRules returns the user's rules in the order they are tried.
+func (r *categoryRepository) ListCategoryRules(ctx context.Context, userID string) ([]db.CategoryRuleModel, error) {
+	return listCategoryRules(ctx, r.client, userID)
+}
+
+func listCategoryRules(ctx context.Context, client *db.PrismaClient, userID string) ([]db.CategoryRuleModel, error) {
+	return client.CategoryRule.FindMany(
+		db.CategoryRule.UserID.Equals(userID),
+	).OrderBy(
+		db.CategoryRule.Priority.Order(db.SortOrderAsc),
+		db.CategoryRule.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(ctx)
+}
+
+func (r *categoryRepository) CreateCategoryRule(ctx context.Context, userID string, rule NewCategoryRule) (*db.CategoryRuleModel, error) {
+	var txType *db.TransactionType
+	if rule.Type != "" {
+		txType = &rule.Type
+	}
+	return r.client.CategoryRule.CreateOne(
+		db.CategoryRule.User.Link(db.User.ID.Equals(userID)),
+		db.CategoryRule.Category.Link(db.Category.ID.Equals(rule.CategoryID)),
+		db.CategoryRule.DescriptionContains.Set(rule.DescriptionContains),
+		db.CategoryRule.Type.SetIfPresent(txType),
+		db.CategoryRule.Priority.Set(rule.Priority),
+	).Exec(ctx)
+}
+
+func (r *categoryRepository) DeleteCategoryRule(ctx context.Context, userID, id string) error {
+	result, err := r.client.CategoryRule.FindMany(
+		db.CategoryRule.ID.Equals(id),
+		db.CategoryRule.UserID.Equals(userID),
+	).Delete().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if result.Count == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+func (r *categoryRepository) GetUserTransaction(ctx context.Context, userID, reference string) (*db.TransactionModel, error) {
+	return r.client.Transaction.FindFirst(
+		db.Transaction.Reference.Equals(reference),
+		db.Transaction.Wallet.Where(db.Wallet.UserID.Equals(userID)),
+	).With(
+		db.Transaction.Tag.Fetch().With(db.TransactionTag.Category.Fetch()),
+	).Exec(ctx)
+}
+
+// TagTransaction sets the transaction's category and note, replacing any
+// tag it had, including one a rule put there.
+func (r *categoryRepository) TagTransaction(ctx context.Context, transactionID string, categoryID, note *string) (*db.TransactionTagModel, error) {
+	create := []db.TransactionTagSetParam{db.TransactionTag.Note.SetIfPresent(note)}
+	update := []db.TransactionTagSetParam{
+		db.TransactionTag.Note.SetOptional(note),
+		db.TransactionTag.Source.Set(db.TagSourceManual),
+	}
+	if categoryID != nil {
+		link := db.TransactionTag.Category.Link(db.Category.ID.Equals(*categoryID))
+		create = append(create, link)
+		update = append(update, link)
+	} else {
+		update = append(update, db.TransactionTag.Category.Unlink())
+	}
+
+	tag, err := r.client.TransactionTag.UpsertOne(
+		db.TransactionTag.TransactionID.Equals(transactionID),
+	).Create(
+		db.TransactionTag.Transaction.Link(db.Transaction.ID.Equals(transactionID)),
+		create...,
+	).Update(update...).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.TransactionTag.FindUnique(
+		db.TransactionTag.ID.Equals(tag.ID),
+	).With(
+		db.TransactionTag.Category.Fetch(),
+	).Exec(ctx)
+}
+
+func (r *categoryRepository) UntagTransaction(ctx context.Context, transactionID string) error {
+	_, err := r.client.TransactionTag.FindMany(
+		db.TransactionTag.TransactionID.Equals(transactionID),
+	).Delete().Exec(ctx)
+	return err
+}
+
+// MatchCategoryRule returns the first of rules, in the order given, whose
+// text is in description, ignoring case, and whose type, if it has one, is
+// txType.
+func MatchCategoryRule(rules []db.CategoryRuleModel, description string, txType db.TransactionType) (*db.CategoryRuleModel, bool) {
+	description = strings.ToLower(description)
+	for i := range rules {
+		rule := &rules[i]
+		if ruleType, ok := rule.Type(); ok && ruleType != txType {
+			continue
+		}
+		if strings.Contains(description, strings.ToLower(rule.DescriptionContains)) {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+// ruleTagOp files the new transaction with reference under the category of
+// the user's first matching rule. It returns nil when no rule matches.
+func (r *walletRepository) ruleTagOp(ctx context.Context, userID, reference, description string, txType db.TransactionType) (db.PrismaTransaction, error) {
+	rules, err := listCategoryRules(ctx, r.client, userID)
+	if err != nil {
+		return nil, err
+	}
+	rule, ok := MatchCategoryRule(rules, description, txType)
+	if !ok {
+		return nil, nil
+	}
+	return r.client.TransactionTag.CreateOne(
+		db.TransactionTag.Transaction.Link(db.Transaction.Reference.Equals(reference)),
+		db.TransactionTag.Category.Link(db.Category.ID.Equals(rule.CategoryID)),
+		db.TransactionTag.Source.Set(db.TagSourceRule),
+	).Tx(), nil
+}
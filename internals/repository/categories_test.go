@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+func categoryRule(categoryID, contains string, txType db.TransactionType) db.CategoryRuleModel {
+	rule := db.CategoryRuleModel{InnerCategoryRule: db.InnerCategoryRule{CategoryID: categoryID, DescriptionContains: contains}}
+	if txType != "" {
+		rule.InnerCategoryRule.Type = &txType
+	}
+	return rule
+}
+
+func TestMatchCategoryRule(t *testing.T) {
+	rules := []db.CategoryRuleModel{
+		categoryRule("salary", "payroll", db.TransactionTypeDeposit),
+		categoryRule("family", "Transfer to Ada", ""),
+		categoryRule("transfers", "transfer", ""),
+	}
+
+	cases := []struct {
+		description string
+		txType      db.TransactionType
+		want        string
+	}{
+		{"transfer to ada obi", db.TransactionTypeTransfer, "family"},
+		{"Transfer to Bola", db.TransactionTypeTransfer, "transfers"},
+		{"ACME PAYROLL OCT", db.TransactionTypeDeposit, "salary"},
+		{"ACME PAYROLL OCT", db.TransactionTypeTransfer, ""},
+		{"Wallet funding", db.TransactionTypeDeposit, ""},
+	}
+	for _, c := range cases {
+		rule, ok := MatchCategoryRule(rules, c.description, c.txType)
+		got := ""
+		if ok {
+			got = rule.CategoryID
+		}
+		if got != c.want {
+			t.Errorf("%q (%s): expected %q, got %q", c.description, c.txType, c.want, got)
+		}
+	}
+}
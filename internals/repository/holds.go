@@ -0,0 +1,408 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+var ErrHoldNotFound = errors.New("no active hold with this reference")
+
+// DisputeHoldReference is the reference of the hold on a disputed deposit,
+// and of the CHARGEBACK transaction if the dispute is lost.
+func DisputeHoldReference(depositReference string) string {
+	return depositReference + "-DISPUTE"
+}
+
+// AvailableBalance is the part of an asset's ledger balance not reserved by holds.
+func AvailableBalance(asset *db.WalletAssetModel) decimal.Decimal {
+	return asset.Balance.Sub(asset.HeldBalance)
+}
+
+// holdOps reserve amount on asset. The ledger balance is untouched until
+// the hold is captured.
+func (r *walletRepository) holdOps(asset *db.WalletAssetModel, amount money.Money, reason db.HoldReason, reference, description string) []db.PrismaTransaction {
+	return []db.PrismaTransaction{
+		r.client.WalletAsset.FindUnique(db.WalletAsset.ID.Equals(asset.ID)).
+			Update(db.WalletAsset.HeldBalance.Increment(amount.Amount())).Tx(),
+		r.client.FundHold.CreateOne(
+			db.FundHold.Asset.Link(db.WalletAsset.ID.Equals(asset.ID)),
+			db.FundHold.Amount.Set(amount.Amount()),
+			db.FundHold.Currency.Set(amount.Currency()),
+			db.FundHold.Reason.Set(reason),
+			db.FundHold.Reference.Set(reference),
+			db.FundHold.Description.Set(description),
+		).Tx(),
+	}
+}
+
+// HoldDisputedDeposit reserves a deposit the customer has disputed until the
+// dispute is resolved. Only what is still available can be held; if the
+// dispute is lost the platform carries whatever was already spent. A dispute
+// that is already held is left alone.
+func (r *walletRepository) HoldDisputedDeposit(ctx context.Context, reference string) error {
+	deposit, err := r.client.Transaction.FindFirst(
+		db.Transaction.Reference.Equals(reference),
+		db.Transaction.Type.Equals(db.TransactionTypeDeposit),
+	).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("disputed deposit %s not found: %w", reference, err)
+	}
+
+	asset, err := r.client.WalletAsset.FindUnique(
+		db.WalletAsset.WalletIDCurrency(
+			db.WalletAsset.WalletID.Equals(deposit.WalletID),
+			db.WalletAsset.Currency.Equals(deposit.Currency),
+		),
+	).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	held := decimal.Min(deposit.Amount, AvailableBalance(asset))
+	if !held.IsPositive() {
+		return nil
+	}
+	amount, err := money.New(held, deposit.Currency)
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Chargeback of deposit %s", reference)
+	ops := r.holdOps(asset, amount, db.HoldReasonDispute, DisputeHoldReference(reference), description)
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
+	if isUniqueConstraintError(err) {
+		return nil
+	}
+	if isBalanceConstraintError(err) {
+		return fmt.Errorf("insufficient funds (race condition)")
+	}
+	return err
+}
+
+// GetHold returns the hold with its asset and the asset's wallet.
+func (r *walletRepository) GetHold(ctx context.Context, reference string) (*db.FundHoldModel, error) {
+	return r.client.FundHold.FindUnique(
+		db.FundHold.Reference.Equals(reference),
+	).With(
+		db.FundHold.Asset.Fetch().With(db.WalletAsset.Wallet.Fetch()),
+	).Exec(ctx)
+}
+
+func (r *walletRepository) activeHold(ctx context.Context, reference string) (*db.FundHoldModel, error) {
+	hold, err := r.GetHold(ctx, reference)
+	if errors.Is(err, db.ErrNotFound) || (err == nil && hold.Status != db.HoldStatusActive) {
+		return nil, ErrHoldNotFound
+	}
+	return hold, err
+}
+
+// resolveHoldOp moves an ACTIVE hold to status and takes its amount off the
+// asset's held balance, and off its ledger balance too when debit is set.
+// Both happen in one statement that only touches the asset if the hold was
+// still ACTIVE; otherwise it divides by zero, which rolls back the rest of
+// the transaction. Of two callers resolving the same hold at once, only one
+// can change the balance.
+func (r *walletRepository) resolveHoldOp(hold *db.FundHoldModel, status db.HoldStatus, debit bool) db.PrismaTransaction {
+	return r.client.Prisma.ExecuteRaw(`
+		WITH resolved AS (
+			UPDATE "FundHold" SET "status" = $2::"HoldStatus", "resolvedAt" = NOW()
+			WHERE "id" = $1 AND "status" = 'ACTIVE'
+			RETURNING "assetId", "amount"
+		), moved AS (
+			UPDATE "WalletAsset" a
+			SET "heldBalance" = a."heldBalance" - r."amount",
+			    "balance" = a."balance" - CASE WHEN $3::boolean THEN r."amount" ELSE 0 END
+			FROM resolved r
+			WHERE a."id" = r."assetId"
+			RETURNING a."id"
+		)
+		SELECT 1 / COUNT(*) FROM moved`,
+		hold.ID, string(status), debit,
+	).Tx()
+}
+
+// isHoldResolvedError reports whether resolveHoldOp found its hold already
+// captured or released by someone else.
+func isHoldResolvedError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "division by zero")
+}
+
+// CaptureHold turns an active hold into a real debit of the ledger balance.
+// A WITHDRAWAL hold settles its pending withdrawal, a DISPUTE hold becomes a
+// CHARGEBACK transaction paid back through the provider, and a REVIEW hold
+// completes the transfer it was holding back.
+func (r *walletRepository) CaptureHold(ctx context.Context, reference string) error {
+	hold, err := r.activeHold(ctx, reference)
+	if err != nil {
+		return err
+	}
+	asset := hold.Asset()
+
+	amount, err := money.New(hold.Amount, hold.Currency)
+	if err != nil {
+		return err
+	}
+
+	ops := []db.PrismaTransaction{r.resolveHoldOp(hold, db.HoldStatusCaptured, true)}
+
+	description, _ := hold.Description()
+	provider := "PAYSTACK"
+	var credit PostingLine
+	switch hold.Reason {
+	case db.HoldReasonWithdrawal:
+		txn, err := r.GetTransactionByReference(ctx, hold.Reference)
+		if err != nil {
+			return fmt.Errorf("withdrawal for hold %s not found: %w", hold.Reference, err)
+		}
+		if p, ok := txn.Provider(); ok {
+			provider = p
+		}
+		opEvent, err := r.withdrawalEventOp(ctx, txn, db.WithdrawalStatusSuccess)
+		if err != nil {
+			return err
+		}
+		ops = append(ops,
+			r.client.Transaction.FindUnique(db.Transaction.ID.Equals(txn.ID)).
+				Update(db.Transaction.Status.Set(db.TransactionStatusSuccess)).Tx(),
+			r.withdrawalStatusOp(hold.Reference, db.WithdrawalStatusSuccess),
+			opEvent,
+		)
+		credit = systemCredit(ClearingAccount(provider), amount)
+
+	case db.HoldReasonDispute:
+		deposit, err := r.GetTransactionByReference(ctx, strings.TrimSuffix(hold.Reference, DisputeHoldReference("")))
+		if err == nil {
+			if p, ok := deposit.Provider(); ok {
+				provider = p
+			}
+		}
+		owner, err := r.walletOwner(ctx, asset.WalletID)
+		if err != nil {
+			return err
+		}
+		opEvent, err := r.outboxOp(EventWalletDebited, WalletEvent{
+			UserID:      owner,
+			WalletID:    asset.WalletID,
+			Reference:   hold.Reference,
+			Amount:      hold.Amount,
+			Currency:    hold.Currency,
+			Description: description,
+			Provider:    provider,
+		})
+		if err != nil {
+			return err
+		}
+		ops = append(ops, opEvent, r.client.Transaction.CreateOne(
+			db.Transaction.Wallet.Link(db.Wallet.ID.Equals(asset.WalletID)),
+			db.Transaction.Amount.Set(hold.Amount),
+			db.Transaction.Currency.Set(hold.Currency),
+			db.Transaction.Type.Set(db.TransactionTypeChargeback),
+			db.Transaction.Direction.Set(db.TransactionDirectionDebit),
+			db.Transaction.Reference.Set(hold.Reference),
+			db.Transaction.Status.Set(db.TransactionStatusSuccess),
+			db.Transaction.Description.Set(description),
+			db.Transaction.Provider.Set(provider),
+		).Tx())
+		credit = systemCredit(ClearingAccount(provider), amount)
+
+	case db.HoldReasonReview:
+		debit, received, err := r.reviewedTransfer(ctx, hold.Reference)
+		if err != nil {
+			return err
+		}
+		receiver := received.RelationsTransaction.Wallet
+		opEvent, err := r.outboxOp(EventTransferCompleted, WalletEvent{
+			UserID:               debit.RelationsTransaction.Wallet.UserID,
+			WalletID:             asset.WalletID,
+			Reference:            hold.Reference,
+			Amount:               hold.Amount,
+			Currency:             hold.Currency,
+			Description:          description,
+			CounterpartyUserID:   receiver.UserID,
+			CounterpartyWalletID: receiver.ID,
+		})
+		if err != nil {
+			return err
+		}
+		ops = append(ops,
+			r.client.WalletAsset.UpsertOne(
+				db.WalletAsset.WalletIDCurrency(
+					db.WalletAsset.WalletID.Equals(receiver.ID),
+					db.WalletAsset.Currency.Equals(hold.Currency),
+				),
+			).Create(
+				db.WalletAsset.Wallet.Link(db.Wallet.ID.Equals(receiver.ID)),
+				db.WalletAsset.Currency.Set(hold.Currency),
+				db.WalletAsset.Balance.Set(hold.Amount),
+			).Update(db.WalletAsset.Balance.Increment(hold.Amount)).Tx(),
+			r.client.Transaction.FindMany(
+				db.Transaction.ID.In([]string{debit.ID, received.ID}),
+			).Update(db.Transaction.Status.Set(db.TransactionStatusSuccess)).Tx(),
+			opEvent,
+		)
+		credit = walletCredit(receiver.ID, amount)
+
+	default:
+		return fmt.Errorf("can't capture a %s hold", hold.Reason)
+	}
+
+	journal, err := r.journalOps(Journal{
+		Reference:   hold.Reference,
+		Description: description,
+		Postings:    []PostingLine{walletDebit(asset.WalletID, amount), credit},
+	})
+	if err != nil {
+		return err
+	}
+
+	ops = append(ops, journal...)
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
+	if isHoldResolvedError(err) {
+		return ErrHoldNotFound
+	}
+	if isBalanceConstraintError(err) {
+		return fmt.Errorf("insufficient funds (race condition)")
+	}
+	return err
+}
+
+// ReleaseHold frees the reserved funds without moving any money. A released
+// WITHDRAWAL hold marks its withdrawal FAILED, a released REVIEW hold cancels
+// its transfer and a released DISPUTE hold means the dispute was won.
+func (r *walletRepository) ReleaseHold(ctx context.Context, reference string) error {
+	return r.releaseHold(ctx, reference, db.WithdrawalStatusFailed)
+}
+
+func (r *walletRepository) releaseHold(ctx context.Context, reference string, outcome db.WithdrawalStatus) error {
+	hold, err := r.activeHold(ctx, reference)
+	if err != nil {
+		return err
+	}
+
+	ops := []db.PrismaTransaction{r.resolveHoldOp(hold, db.HoldStatusReleased, false)}
+
+	switch hold.Reason {
+	case db.HoldReasonWithdrawal:
+		txn, err := r.GetTransactionByReference(ctx, hold.Reference)
+		if err != nil {
+			return fmt.Errorf("withdrawal for hold %s not found: %w", hold.Reference, err)
+		}
+		opEvent, err := r.withdrawalEventOp(ctx, txn, outcome)
+		if err != nil {
+			return err
+		}
+		ops = append(ops,
+			r.client.Transaction.FindUnique(db.Transaction.ID.Equals(txn.ID)).
+				Update(db.Transaction.Status.Set(db.TransactionStatusFailed)).Tx(),
+			r.withdrawalStatusOp(hold.Reference, outcome),
+			opEvent,
+		)
+
+	case db.HoldReasonReview:
+		debit, received, err := r.reviewedTransfer(ctx, hold.Reference)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, r.client.Transaction.FindMany(
+			db.Transaction.ID.In([]string{debit.ID, received.ID}),
+		).Update(db.Transaction.Status.Set(db.TransactionStatusFailed)).Tx())
+	}
+
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
+	if isHoldResolvedError(err) {
+		return ErrHoldNotFound
+	}
+	return err
+}
+
+// reviewedTransfer returns the two PENDING legs of a transfer held for
+// review, each with its wallet.
+func (r *walletRepository) reviewedTransfer(ctx context.Context, reference string) (debit, credit *db.TransactionModel, err error) {
+	debit, err = r.GetTransactionByReference(ctx, reference+"-DEBIT")
+	if err == nil {
+		credit, err = r.GetTransactionByReference(ctx, reference+"-CREDIT")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("transfer for hold %s not found: %w", reference, err)
+	}
+	return debit, credit, nil
+}
+
+// CaptureWithdrawal settles a successful payout, found by our reference or
+// Paystack's transfer code. Withdrawals debited before holds existed are just
+// marked SUCCESS.
+func (r *walletRepository) CaptureWithdrawal(ctx context.Context, reference, transferCode string) error {
+	txn, err := r.findWithdrawal(ctx, reference, transferCode)
+	if err != nil || txn == nil {
+		return err
+	}
+
+	err = r.CaptureHold(ctx, txn.Reference)
+	if !errors.Is(err, ErrHoldNotFound) {
+		return err
+	}
+	// The hold may have just been resolved by someone else, so look again.
+	if txn, err = r.findWithdrawal(ctx, reference, transferCode); err != nil || txn == nil {
+		return err
+	}
+	if txn.Status == db.TransactionStatusSuccess {
+		return nil
+	}
+
+	opEvent, err := r.withdrawalEventOp(ctx, txn, db.WithdrawalStatusSuccess)
+	if err != nil {
+		return err
+	}
+	return r.client.Prisma.Transaction(
+		r.client.Transaction.FindUnique(db.Transaction.ID.Equals(txn.ID)).
+			Update(db.Transaction.Status.Set(db.TransactionStatusSuccess)).Tx(),
+		opEvent,
+	).Exec(ctx)
+}
+
+// ReleaseWithdrawal undoes a payout that ended as outcome (FAILED or
+// REVERSED): an active hold is released, anything already debited is refunded.
+func (r *walletRepository) ReleaseWithdrawal(ctx context.Context, reference, transferCode string, outcome db.WithdrawalStatus) error {
+	txn, err := r.findWithdrawal(ctx, reference, transferCode)
+	if err != nil || txn == nil {
+		return err
+	}
+
+	err = r.releaseHold(ctx, txn.Reference, outcome)
+	if !errors.Is(err, ErrHoldNotFound) {
+		return err
+	}
+	// A release that lost the race leaves the withdrawal FAILED, and
+	// refundWithdrawal must see that rather than refund it again.
+	if txn, err = r.findWithdrawal(ctx, reference, transferCode); err != nil || txn == nil {
+		return err
+	}
+	return r.refundWithdrawal(ctx, txn)
+}
+
+func (r *walletRepository) findWithdrawal(ctx context.Context, reference, transferCode string) (*db.TransactionModel, error) {
+	match := []db.TransactionWhereParam{db.Transaction.Reference.Equals(reference)}
+	if transferCode != "" {
+		match = append(match, db.Transaction.GatewayRef.Equals(transferCode))
+	}
+	txn, err := r.client.Transaction.FindFirst(
+		db.Transaction.Type.Equals(db.TransactionTypeWithdrawal),
+		db.Transaction.Or(match...),
+	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	return txn, err
+}
+
+// isBalanceConstraintError reports whether the database refused a write
+// because it would push a balance, held balance or available balance below zero.
+func isBalanceConstraintError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "non_negative_")
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// MonthlyTotal sums a user's successful transactions of one type and
+// direction in one currency over a calendar month (UTC).
+type MonthlyTotal struct {
+	Month     string                  `json:"month"` // "2006-01"
+	Currency  string                  `json:"currency"`
+	Type      db.TransactionType      `json:"type"`
+	Direction db.TransactionDirection `json:"direction"`
+	Count     int                     `json:"count"`
+	Total     decimal.Decimal         `json:"total"`
+}
+
+// CounterpartyTotal sums what a user sent to or received from one
+// counterparty in a month: the other wallet of a transfer, the bank account
+// of a withdrawal, or the provider of a deposit or chargeback.
+type CounterpartyTotal struct {
+	Month         string                  `json:"month"`
+	Currency      string                  `json:"currency"`
+	Direction     db.TransactionDirection `json:"direction"`
+	Name          string                  `json:"name"`
+	AccountNumber string                  `json:"account_number"`
+	Count         int                     `json:"count"`
+	Total         decimal.Decimal         `json:"total"`
+}
+
+type InsightsRepository interface {
+	// MonthlyTotals and CounterpartyTotals aggregate the user's successful
+	// transactions created in [from, to).
+	MonthlyTotals(ctx context.Context, userID string, from, to time.Time) ([]MonthlyTotal, error)
+	CounterpartyTotals(ctx context.Context, userID string, from, to time.Time) ([]CounterpartyTotal, error)
+}
+
+type insightsRepository struct {
+	client *db.PrismaClient
+}
+
+func NewInsightsRepository(client *db.PrismaClient) InsightsRepository {
+	return &insightsRepository{client: client}
+}
+
+func (r *insightsRepository) MonthlyTotals(ctx context.Context, userID string, from, to time.Time) ([]MonthlyTotal, error) {
+	var rows []MonthlyTotal
+	err := r.client.Prisma.QueryRaw(`
+		SELECT to_char(date_trunc('month', t."createdAt"), 'YYYY-MM') AS month,
+		       t."currency" AS currency,
+		       t."type"::text AS type,
+		       t."direction"::text AS direction,
+		       COUNT(*)::int AS count,
+		       SUM(t."amount")::text AS total
+		FROM "Transaction" t
+		JOIN "Wallet" w ON w."id" = t."walletId"
+		WHERE w."userId" = $1 AND t."status" = 'SUCCESS'
+		  AND t."createdAt" >= $2 AND t."createdAt" < $3
+		GROUP BY 1, 2, 3, 4
+		ORDER BY 1, 2, 3, 4`,
+		userID, from, to,
+	).Exec(ctx, &rows)
+	return rows, err
+}
+
+// CounterpartyTotals finds the other side of a transfer through its
+// partner leg: "<ref>-DEBIT" pairs with "<ref>-CREDIT". Swaps, vault moves
+// and moves between wallets stay with the user, so they have no counterparty.
+func (r *insightsRepository) CounterpartyTotals(ctx context.Context, userID string, from, to time.Time) ([]CounterpartyTotal, error) {
+	var rows []CounterpartyTotal
+	err := r.client.Prisma.QueryRaw(`
+		SELECT to_char(date_trunc('month', t."createdAt"), 'YYYY-MM') AS month,
+		       t."currency" AS currency,
+		       t."direction"::text AS direction,
+		       COALESCE(cu."name", wd."accountName", t."provider", 'Unknown') AS name,
+		       COALESCE(cw."accountNumber", wd."accountNumber", '') AS account_number,
+		       COUNT(*)::int AS count,
+		       SUM(t."amount")::text AS total
+		FROM "Transaction" t
+		JOIN "Wallet" w ON w."id" = t."walletId"
+		LEFT JOIN "Transaction" ct ON t."type" = 'TRANSFER' AND ct."reference" = CASE
+		    WHEN t."reference" LIKE '%-DEBIT' THEN left(t."reference", -6) || '-CREDIT'
+		    ELSE left(t."reference", -7) || '-DEBIT'
+		END
+		LEFT JOIN "Wallet" cw ON cw."id" = ct."walletId"
+		LEFT JOIN "User" cu ON cu."id" = cw."userId"
+		LEFT JOIN "Withdrawal" wd ON t."type" = 'WITHDRAWAL' AND wd."reference" = t."reference"
+		WHERE w."userId" = $1 AND t."status" = 'SUCCESS' AND t."type" NOT IN ('SWAP', 'VAULT', 'MOVE')
+		  AND t."createdAt" >= $2 AND t."createdAt" < $3
+		GROUP BY 1, 2, 3, 4, 5`,
+		userID, from, to,
+	).Exec(ctx, &rows)
+	return rows, err
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// AccountBalance is what the ledger holds on a wallet or vault account in one
+// currency. VaultID is empty for wallet accounts.
+type AccountBalance struct {
+	Account     string               `json:"account"`
+	AccountType db.LedgerAccountType `json:"account_type"`
+	Currency    string               `json:"currency"`
+	WalletID    string               `json:"wallet_id"`
+	VaultID     string               `json:"vault_id"`
+	Balance     decimal.Decimal      `json:"balance"`
+}
+
+// NewInterestAccrual is one day's interest on an account.
+type NewInterestAccrual struct {
+	AccountBalance
+	Day        time.Time
+	AnnualRate decimal.Decimal // percent
+	Amount     decimal.Decimal
+}
+
+// InterestDue is the interest accrued on an account and not paid yet.
+// VaultOpen is false for wallet accounts and for vaults closed since.
+type InterestDue struct {
+	Account     string               `json:"account"`
+	AccountType db.LedgerAccountType `json:"account_type"`
+	Currency    string               `json:"currency"`
+	WalletID    string               `json:"wallet_id"`
+	VaultID     string               `json:"vault_id"`
+	VaultOpen   bool                 `json:"vault_open"`
+	Days        int                  `json:"days"`
+	Accrued     decimal.Decimal      `json:"accrued"`
+}
+
+// InterestPayment pays the accruals of an account for the days before
+// Before. Interest on a vault that has been closed goes to the wallet.
+type InterestPayment struct {
+	InterestDue
+	Before      time.Time
+	Amount      money.Money
+	Reference   string
+	Description string
+}
+
+type InterestRepository interface {
+	// LedgerBalances returns every wallet and vault account with a positive
+	// ledger balance as of at.
+	LedgerBalances(ctx context.Context, at time.Time) ([]AccountBalance, error)
+	// LastAccrualDay returns the latest day accrued, and false before the
+	// first accrual.
+	LastAccrualDay(ctx context.Context) (time.Time, bool, error)
+	// RecordAccruals skips accounts already accrued for the day, so a day
+	// can be accrued again safely.
+	RecordAccruals(ctx context.Context, accruals []NewInterestAccrual) error
+	// InterestDue sums the unpaid accruals of days before before.
+	InterestDue(ctx context.Context, before time.Time) ([]InterestDue, error)
+	// PayInterest credits the payment and marks the accruals it covers as
+	// paid in one transaction. What is accrued beyond the payment is carried
+	// into the next period. A payment whose reference exists fails with a
+	// unique constraint error.
+	PayInterest(ctx context.Context, p InterestPayment) error
+}
+
+func NewInterestRepository(client *db.PrismaClient) InterestRepository {
+	return &walletRepository{client: client}
+}
+
+// LedgerBalances sums postings rather than reading WalletAsset.balance, so
+// a past day's closing balance can be worked out after the fact.
+func (r *walletRepository) LedgerBalances(ctx context.Context, at time.Time) ([]AccountBalance, error) {
+	var rows []AccountBalance
+	err := r.client.Prisma.QueryRaw(`
+		SELECT p."account" AS account,
+		       p."accountType"::text AS account_type,
+		       p."currency" AS currency,
+		       COALESCE(p."walletId", v."walletId") AS wallet_id,
+		       COALESCE(v."id", '') AS vault_id,
+		       SUM(CASE p."direction" WHEN 'CREDIT' THEN p."amount" ELSE -p."amount" END)::text AS balance
+		FROM "Posting" p
+		LEFT JOIN "Vault" v ON p."accountType" = 'VAULT' AND v."id" = substr(p."account", length('VAULT:') + 1)
+		WHERE p."accountType" IN ('WALLET', 'VAULT') AND p."createdAt" < $1
+		GROUP BY 1, 2, 3, 4, 5
+		HAVING SUM(CASE p."direction" WHEN 'CREDIT' THEN p."amount" ELSE -p."amount" END) > 0
+		ORDER BY 1, 3`,
+		at,
+	).Exec(ctx, &rows)
+	return rows, err
+}
+
+func (r *walletRepository) LastAccrualDay(ctx context.Context) (time.Time, bool, error) {
+	accrual, err := r.client.InterestAccrual.FindFirst(
+		db.InterestAccrual.Carry.Equals(false),
+	).OrderBy(
+		db.InterestAccrual.Day.Order(db.SortOrderDesc),
+	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return accrual.Day, true, nil
+}
+
+func (r *walletRepository) RecordAccruals(ctx context.Context, accruals []NewInterestAccrual) error {
+	if len(accruals) == 0 {
+		return nil
+	}
+	ops := make([]db.PrismaTransaction, 0, len(accruals))
+	for _, a := range accruals {
+		ops = append(ops, r.client.Prisma.ExecuteRaw(`
+			INSERT INTO "InterestAccrual" ("id", "account", "accountType", "currency", "day", "walletId", "vaultId", "balance", "annualRate", "amount")
+			VALUES (gen_random_uuid()::text, $1, $2::"LedgerAccountType", $3, $4::date, $5, NULLIF($6, ''), $7::numeric, $8::numeric, $9::numeric)
+			ON CONFLICT ("account", "currency", "day", "carry") DO NOTHING`,
+			a.Account, string(a.AccountType), a.Currency, a.Day.Format(time.DateOnly), a.WalletID, a.VaultID,
+			a.Balance.String(), a.AnnualRate.String(), a.Amount.String(),
+		).Tx())
+	}
+	return r.client.Prisma.Transaction(ops...).Exec(ctx)
+}
+
+func (r *walletRepository) InterestDue(ctx context.Context, before time.Time) ([]InterestDue, error) {
+	var rows []InterestDue
+	err := r.client.Prisma.QueryRaw(`
+		SELECT a."account" AS account,
+		       a."accountType"::text AS account_type,
+		       a."currency" AS currency,
+		       a."walletId" AS wallet_id,
+		       COALESCE(a."vaultId", '') AS vault_id,
+		       COALESCE(bool_and(v."status" = 'ACTIVE'), false) AS vault_open,
+		       (COUNT(*) FILTER (WHERE NOT a."carry"))::int AS days,
+		       SUM(a."amount")::text AS accrued
+		FROM "InterestAccrual" a
+		LEFT JOIN "Vault" v ON v."id" = a."vaultId"
+		WHERE a."reference" IS NULL AND a."day" < $1::date
+		GROUP BY 1, 2, 3, 4, 5
+		ORDER BY 1, 3`,
+		before.Format(time.DateOnly),
+	).Exec(ctx, &rows)
+	return rows, err
+}
+
+func (r *walletRepository) PayInterest(ctx context.Context, p InterestPayment) error {
+	amount := p.Amount.Amount()
+	toVault := p.VaultID != "" && p.VaultOpen
+
+	var opCredit db.PrismaTransaction
+	credit := walletCredit(p.WalletID, p.Amount)
+	if toVault {
+		opCredit = r.client.Vault.FindUnique(db.Vault.ID.Equals(p.VaultID)).
+			Update(db.Vault.Balance.Increment(amount)).Tx()
+		credit = vaultCredit(p.VaultID, p.Amount)
+	} else {
+		opCredit = r.client.WalletAsset.UpsertOne(
+			db.WalletAsset.WalletIDCurrency(
+				db.WalletAsset.WalletID.Equals(p.WalletID),
+				db.WalletAsset.Currency.Equals(p.Currency),
+			),
+		).Create(
+			db.WalletAsset.Wallet.Link(db.Wallet.ID.Equals(p.WalletID)),
+			db.WalletAsset.Currency.Set(p.Currency),
+			db.WalletAsset.Balance.Set(amount),
+		).Update(db.WalletAsset.Balance.Increment(amount)).Tx()
+	}
+
+	optional := []db.TransactionSetParam{
+		db.Transaction.Status.Set(db.TransactionStatusSuccess),
+		db.Transaction.Description.Set(p.Description),
+	}
+	if toVault {
+		optional = append(optional, db.Transaction.Vault.Link(db.Vault.ID.Equals(p.VaultID)))
+	}
+	opLog := r.client.Transaction.CreateOne(
+		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(p.WalletID)),
+		db.Transaction.Amount.Set(amount),
+		db.Transaction.Currency.Set(p.Currency),
+		db.Transaction.Type.Set(db.TransactionTypeInterest),
+		db.Transaction.Direction.Set(db.TransactionDirectionCredit),
+		db.Transaction.Reference.Set(p.Reference),
+		optional...,
+	).Tx()
+
+	opPaid := r.client.InterestAccrual.FindMany(
+		db.InterestAccrual.Account.Equals(p.Account),
+		db.InterestAccrual.Currency.Equals(p.Currency),
+		db.InterestAccrual.Reference.IsNull(),
+		db.InterestAccrual.Day.Before(p.Before),
+	).Update(db.InterestAccrual.Reference.Set(p.Reference)).Tx()
+
+	ops := []db.PrismaTransaction{opCredit, opLog, opPaid}
+	if carry := p.Accrued.Sub(amount); carry.IsPositive() {
+		optional := []db.InterestAccrualSetParam{db.InterestAccrual.Carry.Set(true)}
+		if p.VaultID != "" {
+			optional = append(optional, db.InterestAccrual.Vault.Link(db.Vault.ID.Equals(p.VaultID)))
+		}
+		ops = append(ops, r.client.InterestAccrual.CreateOne(
+			db.InterestAccrual.Account.Set(p.Account),
+			db.InterestAccrual.AccountType.Set(p.AccountType),
+			db.InterestAccrual.Currency.Set(p.Currency),
+			db.InterestAccrual.Day.Set(p.Before),
+			db.InterestAccrual.Wallet.Link(db.Wallet.ID.Equals(p.WalletID)),
+			db.InterestAccrual.Balance.Set(decimal.Zero),
+			db.InterestAccrual.AnnualRate.Set(decimal.Zero),
+			db.InterestAccrual.Amount.Set(carry),
+			optional...,
+		).Tx())
+	}
+
+	journal, err := r.journalOps(Journal{
+		Reference:   p.Reference,
+		Description: p.Description,
+		Postings:    []PostingLine{systemDebit(AccountInterest, p.Amount), credit},
+	})
+	if err != nil {
+		return err
+	}
+
+	userID, err := r.walletOwner(ctx, p.WalletID)
+	if err != nil {
+		return err
+	}
+	opEvent, err := r.outboxOp(EventInterestPaid, WalletEvent{
+		UserID:      userID,
+		WalletID:    p.WalletID,
+		Reference:   p.Reference,
+		Amount:      amount,
+		Currency:    p.Currency,
+		Description: p.Description,
+	})
+	if err != nil {
+		return err
+	}
+
+	ops = append(append(ops, opEvent), journal...)
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
+	if isVaultConstraintError(err) {
+		return ErrVaultChanged
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// System accounts on the platform side of every journal entry. Customer
+// wallets are liabilities, so money arriving in a wallet is a CREDIT to the
+// wallet account and a DEBIT to whichever system account it came from.
+const (
+	AccountPaystackClearing = "SYSTEM:PAYSTACK_CLEARING"
+	AccountFXPool           = "SYSTEM:FX_POOL"
+	AccountFees             = "SYSTEM:FEES"
+	AccountSuspense         = "SYSTEM:SUSPENSE"
+	AccountInterest         = "SYSTEM:INTEREST_EXPENSE"
+)
+
+var ErrUnbalancedJournal = errors.New("journal entry does not balance")
+
+// WalletAccount returns the ledger account code of a customer wallet.
+func WalletAccount(walletID string) string {
+	return "WALLET:" + walletID
+}
+
+// ClearingAccount returns the system account that holds funds in transit
+// through a payment provider, e.g. SYSTEM:PAYSTACK_CLEARING.
+func ClearingAccount(provider string) string {
+	if provider == "" {
+		return AccountSuspense
+	}
+	return "SYSTEM:" + strings.ToUpper(provider) + "_CLEARING"
+}
+
+type PostingLine struct {
+	Account   string
+	WalletID  string // set only for wallet accounts
+	VaultID   string // set only for vault accounts
+	Direction db.PostingDirection
+	Amount    money.Money
+}
+
+type Journal struct {
+	Reference   string
+	Description string
+	Postings    []PostingLine
+}
+
+func walletDebit(walletID string, amount money.Money) PostingLine {
+	return PostingLine{Account: WalletAccount(walletID), WalletID: walletID, Direction: db.PostingDirectionDebit, Amount: amount}
+}
+
+func walletCredit(walletID string, amount money.Money) PostingLine {
+	return PostingLine{Account: WalletAccount(walletID), WalletID: walletID, Direction: db.PostingDirectionCredit, Amount: amount}
+}
+
+func systemDebit(account string, amount money.Money) PostingLine {
+	return PostingLine{Account: account, Direction: db.PostingDirectionDebit, Amount: amount}
+}
+
+func systemCredit(account string, amount money.Money) PostingLine {
+	return PostingLine{Account: account, Direction: db.PostingDirectionCredit, Amount: amount}
+}
+
+// Validate checks that the entry has postings and that debits equal credits
+// in every currency it touches.
+func (j Journal) Validate() error {
+	if j.Reference == "" {
+		return fmt.Errorf("%w: missing reference", ErrUnbalancedJournal)
+	}
+	if len(j.Postings) < 2 {
+		return fmt.Errorf("%w: %s needs at least two postings", ErrUnbalancedJournal, j.Reference)
+	}
+
+	totals := map[string]decimal.Decimal{}
+	for _, p := range j.Postings {
+		if !p.Amount.IsPositive() {
+			return fmt.Errorf("%w: %s has a non-positive posting on %s", ErrUnbalancedJournal, j.Reference, p.Account)
+		}
+		currency := p.Amount.Currency()
+		switch p.Direction {
+		case db.PostingDirectionDebit:
+			totals[currency] = totals[currency].Add(p.Amount.Amount())
+		case db.PostingDirectionCredit:
+			totals[currency] = totals[currency].Sub(p.Amount.Amount())
+		default:
+			return fmt.Errorf("%w: %s has an unknown direction %q", ErrUnbalancedJournal, j.Reference, p.Direction)
+		}
+	}
+
+	for currency, total := range totals {
+		if !total.IsZero() {
+			return fmt.Errorf("%w: %s is off by %s %s", ErrUnbalancedJournal, j.Reference, total, currency)
+		}
+	}
+	return nil
+}
+
+// journalOps validates the entry and returns the writes that record it, to be
+// executed in the same Prisma transaction as the balance changes they describe.
+func (r *walletRepository) journalOps(j Journal) ([]db.PrismaTransaction, error) {
+	if err := j.Validate(); err != nil {
+		return nil, err
+	}
+
+	ops := []db.PrismaTransaction{
+		r.client.JournalEntry.CreateOne(
+			db.JournalEntry.Reference.Set(j.Reference),
+			db.JournalEntry.Description.Set(j.Description),
+		).Tx(),
+	}
+
+	for _, p := range j.Postings {
+		accountType := db.LedgerAccountTypeSystem
+		var optional []db.PostingSetParam
+		if p.WalletID != "" {
+			accountType = db.LedgerAccountTypeWallet
+			optional = append(optional, db.Posting.Wallet.Link(db.Wallet.ID.Equals(p.WalletID)))
+		}
+		if p.VaultID != "" {
+			accountType = db.LedgerAccountTypeVault
+		}
+
+		ops = append(ops, r.client.Posting.CreateOne(
+			db.Posting.Entry.Link(db.JournalEntry.Reference.Equals(j.Reference)),
+			db.Posting.Account.Set(p.Account),
+			db.Posting.AccountType.Set(accountType),
+			db.Posting.Currency.Set(p.Amount.Currency()),
+			db.Posting.Direction.Set(p.Direction),
+			db.Posting.Amount.Set(p.Amount.Amount()),
+			optional...,
+		).Tx())
+	}
+	return ops, nil
+}
+
+// PostJournal records a standalone entry that has no matching balance change,
+// e.g. a movement between two system accounts.
+func (r *walletRepository) PostJournal(ctx context.Context, j Journal) error {
+	ops, err := r.journalOps(j)
+	if err != nil {
+		return err
+	}
+	return r.client.Prisma.Transaction(ops...).Exec(ctx)
+}
+
+func (r *walletRepository) GetJournalEntry(ctx context.Context, reference string) (*db.JournalEntryModel, error) {
+	return r.client.JournalEntry.FindUnique(
+		db.JournalEntry.Reference.Equals(reference),
+	).With(
+		db.JournalEntry.Postings.Fetch(),
+	).Exec(ctx)
+}
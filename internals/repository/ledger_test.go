@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+)
+
+func TestJournalValidate(t *testing.T) {
+	t.Run("Balanced Swap", func(t *testing.T) {
+		j := Journal{
+			Reference: "SWAP-1",
+			Postings: []PostingLine{
+				walletDebit("w1", money.FromMinor(150000, "NGN")),
+				systemCredit(AccountFXPool, money.FromMinor(150000, "NGN")),
+				systemDebit(AccountFXPool, money.FromMinor(100, "USD")),
+				walletCredit("w1", money.FromMinor(100, "USD")),
+			},
+		}
+		if err := j.Validate(); err != nil {
+			t.Fatalf("expected balanced entry, got: %v", err)
+		}
+	})
+
+	t.Run("Unbalanced Currency", func(t *testing.T) {
+		j := Journal{
+			Reference: "SWAP-2",
+			Postings: []PostingLine{
+				walletDebit("w1", money.FromMinor(150000, "NGN")),
+				walletCredit("w1", money.FromMinor(100, "USD")),
+			},
+		}
+		if err := j.Validate(); !errors.Is(err, ErrUnbalancedJournal) {
+			t.Fatalf("expected ErrUnbalancedJournal, got: %v", err)
+		}
+	})
+
+	t.Run("Single Posting", func(t *testing.T) {
+		j := Journal{
+			Reference: "DEP-1",
+			Postings:  []PostingLine{walletCredit("w1", money.FromMinor(10000, "NGN"))},
+		}
+		if err := j.Validate(); !errors.Is(err, ErrUnbalancedJournal) {
+			t.Fatalf("expected ErrUnbalancedJournal, got: %v", err)
+		}
+	})
+
+	t.Run("Non Positive Amount", func(t *testing.T) {
+		j := Journal{
+			Reference: "DEP-2",
+			Postings: []PostingLine{
+				systemDebit(AccountSuspense, money.Zero("NGN")),
+				walletCredit("w1", money.Zero("NGN")),
+			},
+		}
+		if err := j.Validate(); !errors.Is(err, ErrUnbalancedJournal) {
+			t.Fatalf("expected ErrUnbalancedJournal, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// InboxEntry is a notification to add to a user's in-app inbox.
+type InboxEntry struct {
+	UserID string
+	Event  string
+	Title  string
+	Body   string
+	Data   map[string]string // stored as JSON
+
+	// Key makes the entry idempotent: adding one with a key already in the
+	// inbox does nothing.
+	Key string
+}
+
+type NotificationRepository interface {
+	CreateInboxEntry(ctx context.Context, entry InboxEntry) error
+	ListInbox(ctx context.Context, userID string, offset, limit int) ([]db.NotificationModel, error)
+	CountUnread(ctx context.Context, userID string) (int, error)
+	// MarkRead returns db.ErrNotFound unless id is one of the user's notifications.
+	MarkRead(ctx context.Context, userID, id string) error
+	MarkAllRead(ctx context.Context, userID string) (int, error)
+
+	ListNotificationPreferences(ctx context.Context, userID string) ([]db.NotificationPreferenceModel, error)
+	SetNotificationPreference(ctx context.Context, userID, event, channel string, enabled bool) error
+}
+
+type notificationRepository struct {
+	client *db.PrismaClient
+}
+
+func NewNotificationRepository(client *db.PrismaClient) NotificationRepository {
+	return &notificationRepository{client: client}
+}
+
+func (r *notificationRepository) CreateInboxEntry(ctx context.Context, entry InboxEntry) error {
+	var data *string
+	if len(entry.Data) > 0 {
+		encoded, err := json.Marshal(entry.Data)
+		if err != nil {
+			return err
+		}
+		data = optionalString(string(encoded))
+	}
+
+	_, err := r.client.Notification.CreateOne(
+		db.Notification.User.Link(db.User.ID.Equals(entry.UserID)),
+		db.Notification.Event.Set(entry.Event),
+		db.Notification.Title.Set(entry.Title),
+		db.Notification.Body.Set(entry.Body),
+		db.Notification.Data.SetIfPresent(data),
+		db.Notification.Key.SetIfPresent(optionalString(entry.Key)),
+	).Exec(ctx)
+	if isUniqueConstraintError(err) {
+		return nil
+	}
+	return err
+}
+
+// ListInbox returns the user's notifications, newest first.
+func (r *notificationRepository) ListInbox(ctx context.Context, userID string, offset, limit int) ([]db.NotificationModel, error) {
+	return r.client.Notification.FindMany(
+		db.Notification.UserID.Equals(userID),
+	).OrderBy(
+		db.Notification.CreatedAt.Order(db.SortOrderDesc),
+	).Skip(offset).Take(limit).Exec(ctx)
+}
+
+func (r *notificationRepository) CountUnread(ctx context.Context, userID string) (int, error) {
+	var rows []struct {
+		Count int `json:"count"`
+	}
+	err := r.client.Prisma.QueryRaw(
+		`SELECT COUNT(*)::int AS count FROM "Notification" WHERE "userId" = $1 AND "readAt" IS NULL`,
+		userID,
+	).Exec(ctx, &rows)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	return rows[0].Count, nil
+}
+
+func (r *notificationRepository) MarkRead(ctx context.Context, userID, id string) error {
+	notification, err := r.client.Notification.FindFirst(
+		db.Notification.ID.Equals(id),
+		db.Notification.UserID.Equals(userID),
+	).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if _, read := notification.ReadAt(); read {
+		return nil
+	}
+
+	_, err = r.client.Notification.FindUnique(db.Notification.ID.Equals(id)).Update(
+		db.Notification.ReadAt.Set(time.Now()),
+	).Exec(ctx)
+	return err
+}
+
+func (r *notificationRepository) MarkAllRead(ctx context.Context, userID string) (int, error) {
+	result, err := r.client.Notification.FindMany(
+		db.Notification.UserID.Equals(userID),
+		db.Notification.ReadAt.IsNull(),
+	).Update(
+		db.Notification.ReadAt.Set(time.Now()),
+	).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
+
+func (r *notificationRepository) ListNotificationPreferences(ctx context.Context, userID string) ([]db.NotificationPreferenceModel, error) {
+	return r.client.NotificationPreference.FindMany(
+		db.NotificationPreference.UserID.Equals(userID),
+	).Exec(ctx)
+}
+
+func (r *notificationRepository) SetNotificationPreference(ctx context.Context, userID, event, channel string, enabled bool) error {
+	_, err := r.client.NotificationPreference.UpsertOne(
+		db.NotificationPreference.UserIDEventChannel(
+			db.NotificationPreference.UserID.Equals(userID),
+			db.NotificationPreference.Event.Equals(event),
+			db.NotificationPreference.Channel.Equals(channel),
+		),
+	).Create(
+		db.NotificationPreference.User.Link(db.User.ID.Equals(userID)),
+		db.NotificationPreference.Event.Set(event),
+		db.NotificationPreference.Channel.Set(channel),
+		db.NotificationPreference.Enabled.Set(enabled),
+	).Update(
+		db.NotificationPreference.Enabled.Set(enabled),
+	).Exec(ctx)
+	return err
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// Domain events written to the outbox alongside the ledger change.
+const (
+	EventWalletCredited      = "wallet.credited"
+	EventWalletDebited       = "wallet.debited"
+	EventTransferCompleted   = "transfer.completed"
+	EventSwapCompleted       = "swap.completed"
+	EventWithdrawalRequested = "withdrawal.requested"
+	EventWithdrawalSucceeded = "withdrawal.succeeded"
+	EventWithdrawalFailed    = "withdrawal.failed"
+	EventWithdrawalReversed  = "withdrawal.reversed"
+	EventVaultDeposited      = "vault.deposited"
+	EventVaultWithdrawn      = "vault.withdrawn"
+	EventInterestPaid        = "interest.paid"
+	EventWalletMoved         = "wallet.moved"
+)
+
+// WalletEvent is the payload of every outbox event; fields that don't apply
+// to an event type are left empty.
+type WalletEvent struct {
+	UserID      string          `json:"user_id"`
+	WalletID    string          `json:"wallet_id"`
+	Reference   string          `json:"reference"`
+	Amount      decimal.Decimal `json:"amount"`
+	Currency    string          `json:"currency"`
+	Description string          `json:"description,omitempty"`
+	Provider    string          `json:"provider,omitempty"`
+
+	// The receiving side of a transfer, or of a move between the user's
+	// own wallets.
+	CounterpartyUserID   string `json:"counterparty_user_id,omitempty"`
+	CounterpartyWalletID string `json:"counterparty_wallet_id,omitempty"`
+
+	// What a swap bought.
+	ToAmount   *decimal.Decimal `json:"to_amount,omitempty"`
+	ToCurrency string           `json:"to_currency,omitempty"`
+}
+
+type OutboxRepository interface {
+	ListUnpublishedOutbox(ctx context.Context, limit int) ([]db.OutboxEventModel, error)
+	MarkOutboxPublished(ctx context.Context, id string) error
+	// RecordOutboxFailure counts a failed attempt. A parked event is left
+	// out of ListUnpublishedOutbox from then on.
+	RecordOutboxFailure(ctx context.Context, id, message string, park bool) error
+	// ListParkedOutbox returns parked events, oldest first.
+	ListParkedOutbox(ctx context.Context, limit int) ([]db.OutboxEventModel, error)
+	// UnparkOutbox gives a parked event a fresh set of attempts. It returns
+	// db.ErrNotFound unless the event with id is parked.
+	UnparkOutbox(ctx context.Context, id string) error
+}
+
+type outboxRepository struct {
+	client *db.PrismaClient
+}
+
+func NewOutboxRepository(client *db.PrismaClient) OutboxRepository {
+	return &outboxRepository{client: client}
+}
+
+// ListUnpublishedOutbox returns the oldest events not yet handed to the
+// queue, leaving out parked ones.
+func (r *outboxRepository) ListUnpublishedOutbox(ctx context.Context, limit int) ([]db.OutboxEventModel, error) {
+	return r.client.OutboxEvent.FindMany(
+		db.OutboxEvent.PublishedAt.IsNull(),
+		db.OutboxEvent.FailedAt.IsNull(),
+	).OrderBy(
+		db.OutboxEvent.CreatedAt.Order(db.SortOrderAsc),
+	).Take(limit).Exec(ctx)
+}
+
+func (r *outboxRepository) MarkOutboxPublished(ctx context.Context, id string) error {
+	_, err := r.client.OutboxEvent.FindUnique(db.OutboxEvent.ID.Equals(id)).Update(
+		db.OutboxEvent.PublishedAt.Set(time.Now()),
+		db.OutboxEvent.Attempts.Increment(1),
+	).Exec(ctx)
+	return err
+}
+
+func (r *outboxRepository) RecordOutboxFailure(ctx context.Context, id, message string, park bool) error {
+	update := []db.OutboxEventSetParam{
+		db.OutboxEvent.Attempts.Increment(1),
+		db.OutboxEvent.LastError.Set(message),
+	}
+	if park {
+		update = append(update, db.OutboxEvent.FailedAt.Set(time.Now()))
+	}
+	_, err := r.client.OutboxEvent.FindUnique(db.OutboxEvent.ID.Equals(id)).Update(update...).Exec(ctx)
+	return err
+}
+
+func (r *outboxRepository) ListParkedOutbox(ctx context.Context, limit int) ([]db.OutboxEventModel, error) {
+	return r.client.OutboxEvent.FindMany(
+		db.OutboxEvent.PublishedAt.IsNull(),
+		db.OutboxEvent.Not(db.OutboxEvent.FailedAt.IsNull()),
+	).OrderBy(
+		db.OutboxEvent.CreatedAt.Order(db.SortOrderAsc),
+	).Take(limit).Exec(ctx)
+}
+
+func (r *outboxRepository) UnparkOutbox(ctx context.Context, id string) error {
+	result, err := r.client.OutboxEvent.FindMany(
+		db.OutboxEvent.ID.Equals(id),
+		db.OutboxEvent.PublishedAt.IsNull(),
+		db.OutboxEvent.Not(db.OutboxEvent.FailedAt.IsNull()),
+	).Update(
+		db.OutboxEvent.FailedAt.SetOptional(nil),
+		db.OutboxEvent.Attempts.Set(0),
+	).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if result.Count == 0 {
+		return db.ErrNotFound
+	}
+	return nil
+}
+
+// outboxOp records an event as part of the caller's transaction, so it exists
+// exactly when the change it describes was committed.
+func (r *walletRepository) outboxOp(eventType string, e WalletEvent) (db.PrismaTransaction, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.OutboxEvent.CreateOne(
+		db.OutboxEvent.Type.Set(eventType),
+		db.OutboxEvent.WalletID.Set(e.WalletID),
+		db.OutboxEvent.Payload.Set(string(payload)),
+	).Tx(), nil
+}
+
+// walletOwner returns the id of the user a wallet belongs to.
+func (r *walletRepository) walletOwner(ctx context.Context, walletID string) (string, error) {
+	wallet, err := r.client.Wallet.FindUnique(db.Wallet.ID.Equals(walletID)).Exec(ctx)
+	if err != nil {
+		return "", err
+	}
+	return wallet.UserID, nil
+}
+
+// withdrawalEvent is the event for a withdrawal settling in status.
+func withdrawalEvent(status db.WithdrawalStatus) string {
+	switch status {
+	case db.WithdrawalStatusSuccess:
+		return EventWithdrawalSucceeded
+	case db.WithdrawalStatusReversed:
+		return EventWithdrawalReversed
+	default:
+		return EventWithdrawalFailed
+	}
+}
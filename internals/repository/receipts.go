@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type ReceiptRepository interface {
+	// GetReceiptTransaction returns the transaction with its wallet and the
+	// wallet's holder.
+	GetReceiptTransaction(ctx context.Context, reference string) (*db.TransactionModel, error)
+	GetWithdrawal(ctx context.Context, reference string) (*db.WithdrawalModel, error)
+	// ListJournalPostings returns the postings of the journal entry for
+	// reference, or none if it has no entry.
+	ListJournalPostings(ctx context.Context, reference string) ([]db.PostingModel, error)
+}
+
+type receiptRepository struct {
+	client *db.PrismaClient
+}
+
+func NewReceiptRepository(client *db.PrismaClient) ReceiptRepository {
+	return &receiptRepository{client: client}
+}
+
+func (r *receiptRepository) GetReceiptTransaction(ctx context.Context, reference string) (*db.TransactionModel, error) {
+	return r.client.Transaction.FindUnique(
+		db.Transaction.Reference.Equals(reference),
+	).With(
+		db.Transaction.Wallet.Fetch().With(db.Wallet.User.Fetch()),
+	).Exec(ctx)
+}
+
+func (r *receiptRepository) GetWithdrawal(ctx context.Context, reference string) (*db.WithdrawalModel, error) {
+	return r.client.Withdrawal.FindUnique(db.Withdrawal.Reference.Equals(reference)).Exec(ctx)
+}
+
+func (r *receiptRepository) ListJournalPostings(ctx context.Context, reference string) ([]db.PostingModel, error) {
+	return r.client.Posting.FindMany(
+		db.Posting.Entry.Where(db.JournalEntry.Reference.Equals(reference)),
+	).Exec(ctx)
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// Finding is one discrepancy spotted by a reconciliation run. Expected is what
+// our records say the value should be, Actual is what was found.
+type Finding struct {
+	Issue     string
+	WalletID  string
+	Currency  string
+	Reference string
+	Expected  *decimal.Decimal
+	Actual    *decimal.Decimal
+	Detail    string
+	Action    string
+}
+
+type ReconciliationRepository interface {
+	ListWalletAssets(ctx context.Context) ([]db.WalletAssetModel, error)
+	GetWalletAssets(ctx context.Context, walletID string) ([]db.WalletAssetModel, error)
+	GetWalletTransactions(ctx context.Context, walletID string) ([]db.TransactionModel, error)
+	ListActiveHolds(ctx context.Context, walletID string) ([]db.FundHoldModel, error)
+	FindTransaction(ctx context.Context, reference, gatewayRef string) (*db.TransactionModel, error)
+	ListProviderDeposits(ctx context.Context, provider string, from, to time.Time) ([]db.TransactionModel, error)
+	CreateRun(ctx context.Context, kind db.ReconciliationKind, trigger string) (*db.ReconciliationRunModel, error)
+	AddFindings(ctx context.Context, runID string, findings []Finding) error
+	FinishRun(ctx context.Context, runID string, status db.ReconciliationStatus, checked, mismatches int, runErr error) (*db.ReconciliationRunModel, error)
+	ListRuns(ctx context.Context, kind db.ReconciliationKind, limit int) ([]db.ReconciliationRunModel, error)
+	GetRun(ctx context.Context, runID string) (*db.ReconciliationRunModel, error)
+	SetWalletFrozen(ctx context.Context, walletID string, frozen bool, reason string) (*db.WalletModel, error)
+}
+
+type reconciliationRepository struct {
+	client *db.PrismaClient
+}
+
+func NewReconciliationRepository(client *db.PrismaClient) ReconciliationRepository {
+	return &reconciliationRepository{client: client}
+}
+
+func (r *reconciliationRepository) ListWalletAssets(ctx context.Context) ([]db.WalletAssetModel, error) {
+	return r.client.WalletAsset.FindMany().With(
+		db.WalletAsset.Wallet.Fetch(),
+	).OrderBy(
+		db.WalletAsset.WalletID.Order(db.SortOrderAsc),
+	).Exec(ctx)
+}
+
+func (r *reconciliationRepository) GetWalletAssets(ctx context.Context, walletID string) ([]db.WalletAssetModel, error) {
+	return r.client.WalletAsset.FindMany(
+		db.WalletAsset.WalletID.Equals(walletID),
+	).With(
+		db.WalletAsset.Wallet.Fetch(),
+	).Exec(ctx)
+}
+
+func (r *reconciliationRepository) GetWalletTransactions(ctx context.Context, walletID string) ([]db.TransactionModel, error) {
+	return r.client.Transaction.FindMany(
+		db.Transaction.WalletID.Equals(walletID),
+	).OrderBy(
+		db.Transaction.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(ctx)
+}
+
+func (r *reconciliationRepository) ListActiveHolds(ctx context.Context, walletID string) ([]db.FundHoldModel, error) {
+	return r.client.FundHold.FindMany(
+		db.FundHold.Status.Equals(db.HoldStatusActive),
+		db.FundHold.Asset.Where(db.WalletAsset.WalletID.Equals(walletID)),
+	).Exec(ctx)
+}
+
+// FindTransaction looks a transaction up by our reference or, failing that, by
+// the provider's own code for it. It returns nil when neither matches.
+func (r *reconciliationRepository) FindTransaction(ctx context.Context, reference, gatewayRef string) (*db.TransactionModel, error) {
+	var conditions []db.TransactionWhereParam
+	if reference != "" {
+		conditions = append(conditions, db.Transaction.Reference.Equals(reference))
+	}
+	if gatewayRef != "" {
+		conditions = append(conditions, db.Transaction.GatewayRef.Equals(gatewayRef))
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	txn, err := r.client.Transaction.FindFirst(
+		db.Transaction.Or(conditions...),
+	).With(
+		db.Transaction.Wallet.Fetch().With(db.Wallet.User.Fetch()),
+	).Exec(ctx)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	return txn, err
+}
+
+func (r *reconciliationRepository) ListProviderDeposits(ctx context.Context, provider string, from, to time.Time) ([]db.TransactionModel, error) {
+	return r.client.Transaction.FindMany(
+		db.Transaction.Provider.Equals(provider),
+		db.Transaction.Type.Equals(db.TransactionTypeDeposit),
+		db.Transaction.CreatedAt.Gte(from),
+		db.Transaction.CreatedAt.Lt(to),
+	).Exec(ctx)
+}
+
+func (r *reconciliationRepository) CreateRun(ctx context.Context, kind db.ReconciliationKind, trigger string) (*db.ReconciliationRunModel, error) {
+	return r.client.ReconciliationRun.CreateOne(
+		db.ReconciliationRun.Kind.Set(kind),
+		db.ReconciliationRun.Trigger.Set(trigger),
+	).Exec(ctx)
+}
+
+func (r *reconciliationRepository) AddFindings(ctx context.Context, runID string, findings []Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	ops := make([]db.PrismaTransaction, 0, len(findings))
+	for _, f := range findings {
+		ops = append(ops, r.client.ReconciliationItem.CreateOne(
+			db.ReconciliationItem.Run.Link(db.ReconciliationRun.ID.Equals(runID)),
+			db.ReconciliationItem.Issue.Set(f.Issue),
+			db.ReconciliationItem.WalletID.SetIfPresent(optionalString(f.WalletID)),
+			db.ReconciliationItem.Currency.SetIfPresent(optionalString(f.Currency)),
+			db.ReconciliationItem.Reference.SetIfPresent(optionalString(f.Reference)),
+			db.ReconciliationItem.Expected.SetIfPresent(f.Expected),
+			db.ReconciliationItem.Actual.SetIfPresent(f.Actual),
+			db.ReconciliationItem.Detail.SetIfPresent(optionalString(f.Detail)),
+			db.ReconciliationItem.Action.SetIfPresent(optionalString(f.Action)),
+		).Tx())
+	}
+	return r.client.Prisma.Transaction(ops...).Exec(ctx)
+}
+
+func (r *reconciliationRepository) FinishRun(ctx context.Context, runID string, status db.ReconciliationStatus, checked, mismatches int, runErr error) (*db.ReconciliationRunModel, error) {
+	var errMsg *string
+	if runErr != nil {
+		errMsg = optionalString(runErr.Error())
+	}
+
+	return r.client.ReconciliationRun.FindUnique(
+		db.ReconciliationRun.ID.Equals(runID),
+	).Update(
+		db.ReconciliationRun.Status.Set(status),
+		db.ReconciliationRun.Checked.Set(checked),
+		db.ReconciliationRun.Mismatches.Set(mismatches),
+		db.ReconciliationRun.Error.SetIfPresent(errMsg),
+		db.ReconciliationRun.FinishedAt.Set(time.Now()),
+	).Exec(ctx)
+}
+
+func (r *reconciliationRepository) ListRuns(ctx context.Context, kind db.ReconciliationKind, limit int) ([]db.ReconciliationRunModel, error) {
+	return r.client.ReconciliationRun.FindMany(
+		db.ReconciliationRun.Kind.Equals(kind),
+	).OrderBy(
+		db.ReconciliationRun.StartedAt.Order(db.SortOrderDesc),
+	).Take(limit).Exec(ctx)
+}
+
+func (r *reconciliationRepository) GetRun(ctx context.Context, runID string) (*db.ReconciliationRunModel, error) {
+	return r.client.ReconciliationRun.FindUnique(
+		db.ReconciliationRun.ID.Equals(runID),
+	).With(
+		db.ReconciliationRun.Items.Fetch(),
+	).Exec(ctx)
+}
+
+func (r *reconciliationRepository) SetWalletFrozen(ctx context.Context, walletID string, frozen bool, reason string) (*db.WalletModel, error) {
+	return r.client.Wallet.FindUnique(
+		db.Wallet.ID.Equals(walletID),
+	).Update(
+		db.Wallet.Frozen.Set(frozen),
+		db.Wallet.FrozenReason.SetOptional(optionalString(reason)),
+	).Exec(ctx)
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type StatementRepository interface {
+	// GetStatementAccount returns the asset in currency of one of the user's
+	// wallets, the default one when walletID is empty, with the wallet and
+	// its holder.
+	GetStatementAccount(ctx context.Context, userID, walletID, currency string) (*db.WalletAssetModel, error)
+	// ListTransactionsSince returns the wallet's transactions in currency
+	// from since onwards, oldest first.
+	ListTransactionsSince(ctx context.Context, walletID, currency string, since time.Time) ([]db.TransactionModel, error)
+	ListActiveHolds(ctx context.Context, assetID string) ([]db.FundHoldModel, error)
+}
+
+type statementRepository struct {
+	client *db.PrismaClient
+}
+
+func NewStatementRepository(client *db.PrismaClient) StatementRepository {
+	return &statementRepository{client: client}
+}
+
+func (r *statementRepository) GetStatementAccount(ctx context.Context, userID, walletID, currency string) (*db.WalletAssetModel, error) {
+	return r.client.WalletAsset.FindFirst(
+		db.WalletAsset.Currency.Equals(currency),
+		db.WalletAsset.Wallet.Where(userWallet(userID, walletID)...),
+	).With(
+		db.WalletAsset.Wallet.Fetch().With(db.Wallet.User.Fetch()),
+	).Exec(ctx)
+}
+
+func (r *statementRepository) ListTransactionsSince(ctx context.Context, walletID, currency string, since time.Time) ([]db.TransactionModel, error) {
+	return r.client.Transaction.FindMany(
+		db.Transaction.WalletID.Equals(walletID),
+		db.Transaction.Currency.Equals(currency),
+		db.Transaction.CreatedAt.Gte(since),
+	).OrderBy(
+		db.Transaction.CreatedAt.Order(db.SortOrderAsc),
+		db.Transaction.ID.Order(db.SortOrderAsc),
+	).Exec(ctx)
+}
+
+func (r *statementRepository) ListActiveHolds(ctx context.Context, assetID string) ([]db.FundHoldModel, error) {
+	return r.client.FundHold.FindMany(
+		db.FundHold.Status.Equals(db.HoldStatusActive),
+		db.FundHold.AssetID.Equals(assetID),
+	).Exec(ctx)
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// TransactionFilter narrows a user's transaction history. Zero values don't
+// filter. From is inclusive and To exclusive.
+type TransactionFilter struct {
+	WalletID  string // one of the user's wallets; all of them when empty
+	Types     []db.TransactionType
+	Statuses  []db.TransactionStatus
+	Direction db.TransactionDirection
+	Currency  string
+	From      time.Time
+	To        time.Time
+	MinAmount *decimal.Decimal
+	MaxAmount *decimal.Decimal
+
+	// Search matches part of the reference or description, ignoring case.
+	Search string
+
+	// Categories keeps transactions filed under any of these category ids.
+	Categories []string
+
+	// After is the id of the last transaction of the previous page.
+	After string
+	Limit int
+}
+
+// ListTransactions returns a page of the user's transactions, newest first.
+func (r *walletRepository) ListTransactions(ctx context.Context, userID string, filter TransactionFilter) ([]db.TransactionModel, error) {
+	where := []db.TransactionWhereParam{
+		db.Transaction.Wallet.Where(db.Wallet.UserID.Equals(userID)),
+	}
+	if filter.WalletID != "" {
+		where = append(where, db.Transaction.WalletID.Equals(filter.WalletID))
+	}
+	if len(filter.Types) > 0 {
+		where = append(where, db.Transaction.Type.In(filter.Types))
+	}
+	if len(filter.Statuses) > 0 {
+		where = append(where, db.Transaction.Status.In(filter.Statuses))
+	}
+	if filter.Direction != "" {
+		where = append(where, db.Transaction.Direction.Equals(filter.Direction))
+	}
+	if filter.Currency != "" {
+		where = append(where, db.Transaction.Currency.Equals(filter.Currency))
+	}
+	if !filter.From.IsZero() {
+		where = append(where, db.Transaction.CreatedAt.Gte(filter.From))
+	}
+	if !filter.To.IsZero() {
+		where = append(where, db.Transaction.CreatedAt.Lt(filter.To))
+	}
+	if filter.MinAmount != nil {
+		where = append(where, db.Transaction.Amount.Gte(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		where = append(where, db.Transaction.Amount.Lte(*filter.MaxAmount))
+	}
+	if filter.Search != "" {
+		// Conditions on the same field are merged, so each pair below
+		// becomes one case-insensitive "contains".
+		where = append(where, db.Transaction.Or(
+			db.Transaction.Reference.Contains(filter.Search),
+			db.Transaction.Reference.Mode(db.QueryModeInsensitive),
+			db.Transaction.Description.Contains(filter.Search),
+			db.Transaction.Description.Mode(db.QueryModeInsensitive),
+		))
+	}
+
+	if len(filter.Categories) > 0 {
+		where = append(where, db.Transaction.Tag.Where(db.TransactionTag.CategoryID.In(filter.Categories)))
+	}
+
+	query := r.client.Transaction.FindMany(where...).With(
+		db.Transaction.Tag.Fetch().With(db.TransactionTag.Category.Fetch()),
+	).OrderBy(
+		db.Transaction.CreatedAt.Order(db.SortOrderDesc),
+		db.Transaction.ID.Order(db.SortOrderDesc),
+	)
+	if filter.After != "" {
+		query = query.Cursor(db.Transaction.ID.Cursor(filter.After)).Skip(1)
+	}
+	return query.Take(filter.Limit).Exec(ctx)
+}
@@ -22,6 +22,15 @@ type UserRepository interface {
 	ValidateRefreshToken(ctx context.Context, token string) (bool, error)
 	RevokeRefreshToken(ctx context.Context, token string) error
 	FindUserByEmailOrAccount(ctx context.Context, query string) (*db.UserModel, error)
+	UpdateNotificationContact(ctx context.Context, userID string, contact NotificationContact) (*db.UserModel, error)
+}
+
+// NotificationContact changes where a user's notifications go. Nil fields are
+// left as they are.
+type NotificationContact struct {
+	Phone     *string
+	PushToken *string
+	Locale    *string
 }
 
 
@@ -55,6 +64,7 @@ func (r *userRepo) CreateUserWithWallet(ctx context.Context, email, password, na
 	wallet, err := r.client.Wallet.CreateOne(
 		db.Wallet.AccountNumber.Set(accNum),
 		db.Wallet.User.Link(db.User.ID.Equals(user.ID)),
+		db.Wallet.IsDefault.Set(true),
 	).Exec(ctx)
 
 	if err != nil {
@@ -146,11 +156,21 @@ func (r *userRepo) FindUserByEmailOrAccount(ctx context.Context, query string) (
     return r.client.User.FindFirst(
         db.User.Or(
             db.User.Email.Equals(query),
-            db.User.Wallet.Where(
+            db.User.Wallets.Some(
                 db.Wallet.AccountNumber.Equals(query),
             ),
         ),
     ).With(
-        db.User.Wallet.Fetch(),
+        db.User.Wallets.Fetch().OrderBy(db.Wallet.IsDefault.Order(db.SortOrderDesc)),
     ).Exec(ctx)
-}
\ No newline at end of file
+}
+
+func (r *userRepo) UpdateNotificationContact(ctx context.Context, userID string, contact NotificationContact) (*db.UserModel, error) {
+	return r.client.User.FindUnique(
+		db.User.ID.Equals(userID),
+	).Update(
+		db.User.Phone.SetIfPresent(contact.Phone),
+		db.User.PushToken.SetIfPresent(contact.PushToken),
+		db.User.Locale.SetIfPresent(contact.Locale),
+	).Exec(ctx)
+}
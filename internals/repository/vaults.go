@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// ErrVaultChanged is returned when a vault was closed or moved while a
+// transfer into or out of it was being written.
+var ErrVaultChanged = errors.New("vault changed while moving funds, please retry")
+
+// NewVault is a vault to open in a wallet.
+type NewVault struct {
+	WalletID     string // the user's default wallet when empty
+	Name         string
+	Currency     string
+	TargetAmount *decimal.Decimal
+	LockedUntil  *time.Time
+}
+
+// VaultWithdrawal moves Amount out of a vault. Penalty is kept as a fee and
+// the rest reaches the wallet. Close closes the vault, which must then be
+// empty.
+type VaultWithdrawal struct {
+	Amount      money.Money
+	Penalty     money.Money
+	Close       bool
+	Reference   string
+	Description string
+}
+
+// VaultAccount returns the ledger account code of a vault.
+func VaultAccount(vaultID string) string {
+	return "VAULT:" + vaultID
+}
+
+func vaultDebit(vaultID string, amount money.Money) PostingLine {
+	return PostingLine{Account: VaultAccount(vaultID), VaultID: vaultID, Direction: db.PostingDirectionDebit, Amount: amount}
+}
+
+func vaultCredit(vaultID string, amount money.Money) PostingLine {
+	return PostingLine{Account: VaultAccount(vaultID), VaultID: vaultID, Direction: db.PostingDirectionCredit, Amount: amount}
+}
+
+func (r *walletRepository) CreateVault(ctx context.Context, walletID string, v NewVault) (*db.VaultModel, error) {
+	return r.client.Vault.CreateOne(
+		db.Vault.Wallet.Link(db.Wallet.ID.Equals(walletID)),
+		db.Vault.Name.Set(v.Name),
+		db.Vault.Currency.Set(v.Currency),
+		db.Vault.TargetAmount.SetIfPresent(v.TargetAmount),
+		db.Vault.LockedUntil.SetIfPresent(v.LockedUntil),
+	).Exec(ctx)
+}
+
+// ListVaults returns the user's vaults, oldest first, with or without the
+// closed ones.
+func (r *walletRepository) ListVaults(ctx context.Context, userID string, includeClosed bool) ([]db.VaultModel, error) {
+	where := []db.VaultWhereParam{db.Vault.Wallet.Where(db.Wallet.UserID.Equals(userID))}
+	if !includeClosed {
+		where = append(where, db.Vault.Status.Equals(db.VaultStatusActive))
+	}
+	return r.client.Vault.FindMany(where...).OrderBy(
+		db.Vault.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(ctx)
+}
+
+// GetVault returns db.ErrNotFound unless id is one of the user's vaults.
+func (r *walletRepository) GetVault(ctx context.Context, userID, id string) (*db.VaultModel, error) {
+	return r.client.Vault.FindFirst(
+		db.Vault.ID.Equals(id),
+		db.Vault.Wallet.Where(db.Wallet.UserID.Equals(userID)),
+	).With(
+		db.Vault.Transactions.Fetch().OrderBy(db.Transaction.CreatedAt.Order(db.SortOrderDesc)).Take(20),
+	).Exec(ctx)
+}
+
+// CloseVault closes an empty vault.
+func (r *walletRepository) CloseVault(ctx context.Context, vaultID string) error {
+	_, err := r.client.Vault.FindUnique(db.Vault.ID.Equals(vaultID)).Update(
+		db.Vault.Status.Set(db.VaultStatusClosed),
+		db.Vault.ClosedAt.Set(time.Now()),
+	).Exec(ctx)
+	if isVaultConstraintError(err) {
+		return ErrVaultChanged
+	}
+	return err
+}
+
+// DepositToVault moves amount from the spendable balance of the wallet
+// asset in the vault's currency into the vault.
+func (r *walletRepository) DepositToVault(ctx context.Context, vault *db.VaultModel, amount money.Money, reference, description string) error {
+	if err := r.ensureNotFrozen(ctx, vault.WalletID); err != nil {
+		return err
+	}
+	asset, err := r.client.WalletAsset.FindUnique(
+		db.WalletAsset.WalletIDCurrency(
+			db.WalletAsset.WalletID.Equals(vault.WalletID),
+			db.WalletAsset.Currency.Equals(vault.Currency),
+		),
+	).Exec(ctx)
+	if err != nil || AvailableBalance(asset).LessThan(amount.Amount()) {
+		return fmt.Errorf("insufficient balance")
+	}
+
+	opDebit := r.client.WalletAsset.FindUnique(db.WalletAsset.ID.Equals(asset.ID)).
+		Update(db.WalletAsset.Balance.Decrement(amount.Amount())).Tx()
+
+	opVault := r.client.Vault.FindUnique(db.Vault.ID.Equals(vault.ID)).
+		Update(db.Vault.Balance.Increment(amount.Amount())).Tx()
+
+	opLog := r.client.Transaction.CreateOne(
+		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(vault.WalletID)),
+		db.Transaction.Amount.Set(amount.Amount()),
+		db.Transaction.Currency.Set(vault.Currency),
+		db.Transaction.Type.Set(db.TransactionTypeVault),
+		db.Transaction.Direction.Set(db.TransactionDirectionDebit),
+		db.Transaction.Reference.Set(reference),
+		db.Transaction.Status.Set(db.TransactionStatusSuccess),
+		db.Transaction.Description.Set(description),
+		db.Transaction.Vault.Link(db.Vault.ID.Equals(vault.ID)),
+	).Tx()
+
+	journal, err := r.journalOps(Journal{
+		Reference:   reference,
+		Description: description,
+		Postings: []PostingLine{
+			walletDebit(vault.WalletID, amount),
+			vaultCredit(vault.ID, amount),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	userID, err := r.walletOwner(ctx, vault.WalletID)
+	if err != nil {
+		return err
+	}
+	opEvent, err := r.outboxOp(EventVaultDeposited, WalletEvent{
+		UserID:      userID,
+		WalletID:    vault.WalletID,
+		Reference:   reference,
+		Amount:      amount.Amount(),
+		Currency:    vault.Currency,
+		Description: description,
+	})
+	if err != nil {
+		return err
+	}
+
+	ops := append([]db.PrismaTransaction{opDebit, opVault, opLog, opEvent}, journal...)
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
+	if isVaultConstraintError(err) {
+		return ErrVaultChanged
+	}
+	if isBalanceConstraintError(err) {
+		return fmt.Errorf("insufficient funds (race condition)")
+	}
+	return err
+}
+
+// WithdrawFromVault moves w.Amount out of the vault, paying the wallet what
+// is left after w.Penalty.
+func (r *walletRepository) WithdrawFromVault(ctx context.Context, vault *db.VaultModel, w VaultWithdrawal) error {
+	if err := r.ensureNotFrozen(ctx, vault.WalletID); err != nil {
+		return err
+	}
+	credited, err := w.Amount.Sub(w.Penalty)
+	if err != nil {
+		return err
+	}
+	if !credited.IsPositive() {
+		return fmt.Errorf("penalty takes the whole withdrawal")
+	}
+
+	vaultUpdate := []db.VaultSetParam{db.Vault.Balance.Decrement(w.Amount.Amount())}
+	if w.Close {
+		vaultUpdate = append(vaultUpdate,
+			db.Vault.Status.Set(db.VaultStatusClosed),
+			db.Vault.ClosedAt.Set(time.Now()),
+		)
+	}
+	opVault := r.client.Vault.FindUnique(db.Vault.ID.Equals(vault.ID)).Update(vaultUpdate...).Tx()
+
+	opCredit := r.client.WalletAsset.UpsertOne(
+		db.WalletAsset.WalletIDCurrency(
+			db.WalletAsset.WalletID.Equals(vault.WalletID),
+			db.WalletAsset.Currency.Equals(vault.Currency),
+		),
+	).Create(
+		db.WalletAsset.Wallet.Link(db.Wallet.ID.Equals(vault.WalletID)),
+		db.WalletAsset.Currency.Set(vault.Currency),
+		db.WalletAsset.Balance.Set(credited.Amount()),
+	).Update(db.WalletAsset.Balance.Increment(credited.Amount())).Tx()
+
+	opLog := r.client.Transaction.CreateOne(
+		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(vault.WalletID)),
+		db.Transaction.Amount.Set(credited.Amount()),
+		db.Transaction.Currency.Set(vault.Currency),
+		db.Transaction.Type.Set(db.TransactionTypeVault),
+		db.Transaction.Direction.Set(db.TransactionDirectionCredit),
+		db.Transaction.Reference.Set(w.Reference),
+		db.Transaction.Status.Set(db.TransactionStatusSuccess),
+		db.Transaction.Description.Set(w.Description),
+		db.Transaction.Vault.Link(db.Vault.ID.Equals(vault.ID)),
+	).Tx()
+
+	postings := []PostingLine{
+		vaultDebit(vault.ID, w.Amount),
+		walletCredit(vault.WalletID, credited),
+	}
+	if w.Penalty.IsPositive() {
+		postings = append(postings, systemCredit(AccountFees, w.Penalty))
+	}
+	journal, err := r.journalOps(Journal{Reference: w.Reference, Description: w.Description, Postings: postings})
+	if err != nil {
+		return err
+	}
+
+	userID, err := r.walletOwner(ctx, vault.WalletID)
+	if err != nil {
+		return err
+	}
+	opEvent, err := r.outboxOp(EventVaultWithdrawn, WalletEvent{
+		UserID:      userID,
+		WalletID:    vault.WalletID,
+		Reference:   w.Reference,
+		Amount:      credited.Amount(),
+		Currency:    vault.Currency,
+		Description: w.Description,
+	})
+	if err != nil {
+		return err
+	}
+
+	ops := append([]db.PrismaTransaction{opVault, opCredit, opLog, opEvent}, journal...)
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
+	if isVaultConstraintError(err) {
+		return ErrVaultChanged
+	}
+	return err
+}
+
+// isVaultConstraintError reports whether a vault went below zero or kept a
+// balance while being closed, i.e. another move got there first.
+func isVaultConstraintError(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "non_negative_vault_balance") || strings.Contains(err.Error(), "closed_vault_empty"))
+}
@@ -2,26 +2,60 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
 	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
 )
 
+var ErrWalletFrozen = errors.New("wallet is frozen")
+
+// Methods taking a walletID along with a userID only reach that wallet if it
+// is one of the user's; an empty walletID is the user's default wallet.
 type WalletRepository interface {
-	GetWalletWithAssets(ctx context.Context, userID string) (*db.WalletModel, error)
+	ListWallets(ctx context.Context, userID string) ([]db.WalletModel, error)
+	CreateWallet(ctx context.Context, userID string, w NewWallet) (*db.WalletModel, error)
+	GetWalletWithAssets(ctx context.Context, userID, walletID string) (*db.WalletModel, error)
 	UpdateTransactionStatus(ctx context.Context, reference string, status db.TransactionStatus) error
 	RefundWithdrawal(ctx context.Context, reference string) error
-	GetAssetByCurrency(ctx context.Context, userID string, currency string) (*db.WalletAssetModel, error)
-	DebitForWithdrawal(ctx context.Context, userID, currency string, amount float64, reference, transferCode, description string) error
-	CreditWallet(ctx context.Context, walletID, currency string, amount float64, reference, description string, txType db.TransactionType) error
-	CreditWalletByEmail(ctx context.Context, email, currency string, amount float64, reference, description, provider string) error
-	SwapFunds(ctx context.Context, userID, fromCurrency, toCurrency string, sourceAmount, amountOut float64, reference, description string) error
-	GetTransactions(ctx context.Context, userID string) ([]db.TransactionModel, error)
+	GetAssetByCurrency(ctx context.Context, userID, walletID, currency string) (*db.WalletAssetModel, error)
+	CreditWallet(ctx context.Context, walletID string, amount money.Money, reference, description string, txType db.TransactionType) error
+	CreditWalletByEmail(ctx context.Context, email string, amount money.Money, reference, description, provider string) error
+	SwapFunds(ctx context.Context, userID, walletID string, source, dest money.Money, reference, description string) error
+	ListTransactions(ctx context.Context, userID string, filter TransactionFilter) ([]db.TransactionModel, error)
 	GetUserByID(ctx context.Context, userID string) (*db.UserModel, error)
 	GetWalletByAccountNumber(ctx context.Context, accountNumber string) (*db.WalletModel, error)
 	GetTransactionByReference(ctx context.Context, reference string) (*db.TransactionModel, error)
-	TransferFunds(ctx context.Context, fromUserID, toAccountNumber, currency string, amount float64, reference, descSender, descReceiver string) error
+	// TransferFunds sends amount from one of the user's wallets to an
+	// account number. When the account is another of the user's own wallets
+	// the money is moved instead: recorded as MOVE, untagged and unnotified.
+	// A transfer flagged for review only reserves amount under a REVIEW hold
+	// and records both legs PENDING until the hold is captured or released.
+	TransferFunds(ctx context.Context, fromUserID, fromWalletID, toAccountNumber string, amount money.Money, reference, descSender, descReceiver string, review bool) error
+	PostJournal(ctx context.Context, j Journal) error
+	GetJournalEntry(ctx context.Context, reference string) (*db.JournalEntryModel, error)
+	HoldDisputedDeposit(ctx context.Context, reference string) error
+	CaptureHold(ctx context.Context, reference string) error
+	ReleaseHold(ctx context.Context, reference string) error
+	GetHold(ctx context.Context, reference string) (*db.FundHoldModel, error)
+	CreateWithdrawal(ctx context.Context, userID string, w NewWithdrawal) (*db.WithdrawalModel, error)
+	GetWithdrawal(ctx context.Context, reference string) (*db.WithdrawalModel, error)
+	StartWithdrawalAttempt(ctx context.Context, reference string) (*db.WithdrawalModel, error)
+	SetWithdrawalRecipient(ctx context.Context, reference, recipientCode string) error
+	SetWithdrawalTransfer(ctx context.Context, reference, transferCode string) error
+	RecordWithdrawalError(ctx context.Context, reference, message string) error
+	ListStuckWithdrawals(ctx context.Context, before time.Time, limit int) ([]db.WithdrawalModel, error)
+	CaptureWithdrawal(ctx context.Context, reference, transferCode string) error
+	ReleaseWithdrawal(ctx context.Context, reference, transferCode string, outcome db.WithdrawalStatus) error
+	CreateVault(ctx context.Context, walletID string, v NewVault) (*db.VaultModel, error)
+	ListVaults(ctx context.Context, userID string, includeClosed bool) ([]db.VaultModel, error)
+	GetVault(ctx context.Context, userID, id string) (*db.VaultModel, error)
+	CloseVault(ctx context.Context, vaultID string) error
+	DepositToVault(ctx context.Context, vault *db.VaultModel, amount money.Money, reference, description string) error
+	WithdrawFromVault(ctx context.Context, vault *db.VaultModel, w VaultWithdrawal) error
 }
 
 type walletRepository struct {
@@ -32,18 +66,19 @@ func NewWalletRepository(client *db.PrismaClient) WalletRepository {
 	return &walletRepository{client: client}
 }
 
-func (r *walletRepository) GetWalletWithAssets(ctx context.Context, userID string) (*db.WalletModel, error) {
-	return r.client.Wallet.FindUnique(
-		db.Wallet.UserID.Equals(userID),
+func (r *walletRepository) GetWalletWithAssets(ctx context.Context, userID, walletID string) (*db.WalletModel, error) {
+	return r.client.Wallet.FindFirst(
+		userWallet(userID, walletID)...,
 	).With(
 		db.Wallet.Assets.Fetch(),
+		db.Wallet.Vaults.Fetch(db.Vault.Status.Equals(db.VaultStatusActive)).OrderBy(db.Vault.CreatedAt.Order(db.SortOrderAsc)),
 	).Exec(ctx)
 }
 
-func (r *walletRepository) GetAssetByCurrency(ctx context.Context, userID string, currency string) (*db.WalletAssetModel, error) {
+func (r *walletRepository) GetAssetByCurrency(ctx context.Context, userID, walletID, currency string) (*db.WalletAssetModel, error) {
 	return r.client.WalletAsset.FindFirst(
 		db.WalletAsset.Currency.Equals(currency),
-		db.WalletAsset.Wallet.Where(db.Wallet.UserID.Equals(userID)),
+		db.WalletAsset.Wallet.Where(userWallet(userID, walletID)...),
 	).Exec(ctx)
 }
 
@@ -59,14 +94,6 @@ func (r *walletRepository) GetWalletByAccountNumber(ctx context.Context, account
 	).Exec(ctx)
 }
 
-func (r *walletRepository) GetTransactions(ctx context.Context, userID string) ([]db.TransactionModel, error) {
-	return r.client.Transaction.FindMany(
-		db.Transaction.Wallet.Where(db.Wallet.UserID.Equals(userID)),
-	).OrderBy(
-		db.Transaction.CreatedAt.Order(db.SortOrderDesc),
-	).Exec(ctx)
-}
-
 func (r *walletRepository) GetTransactionByReference(ctx context.Context, reference string) (*db.TransactionModel, error) {
 	return r.client.Transaction.FindUnique(
 		db.Transaction.Reference.Equals(reference),
@@ -75,14 +102,14 @@ func (r *walletRepository) GetTransactionByReference(ctx context.Context, refere
 	).Exec(ctx)
 }
 
-
-
-func (r *walletRepository) CreditWallet(ctx context.Context, walletID, currency string, amount float64, reference, description string, txType db.TransactionType) error {
+func (r *walletRepository) CreditWallet(ctx context.Context, walletID string, amount money.Money, reference, description string, txType db.TransactionType) error {
+	currency := amount.Currency()
 	opTx := r.client.Transaction.CreateOne(
 		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(walletID)),
-		db.Transaction.Amount.Set(amount),
+		db.Transaction.Amount.Set(amount.Amount()),
 		db.Transaction.Currency.Set(currency),
 		db.Transaction.Type.Set(txType),
+		db.Transaction.Direction.Set(db.TransactionDirectionCredit),
 		db.Transaction.Reference.Set(reference),
 		db.Transaction.Status.Set(db.TransactionStatusSuccess),
 		db.Transaction.Description.Set(description),
@@ -96,26 +123,60 @@ func (r *walletRepository) CreditWallet(ctx context.Context, walletID, currency
 	).Create(
 		db.WalletAsset.Wallet.Link(db.Wallet.ID.Equals(walletID)),
 		db.WalletAsset.Currency.Set(currency),
-		db.WalletAsset.Balance.Set(amount),
+		db.WalletAsset.Balance.Set(amount.Amount()),
 	).Update(
-		db.WalletAsset.Balance.Increment(amount),
+		db.WalletAsset.Balance.Increment(amount.Amount()),
 	).Tx()
 
-	return r.client.Prisma.Transaction(opTx, opAsset).Exec(ctx)
+	journal, err := r.journalOps(Journal{
+		Reference:   reference,
+		Description: description,
+		Postings: []PostingLine{
+			systemDebit(AccountSuspense, amount),
+			walletCredit(walletID, amount),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	owner, err := r.walletOwner(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	opEvent, err := r.outboxOp(EventWalletCredited, WalletEvent{
+		UserID:      owner,
+		WalletID:    walletID,
+		Reference:   reference,
+		Amount:      amount.Amount(),
+		Currency:    currency,
+		Description: description,
+	})
+	if err != nil {
+		return err
+	}
+
+	ops := append([]db.PrismaTransaction{opTx, opAsset, opEvent}, journal...)
+	return r.client.Prisma.Transaction(ops...).Exec(ctx)
 }
 
-func (r *walletRepository) SwapFunds(ctx context.Context, userID, fromCurrency, toCurrency string, sourceAmount, amountOut float64, reference, description string) error {
-	sourceAsset, err := r.GetAssetByCurrency(ctx, userID, fromCurrency)
+func (r *walletRepository) SwapFunds(ctx context.Context, userID, walletID string, source, dest money.Money, reference, description string) error {
+	fromCurrency, toCurrency := source.Currency(), dest.Currency()
+	sourceAsset, err := r.GetAssetByCurrency(ctx, userID, walletID, fromCurrency)
 	if err != nil || sourceAsset == nil {
 		return fmt.Errorf("insufficient funds: source wallet not found")
 	}
 
-	if sourceAsset.Balance < sourceAmount {
+	if err := r.ensureNotFrozen(ctx, sourceAsset.WalletID); err != nil {
+		return err
+	}
+
+	if AvailableBalance(sourceAsset).LessThan(source.Amount()) {
 		return fmt.Errorf("insufficient balance")
 	}
 
 	opDebit := r.client.WalletAsset.FindUnique(db.WalletAsset.ID.Equals(sourceAsset.ID)).
-		Update(db.WalletAsset.Balance.Decrement(sourceAmount)).Tx()
+		Update(db.WalletAsset.Balance.Decrement(source.Amount())).Tx()
 
 	opCredit := r.client.WalletAsset.UpsertOne(
 		db.WalletAsset.WalletIDCurrency(
@@ -125,14 +186,15 @@ func (r *walletRepository) SwapFunds(ctx context.Context, userID, fromCurrency,
 	).Create(
 		db.WalletAsset.Wallet.Link(db.Wallet.ID.Equals(sourceAsset.WalletID)),
 		db.WalletAsset.Currency.Set(toCurrency),
-		db.WalletAsset.Balance.Set(amountOut),
-	).Update(db.WalletAsset.Balance.Increment(amountOut)).Tx()
+		db.WalletAsset.Balance.Set(dest.Amount()),
+	).Update(db.WalletAsset.Balance.Increment(dest.Amount())).Tx()
 
 	opLogOut := r.client.Transaction.CreateOne(
 		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(sourceAsset.WalletID)),
-		db.Transaction.Amount.Set(sourceAmount),
+		db.Transaction.Amount.Set(source.Amount()),
 		db.Transaction.Currency.Set(fromCurrency),
 		db.Transaction.Type.Set(db.TransactionTypeSwap),
+		db.Transaction.Direction.Set(db.TransactionDirectionDebit),
 		db.Transaction.Reference.Set(reference+"-OUT"),
 		db.Transaction.Status.Set(db.TransactionStatusSuccess),
 		db.Transaction.Description.Set(description),
@@ -140,23 +202,55 @@ func (r *walletRepository) SwapFunds(ctx context.Context, userID, fromCurrency,
 
 	opLogIn := r.client.Transaction.CreateOne(
 		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(sourceAsset.WalletID)),
-		db.Transaction.Amount.Set(amountOut),
+		db.Transaction.Amount.Set(dest.Amount()),
 		db.Transaction.Currency.Set(toCurrency),
 		db.Transaction.Type.Set(db.TransactionTypeSwap),
+		db.Transaction.Direction.Set(db.TransactionDirectionCredit),
 		db.Transaction.Reference.Set(reference+"-IN"),
 		db.Transaction.Status.Set(db.TransactionStatusSuccess),
 		db.Transaction.Description.Set(description),
 	).Tx()
 
-	err = r.client.Prisma.Transaction(opDebit, opCredit, opLogOut, opLogIn).Exec(ctx)
-	if err != nil && strings.Contains(err.Error(), "non_negative_balance") {
+	journal, err := r.journalOps(Journal{
+		Reference:   reference,
+		Description: description,
+		Postings: []PostingLine{
+			walletDebit(sourceAsset.WalletID, source),
+			systemCredit(AccountFXPool, source),
+			systemDebit(AccountFXPool, dest),
+			walletCredit(sourceAsset.WalletID, dest),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	bought := dest.Amount()
+	opEvent, err := r.outboxOp(EventSwapCompleted, WalletEvent{
+		UserID:      userID,
+		WalletID:    sourceAsset.WalletID,
+		Reference:   reference,
+		Amount:      source.Amount(),
+		Currency:    fromCurrency,
+		Description: description,
+		ToAmount:    &bought,
+		ToCurrency:  toCurrency,
+	})
+	if err != nil {
+		return err
+	}
+
+	ops := append([]db.PrismaTransaction{opDebit, opCredit, opLogOut, opLogIn, opEvent}, journal...)
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
+	if isBalanceConstraintError(err) {
 		return fmt.Errorf("insufficient funds (race condition)")
 	}
 	return err
 }
 
-func (r *walletRepository) TransferFunds(ctx context.Context, fromUserID, toAccountNumber, currency string, amount float64, reference, descSender, descReceiver string) error {
-	senderAsset, err := r.GetAssetByCurrency(ctx, fromUserID, currency)
+func (r *walletRepository) TransferFunds(ctx context.Context, fromUserID, fromWalletID, toAccountNumber string, amount money.Money, reference, descSender, descReceiver string, review bool) error {
+	currency := amount.Currency()
+	senderAsset, err := r.GetAssetByCurrency(ctx, fromUserID, fromWalletID, currency)
 	if err != nil || senderAsset == nil {
 		return fmt.Errorf("insufficient funds")
 	}
@@ -166,12 +260,30 @@ func (r *walletRepository) TransferFunds(ctx context.Context, fromUserID, toAcco
 		return fmt.Errorf("recipient not found")
 	}
 
-	if receiverWallet.UserID == fromUserID {
-		return fmt.Errorf("cannot transfer to self")
+	if receiverWallet.ID == senderAsset.WalletID {
+		return fmt.Errorf("cannot transfer to the same wallet")
+	}
+	txType, event := db.TransactionTypeTransfer, EventTransferCompleted
+	own := receiverWallet.UserID == fromUserID
+	if own {
+		txType, event = db.TransactionTypeMove, EventWalletMoved
+	}
+
+	if err := r.ensureNotFrozen(ctx, senderAsset.WalletID); err != nil {
+		return err
+	}
+
+	if AvailableBalance(senderAsset).LessThan(amount.Amount()) {
+		return fmt.Errorf("insufficient balance")
+	}
+
+	status := db.TransactionStatusSuccess
+	if review {
+		status = db.TransactionStatusPending
 	}
 
 	opDebit := r.client.WalletAsset.FindUnique(db.WalletAsset.ID.Equals(senderAsset.ID)).
-		Update(db.WalletAsset.Balance.Decrement(amount)).Tx()
+		Update(db.WalletAsset.Balance.Decrement(amount.Amount())).Tx()
 
 	opCredit := r.client.WalletAsset.UpsertOne(
 		db.WalletAsset.WalletIDCurrency(
@@ -181,74 +293,102 @@ func (r *walletRepository) TransferFunds(ctx context.Context, fromUserID, toAcco
 	).Create(
 		db.WalletAsset.Wallet.Link(db.Wallet.ID.Equals(receiverWallet.ID)),
 		db.WalletAsset.Currency.Set(currency),
-		db.WalletAsset.Balance.Set(amount),
-	).Update(db.WalletAsset.Balance.Increment(amount)).Tx()
+		db.WalletAsset.Balance.Set(amount.Amount()),
+	).Update(db.WalletAsset.Balance.Increment(amount.Amount())).Tx()
 
 	opLogS := r.client.Transaction.CreateOne(
 		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(senderAsset.WalletID)),
-		db.Transaction.Amount.Set(amount),
+		db.Transaction.Amount.Set(amount.Amount()),
 		db.Transaction.Currency.Set(currency),
-		db.Transaction.Type.Set(db.TransactionTypeTransfer),
+		db.Transaction.Type.Set(txType),
+		db.Transaction.Direction.Set(db.TransactionDirectionDebit),
 		db.Transaction.Reference.Set(reference+"-DEBIT"),
-		db.Transaction.Status.Set(db.TransactionStatusSuccess),
+		db.Transaction.Status.Set(status),
 		db.Transaction.Description.Set(descSender),
 	).Tx()
 
 	opLogR := r.client.Transaction.CreateOne(
 		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(receiverWallet.ID)),
-		db.Transaction.Amount.Set(amount),
+		db.Transaction.Amount.Set(amount.Amount()),
 		db.Transaction.Currency.Set(currency),
-		db.Transaction.Type.Set(db.TransactionTypeTransfer),
+		db.Transaction.Type.Set(txType),
+		db.Transaction.Direction.Set(db.TransactionDirectionCredit),
 		db.Transaction.Reference.Set(reference+"-CREDIT"),
-		db.Transaction.Status.Set(db.TransactionStatusSuccess),
+		db.Transaction.Status.Set(status),
 		db.Transaction.Description.Set(descReceiver),
 	).Tx()
 
-	err = r.client.Prisma.Transaction(opDebit, opCredit, opLogS, opLogR).Exec(ctx)
-	if err != nil && strings.Contains(err.Error(), "non_negative_balance") {
-		return fmt.Errorf("insufficient funds (race condition)")
+	journal, err := r.journalOps(Journal{
+		Reference:   reference,
+		Description: descSender,
+		Postings: []PostingLine{
+			walletDebit(senderAsset.WalletID, amount),
+			walletCredit(receiverWallet.ID, amount),
+		},
+	})
+	if err != nil {
+		return err
 	}
-	return err
-}
 
-func (r *walletRepository) DebitForWithdrawal(ctx context.Context, userID, currency string, amount float64, reference, transferCode, description string) error {
-	asset, err := r.GetAssetByCurrency(ctx, userID, currency)
-	if err != nil || asset == nil || asset.Balance < amount {
-		return fmt.Errorf("insufficient funds")
+	opEvent, err := r.outboxOp(event, WalletEvent{
+		UserID:               fromUserID,
+		WalletID:             senderAsset.WalletID,
+		Reference:            reference,
+		Amount:               amount.Amount(),
+		Currency:             currency,
+		Description:          descSender,
+		CounterpartyUserID:   receiverWallet.UserID,
+		CounterpartyWalletID: receiverWallet.ID,
+	})
+	if err != nil {
+		return err
 	}
 
-	opDebit := r.client.WalletAsset.FindUnique(db.WalletAsset.ID.Equals(asset.ID)).
-		Update(db.WalletAsset.Balance.Decrement(amount)).Tx()
+	ops := append([]db.PrismaTransaction{opDebit, opCredit, opLogS, opLogR, opEvent}, journal...)
+	if review {
+		// Nothing moves yet: CaptureHold completes the transfer, with the
+		// balances, journal and event above, and ReleaseHold cancels it.
+		ops = append(r.holdOps(senderAsset, amount, db.HoldReasonReview, reference, descSender), opLogS, opLogR)
+	}
 
-	opLog := r.client.Transaction.CreateOne(
-		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(asset.WalletID)),
-		db.Transaction.Amount.Set(amount),
-		db.Transaction.Currency.Set(currency),
-		db.Transaction.Type.Set(db.TransactionTypeWithdrawal),
-		db.Transaction.Reference.Set(reference),
-		db.Transaction.Status.Set(db.TransactionStatusPending),
-		db.Transaction.GatewayRef.Set(transferCode),
-		db.Transaction.Provider.Set("PAYSTACK"),
-		db.Transaction.Description.Set(description),
-	).Tx()
+	// Each party's own rules file their side of the transfer. A move between
+	// one's own wallets is neither spending nor income, so it isn't filed.
+	if !own {
+		for _, leg := range []struct{ userID, reference, description string }{
+			{fromUserID, reference + "-DEBIT", descSender},
+			{receiverWallet.UserID, reference + "-CREDIT", descReceiver},
+		} {
+			opTag, err := r.ruleTagOp(ctx, leg.userID, leg.reference, leg.description, db.TransactionTypeTransfer)
+			if err != nil {
+				return err
+			}
+			if opTag != nil {
+				ops = append(ops, opTag)
+			}
+		}
+	}
 
-	err = r.client.Prisma.Transaction(opDebit, opLog).Exec(ctx)
-	if err != nil && strings.Contains(err.Error(), "non_negative_balance") {
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
+	if isBalanceConstraintError(err) {
 		return fmt.Errorf("insufficient funds (race condition)")
 	}
 	return err
 }
 
-func (r *walletRepository) CreditWalletByEmail(ctx context.Context, email, currency string, amount float64, reference, description, provider string) error {
-	user, err := r.client.User.FindUnique(db.User.Email.Equals(email)).With(db.User.Wallet.Fetch()).Exec(ctx)
+func (r *walletRepository) CreditWalletByEmail(ctx context.Context, email string, amount money.Money, reference, description, provider string) error {
+	currency := amount.Currency()
+	user, err := r.client.User.FindUnique(db.User.Email.Equals(email)).With(
+		db.User.Wallets.Fetch(db.Wallet.IsDefault.Equals(true)),
+	).Exec(ctx)
 	if err != nil || user == nil {
 		return fmt.Errorf("user not found")
 	}
 
-	wallet, ok := user.Wallet()
-	if !ok {
+	wallets := user.Wallets()
+	if len(wallets) == 0 {
 		return fmt.Errorf("wallet not initialized")
 	}
+	wallet := wallets[0]
 
 	opAsset := r.client.WalletAsset.UpsertOne(
 		db.WalletAsset.WalletIDCurrency(
@@ -258,21 +398,57 @@ func (r *walletRepository) CreditWalletByEmail(ctx context.Context, email, curre
 	).Create(
 		db.WalletAsset.Wallet.Link(db.Wallet.ID.Equals(wallet.ID)),
 		db.WalletAsset.Currency.Set(currency),
-		db.WalletAsset.Balance.Set(amount),
-	).Update(db.WalletAsset.Balance.Increment(amount)).Tx()
+		db.WalletAsset.Balance.Set(amount.Amount()),
+	).Update(db.WalletAsset.Balance.Increment(amount.Amount())).Tx()
 
 	opLog := r.client.Transaction.CreateOne(
 		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(wallet.ID)),
-		db.Transaction.Amount.Set(amount),
+		db.Transaction.Amount.Set(amount.Amount()),
 		db.Transaction.Currency.Set(currency),
 		db.Transaction.Type.Set(db.TransactionTypeDeposit),
+		db.Transaction.Direction.Set(db.TransactionDirectionCredit),
 		db.Transaction.Reference.Set(reference),
 		db.Transaction.Status.Set(db.TransactionStatusSuccess),
 		db.Transaction.Description.Set(description),
 		db.Transaction.Provider.Set(provider),
 	).Tx()
 
-	err = r.client.Prisma.Transaction(opAsset, opLog).Exec(ctx)
+	journal, err := r.journalOps(Journal{
+		Reference:   reference,
+		Description: description,
+		Postings: []PostingLine{
+			systemDebit(ClearingAccount(provider), amount),
+			walletCredit(wallet.ID, amount),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	opEvent, err := r.outboxOp(EventWalletCredited, WalletEvent{
+		UserID:      user.ID,
+		WalletID:    wallet.ID,
+		Reference:   reference,
+		Amount:      amount.Amount(),
+		Currency:    currency,
+		Description: description,
+		Provider:    provider,
+	})
+	if err != nil {
+		return err
+	}
+
+	ops := append([]db.PrismaTransaction{opAsset, opLog, opEvent}, journal...)
+
+	opTag, err := r.ruleTagOp(ctx, user.ID, reference, description, db.TransactionTypeDeposit)
+	if err != nil {
+		return err
+	}
+	if opTag != nil {
+		ops = append(ops, opTag)
+	}
+
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
 	if isUniqueConstraintError(err) {
 		return nil
 	}
@@ -285,7 +461,16 @@ func (r *walletRepository) RefundWithdrawal(ctx context.Context, transferCode st
 		db.Transaction.Type.Equals(db.TransactionTypeWithdrawal),
 	).Exec(ctx)
 
-	if err != nil || txn == nil || txn.Status == db.TransactionStatusFailed {
+	if err != nil || txn == nil {
+		return nil
+	}
+	return r.refundWithdrawal(ctx, txn)
+}
+
+// refundWithdrawal credits back a withdrawal whose amount already left the
+// balance. A withdrawal that had succeeded is recorded as REVERSED.
+func (r *walletRepository) refundWithdrawal(ctx context.Context, txn *db.TransactionModel) error {
+	if txn.Status == db.TransactionStatusFailed {
 		return nil
 	}
 
@@ -299,7 +484,36 @@ func (r *walletRepository) RefundWithdrawal(ctx context.Context, transferCode st
 	opStatus := r.client.Transaction.FindUnique(db.Transaction.ID.Equals(txn.ID)).
 		Update(db.Transaction.Status.Set(db.TransactionStatusFailed)).Tx()
 
-	return r.client.Prisma.Transaction(opRefund, opStatus).Exec(ctx)
+	refund, err := money.New(txn.Amount, txn.Currency)
+	if err != nil {
+		return err
+	}
+
+	provider, _ := txn.Provider()
+	journal, err := r.journalOps(Journal{
+		Reference:   txn.Reference + "-REFUND",
+		Description: "Refund of failed withdrawal " + txn.Reference,
+		Postings: []PostingLine{
+			systemDebit(ClearingAccount(provider), refund),
+			walletCredit(txn.WalletID, refund),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	outcome := db.WithdrawalStatusFailed
+	if txn.Status == db.TransactionStatusSuccess {
+		outcome = db.WithdrawalStatusReversed
+	}
+
+	opEvent, err := r.withdrawalEventOp(ctx, txn, outcome)
+	if err != nil {
+		return err
+	}
+
+	ops := append([]db.PrismaTransaction{opRefund, opStatus, r.withdrawalStatusOp(txn.Reference, outcome), opEvent}, journal...)
+	return r.client.Prisma.Transaction(ops...).Exec(ctx)
 }
 
 func (r *walletRepository) UpdateTransactionStatus(ctx context.Context, reference string, status db.TransactionStatus) error {
@@ -308,6 +522,19 @@ func (r *walletRepository) UpdateTransactionStatus(ctx context.Context, referenc
 	return err
 }
 
+// ensureNotFrozen refuses debits from a wallet that reconciliation (or an
+// operator) has frozen. Credits are still accepted.
+func (r *walletRepository) ensureNotFrozen(ctx context.Context, walletID string) error {
+	wallet, err := r.client.Wallet.FindUnique(db.Wallet.ID.Equals(walletID)).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if wallet.Frozen {
+		return ErrWalletFrozen
+	}
+	return nil
+}
+
 func isUniqueConstraintError(err error) bool {
 	return err != nil && (strings.Contains(err.Error(), "Unique constraint failed") || strings.Contains(err.Error(), "P2002"))
 }
@@ -2,11 +2,13 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"os"
 	"strings"
 	"testing"
 
 	"github.com/joho/godotenv"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
 	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
 )
 
@@ -54,13 +56,20 @@ func TestWalletSafetyConstraint(t *testing.T) {
 		t.Fatalf("Failed to create test wallet: %v", err)
 	}
 
-	err = repo.CreditWallet(ctx, wallet.ID, "USD", 10.0, "SEED_123", "Initial Deposit", db.TransactionTypeDeposit)
+	err = repo.CreditWallet(ctx, wallet.ID, money.FromMinor(1000, "USD"), "SEED_123", "Initial Deposit", db.TransactionTypeDeposit)
 	if err != nil {
 		t.Fatalf("Failed to seed wallet: %v", err)
 	}
 
 	t.Run("Prevent Negative Balance", func(t *testing.T) {
-		err := repo.DebitForWithdrawal(ctx, user.ID, "USD", 50.0, "FAIL_REF_001", "CODE_RED", "Illegal Withdrawal Attempt")
+		_, err := repo.CreateWithdrawal(ctx, user.ID, NewWithdrawal{
+			Amount:        money.FromMinor(5000, "USD"),
+			Reference:     "FAIL_REF_001",
+			AccountNumber: "0000000000",
+			AccountName:   "Safety Test",
+			BankCode:      "000",
+			Reason:        "Illegal Withdrawal Attempt",
+		})
 
 		if err == nil {
 			t.Errorf("SECURITY BREACH: Transaction allowed balance to go negative!")
@@ -81,4 +90,82 @@ func TestWalletSafetyConstraint(t *testing.T) {
 			t.Log("Verified: Go Repository logic blocked the transaction.")
 		}
 	})
-}
\ No newline at end of file
+}
+func TestReleaseHoldOnce(t *testing.T) {
+	client := db.NewClient()
+	if err := client.Prisma.Connect(); err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	defer client.Prisma.Disconnect()
+
+	ctx := context.Background()
+	repo := NewWalletRepository(client)
+	user, err := client.User.CreateOne(
+		db.User.Email.Set("release_test@mzl.com"),
+		db.User.Password.Set("hashed_pass"),
+		db.User.Name.Set("Release Test"),
+	).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	defer func() {
+		_, _ = client.User.FindUnique(db.User.ID.Equals(user.ID)).Delete().Exec(ctx)
+	}()
+
+	wallet, err := client.Wallet.CreateOne(
+		db.Wallet.AccountNumber.Set("TEST-ACC-456"),
+		db.Wallet.User.Link(db.User.ID.Equals(user.ID)),
+	).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create test wallet: %v", err)
+	}
+	if err := repo.CreditWallet(ctx, wallet.ID, money.FromMinor(1000, "USD"), "SEED_456", "Initial Deposit", db.TransactionTypeDeposit); err != nil {
+		t.Fatalf("Failed to seed wallet: %v", err)
+	}
+
+	// Two holds, so a double release would eat into the one left active.
+	for _, reference := range []string{"HOLD_REF_001", "HOLD_REF_002"} {
+		_, err := repo.CreateWithdrawal(ctx, user.ID, NewWithdrawal{
+			WalletID:      wallet.ID,
+			Provider:      "PAYSTACK",
+			Amount:        money.FromMinor(300, "USD"),
+			Reference:     reference,
+			AccountNumber: "0000000000",
+			AccountName:   "Release Test",
+			BankCode:      "000",
+		})
+		if err != nil {
+			t.Fatalf("Failed to hold %s: %v", reference, err)
+		}
+	}
+
+	// A webhook and the poller releasing the same hold at once.
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errs <- repo.ReleaseHold(ctx, "HOLD_REF_001") }()
+	}
+	released := 0
+	for i := 0; i < 2; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			released++
+		case !errors.Is(err, ErrHoldNotFound):
+			t.Fatalf("expected the losing release to find no active hold, got %v", err)
+		}
+	}
+	if released != 1 {
+		t.Fatalf("expected exactly one release to succeed, got %d", released)
+	}
+	if err := repo.ReleaseHold(ctx, "HOLD_REF_001"); !errors.Is(err, ErrHoldNotFound) {
+		t.Fatalf("expected a released hold to stay released, got %v", err)
+	}
+
+	asset, err := repo.GetAssetByCurrency(ctx, user.ID, wallet.ID, "USD")
+	if err != nil {
+		t.Fatalf("Failed to read asset: %v", err)
+	}
+	if !asset.HeldBalance.Equal(money.FromMinor(300, "USD").Amount()) || !asset.Balance.Equal(money.FromMinor(1000, "USD").Amount()) {
+		t.Fatalf("expected only the other hold to remain, got balance %s held %s", asset.Balance, asset.HeldBalance)
+	}
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// NewWallet is an additional wallet to open for a user.
+type NewWallet struct {
+	Name string
+	Kind db.WalletKind
+}
+
+// userWallet matches one of the user's wallets: walletID, or the default
+// wallet when walletID is empty.
+func userWallet(userID, walletID string) []db.WalletWhereParam {
+	if walletID == "" {
+		return []db.WalletWhereParam{db.Wallet.UserID.Equals(userID), db.Wallet.IsDefault.Equals(true)}
+	}
+	return []db.WalletWhereParam{db.Wallet.UserID.Equals(userID), db.Wallet.ID.Equals(walletID)}
+}
+
+// ListWallets returns the user's wallets with their assets and open vaults,
+// the default wallet first and the rest oldest first.
+func (r *walletRepository) ListWallets(ctx context.Context, userID string) ([]db.WalletModel, error) {
+	return r.client.Wallet.FindMany(
+		db.Wallet.UserID.Equals(userID),
+	).With(
+		db.Wallet.Assets.Fetch(),
+		db.Wallet.Vaults.Fetch(db.Vault.Status.Equals(db.VaultStatusActive)).OrderBy(db.Vault.CreatedAt.Order(db.SortOrderAsc)),
+	).OrderBy(
+		db.Wallet.IsDefault.Order(db.SortOrderDesc),
+		db.Wallet.CreatedAt.Order(db.SortOrderAsc),
+	).Exec(ctx)
+}
+
+// CreateWallet opens a wallet with a new account number and an empty NGN
+// asset, as sign-up does for the default wallet. A name the user already
+// has fails with a unique constraint error.
+func (r *walletRepository) CreateWallet(ctx context.Context, userID string, w NewWallet) (*db.WalletModel, error) {
+	accountNumber, err := utils.GenerateAccountNumber()
+	if err != nil {
+		return nil, errors.New("failed to create account number")
+	}
+
+	wallet, err := r.client.Wallet.CreateOne(
+		db.Wallet.AccountNumber.Set(strconv.FormatInt(accountNumber, 10)),
+		db.Wallet.User.Link(db.User.ID.Equals(userID)),
+		db.Wallet.Name.Set(w.Name),
+		db.Wallet.Kind.Set(w.Kind),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.client.WalletAsset.CreateOne(
+		db.WalletAsset.Wallet.Link(db.Wallet.ID.Equals(wallet.ID)),
+		db.WalletAsset.Currency.Set("NGN"),
+	).Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create default asset: %w", err)
+	}
+
+	return r.GetWalletWithAssets(ctx, userID, wallet.ID)
+}
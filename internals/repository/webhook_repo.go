@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+var ErrDuplicateWebhook = errors.New("webhook event already received")
+
+type NewWebhookEvent struct {
+	Provider        string
+	EventType       string
+	ProviderEventID string
+	Reference       string
+	Payload         []byte
+	SignatureValid  bool
+	// Detail is stored as the event's lastError, e.g. why it was rejected.
+	Detail string
+}
+
+type WebhookEventFilter struct {
+	Provider string
+	Status   db.WebhookEventStatus
+	Limit    int
+}
+
+type WebhookRepository interface {
+	RecordWebhookEvent(ctx context.Context, e NewWebhookEvent) (*db.WebhookEventModel, error)
+	FindWebhookEvent(ctx context.Context, provider, eventType, providerEventID string) (*db.WebhookEventModel, error)
+	GetWebhookEvent(ctx context.Context, id string) (*db.WebhookEventModel, error)
+	ListWebhookEvents(ctx context.Context, filter WebhookEventFilter) ([]db.WebhookEventModel, error)
+	StartWebhookAttempt(ctx context.Context, id string) (*db.WebhookEventModel, error)
+	FinishWebhookEvent(ctx context.Context, id string, processErr error) (*db.WebhookEventModel, error)
+}
+
+type webhookRepository struct {
+	client *db.PrismaClient
+}
+
+func NewWebhookRepository(client *db.PrismaClient) WebhookRepository {
+	return &webhookRepository{client: client}
+}
+
+// RecordWebhookEvent stores a delivery. Verified events are deduplicated on
+// (provider, eventType, providerEventID) and return ErrDuplicateWebhook when
+// seen before; rejected ones are stored without an id so they never collide.
+func (r *webhookRepository) RecordWebhookEvent(ctx context.Context, e NewWebhookEvent) (*db.WebhookEventModel, error) {
+	status := db.WebhookEventStatusReceived
+	eventID := optionalString(e.ProviderEventID)
+	if !e.SignatureValid {
+		status = db.WebhookEventStatusRejected
+		eventID = nil
+	}
+
+	event, err := r.client.WebhookEvent.CreateOne(
+		db.WebhookEvent.Provider.Set(e.Provider),
+		db.WebhookEvent.EventType.Set(e.EventType),
+		db.WebhookEvent.Payload.Set(string(e.Payload)),
+		db.WebhookEvent.SignatureValid.Set(e.SignatureValid),
+		db.WebhookEvent.ProviderEventID.SetIfPresent(eventID),
+		db.WebhookEvent.Reference.SetIfPresent(optionalString(e.Reference)),
+		db.WebhookEvent.LastError.SetIfPresent(optionalString(e.Detail)),
+		db.WebhookEvent.Status.Set(status),
+	).Exec(ctx)
+	if isUniqueConstraintError(err) {
+		return nil, ErrDuplicateWebhook
+	}
+	return event, err
+}
+
+func (r *webhookRepository) FindWebhookEvent(ctx context.Context, provider, eventType, providerEventID string) (*db.WebhookEventModel, error) {
+	return r.client.WebhookEvent.FindUnique(
+		db.WebhookEvent.ProviderEventTypeProviderEventID(
+			db.WebhookEvent.Provider.Equals(provider),
+			db.WebhookEvent.EventType.Equals(eventType),
+			db.WebhookEvent.ProviderEventID.Equals(providerEventID),
+		),
+	).Exec(ctx)
+}
+
+func (r *webhookRepository) GetWebhookEvent(ctx context.Context, id string) (*db.WebhookEventModel, error) {
+	return r.client.WebhookEvent.FindUnique(
+		db.WebhookEvent.ID.Equals(id),
+	).Exec(ctx)
+}
+
+func (r *webhookRepository) ListWebhookEvents(ctx context.Context, filter WebhookEventFilter) ([]db.WebhookEventModel, error) {
+	var where []db.WebhookEventWhereParam
+	if filter.Provider != "" {
+		where = append(where, db.WebhookEvent.Provider.Equals(filter.Provider))
+	}
+	if filter.Status != "" {
+		where = append(where, db.WebhookEvent.Status.Equals(filter.Status))
+	}
+
+	return r.client.WebhookEvent.FindMany(where...).OrderBy(
+		db.WebhookEvent.ReceivedAt.Order(db.SortOrderDesc),
+	).Take(filter.Limit).Exec(ctx)
+}
+
+// StartWebhookAttempt counts a processing attempt and returns the event.
+func (r *webhookRepository) StartWebhookAttempt(ctx context.Context, id string) (*db.WebhookEventModel, error) {
+	return r.client.WebhookEvent.FindUnique(
+		db.WebhookEvent.ID.Equals(id),
+	).Update(
+		db.WebhookEvent.Attempts.Increment(1),
+	).Exec(ctx)
+}
+
+// FinishWebhookEvent marks the event PROCESSED, or FAILED with processErr.
+func (r *webhookRepository) FinishWebhookEvent(ctx context.Context, id string, processErr error) (*db.WebhookEventModel, error) {
+	if processErr != nil {
+		return r.client.WebhookEvent.FindUnique(
+			db.WebhookEvent.ID.Equals(id),
+		).Update(
+			db.WebhookEvent.Status.Set(db.WebhookEventStatusFailed),
+			db.WebhookEvent.LastError.Set(processErr.Error()),
+		).Exec(ctx)
+	}
+
+	return r.client.WebhookEvent.FindUnique(
+		db.WebhookEvent.ID.Equals(id),
+	).Update(
+		db.WebhookEvent.Status.Set(db.WebhookEventStatusProcessed),
+		db.WebhookEvent.ProcessedAt.Set(time.Now()),
+	).Exec(ctx)
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+var ErrWithdrawalSettled = errors.New("withdrawal has already settled")
+
+// withdrawalTransitions lists where a withdrawal may go from each status.
+// PROCESSING may repeat while the payout call is retried, and a SUCCESS can
+// still be REVERSED when the bank sends the money back.
+var withdrawalTransitions = map[db.WithdrawalStatus][]db.WithdrawalStatus{
+	db.WithdrawalStatusPending:    {db.WithdrawalStatusProcessing, db.WithdrawalStatusFailed},
+	db.WithdrawalStatusProcessing: {db.WithdrawalStatusProcessing, db.WithdrawalStatusSuccess, db.WithdrawalStatusFailed, db.WithdrawalStatusReversed},
+	db.WithdrawalStatusSuccess:    {db.WithdrawalStatusReversed},
+}
+
+// CanTransitionWithdrawal reports whether a withdrawal in status from may move to status to.
+func CanTransitionWithdrawal(from, to db.WithdrawalStatus) bool {
+	for _, next := range withdrawalTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// withdrawalSources returns every status a withdrawal may reach to from.
+func withdrawalSources(to db.WithdrawalStatus) []db.WithdrawalStatus {
+	var sources []db.WithdrawalStatus
+	for from := range withdrawalTransitions {
+		if CanTransitionWithdrawal(from, to) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
+
+// withdrawalStatusOp moves the withdrawal to status to, leaving it untouched
+// when the state machine doesn't allow it (or the withdrawal predates this table).
+func (r *walletRepository) withdrawalStatusOp(reference string, to db.WithdrawalStatus) db.PrismaTransaction {
+	return r.client.Withdrawal.FindMany(
+		db.Withdrawal.Reference.Equals(reference),
+		db.Withdrawal.Status.In(withdrawalSources(to)),
+	).Update(
+		db.Withdrawal.Status.Set(to),
+	).Tx()
+}
+
+// withdrawalEventOp records the withdrawal.* event for txn settling in status.
+func (r *walletRepository) withdrawalEventOp(ctx context.Context, txn *db.TransactionModel, status db.WithdrawalStatus) (db.PrismaTransaction, error) {
+	owner, err := r.walletOwner(ctx, txn.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	provider, _ := txn.Provider()
+	description, _ := txn.Description()
+	return r.outboxOp(withdrawalEvent(status), WalletEvent{
+		UserID:      owner,
+		WalletID:    txn.WalletID,
+		Reference:   txn.Reference,
+		Amount:      txn.Amount,
+		Currency:    txn.Currency,
+		Description: description,
+		Provider:    provider,
+	})
+}
+
+type NewWithdrawal struct {
+	WalletID      string // the user's default wallet when empty
+	Provider      string
+	Amount        money.Money
+	Reference     string
+	AccountNumber string
+	AccountName   string
+	BankCode      string
+	Reason        string
+}
+
+// CreateWithdrawal holds the amount on the user's wallet and records the
+// PENDING withdrawal with its PENDING transaction. Nothing is sent to the
+// gateway here; the balance is only debited once the transfer succeeds.
+func (r *walletRepository) CreateWithdrawal(ctx context.Context, userID string, w NewWithdrawal) (*db.WithdrawalModel, error) {
+	currency := w.Amount.Currency()
+	asset, err := r.GetAssetByCurrency(ctx, userID, w.WalletID, currency)
+	if err != nil || asset == nil || AvailableBalance(asset).LessThan(w.Amount.Amount()) {
+		return nil, fmt.Errorf("insufficient funds")
+	}
+
+	if err := r.ensureNotFrozen(ctx, asset.WalletID); err != nil {
+		return nil, err
+	}
+
+	opLog := r.client.Transaction.CreateOne(
+		db.Transaction.Wallet.Link(db.Wallet.ID.Equals(asset.WalletID)),
+		db.Transaction.Amount.Set(w.Amount.Amount()),
+		db.Transaction.Currency.Set(currency),
+		db.Transaction.Type.Set(db.TransactionTypeWithdrawal),
+		db.Transaction.Direction.Set(db.TransactionDirectionDebit),
+		db.Transaction.Reference.Set(w.Reference),
+		db.Transaction.Status.Set(db.TransactionStatusPending),
+		db.Transaction.Provider.Set(w.Provider),
+		db.Transaction.Description.Set(w.Reason),
+	).Tx()
+
+	opWithdrawal := r.client.Withdrawal.CreateOne(
+		db.Withdrawal.Wallet.Link(db.Wallet.ID.Equals(asset.WalletID)),
+		db.Withdrawal.Reference.Set(w.Reference),
+		db.Withdrawal.Amount.Set(w.Amount.Amount()),
+		db.Withdrawal.Currency.Set(currency),
+		db.Withdrawal.AccountNumber.Set(w.AccountNumber),
+		db.Withdrawal.AccountName.Set(w.AccountName),
+		db.Withdrawal.BankCode.Set(w.BankCode),
+		db.Withdrawal.Reason.Set(w.Reason),
+		db.Withdrawal.Provider.Set(w.Provider),
+	).Tx()
+
+	owner, err := r.walletOwner(ctx, asset.WalletID)
+	if err != nil {
+		return nil, err
+	}
+	opEvent, err := r.outboxOp(EventWithdrawalRequested, WalletEvent{
+		UserID:      owner,
+		WalletID:    asset.WalletID,
+		Reference:   w.Reference,
+		Amount:      w.Amount.Amount(),
+		Currency:    currency,
+		Description: w.Reason,
+		Provider:    w.Provider,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ops := append(r.holdOps(asset, w.Amount, db.HoldReasonWithdrawal, w.Reference, w.Reason), opLog, opWithdrawal, opEvent)
+	err = r.client.Prisma.Transaction(ops...).Exec(ctx)
+	if isBalanceConstraintError(err) {
+		return nil, fmt.Errorf("insufficient funds (race condition)")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return opWithdrawal.Result(), nil
+}
+
+func (r *walletRepository) GetWithdrawal(ctx context.Context, reference string) (*db.WithdrawalModel, error) {
+	return r.client.Withdrawal.FindUnique(
+		db.Withdrawal.Reference.Equals(reference),
+	).With(
+		db.Withdrawal.Wallet.Fetch(),
+	).Exec(ctx)
+}
+
+// StartWithdrawalAttempt moves the withdrawal to PROCESSING and counts the
+// attempt. It returns ErrWithdrawalSettled once the withdrawal has finished.
+func (r *walletRepository) StartWithdrawalAttempt(ctx context.Context, reference string) (*db.WithdrawalModel, error) {
+	result, err := r.client.Withdrawal.FindMany(
+		db.Withdrawal.Reference.Equals(reference),
+		db.Withdrawal.Status.In(withdrawalSources(db.WithdrawalStatusProcessing)),
+	).Update(
+		db.Withdrawal.Status.Set(db.WithdrawalStatusProcessing),
+		db.Withdrawal.Attempts.Increment(1),
+	).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.Count == 0 {
+		return nil, ErrWithdrawalSettled
+	}
+	return r.GetWithdrawal(ctx, reference)
+}
+
+func (r *walletRepository) SetWithdrawalRecipient(ctx context.Context, reference, recipientCode string) error {
+	_, err := r.client.Withdrawal.FindUnique(
+		db.Withdrawal.Reference.Equals(reference),
+	).Update(
+		db.Withdrawal.RecipientCode.Set(recipientCode),
+	).Exec(ctx)
+	return err
+}
+
+// SetWithdrawalTransfer stores the gateway's transfer code on the withdrawal
+// and on its transaction, where the transfer.* webhooks look it up.
+func (r *walletRepository) SetWithdrawalTransfer(ctx context.Context, reference, transferCode string) error {
+	return r.client.Prisma.Transaction(
+		r.client.Withdrawal.FindUnique(db.Withdrawal.Reference.Equals(reference)).
+			Update(db.Withdrawal.TransferCode.Set(transferCode)).Tx(),
+		r.client.Transaction.FindUnique(db.Transaction.Reference.Equals(reference)).
+			Update(db.Transaction.GatewayRef.Set(transferCode)).Tx(),
+	).Exec(ctx)
+}
+
+func (r *walletRepository) RecordWithdrawalError(ctx context.Context, reference, message string) error {
+	_, err := r.client.Withdrawal.FindUnique(
+		db.Withdrawal.Reference.Equals(reference),
+	).Update(
+		db.Withdrawal.LastError.Set(message),
+	).Exec(ctx)
+	return err
+}
+
+// ListStuckWithdrawals returns unfinished withdrawals not touched since
+// before, each with its wallet.
+func (r *walletRepository) ListStuckWithdrawals(ctx context.Context, before time.Time, limit int) ([]db.WithdrawalModel, error) {
+	return r.client.Withdrawal.FindMany(
+		db.Withdrawal.Status.In([]db.WithdrawalStatus{db.WithdrawalStatusPending, db.WithdrawalStatusProcessing}),
+		db.Withdrawal.UpdatedAt.Before(before),
+	).With(
+		db.Withdrawal.Wallet.Fetch(),
+	).OrderBy(
+		db.Withdrawal.UpdatedAt.Order(db.SortOrderAsc),
+	).Take(limit).Exec(ctx)
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+func (s *Server) ListCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	categories, err := s.CategoryService.Categories(r.Context(), userID)
+	if err != nil {
+		s.Logger.Error("failed to list categories", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "categories retrieved",
+		"data":    categories,
+	})
+}
+
+// CreateCategoryHandler takes {"name", "color"}; color is optional.
+func (s *Server) CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	var req struct {
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	category, err := s.CategoryService.CreateCategory(r.Context(), userID, req.Name, req.Color)
+	switch {
+	case errors.Is(err, service.ErrInvalidCategory):
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, service.ErrCategoryExists):
+		utils.ErrorJSON(w, r, http.StatusConflict, err)
+		return
+	case err != nil:
+		s.Logger.Error("failed to create category", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusCreated, map[string]interface{}{
+		"status":  "success",
+		"message": "category created",
+		"data":    category,
+	})
+}
+
+func (s *Server) DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	err := s.CategoryService.DeleteCategory(r.Context(), userID, chi.URLParam(r, "id"))
+	if errors.Is(err, service.ErrCategoryNotFound) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to delete category", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "category deleted",
+	})
+}
+
+func (s *Server) ListCategoryRulesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	rules, err := s.CategoryService.Rules(r.Context(), userID)
+	if err != nil {
+		s.Logger.Error("failed to list category rules", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "category rules retrieved",
+		"data":    rules,
+	})
+}
+
+// CreateCategoryRuleHandler takes {"category_id", "description_contains",
+// "type", "priority"}; type and priority are optional. Lower priorities are
+// tried first.
+func (s *Server) CreateCategoryRuleHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	var req struct {
+		CategoryID          string `json:"category_id"`
+		DescriptionContains string `json:"description_contains"`
+		Type                string `json:"type"`
+		Priority            int    `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	rule, err := s.CategoryService.CreateRule(r.Context(), userID, repository.NewCategoryRule{
+		CategoryID:          req.CategoryID,
+		DescriptionContains: req.DescriptionContains,
+		Type:                db.TransactionType(strings.ToUpper(req.Type)),
+		Priority:            req.Priority,
+	})
+	switch {
+	case errors.Is(err, service.ErrInvalidCategoryRule):
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, service.ErrCategoryNotFound):
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	case err != nil:
+		s.Logger.Error("failed to create category rule", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusCreated, map[string]interface{}{
+		"status":  "success",
+		"message": "category rule created",
+		"data":    rule,
+	})
+}
+
+func (s *Server) DeleteCategoryRuleHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	err := s.CategoryService.DeleteRule(r.Context(), userID, chi.URLParam(r, "id"))
+	if errors.Is(err, service.ErrCategoryRuleNotFound) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to delete category rule", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "category rule deleted",
+	})
+}
+
+// TagTransactionHandler takes {"category_id", "note"}, at least one of them,
+// for the transaction with the reference shown in the user's history.
+func (s *Server) TagTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	var req service.TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	tag, err := s.CategoryService.Tag(r.Context(), userID, chi.URLParam(r, "reference"), req)
+	switch {
+	case errors.Is(err, service.ErrInvalidTag):
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, service.ErrTaggedTxnNotFound), errors.Is(err, service.ErrCategoryNotFound):
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	case err != nil:
+		s.Logger.Error("failed to tag transaction", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "transaction tagged",
+		"data":    tag,
+	})
+}
+
+func (s *Server) UntagTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	err := s.CategoryService.Untag(r.Context(), userID, chi.URLParam(r, "reference"))
+	if errors.Is(err, service.ErrTaggedTxnNotFound) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to untag transaction", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "transaction tag removed",
+	})
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+)
+
+// adminActor names whoever is calling the admin API, for the audit log.
+// mzlctl sends the operator's user name.
+func adminActor(r *http.Request) string {
+	if actor := r.Header.Get("X-Admin-Actor"); actor != "" {
+		return actor
+	}
+	return "admin"
+}
+
+// DeadLetterDepthsHandler reports how many jobs sit in each dead-letter
+// queue. Alerting polls this.
+func (s *Server) DeadLetterDepthsHandler(w http.ResponseWriter, r *http.Request) {
+	depths, err := s.DeadLetterService.Depths(r.Context())
+	if err != nil {
+		s.Logger.Error("failed to read dead-letter depths", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "dead-letter depths retrieved",
+		"data":    depths,
+	})
+}
+
+// JobStatsHandler reports per job type counts of processed, failed, retried
+// and dead-lettered jobs since this process started.
+func (s *Server) JobStatsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "job stats retrieved",
+		"data":    s.RedisSvc.JobStats(),
+	})
+}
+
+func (s *Server) ListDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	jobs, err := s.DeadLetterService.List(r.Context(), chi.URLParam(r, "queue"), offset, limit)
+	if errors.Is(err, service.ErrUnknownQueue) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to list dead letters", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "dead letters retrieved",
+		"data":    jobs,
+	})
+}
+
+// RequeueDeadLettersHandler puts {"ids": [...]} or {"all": true} back on the queue.
+func (s *Server) RequeueDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	s.actOnDeadLetters(w, r, s.DeadLetterService.Requeue, "dead letters requeued")
+}
+
+// PurgeDeadLettersHandler deletes {"ids": [...]} or {"all": true}.
+func (s *Server) PurgeDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	s.actOnDeadLetters(w, r, s.DeadLetterService.Purge, "dead letters purged")
+}
+
+func (s *Server) actOnDeadLetters(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, actor, queueName string, sel service.DeadLetterSelection) (int, error), message string) {
+	var sel service.DeadLetterSelection
+	if err := json.NewDecoder(r.Body).Decode(&sel); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	count, err := fn(r.Context(), adminActor(r), chi.URLParam(r, "queue"), sel)
+	switch {
+	case errors.Is(err, service.ErrUnknownQueue):
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	case errors.Is(err, service.ErrNothingSelected), errors.Is(err, service.ErrAmbiguousRequest):
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		s.Logger.Error("dead-letter action failed", "queue", chi.URLParam(r, "queue"), "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": message,
+		"data":    map[string]int{"count": count},
+	})
+}
+
+func (s *Server) ListAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	entries, err := s.DeadLetterService.AuditLog(r.Context(), limit)
+	if err != nil {
+		s.Logger.Error("failed to list audit log", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "audit log retrieved",
+		"data":    entries,
+	})
+}
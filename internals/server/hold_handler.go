@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+)
+
+// CaptureHoldHandler takes the held funds: it settles a withdrawal, pays a
+// lost dispute back or approves a transfer held for review.
+func (s *Server) CaptureHoldHandler(w http.ResponseWriter, r *http.Request) {
+	s.resolveHold(w, r, s.WalletService.CaptureHold, "hold captured")
+}
+
+// ReleaseHoldHandler frees the held funds: it fails a withdrawal, closes a
+// won dispute or rejects a transfer held for review.
+func (s *Server) ReleaseHoldHandler(w http.ResponseWriter, r *http.Request) {
+	s.resolveHold(w, r, s.WalletService.ReleaseHold, "hold released")
+}
+
+func (s *Server) resolveHold(w http.ResponseWriter, r *http.Request, resolve func(context.Context, string) error, message string) {
+	reference := chi.URLParam(r, "reference")
+	if err := resolve(r.Context(), reference); err != nil {
+		if errors.Is(err, service.ErrHoldNotFound) {
+			utils.ErrorJSON(w, r, http.StatusNotFound, err)
+			return
+		}
+		s.Logger.Error("failed to resolve hold", "reference", reference, "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": message,
+		"data":    map[string]string{"reference": reference},
+	})
+}
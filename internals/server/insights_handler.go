@@ -0,0 +1,33 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+)
+
+// InsightsHandler summarises the user's last ?months= months (default 6, at
+// most 24), optionally for one ?currency=.
+func (s *Server) InsightsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	months, _ := strconv.Atoi(r.URL.Query().Get("months"))
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+
+	insights, err := s.InsightsService.Insights(r.Context(), userID, months, currency)
+	if err != nil {
+		s.Logger.Error("failed to build insights", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "insights retrieved",
+		"data":    insights,
+	})
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+)
+
+func (s *Server) InterestRatesHandler(w http.ResponseWriter, r *http.Request) {
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "interest rates retrieved",
+		"data":    s.InterestService.Rates(),
+	})
+}
+
+// PreviewInterestHandler shows what paying ?month=YYYY-MM (default: this
+// month) would pay each account. Nothing is paid.
+func (s *Server) PreviewInterestHandler(w http.ResponseWriter, r *http.Request) {
+	month, ok := interestMonth(w, r, time.Now())
+	if !ok {
+		return
+	}
+
+	payout, err := s.InterestService.Preview(r.Context(), month)
+	if errors.Is(err, service.ErrInterestMonth) {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to preview interest", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "interest preview",
+		"data":    payout,
+	})
+}
+
+// PayInterestHandler pays ?month=YYYY-MM (default: last month). Accounts
+// already paid for the month are left alone.
+func (s *Server) PayInterestHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	month, ok := interestMonth(w, r, time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC))
+	if !ok {
+		return
+	}
+
+	payout, err := s.InterestService.Pay(r.Context(), month)
+	switch {
+	case errors.Is(err, service.ErrInterestMonth):
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, service.ErrInterestRunning):
+		utils.ErrorJSON(w, r, http.StatusConflict, err)
+		return
+	case err != nil:
+		s.Logger.Error("failed to pay interest", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "interest paid",
+		"data":    payout,
+	})
+}
+
+func interestMonth(w http.ResponseWriter, r *http.Request, fallback time.Time) (time.Time, bool) {
+	v := r.URL.Query().Get("month")
+	if v == "" {
+		return fallback, true
+	}
+	month, err := time.Parse("2006-01", v)
+	if err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("month must be YYYY-MM"))
+		return time.Time{}, false
+	}
+	return month, true
+}
@@ -13,4 +13,20 @@ func (s *Server) AddMiddlewaresToHandler(h http.Handler, middlewares ...func(htt
 	}
 
 	return handler
-}
\ No newline at end of file
+}
+
+// exceptStreams applies mw to every request but the wallet stream, which
+// stays open far longer than a request should. It goes by the route rather
+// than the Accept header, which clients may omit or send with other types.
+func exceptStreams(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == walletStreamPath {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
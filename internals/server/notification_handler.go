@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+)
+
+// ListNotificationsHandler returns the user's inbox, newest first, paged
+// with ?offset= and ?limit=.
+func (s *Server) ListNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	notifications, err := s.NotificationService.Inbox(r.Context(), userID, offset, limit)
+	if err != nil {
+		s.Logger.Error("failed to list notifications", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "notifications retrieved",
+		"data":    notifications,
+	})
+}
+
+func (s *Server) UnreadNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	count, err := s.NotificationService.UnreadCount(r.Context(), userID)
+	if err != nil {
+		s.Logger.Error("failed to count unread notifications", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "unread count retrieved",
+		"data":    map[string]int{"unread": count},
+	})
+}
+
+func (s *Server) MarkNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	err := s.NotificationService.MarkRead(r.Context(), userID, chi.URLParam(r, "id"))
+	if errors.Is(err, service.ErrNotificationNotFound) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to mark notification read", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]string{
+		"status":  "success",
+		"message": "notification marked as read",
+	})
+}
+
+func (s *Server) MarkAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	marked, err := s.NotificationService.MarkAllRead(r.Context(), userID)
+	if err != nil {
+		s.Logger.Error("failed to mark notifications read", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "notifications marked as read",
+		"data":    map[string]int{"marked": marked},
+	})
+}
+
+func (s *Server) GetNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	prefs, err := s.NotificationService.Preferences(r.Context(), userID)
+	if err != nil {
+		s.Logger.Error("failed to load notification preferences", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "notification preferences retrieved",
+		"data":    prefs,
+	})
+}
+
+// UpdateNotificationPreferencesHandler takes {"<event>": {"<channel>": bool}}
+// for the pairs to change and returns the full set.
+func (s *Server) UpdateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	var req map[string]map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+
+	err := s.NotificationService.UpdatePreferences(r.Context(), userID, req)
+	if errors.Is(err, service.ErrUnknownNotificationPreference) {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to update notification preferences", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	s.GetNotificationPreferencesHandler(w, r)
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+)
+
+// ListParkedOutboxHandler lists the outbox events the relay gave up on,
+// oldest first, with the error that parked each of them.
+func (s *Server) ListParkedOutboxHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	events, err := s.OutboxRelay.ListParked(r.Context(), limit)
+	if err != nil {
+		s.Logger.Error("failed to list parked outbox events", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "parked outbox events retrieved",
+		"data":    events,
+	})
+}
+
+// RequeueOutboxEventHandler hands a parked event back to the relay, which
+// publishes it on its next run.
+func (s *Server) RequeueOutboxEventHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	err := s.OutboxRelay.Requeue(r.Context(), id)
+	if errors.Is(err, service.ErrOutboxEventNotParked) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to requeue outbox event", "event", id, "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+	s.Logger.Info("outbox event requeued", "event", id, "actor", adminActor(r))
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "outbox event requeued",
+		"data":    map[string]string{"id": id},
+	})
+}
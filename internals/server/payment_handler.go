@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"time"
+	"strings"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
 	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
 	"github.com/theabdullahishola/mzl-payment-app/internals/service"
 	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
 )
@@ -15,8 +18,13 @@ import (
 func (s *Server) GetBanksDetailHandler(w http.ResponseWriter, r *http.Request) {
   
     currency := r.URL.Query().Get("currency")
+    provider := r.URL.Query().Get("provider")
     
-    banks, err := s.PaymentService.GetBankList(r.Context(),currency)
+    banks, err := s.PaymentService.GetBankList(r.Context(), provider, currency)
+    if errors.Is(err, gateway.ErrUnknownProvider) {
+        utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+        return
+    }
     if err != nil {
         utils.ErrorJSON(w, r, http.StatusBadGateway, err)
         return
@@ -33,6 +41,7 @@ func (s *Server) GetBanksDetailHandler(w http.ResponseWriter, r *http.Request) {
 type ResolveAccountRequest struct {
     AccountNumber string `json:"account_number"`
     BankCode      string `json:"bank_code"`
+    Provider      string `json:"provider"`
 }
 
 func (s *Server) ResolveAccountDetailsHandler(w http.ResponseWriter, r *http.Request) {
@@ -42,7 +51,7 @@ func (s *Server) ResolveAccountDetailsHandler(w http.ResponseWriter, r *http.Req
         return
     }
 
-    accountName, err := s.PaymentService.ResolveBankAccount(req.AccountNumber, req.BankCode)
+    accountName, err := s.PaymentService.ResolveBankAccount(r.Context(), req.Provider, req.AccountNumber, req.BankCode)
     if err != nil {
         utils.ErrorJSON(w, r, http.StatusBadRequest, err)
         return
@@ -59,54 +68,80 @@ func (s *Server) ResolveAccountDetailsHandler(w http.ResponseWriter, r *http.Req
 
 type WithdrawalPayload service.WithdrawalRequest
 
+// WithdrawalHandler holds the funds and queues the payout. The transfer itself
+// runs in the background, so the withdrawal comes back PENDING.
 func (s *Server) WithdrawalHandler(w http.ResponseWriter, r *http.Request) {
-   
+    idempotencyKey := r.Header.Get("Idempotency-Key")
+    if idempotencyKey == "" {
+        utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("missing Idempotency-Key header"))
+        return
+    }
+
     var req WithdrawalPayload
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request"))
         return
     }
 
-
     userID := r.Context().Value(middlewares.UserIDKey).(string)
+    logger := s.Logger.With("idemp_key", idempotencyKey, "user_id", userID)
 
-  
     if err := s.AuthService.VerifyTransactionPin(r.Context(), userID, req.Pin); err != nil {
         utils.ErrorJSON(w, r, http.StatusUnauthorized, errors.New("incorrect transaction pin"))
         return
     }
-    recipientCode, err := s.PaymentService.CreateTransferRecipient(req.AccountName, req.AccountNumber, req.BankCode, req.Currency)
-    if err != nil {
-        utils.ErrorJSON(w, r, http.StatusBadGateway, fmt.Errorf("recipient creation failed: %w", err))
+    amount, err := money.New(req.Amount, req.Currency)
+    if err != nil || !amount.IsPositive() {
+        utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("valid currency and amount greater than 0 are required"))
+        return
+    }
+    if req.AccountNumber == "" || req.AccountName == "" || req.BankCode == "" {
+        utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("account number, account name and bank code are required"))
         return
     }
 
- 
-    reference := fmt.Sprintf("WDR-%d", time.Now().UnixNano())
-    
-    paystackResp, err := s.PaymentService.InitiateTransfer(req.Amount, recipientCode, reference, req.Reason)
+    withdrawal, err := s.WithdrawalService.RequestWithdrawal(r.Context(), userID, idempotencyKey, service.WithdrawalRequest(req))
     if err != nil {
-        utils.ErrorJSON(w, r, http.StatusBadGateway, fmt.Errorf("transfer failed: %w", err))
+        switch {
+        case errors.Is(err, service.ErrInvalidWithdrawalReference), errors.Is(err, gateway.ErrUnknownProvider):
+            utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+        case errors.Is(err, service.ErrTransactionAlreadyProcessed):
+            utils.ErrorJSON(w, r, http.StatusConflict, errors.New("Idempotency-Key was already used for a different withdrawal"))
+        case errors.Is(err, service.ErrWalletFrozen):
+            utils.ErrorJSON(w, r, http.StatusForbidden, err)
+        case strings.HasPrefix(err.Error(), "insufficient funds"):
+            utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+        default:
+            logger.Error("withdrawal request failed", "error", err)
+            utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("system error processing withdrawal"))
+        }
         return
     }
 
-    err = s.WalletService.WithdrawFunds(
-        r.Context(), 
-        userID, 
-        service.WithdrawalRequest(req),
-    )
+    utils.JSON(w, r, http.StatusAccepted, map[string]interface{}{
+        "status":  "success",
+        "message": "Withdrawal processing",
+        "data":    withdrawal,
+    })
+}
+
+func (s *Server) GetWithdrawalHandler(w http.ResponseWriter, r *http.Request) {
+    userID := r.Context().Value(middlewares.UserIDKey).(string)
 
+    withdrawal, err := s.WithdrawalService.GetWithdrawal(r.Context(), userID, chi.URLParam(r, "reference"))
+    if errors.Is(err, service.ErrWithdrawalNotFound) {
+        utils.ErrorJSON(w, r, http.StatusNotFound, err)
+        return
+    }
     if err != nil {
-        s.Logger.Error("CRITICAL: Paystack transfer success but DB debit failed", "ref", reference, "error", err)
-        utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("system error processing withdrawal"))
+        utils.ErrorJSON(w, r, http.StatusInternalServerError, err)
         return
     }
 
-
-    utils.JSON(w, r,http.StatusOK, map[string]interface{}{
-        "status": "success",
-        "message": "Withdrawal processing",
-        "data": paystackResp.Data,
+    utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+        "status":  "success",
+        "message": "withdrawal retrieved successfully",
+        "data":    withdrawal,
     })
 }
 
@@ -117,7 +152,9 @@ func (s *Server) VerifyPaymentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := s.PaymentService.VerifyAndCredit(r.Context(), reference)
+	provider := r.URL.Query().Get("provider")
+
+	result, err := s.PaymentService.VerifyAndCredit(r.Context(), provider, reference)
 	if err != nil {
 		s.Logger.Error("verification failed", "ref", reference, "error", err)
 		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
@@ -5,15 +5,20 @@ import (
 	"errors"
 	"io"
 	"net/http"
-	"time"
+	"strings"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
 	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
 	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
 )
 
 type InitiatePaymentRequest struct {
-	Amount   float64 `json:"amount"`
-	Currency string  `json:"currency"`
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
+	Provider string          `json:"provider"` // optional, the default gateway otherwise
 }
 
 func (s *Server) InitiatePaymentHandler(w http.ResponseWriter, r *http.Request) {
@@ -32,7 +37,17 @@ func (s *Server) InitiatePaymentHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	data, err := s.PaymentService.InitializeTransaction(user.Email, req.Currency, req.Amount)
+	amount, err := money.New(req.Amount, req.Currency)
+	if err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if !amount.IsPositive() {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("amount must be greater than zero"))
+		return
+	}
+
+	charge, err := s.PaymentService.InitializeTransaction(r.Context(), req.Provider, user.Email, amount)
 	if err != nil {
 		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
 		return
@@ -41,57 +56,52 @@ func (s *Server) InitiatePaymentHandler(w http.ResponseWriter, r *http.Request)
 	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
 		"status": "success",
 		"data": map[string]string{
-			"authorization_url": data.Data.AuthorizationURL,
-			"reference":         data.Data.Reference,
+			"authorization_url": charge.AuthorizationURL,
+			"reference":         charge.Reference,
 		},
 	})
 }
 
-func (s *Server) PaystackWebhookHandler(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		s.Logger.Error("failed to read webhook body", "error", err)
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
+// maxWebhookBody caps what a webhook delivery may send; real events are a
+// few kilobytes.
+const maxWebhookBody = 1 << 20
 
-	signature := r.Header.Get("x-paystack-signature")
-	if signature == "" {
-		s.Logger.Warn("webhook missing signature header")
-		w.WriteHeader(http.StatusUnauthorized)
+// WebhookHandler accepts webhooks for any registered gateway at
+// /api/v1/webhooks/{provider}. Every delivery is stored; verified ones are
+// queued for processing and repeats of a processed event are acknowledged.
+func (s *Server) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	provider := strings.ToUpper(chi.URLParam(r, "provider"))
+	if _, err := s.Gateways.Get(provider); err != nil {
+		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	if !s.PaymentService.VerifyPaystackSignature(body, signature) {
-		s.Logger.Warn("invalid paystack signature attempt")
-		w.WriteHeader(http.StatusUnauthorized)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.Logger.Warn("webhook body too large", "provider", provider, "limit", tooLarge.Limit)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
 		return
 	}
-
-	var event struct {
-		Data struct {
-			Reference string `json:"reference"`
-		} `json:"data"`
-	}
-	if err := json.Unmarshal(body, &event); err == nil && event.Data.Reference != "" {
-		locked, _ := s.RedisSvc.TryLockIdempotencyKey(r.Context(), event.Data.Reference, 24*time.Hour)
-		if !locked {
-			s.Logger.Info("ignoring duplicate webhook", "ref", event.Data.Reference)
-			w.WriteHeader(http.StatusOK) 
-			return
-		}
-	}
-
-	err = s.RedisSvc.Enqueue(r.Context(), "payment_webhooks", body)
 	if err != nil {
-		if event.Data.Reference != "" {
-			_ = s.RedisSvc.Delete(r.Context(), "idemp:"+event.Data.Reference)
-		}
-		s.Logger.Error("failed to queue webhook", "error", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.Logger.Error("failed to read webhook body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	defer r.Body.Close()
 
-	w.WriteHeader(http.StatusOK)
+	event, err := s.WebhookService.Receive(r.Context(), provider, r.Header, body)
+	switch {
+	case errors.Is(err, service.ErrInvalidWebhookSignature):
+		s.Logger.Warn("invalid webhook signature attempt", "provider", provider, "event", event.ID)
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, service.ErrUnreadableWebhook):
+		s.Logger.Warn("unreadable webhook", "provider", provider, "event", event.ID, "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+	case err != nil:
+		s.Logger.Error("failed to accept webhook", "provider", provider, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
 }
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+)
+
+// ReceiptHandler returns the receipt for one of the user's transactions as
+// ?format=json (default), html or pdf.
+func (s *Server) ReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	receipt, err := s.ReceiptService.Receipt(r.Context(), userID, chi.URLParam(r, "reference"))
+	if errors.Is(err, service.ErrReceiptNotFound) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to load receipt", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	switch format := strings.ToLower(r.URL.Query().Get("format")); format {
+	case "", "json":
+		utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"message": "receipt retrieved",
+			"data":    receipt,
+		})
+
+	case "html":
+		page, err := service.RenderReceiptHTML(receipt)
+		if err != nil {
+			s.Logger.Error("failed to render receipt", "error", err)
+			utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(page)
+
+	case "pdf":
+		doc := service.RenderReceiptPDF(receipt)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "receipt-"+receipt.Reference+".pdf"))
+		w.WriteHeader(http.StatusOK)
+		w.Write(doc)
+
+	default:
+		utils.ErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("unknown format %q, want json, html or pdf", format))
+	}
+}
+
+// receiptURL is where the payment made with reference has its receipt.
+func receiptURL(reference string) string {
+	return "/api/v1/transactions/" + url.PathEscape(reference) + "/receipt"
+}
+
+// VerifyReceiptHandler lets anyone holding a receipt check it with its
+// ?reference= and ?code=. It needs no login; a genuine receipt comes back
+// with the transaction's current status.
+func (s *Server) VerifyReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("reference") == "" || q.Get("code") == "" {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("reference and code are required"))
+		return
+	}
+
+	receipt, err := s.ReceiptService.Verify(r.Context(), q.Get("reference"), q.Get("code"))
+	if errors.Is(err, service.ErrInvalidReceiptCode) {
+		utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"message": err.Error(),
+			"data":    map[string]interface{}{"valid": false},
+		})
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to verify receipt", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "receipt is genuine",
+		"data":    map[string]interface{}{"valid": true, "receipt": receipt},
+	})
+}
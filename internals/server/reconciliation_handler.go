@@ -0,0 +1,172 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// RunBalanceReconciliationHandler runs the ledger-vs-balance check now.
+// ?freeze=true|false overrides RECONCILIATION_FREEZE for this run.
+func (s *Server) RunBalanceReconciliationHandler(w http.ResponseWriter, r *http.Request) {
+	freeze := s.Config.ReconciliationFreeze
+	if v := r.URL.Query().Get("freeze"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("freeze must be true or false"))
+			return
+		}
+		freeze = parsed
+	}
+
+	run, err := s.ReconciliationService.RunBalanceReconciliation(r.Context(), service.TriggerManual, freeze)
+	if err != nil {
+		if errors.Is(err, service.ErrReconciliationRunning) {
+			utils.ErrorJSON(w, r, http.StatusConflict, err)
+			return
+		}
+		s.Logger.Error("manual reconciliation failed", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("reconciliation failed"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "reconciliation completed",
+		"data":    run,
+	})
+}
+
+func (s *Server) ListBalanceReconciliationsHandler(w http.ResponseWriter, r *http.Request) {
+	s.listReconciliationRuns(w, r, db.ReconciliationKindBalance)
+}
+
+// RunSettlementReconciliationHandler matches Paystack against our transactions
+// for ?from=&to= (RFC 3339 or YYYY-MM-DD; defaults to the configured lookback).
+// ?fix=true|false overrides SETTLEMENT_AUTO_FIX for this run.
+func (s *Server) RunSettlementReconciliationHandler(w http.ResponseWriter, r *http.Request) {
+	to := time.Now()
+	from := to.Add(-s.Config.SettlementLookback)
+
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = parseWindowTime(v); err != nil {
+			utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = parseWindowTime(v); err != nil {
+			utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	autoFix := s.Config.SettlementAutoFix
+	if v := r.URL.Query().Get("fix"); v != "" {
+		if autoFix, err = strconv.ParseBool(v); err != nil {
+			utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("fix must be true or false"))
+			return
+		}
+	}
+
+	run, err := s.ReconciliationService.RunSettlementReconciliation(r.Context(), service.TriggerManual, from, to, autoFix)
+	if err != nil {
+		if errors.Is(err, service.ErrReconciliationRunning) {
+			utils.ErrorJSON(w, r, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, service.ErrSettlementWindowTooLarge) {
+			utils.ErrorJSON(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+		s.Logger.Error("manual settlement reconciliation failed", "error", err)
+		utils.ErrorJSON(w, r, http.StatusBadGateway, fmt.Errorf("settlement reconciliation failed: %w", err))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "settlement reconciliation completed",
+		"data":    run,
+	})
+}
+
+func (s *Server) ListSettlementReconciliationsHandler(w http.ResponseWriter, r *http.Request) {
+	s.listReconciliationRuns(w, r, db.ReconciliationKindSettlement)
+}
+
+func parseWindowTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or YYYY-MM-DD", v)
+}
+
+func (s *Server) listReconciliationRuns(w http.ResponseWriter, r *http.Request, kind db.ReconciliationKind) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	runs, err := s.ReconciliationService.ListRuns(r.Context(), kind, limit)
+	if err != nil {
+		s.Logger.Error("failed to list reconciliation runs", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "reconciliation runs retrieved",
+		"data":    runs,
+	})
+}
+
+func (s *Server) GetReconciliationRunHandler(w http.ResponseWriter, r *http.Request) {
+	run, err := s.ReconciliationService.GetRun(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			utils.ErrorJSON(w, r, http.StatusNotFound, errors.New("reconciliation run not found"))
+			return
+		}
+		s.Logger.Error("failed to get reconciliation run", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "reconciliation run retrieved",
+		"data": map[string]interface{}{
+			"run":   run,
+			"items": run.Items(),
+		},
+	})
+}
+
+func (s *Server) UnfreezeWalletHandler(w http.ResponseWriter, r *http.Request) {
+	wallet, err := s.ReconciliationService.UnfreezeWallet(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			utils.ErrorJSON(w, r, http.StatusNotFound, errors.New("wallet not found"))
+			return
+		}
+		s.Logger.Error("failed to unfreeze wallet", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "wallet unfrozen",
+		"data":    wallet,
+	})
+}
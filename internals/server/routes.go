@@ -65,6 +65,96 @@ func (s *Server) registerRoutes() {
 				s.AuthMiddleware.MiddlewareAuthHandler, s.RateLimit(5, time.Minute),
 			),
 		},
+		{
+			Name:    "List Wallets",
+			Method:  "GET",
+			Pattern: "/api/v1/wallets",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.ListWalletsHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Create Wallet",
+			Method:  "POST",
+			Pattern: "/api/v1/wallets",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.CreateWalletHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Get Wallet By ID",
+			Method:  "GET",
+			Pattern: "/api/v1/wallets/{id}",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.GetWalletHandlerV1),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Move Between Wallets",
+			Method:  "POST",
+			Pattern: "/api/v1/wallets/{id}/move",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.MoveFundsHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "List Vaults",
+			Method:  "GET",
+			Pattern: "/api/v1/vaults",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.ListVaultsHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Create Vault",
+			Method:  "POST",
+			Pattern: "/api/v1/vaults",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.CreateVaultHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Get Vault",
+			Method:  "GET",
+			Pattern: "/api/v1/vaults/{id}",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.GetVaultHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Deposit To Vault",
+			Method:  "POST",
+			Pattern: "/api/v1/vaults/{id}/deposit",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.DepositToVaultHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Withdraw From Vault",
+			Method:  "POST",
+			Pattern: "/api/v1/vaults/{id}/withdraw",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.WithdrawFromVaultHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Break Vault",
+			Method:  "POST",
+			Pattern: "/api/v1/vaults/{id}/break",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.BreakVaultHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
 		{
 			Name:    "Transaction History",
 			Method:  "GET",
@@ -74,6 +164,102 @@ func (s *Server) registerRoutes() {
 				s.AuthMiddleware.MiddlewareAuthHandler,
 			),
 		},
+		{
+			Name:    "Transaction Receipt",
+			Method:  "GET",
+			Pattern: "/api/v1/transactions/{reference}/receipt",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.ReceiptHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Tag Transaction",
+			Method:  "PUT",
+			Pattern: "/api/v1/transactions/{reference}/tag",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.TagTransactionHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Untag Transaction",
+			Method:  "DELETE",
+			Pattern: "/api/v1/transactions/{reference}/tag",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.UntagTransactionHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:        "Verify Receipt",
+			Method:      "GET",
+			Pattern:     "/api/v1/receipts/verify",
+			HandlerFunc: http.HandlerFunc(s.VerifyReceiptHandler),
+		},
+		{
+			Name:    "Insights",
+			Method:  "GET",
+			Pattern: "/api/v1/insights",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.InsightsHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "List Categories",
+			Method:  "GET",
+			Pattern: "/api/v1/categories",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.ListCategoriesHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Create Category",
+			Method:  "POST",
+			Pattern: "/api/v1/categories",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.CreateCategoryHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Delete Category",
+			Method:  "DELETE",
+			Pattern: "/api/v1/categories/{id}",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.DeleteCategoryHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "List Category Rules",
+			Method:  "GET",
+			Pattern: "/api/v1/categories/rules",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.ListCategoryRulesHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Create Category Rule",
+			Method:  "POST",
+			Pattern: "/api/v1/categories/rules",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.CreateCategoryRuleHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Delete Category Rule",
+			Method:  "DELETE",
+			Pattern: "/api/v1/categories/rules/{id}",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.DeleteCategoryRuleHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
 		{
 			Name:    "Get Rate",
 			Method:  "GET",
@@ -92,6 +278,96 @@ func (s *Server) registerRoutes() {
 				s.AuthMiddleware.MiddlewareAuthHandler,
 			),
 		},
+		{
+			Name:    "Update Contact Details",
+			Method:  "PUT",
+			Pattern: "/api/v1/user/contact",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.UpdateContactHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "List Notifications",
+			Method:  "GET",
+			Pattern: "/api/v1/notifications",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.ListNotificationsHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Unread Notification Count",
+			Method:  "GET",
+			Pattern: "/api/v1/notifications/unread-count",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.UnreadNotificationsHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Mark Notification Read",
+			Method:  "POST",
+			Pattern: "/api/v1/notifications/{id}/read",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.MarkNotificationReadHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Mark All Notifications Read",
+			Method:  "POST",
+			Pattern: "/api/v1/notifications/read-all",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.MarkAllNotificationsReadHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Get Notification Preferences",
+			Method:  "GET",
+			Pattern: "/api/v1/notifications/preferences",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.GetNotificationPreferencesHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Update Notification Preferences",
+			Method:  "PUT",
+			Pattern: "/api/v1/notifications/preferences",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.UpdateNotificationPreferencesHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Wallet Stream",
+			Method:  "GET",
+			Pattern: walletStreamPath,
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.WalletStreamHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Wallet Statement",
+			Method:  "GET",
+			Pattern: "/api/v1/wallet/statements",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.StatementHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
+		{
+			Name:    "Queued Wallet Statement",
+			Method:  "GET",
+			Pattern: "/api/v1/wallet/statements/{id}",
+			HandlerFunc: s.AddMiddlewaresToHandler(
+				http.HandlerFunc(s.StatementExportHandler),
+				s.AuthMiddleware.MiddlewareAuthHandler,
+			),
+		},
 		{
 			Name:        "Set PIN",
 			Method:      "POST",
@@ -101,8 +377,8 @@ func (s *Server) registerRoutes() {
 		{
 			Name:        "Webhook Trigger",
 			Method:      "POST",
-			Pattern:     "/api/v1/webhooks/paystack",
-			HandlerFunc: http.HandlerFunc(s.PaystackWebhookHandler),
+			Pattern:     "/api/v1/webhooks/{provider}",
+			HandlerFunc: http.HandlerFunc(s.WebhookHandler),
 		},
 		{
 			Name:        "Verify Payment",
@@ -134,6 +410,12 @@ func (s *Server) registerRoutes() {
 			Pattern:     "/api/v1/withdraw",
 			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.WithdrawalHandler), s.AuthMiddleware.MiddlewareAuthHandler, s.RateLimit(5, time.Minute)),
 		},
+		{
+			Name:        "Get Withdrawal",
+			Method:      "GET",
+			Pattern:     "/api/v1/withdrawals/{reference}",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.GetWithdrawalHandler), s.AuthMiddleware.MiddlewareAuthHandler),
+		},
 		{
 			Name:        "rotate Refresh token",
 			Method:      "POST",
@@ -146,6 +428,138 @@ func (s *Server) registerRoutes() {
 			Pattern:     "/api/v1/users/lookup",
 			HandlerFunc: http.HandlerFunc(s.LookupUserHandler),
 		},
+		{
+			Name:        "Interest Rates",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/interest/rates",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.InterestRatesHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Preview Interest",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/interest/preview",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.PreviewInterestHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Pay Interest",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/interest/pay",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.PayInterestHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Run Balance Reconciliation",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/reconciliation/balance",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.RunBalanceReconciliationHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "List Balance Reconciliations",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/reconciliation/balance",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.ListBalanceReconciliationsHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Run Settlement Reconciliation",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/reconciliation/settlement",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.RunSettlementReconciliationHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "List Settlement Reconciliations",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/reconciliation/settlement",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.ListSettlementReconciliationsHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Get Reconciliation Run",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/reconciliation/runs/{id}",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.GetReconciliationRunHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "List Webhook Events",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/webhooks",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.ListWebhookEventsHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Get Webhook Event",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/webhooks/{id}",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.GetWebhookEventHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Replay Webhook Event",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/webhooks/{id}/replay",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.ReplayWebhookEventHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Job Stats",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/jobs",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.JobStatsHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Dead Letter Depths",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/dlq",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.DeadLetterDepthsHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "List Dead Letters",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/dlq/{queue}",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.ListDeadLettersHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Requeue Dead Letters",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/dlq/{queue}/requeue",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.RequeueDeadLettersHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Purge Dead Letters",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/dlq/{queue}/purge",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.PurgeDeadLettersHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "List Parked Outbox Events",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/outbox/parked",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.ListParkedOutboxHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Requeue Outbox Event",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/outbox/{id}/requeue",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.RequeueOutboxEventHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "List Audit Log",
+			Method:      "GET",
+			Pattern:     "/api/v1/admin/audit",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.ListAuditLogHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Unfreeze Wallet",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/wallets/{id}/unfreeze",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.UnfreezeWalletHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Capture Hold",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/holds/{reference}/capture",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.CaptureHoldHandler), s.AuthMiddleware.AdminOnly),
+		},
+		{
+			Name:        "Release Hold",
+			Method:      "POST",
+			Pattern:     "/api/v1/admin/holds/{reference}/release",
+			HandlerFunc: s.AddMiddlewaresToHandler(http.HandlerFunc(s.ReleaseHoldHandler), s.AuthMiddleware.AdminOnly),
+		},
 	}
 
 	for _, route := range routes {
@@ -7,13 +7,17 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/shopspring/decimal"
 	"github.com/theabdullahishola/mzl-payment-app/internals/config"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
 	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/notify"
 	"github.com/theabdullahishola/mzl-payment-app/internals/pkg"
 	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
 	"github.com/theabdullahishola/mzl-payment-app/internals/service"
@@ -30,6 +34,31 @@ type Server struct {
 	AuthMiddleware middlewares.AuthMiddleware
 	PaymentService service.PaymentService
 	RedisSvc       service.QueueService
+	Gateways       *gateway.Registry
+	WebhookService service.WebhookService
+
+	DeadLetterService service.DeadLetterService
+	OutboxRelay       service.OutboxRelay
+
+	ReconciliationService service.ReconciliationService
+	WithdrawalService     service.WithdrawalService
+	NotificationService   service.NotificationService
+	WalletStream          service.WalletStream
+	StatementService      service.StatementService
+	ReceiptService        service.ReceiptService
+	InsightsService       service.InsightsService
+	CategoryService       service.CategoryService
+	VaultService          service.VaultService
+	InterestService       service.InterestService
+
+	// Queue workers and schedulers run until stopBackground is called on
+	// shutdown; background tracks them so jobs can finish first.
+	stopBackground context.CancelFunc
+	background     sync.WaitGroup
+
+	// closeStreams is closed on shutdown to end open event streams, which
+	// would otherwise hold it up.
+	closeStreams chan struct{}
 }
 
 func New(cfg *config.Config, dbClient *db.PrismaClient) *Server {
@@ -40,22 +69,66 @@ func New(cfg *config.Config, dbClient *db.PrismaClient) *Server {
 	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.AllowContentType("application/json"))
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(exceptStreams(middleware.Timeout(60 * time.Second)))
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	userRepo := repository.NewUserRepository(dbClient)
 	walletrepo := repository.NewWalletRepository(dbClient)
 
-	paystack := service.NewClient(config.Load().PAYSTACK_SECRET_KEY)
+	paystack := gateway.NewPaystack(config.Load().PAYSTACK_SECRET_KEY, cfg.PaystackBaseURL, cfg.PaystackCallbackURL)
+	adapters := []gateway.PaymentGateway{paystack}
+	if cfg.FlutterwaveSecretKey != "" {
+		adapters = append(adapters, gateway.NewFlutterwave(cfg.FlutterwaveSecretKey, cfg.FlutterwaveWebhookHash, cfg.FlutterwaveBaseURL, cfg.FlutterwaveRedirectURL))
+	}
+	gateways := gateway.NewRegistry(cfg.PaymentProvider, adapters...)
 	authmid := middlewares.NewAuthMiddleware(cfg)
 	redisSvc, err := pkg.NewRedisQueue(config.Load().REDIS_URL, logger)
 	if err != nil {
 		return nil
 	}
 
-	authSvc := service.NewAuthService(userRepo, cfg, redisSvc)
-	paymentsvc := service.NewPaymentService(walletrepo, *paystack, redisSvc, userRepo)
-	redisSvc.SetPaymentService(paymentsvc)
-	walletsvc := service.NewWalletService(walletrepo, paymentsvc, userRepo, redisSvc)
+	templates, err := notify.DefaultTemplates()
+	if err != nil {
+		logger.Error("failed to load notification templates", "error", err)
+		return nil
+	}
+	senders, err := notificationSenders(cfg)
+	if err != nil {
+		logger.Error("failed to set up notification senders", "error", err)
+		return nil
+	}
+	notificationRepo := repository.NewNotificationRepository(dbClient)
+	senders[notify.ChannelInbox] = service.NewInboxSender(notificationRepo)
+	notificationSvc := service.NewNotificationService(userRepo, notificationRepo, templates, senders, redisSvc, logger)
+
+	authSvc := service.NewAuthService(userRepo, cfg, redisSvc, notificationSvc)
+	paymentsvc := service.NewPaymentService(walletrepo, gateways, redisSvc, userRepo)
+	webhookSvc := service.NewWebhookService(repository.NewWebhookRepository(dbClient), paymentsvc, redisSvc, logger)
+	deadLetterSvc := service.NewDeadLetterService(redisSvc, repository.NewAuditRepository(dbClient), logger)
+	reviewLimits, err := service.ParseReviewLimits(cfg.TransferReviewLimits)
+	if err != nil {
+		logger.Error("invalid TRANSFER_REVIEW_LIMITS, holding no transfers for review", "error", err)
+		reviewLimits = nil
+	}
+	walletsvc := service.NewWalletService(walletrepo, paymentsvc, userRepo, redisSvc, reviewLimits)
+	withdrawalSvc := service.NewWithdrawalService(walletrepo, gateways, redisSvc, logger)
+	walletStream := service.NewWalletStream(redisSvc, logger)
+	outboxRelay := service.NewOutboxRelay(repository.NewOutboxRepository(dbClient), redisSvc, logger)
+	statementSvc := service.NewStatementService(repository.NewStatementRepository(dbClient), redisSvc, logger)
+	receiptKey := cfg.ReceiptSigningKey
+	if receiptKey == "" {
+		receiptKey = cfg.JWTSecret
+	}
+	receiptSvc := service.NewReceiptService(repository.NewReceiptRepository(dbClient), receiptKey, cfg.PublicURL)
+	if cfg.VaultBreakPenalty.IsNegative() || cfg.VaultBreakPenalty.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		logger.Error("invalid VAULT_BREAK_PENALTY, using the default", "penalty", cfg.VaultBreakPenalty.String())
+	}
+	interestRates, err := service.ParseInterestRates(cfg.InterestRates)
+	if err != nil {
+		logger.Error("invalid INTEREST_RATES, paying no interest", "error", err)
+		interestRates = nil
+	}
+	interestSvc := service.NewInterestService(repository.NewInterestRepository(dbClient), interestRates, redisSvc, logger)
+	reconSvc := service.NewReconciliationService(repository.NewReconciliationRepository(dbClient), walletrepo, paystack, redisSvc, logger)
 
 	s := &Server{
 		Logger:         logger,
@@ -67,8 +140,58 @@ func New(cfg *config.Config, dbClient *db.PrismaClient) *Server {
 		AuthMiddleware: *authmid,
 		PaymentService: paymentsvc,
 		RedisSvc:       redisSvc,
+		Gateways:       gateways,
+		WebhookService: webhookSvc,
+
+		DeadLetterService: deadLetterSvc,
+		OutboxRelay:       outboxRelay,
+
+		ReconciliationService: reconSvc,
+		WithdrawalService:     withdrawalSvc,
+		NotificationService:   notificationSvc,
+		WalletStream:          walletStream,
+		StatementService:      statementSvc,
+		ReceiptService:        receiptSvc,
+		InsightsService:       service.NewInsightsService(repository.NewInsightsRepository(dbClient), redisSvc),
+		CategoryService:       service.NewCategoryService(repository.NewCategoryRepository(dbClient), redisSvc),
+		VaultService:          service.NewVaultService(walletrepo, redisSvc, cfg.VaultBreakPenalty),
+		InterestService:       interestSvc,
+
+		closeStreams: make(chan struct{}),
+	}
+
+	bg, stop := context.WithCancel(context.Background())
+	s.stopBackground = stop
+	retryPolicies, err := service.RetryPolicies(cfg.QueueRetryPolicies)
+	if err != nil {
+		logger.Error("invalid QUEUE_RETRY_POLICIES, using defaults", "error", err)
+		retryPolicies = service.DefaultRetryPolicies
+	}
+	workerOptions := func(jobType string) service.WorkerOptions {
+		return service.WorkerOptions{
+			Concurrency:       cfg.QueueConcurrency,
+			VisibilityTimeout: cfg.QueueVisibilityTimeout,
+			Retry:             service.RetryPolicyFor(retryPolicies, jobType),
+		}
 	}
-	go redisSvc.StartWorker(context.Background(), "payment_webhooks")
+	redisSvc.RegisterHandler(service.WebhookQueue, webhookSvc.ProcessQueued, workerOptions(service.WebhookQueue))
+	redisSvc.RegisterHandler(service.WithdrawalQueue, withdrawalSvc.ProcessWithdrawal, workerOptions(service.WithdrawalQueue))
+	redisSvc.RegisterHandler(service.DomainEventQueue, service.DispatchDomainEvents(
+		service.InvalidateWalletCaches(redisSvc),
+		service.StreamWalletUpdates(walletStream, walletrepo),
+		notificationSvc.OnDomainEvent,
+	), workerOptions(service.DomainEventQueue))
+	redisSvc.RegisterHandler(service.NotificationQueue, notificationSvc.Deliver, workerOptions(service.NotificationQueue))
+	redisSvc.RegisterHandler(service.StatementQueue, statementSvc.ProcessQueued, workerOptions(service.StatementQueue))
+	s.runInBackground(func() { redisSvc.Run(bg) })
+	s.runInBackground(func() { outboxRelay.Start(bg, cfg.OutboxRelayInterval) })
+	s.runInBackground(func() { walletStream.Run(bg) })
+	s.runInBackground(func() { withdrawalSvc.StartPoller(bg, cfg.WithdrawalPollInterval, cfg.WithdrawalStuckAfter) })
+	s.runInBackground(func() { reconSvc.StartBalanceScheduler(bg, cfg.ReconciliationInterval, cfg.ReconciliationFreeze) })
+	s.runInBackground(func() {
+		reconSvc.StartSettlementScheduler(bg, cfg.SettlementInterval, cfg.SettlementLookback, cfg.SettlementAutoFix)
+	})
+	s.runInBackground(func() { interestSvc.StartScheduler(bg, cfg.InterestInterval) })
 	s.registerRoutes()
 
 	return s
@@ -83,6 +206,7 @@ func (s *Server) Start() {
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	srv.RegisterOnShutdown(func() { close(s.closeStreams) })
 
 	s.gracefulShutdown(srv)
 }
@@ -109,11 +233,62 @@ func (s *Server) gracefulShutdown(srv *http.Server) {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	s.drainBackground()
 	s.closeDB()
 
 	log.Println("Server exited properly")
 }
 
+// notificationSenders uses SMTP for email when it is configured and logs
+// everything else, as there are no SMS or push providers yet.
+func notificationSenders(cfg *config.Config) (map[string]notify.Sender, error) {
+	fallback := notify.NewLogSender(os.Stdout)
+	if cfg.NotificationLogFile != "" {
+		sender, err := notify.NewFileSender(cfg.NotificationLogFile)
+		if err != nil {
+			return nil, err
+		}
+		fallback = sender
+	}
+
+	email := fallback
+	if cfg.SMTPHost != "" {
+		email = notify.NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+	return map[string]notify.Sender{
+		notify.ChannelEmail: email,
+		notify.ChannelSMS:   fallback,
+		notify.ChannelPush:  fallback,
+	}, nil
+}
+
+func (s *Server) runInBackground(fn func()) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		fn()
+	}()
+}
+
+// drainBackground stops workers from taking new jobs and waits, up to
+// QueueDrainTimeout, for the ones they are running.
+func (s *Server) drainBackground() {
+	log.Println("Draining queue workers...")
+	s.stopBackground()
+
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(s.Config.QueueDrainTimeout):
+		log.Printf("Gave up waiting for queue workers after %s; unacknowledged jobs will be redelivered", s.Config.QueueDrainTimeout)
+	}
+}
+
 func (s *Server) closeDB() {
 	log.Println("Closing database connection...")
 	if err := s.DB.Prisma.Disconnect(); err != nil {
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+)
+
+// StatementHandler returns the statement for ?currency= between ?from= and
+// ?to= as ?format=csv or pdf. Statements longer than
+// service.MaxSyncStatementRange are queued instead: the response is a 202
+// with the export to poll at /api/v1/wallet/statements/{id}.
+func (s *Server) StatementHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+	q := r.URL.Query()
+
+	req := service.StatementRequest{
+		WalletID: q.Get("wallet_id"),
+		Currency: strings.ToUpper(q.Get("currency")),
+		Format:   strings.ToLower(q.Get("format")),
+	}
+	var err error
+	if req.From, err = parseDateParam(q.Get("from"), false); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("invalid from: %w", err))
+		return
+	}
+	if req.To, err = parseDateParam(q.Get("to"), true); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, fmt.Errorf("invalid to: %w", err))
+		return
+	}
+
+	if req.Large() {
+		export, err := s.StatementService.Queue(r.Context(), userID, req)
+		if err != nil {
+			s.statementError(w, r, err)
+			return
+		}
+		utils.JSON(w, r, http.StatusAccepted, map[string]interface{}{
+			"status":  "success",
+			"message": "statement is being generated",
+			"data":    statementExportView(export),
+		})
+		return
+	}
+
+	file, err := s.StatementService.Generate(r.Context(), userID, req)
+	if err != nil {
+		s.statementError(w, r, err)
+		return
+	}
+	writeStatementFile(w, file)
+}
+
+// StatementExportHandler reports on a queued statement and downloads it once
+// it is ready.
+func (s *Server) StatementExportHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	export, file, err := s.StatementService.Export(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		s.statementError(w, r, err)
+		return
+	}
+	if file != nil {
+		writeStatementFile(w, file)
+		return
+	}
+
+	status := http.StatusAccepted
+	if export.Status == service.StatementFailed {
+		status = http.StatusOK
+	}
+	utils.JSON(w, r, status, map[string]interface{}{
+		"status":  "success",
+		"message": "statement is " + strings.ToLower(export.Status),
+		"data":    statementExportView(export),
+	})
+}
+
+func (s *Server) statementError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidStatementRequest):
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+	case errors.Is(err, service.ErrWalletNotFound):
+		utils.ErrorJSON(w, r, http.StatusNotFound, errors.New("wallet asset not found for this currency"))
+	case errors.Is(err, service.ErrStatementNotFound):
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+	default:
+		s.Logger.Error("failed to produce statement", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+	}
+}
+
+func statementExportView(export *service.StatementExport) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           export.ID,
+		"status":       export.Status,
+		"currency":     export.Request.Currency,
+		"from":         export.Request.From,
+		"to":           export.Request.To,
+		"format":       export.Request.Format,
+		"error":        export.Error,
+		"created_at":   export.CreatedAt,
+		"expires_at":   export.ExpiresAt,
+		"download_url": "/api/v1/wallet/statements/" + export.ID,
+	}
+}
+
+func writeStatementFile(w http.ResponseWriter, file *service.StatementFile) {
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
+	w.Header().Set("Content-Length", fmt.Sprint(len(file.Content)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(file.Content)
+}
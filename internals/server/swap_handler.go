@@ -5,7 +5,9 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/shopspring/decimal"
 	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
 	"github.com/theabdullahishola/mzl-payment-app/internals/service"
 	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
 )
@@ -13,10 +15,11 @@ import (
 
 
 type SwapRequest struct {
-	FromCurrency string  `json:"from_currency"`
-	ToCurrency   string  `json:"to_currency"`
-	Amount       float64 `json:"amount"`
-	Pin          string  `json:"pin"`
+	WalletID     string          `json:"wallet_id"` // optional, the default wallet otherwise
+	FromCurrency string          `json:"from_currency"`
+	ToCurrency   string          `json:"to_currency"`
+	Amount       decimal.Decimal `json:"amount"`
+	Pin          string          `json:"pin"`
 }
 
 func (s *Server) SwapHandlerV1(w http.ResponseWriter, r *http.Request) {
@@ -34,7 +37,12 @@ func (s *Server) SwapHandlerV1(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Amount <= 0 {
+	amount, err := money.New(req.Amount, req.FromCurrency)
+	if err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if !amount.IsPositive() {
 		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("amount must be greater than zero"))
 		return
 	}
@@ -48,13 +56,13 @@ func (s *Server) SwapHandlerV1(w http.ResponseWriter, r *http.Request) {
 	logger = logger.With("user_id", userID)
 
 	// Pin Verification
-	err := s.AuthService.VerifyTransactionPin(r.Context(), userID, req.Pin)
+	err = s.AuthService.VerifyTransactionPin(r.Context(), userID, req.Pin)
 	if err != nil {
 		utils.ErrorJSON(w, r, http.StatusUnauthorized, errors.New("incorrect transaction pin"))
 		return
 	}
 
-	result, err := s.WalletService.SwapFunds(r.Context(), userID, req.FromCurrency, req.ToCurrency, req.Amount, idempotencyKey)
+	result, err := s.WalletService.SwapFunds(r.Context(), userID, req.WalletID, amount, req.ToCurrency, idempotencyKey)
 	if err != nil {
 		if errors.Is(err, service.ErrTransactionAlreadyProcessed) {
 			logger.Info("idempotent swap request detected")
@@ -65,12 +73,18 @@ func (s *Server) SwapHandlerV1(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if errors.Is(err, service.ErrWalletFrozen) {
+			utils.ErrorJSON(w, r, http.StatusForbidden, err)
+			return
+		}
+
 		logger.Error("swap failed", "error", err)
 		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	logger.Info("swap successful", "from", req.FromCurrency, "to", req.ToCurrency)
+	(*result)["receipt_url"] = receiptURL(idempotencyKey)
 	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "swap successful",
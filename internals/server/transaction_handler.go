@@ -2,12 +2,25 @@ package server
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
 	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
 )
 
+// GetTransactionHistoryV1 pages through the user's transactions with
+// ?cursor= and ?limit=, filtered by ?type=, ?status= (both comma separated),
+// ?direction=, ?currency=, ?from=, ?to=, ?min_amount=, ?max_amount=, ?q= and
+// ?category= (comma separated category ids).
 func (s *Server) GetTransactionHistoryV1(w http.ResponseWriter, r *http.Request) {
 	val := r.Context().Value(middlewares.UserIDKey)
 	userID, ok := val.(string)
@@ -16,16 +29,116 @@ func (s *Server) GetTransactionHistoryV1(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	transactions, err := s.WalletService.GetTransactionHistory(r.Context(), userID)
+	filter, err := transactionFilter(r.URL.Query())
+	if err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := s.WalletService.GetTransactionHistory(r.Context(), userID, filter, r.URL.Query().Get("cursor"))
+	if errors.Is(err, service.ErrInvalidCursor) {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
 	if err != nil {
 		s.Logger.Error("failed to get history", "error", err)
 		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
 		return
 	}
 
-	utils.JSON(w,r, http.StatusOK, map[string]interface{}{
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "transaction history retrieved",
-		"data":    transactions,
+		"data":    page,
 	})
-}
\ No newline at end of file
+}
+
+func transactionFilter(q url.Values) (repository.TransactionFilter, error) {
+	var filter repository.TransactionFilter
+	var err error
+
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+	filter.WalletID = q.Get("wallet_id")
+	filter.Currency = strings.ToUpper(q.Get("currency"))
+	filter.Search = strings.TrimSpace(q.Get("q"))
+	filter.Categories = splitList(q.Get("category"))
+
+	for _, t := range splitList(q.Get("type")) {
+		switch txType := db.TransactionType(strings.ToUpper(t)); txType {
+		case db.TransactionTypeDeposit, db.TransactionTypeWithdrawal, db.TransactionTypeTransfer, db.TransactionTypeSwap, db.TransactionTypeChargeback, db.TransactionTypeVault, db.TransactionTypeInterest, db.TransactionTypeMove:
+			filter.Types = append(filter.Types, txType)
+		default:
+			return filter, fmt.Errorf("unknown transaction type %q", t)
+		}
+	}
+	for _, st := range splitList(q.Get("status")) {
+		switch status := db.TransactionStatus(strings.ToUpper(st)); status {
+		case db.TransactionStatusPending, db.TransactionStatusSuccess, db.TransactionStatusFailed:
+			filter.Statuses = append(filter.Statuses, status)
+		default:
+			return filter, fmt.Errorf("unknown transaction status %q", st)
+		}
+	}
+	if d := q.Get("direction"); d != "" {
+		switch direction := db.TransactionDirection(strings.ToUpper(d)); direction {
+		case db.TransactionDirectionCredit, db.TransactionDirectionDebit:
+			filter.Direction = direction
+		default:
+			return filter, fmt.Errorf("unknown direction %q", d)
+		}
+	}
+
+	if filter.From, err = parseDateParam(q.Get("from"), false); err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseDateParam(q.Get("to"), true); err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+	if filter.MinAmount, err = parseAmountParam(q.Get("min_amount")); err != nil {
+		return filter, fmt.Errorf("invalid min_amount: %w", err)
+	}
+	if filter.MaxAmount, err = parseAmountParam(q.Get("max_amount")); err != nil {
+		return filter, fmt.Errorf("invalid max_amount: %w", err)
+	}
+	return filter, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// parseDateParam accepts RFC 3339 or YYYY-MM-DD. A bare date used as an
+// end bound includes that whole day.
+func parseDateParam(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, errors.New("want RFC 3339 or YYYY-MM-DD")
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func parseAmountParam(s string) (*decimal.Decimal, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
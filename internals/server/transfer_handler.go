@@ -5,7 +5,9 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/shopspring/decimal"
 	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
 	"github.com/theabdullahishola/mzl-payment-app/internals/service"
 	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
 )
@@ -13,11 +15,12 @@ import (
 // --- TRANSFER HANDLER ---
 
 type TransferRequest struct {
-	AccountNumber string  `json:"account_number"`
-	Currency      string  `json:"currency"`
-	Amount        float64 `json:"amount"`
-	Pin           string  `json:"pin"`
-	Description   string  `json:"description"`
+	WalletID      string          `json:"wallet_id"` // the source; optional, the default wallet otherwise
+	AccountNumber string          `json:"account_number"`
+	Currency      string          `json:"currency"`
+	Amount        decimal.Decimal `json:"amount"`
+	Pin           string          `json:"pin"`
+	Description   string          `json:"description"`
 }
 
 func (s *Server) TransferFundsHandlerV1(w http.ResponseWriter, r *http.Request) {
@@ -37,14 +40,19 @@ func (s *Server) TransferFundsHandlerV1(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Validation
-	if req.Amount <= 0 {
-		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("amount must be greater than zero"))
-		return
-	}
 	if req.AccountNumber == "" || req.Currency == "" {
 		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("account number and currency are required"))
 		return
 	}
+	amount, err := money.New(req.Amount, req.Currency)
+	if err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if !amount.IsPositive() {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("amount must be greater than zero"))
+		return
+	}
 
 	val := r.Context().Value(middlewares.UserIDKey)
 	userID, ok := val.(string)
@@ -60,7 +68,7 @@ func (s *Server) TransferFundsHandlerV1(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Pin Verification
-	err := s.AuthService.VerifyTransactionPin(r.Context(), userID, req.Pin)
+	err = s.AuthService.VerifyTransactionPin(r.Context(), userID, req.Pin)
 	if err != nil {
 		logger.Warn("incorrect pin attempt")
 		utils.ErrorJSON(w, r, http.StatusUnauthorized, errors.New("incorrect transaction pin"))
@@ -68,7 +76,21 @@ func (s *Server) TransferFundsHandlerV1(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Logic Execution
-	receiverName, err := s.WalletService.TransferFunds(r.Context(), userID, req.AccountNumber, req.Currency, req.Amount, req.Description, idempotencyKey)
+	receiverName, err := s.WalletService.TransferFunds(r.Context(), userID, req.WalletID, req.AccountNumber, amount, req.Description, idempotencyKey)
+	if errors.Is(err, service.ErrTransferUnderReview) {
+		logger.Info("transfer held for review", "amount", amount.String(), "to", req.AccountNumber)
+		utils.JSON(w, r, http.StatusAccepted, map[string]interface{}{
+			"status":  "success",
+			"message": "transfer is being reviewed and will complete once approved",
+			"data": map[string]interface{}{
+				"recipient":      req.AccountNumber,
+				"amount":         amount.StringFixed(),
+				"currency":       amount.Currency(),
+				"receipientName": receiverName,
+			},
+		})
+		return
+	}
 	if err != nil {
 		// Handle Idempotency Duplicate
 		if errors.Is(err, service.ErrTransactionAlreadyProcessed) {
@@ -78,13 +100,29 @@ func (s *Server) TransferFundsHandlerV1(w http.ResponseWriter, r *http.Request)
 				"message": "transfer already processed",
 				"data": map[string]interface{}{
 					"recipient": req.AccountNumber,
-					"amount":    req.Amount,
-					"currency":  req.Currency,
+					"amount":    amount.StringFixed(),
+					"currency":  amount.Currency(),
 				},
 			})
 			return
 		}
 
+		if errors.Is(err, service.ErrWalletFrozen) {
+			logger.Warn("transfer blocked", "reason", err.Error())
+			utils.ErrorJSON(w, r, http.StatusForbidden, err)
+			return
+		}
+
+		if errors.Is(err, service.ErrUnknownWallet) {
+			utils.ErrorJSON(w, r, http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, service.ErrSameWallet) {
+			logger.Warn("transfer blocked", "reason", err.Error())
+			utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+			return
+		}
+
 		// Handle Business Logic Errors
 		if err.Error() == "insufficient balance" || err.Error() == "recipient account number not found" {
 			logger.Warn("transfer blocked", "reason", err.Error())
@@ -98,15 +136,16 @@ func (s *Server) TransferFundsHandlerV1(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Final Success
-	logger.Info("transfer successful", "amount", req.Amount, "to", req.AccountNumber)
+	logger.Info("transfer successful", "amount", amount.String(), "to", req.AccountNumber)
 	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "transfer successful",
 		"data": map[string]interface{}{
 			"recipient":       req.AccountNumber,
-			"amount":          req.Amount,
-			"currency":        req.Currency,
+			"amount":          amount.StringFixed(),
+			"currency":        amount.Currency(),
 			"receipientName":  receiverName,
+			"receipt_url":     receiptURL(idempotencyKey),
 		},
 	})
 }
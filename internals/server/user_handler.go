@@ -1,11 +1,13 @@
 package server
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
 	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
 	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
 )
@@ -83,3 +85,50 @@ func (s *Server) LookupUserHandler(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 }
+
+type UpdateContactRequest struct {
+	Phone     *string `json:"phone"`
+	PushToken *string `json:"push_token"`
+	Locale    *string `json:"locale"`
+}
+
+func (s *Server) UpdateContactHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middlewares.UserIDKey).(string)
+	if !ok {
+		utils.ErrorJSON(w, r, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	var req UpdateContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request"))
+		return
+	}
+	if req.Locale != nil && *req.Locale == "" {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("locale cannot be empty"))
+		return
+	}
+
+	user, err := s.AuthService.UpdateNotificationContact(r.Context(), userID, repository.NotificationContact{
+		Phone:     req.Phone,
+		PushToken: req.PushToken,
+		Locale:    req.Locale,
+	})
+	if err != nil {
+		s.Logger.Error("failed to update contact details", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	phone, _ := user.Phone()
+	_, hasPushToken := user.PushToken()
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "contact details updated",
+		"data": map[string]interface{}{
+			"phone":          phone,
+			"has_push_token": hasPushToken,
+			"locale":         user.Locale,
+		},
+	})
+}
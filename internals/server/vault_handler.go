@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type CreateVaultRequest struct {
+	WalletID     string           `json:"wallet_id"` // optional, the default wallet otherwise
+	Name         string           `json:"name"`
+	Currency     string           `json:"currency"`
+	TargetAmount *decimal.Decimal `json:"target_amount"`
+	LockedUntil  string           `json:"locked_until"` // RFC 3339 or YYYY-MM-DD
+}
+
+type VaultMoveRequest struct {
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// vaultView reports a vault's balance, its progress towards the target and
+// whether it is still locked.
+func vaultView(vault db.VaultModel) map[string]interface{} {
+	precision := money.Precision(vault.Currency)
+	view := map[string]interface{}{
+		"id":       vault.ID,
+		"name":     vault.Name,
+		"currency": vault.Currency,
+		"balance":  vault.Balance.StringFixed(precision),
+		"status":   vault.Status,
+		"locked":   service.VaultLocked(&vault, time.Now()),
+	}
+	if target, ok := vault.TargetAmount(); ok {
+		view["target_amount"] = target.StringFixed(precision)
+		if target.IsPositive() {
+			view["progress_pct"] = vault.Balance.Div(target).Mul(decimal.NewFromInt(100)).StringFixed(2)
+		}
+	}
+	if until, ok := vault.LockedUntil(); ok {
+		view["locked_until"] = until
+	}
+	if closed, ok := vault.ClosedAt(); ok {
+		view["closed_at"] = closed
+	}
+	view["created_at"] = vault.CreatedAt
+	if vault.RelationsVault.Transactions != nil {
+		view["transactions"] = vault.RelationsVault.Transactions
+	}
+	return view
+}
+
+func vaultViews(vaults []db.VaultModel) []map[string]interface{} {
+	views := make([]map[string]interface{}, 0, len(vaults))
+	for _, vault := range vaults {
+		views = append(views, vaultView(vault))
+	}
+	return views
+}
+
+// ListVaultsHandler returns the user's open vaults, and the closed ones too
+// with ?include_closed=true.
+func (s *Server) ListVaultsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+	includeClosed, _ := strconv.ParseBool(r.URL.Query().Get("include_closed"))
+
+	vaults, err := s.VaultService.Vaults(r.Context(), userID, includeClosed)
+	if err != nil {
+		s.Logger.Error("failed to list vaults", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "vaults retrieved",
+		"data":    vaultViews(vaults),
+	})
+}
+
+func (s *Server) GetVaultHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	vault, err := s.VaultService.Vault(r.Context(), userID, chi.URLParam(r, "id"))
+	if errors.Is(err, service.ErrVaultNotFound) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to get vault", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "vault retrieved",
+		"data":    vaultView(*vault),
+	})
+}
+
+func (s *Server) CreateVaultHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	var req CreateVaultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+	v := repository.NewVault{WalletID: req.WalletID, Name: req.Name, Currency: req.Currency, TargetAmount: req.TargetAmount}
+	if req.LockedUntil != "" {
+		until, err := parseDateParam(req.LockedUntil, false)
+		if err != nil {
+			utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid locked_until: want RFC 3339 or YYYY-MM-DD"))
+			return
+		}
+		v.LockedUntil = &until
+	}
+
+	vault, err := s.VaultService.CreateVault(r.Context(), userID, v)
+	if errors.Is(err, service.ErrInvalidVault) {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, service.ErrUnknownWallet) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.Logger.Error("failed to create vault", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusCreated, map[string]interface{}{
+		"status":  "success",
+		"message": "vault created",
+		"data":    vaultView(*vault),
+	})
+}
+
+func (s *Server) DepositToVaultHandler(w http.ResponseWriter, r *http.Request) {
+	s.moveVaultFunds(w, r, s.VaultService.Deposit, "moved to vault")
+}
+
+func (s *Server) WithdrawFromVaultHandler(w http.ResponseWriter, r *http.Request) {
+	s.moveVaultFunds(w, r, s.VaultService.Withdraw, "moved from vault")
+}
+
+type vaultMove func(ctx context.Context, userID, vaultID string, amount money.Money, reference string) (*db.VaultModel, error)
+
+// moveVaultFunds handles a deposit or withdrawal of {"amount", "currency"};
+// the Idempotency-Key header is the transaction reference.
+func (s *Server) moveVaultFunds(w http.ResponseWriter, r *http.Request, move vaultMove, message string) {
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("missing Idempotency-Key header"))
+		return
+	}
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	var req VaultMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+	amount, err := money.New(req.Amount, req.Currency)
+	if err != nil || !amount.IsPositive() {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("valid currency and amount greater than 0 are required"))
+		return
+	}
+
+	vault, err := move(r.Context(), userID, chi.URLParam(r, "id"), amount, idempotencyKey)
+	if err != nil {
+		s.vaultError(w, r, err)
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": message,
+		"data": map[string]interface{}{
+			"reference": idempotencyKey,
+			"vault":     vaultView(*vault),
+		},
+	})
+}
+
+// BreakVaultHandler empties the vault into the wallet and closes it. Before
+// the lock date the early-break penalty is kept.
+func (s *Server) BreakVaultHandler(w http.ResponseWriter, r *http.Request) {
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("missing Idempotency-Key header"))
+		return
+	}
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	result, err := s.VaultService.Break(r.Context(), userID, chi.URLParam(r, "id"), idempotencyKey)
+	if err != nil {
+		s.vaultError(w, r, err)
+		return
+	}
+
+	precision := money.Precision(result.Vault.Currency)
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "vault closed",
+		"data": map[string]interface{}{
+			"reference": idempotencyKey,
+			"amount":    result.Amount.StringFixed(precision),
+			"penalty":   result.Penalty.StringFixed(precision),
+			"credited":  result.Credited.StringFixed(precision),
+			"vault":     vaultView(*result.Vault),
+		},
+	})
+}
+
+func (s *Server) vaultError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrTransactionAlreadyProcessed):
+		utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+			"status":  "success",
+			"message": "vault transfer already processed (idempotent)",
+		})
+	case errors.Is(err, service.ErrVaultNotFound):
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+	case errors.Is(err, service.ErrVaultCurrency):
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+	case errors.Is(err, service.ErrVaultClosed), errors.Is(err, service.ErrVaultLocked), errors.Is(err, service.ErrVaultChanged):
+		utils.ErrorJSON(w, r, http.StatusConflict, err)
+	case errors.Is(err, service.ErrWalletFrozen):
+		utils.ErrorJSON(w, r, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrVaultBalanceLow), strings.HasPrefix(err.Error(), "insufficient"):
+		utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+	default:
+		s.Logger.Error("vault transfer failed", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
 	"github.com/theabdullahishola/mzl-payment-app/internals/middlewares"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
 	"github.com/theabdullahishola/mzl-payment-app/internals/service"
 	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
 	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
@@ -13,12 +18,14 @@ import (
 
 
 type FundRequest struct {
-	Currency    string  `json:"currency" binding:"required"`
-	Amount      float64 `json:"amount" binding:"required"`
-	Description string  `json:"description"` // Optional
+	WalletID    string          `json:"wallet_id"` // Optional, the default wallet otherwise
+	Currency    string          `json:"currency" binding:"required"`
+	Amount      decimal.Decimal `json:"amount" binding:"required"`
+	Description string          `json:"description"` // Optional
 }
 
-// HandleGetWallet retrieves the user's wallet details.
+// HandleGetWallet retrieves the user's wallet details: the default wallet, or
+// the one named by ?wallet_id= or the {id} URL parameter.
 func (s *Server) GetWalletHandlerV1(w http.ResponseWriter, r *http.Request) {
 	//  Get UserID from Context
 	val := r.Context().Value(middlewares.UserIDKey)
@@ -29,11 +36,16 @@ func (s *Server) GetWalletHandlerV1(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	walletID := chi.URLParam(r, "id")
+	if walletID == "" {
+		walletID = r.URL.Query().Get("wallet_id")
+	}
+
 	// Check for Query Parameter "?currency=..."
 	currencyParam := r.URL.Query().Get("currency")
 
 	if currencyParam != "" {
-		asset, err := s.WalletService.GetAssetByCurrency(r.Context(), userID, currencyParam)
+		asset, err := s.WalletService.GetAssetByCurrency(r.Context(), userID, walletID, currencyParam)
 		if err != nil {
 			if errors.Is(err, service.ErrWalletNotFound) || errors.Is(err, db.ErrNotFound) {
 				utils.ErrorJSON(w, r, http.StatusNotFound, errors.New("wallet asset not found for this currency"))
@@ -47,15 +59,15 @@ func (s *Server) GetWalletHandlerV1(w http.ResponseWriter, r *http.Request) {
 		utils.JSON(w,r, http.StatusOK, map[string]interface{}{
 			"status":  "success",
 			"message": "asset retrieved successfully",
-			"data":    asset,
+			"data":    assetView(*asset),
 		})
 		return
 	}
 
 	
-	wallet, err := s.WalletService.GetWalletWithAssets(r.Context(), userID)
+	wallet, err := s.WalletService.GetWalletWithAssets(r.Context(), userID, walletID)
 	if err != nil {
-		if errors.Is(err, db.ErrNotFound) {
+		if errors.Is(err, service.ErrUnknownWallet) || errors.Is(err, db.ErrNotFound) {
 			utils.ErrorJSON(w, r, http.StatusNotFound, errors.New("wallet not found"))
 			return
 		}
@@ -67,14 +79,172 @@ func (s *Server) GetWalletHandlerV1(w http.ResponseWriter, r *http.Request) {
 	utils.JSON(w,r, http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "wallet retrieved successfully",
+		"data":    walletView(*wallet),
+	})
+}
+
+func walletView(wallet db.WalletModel) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             wallet.ID,
+		"account_number": wallet.AccountNumber,
+		"name":           wallet.Name,
+		"kind":           wallet.Kind,
+		"is_default":     wallet.IsDefault,
+		"assets":         assetViews(wallet.RelationsWallet.Assets), // Returns the list of NGN, USD, etc.
+		"vaults":         vaultViews(wallet.RelationsWallet.Vaults),
+	}
+}
+
+type CreateWalletRequest struct {
+	Name string        `json:"name"`
+	Kind db.WalletKind `json:"kind"` // PERSONAL, BUSINESS or PROJECT; PERSONAL by default
+}
+
+type MoveFundsRequest struct {
+	ToWalletID  string          `json:"to_wallet_id"`
+	Currency    string          `json:"currency"`
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+}
+
+// ListWalletsHandler returns all of the user's wallets, the default one first.
+func (s *Server) ListWalletsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	wallets, err := s.WalletService.ListWallets(r.Context(), userID)
+	if err != nil {
+		s.Logger.Error("failed to list wallets", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	views := make([]map[string]interface{}, 0, len(wallets))
+	for _, wallet := range wallets {
+		views = append(views, walletView(wallet))
+	}
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "wallets retrieved",
+		"data":    views,
+	})
+}
+
+// CreateWalletHandler opens another wallet, with its own account number.
+func (s *Server) CreateWalletHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	var req CreateWalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+
+	wallet, err := s.WalletService.CreateWallet(r.Context(), userID, req.Name, db.WalletKind(strings.ToUpper(string(req.Kind))))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidWallet):
+			utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		case errors.Is(err, service.ErrWalletNameTaken), errors.Is(err, service.ErrTooManyWallets):
+			utils.ErrorJSON(w, r, http.StatusConflict, err)
+		default:
+			s.Logger.Error("failed to create wallet", "error", err)
+			utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		}
+		return
+	}
+
+	utils.JSON(w, r, http.StatusCreated, map[string]interface{}{
+		"status":  "success",
+		"message": "wallet created",
+		"data":    walletView(*wallet),
+	})
+}
+
+// MoveFundsHandler moves money from the {id} wallet to another of the user's
+// wallets. The money doesn't leave the user, so there is no fee and no PIN,
+// as with vaults; the Idempotency-Key header is the reference.
+func (s *Server) MoveFundsHandler(w http.ResponseWriter, r *http.Request) {
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("missing Idempotency-Key header"))
+		return
+	}
+	userID := r.Context().Value(middlewares.UserIDKey).(string)
+
+	var req MoveFundsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("invalid request body"))
+		return
+	}
+	if req.ToWalletID == "" {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("to_wallet_id is required"))
+		return
+	}
+	amount, err := money.New(req.Amount, req.Currency)
+	if err != nil || !amount.IsPositive() {
+		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("valid currency and amount greater than 0 are required"))
+		return
+	}
+
+	fromWalletID := chi.URLParam(r, "id")
+	err = s.WalletService.MoveFunds(r.Context(), userID, fromWalletID, req.ToWalletID, amount, req.Description, idempotencyKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTransactionAlreadyProcessed):
+			utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+				"status":  "success",
+				"message": "move already processed (idempotent)",
+			})
+		case errors.Is(err, service.ErrUnknownWallet):
+			utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		case errors.Is(err, service.ErrWalletFrozen):
+			utils.ErrorJSON(w, r, http.StatusForbidden, err)
+		case errors.Is(err, service.ErrSameWallet), strings.HasPrefix(err.Error(), "insufficient"):
+			utils.ErrorJSON(w, r, http.StatusBadRequest, err)
+		default:
+			s.Logger.Error("wallet move failed", "error", err)
+			utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		}
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "funds moved",
 		"data": map[string]interface{}{
-			"id":             wallet.ID,
-			"account_number": wallet.AccountNumber,
-			"assets":         wallet.Assets(), // Returns the list of NGN, USD, etc.
+			"from_wallet_id": fromWalletID,
+			"to_wallet_id":   req.ToWalletID,
+			"amount":         amount.StringFixed(),
+			"currency":       amount.Currency(),
+			"reference":      idempotencyKey,
+			"receipt_url":    receiptURL(idempotencyKey),
 		},
 	})
 }
 
+// assetView reports both balances of an asset: ledger_balance is everything
+// the customer owns, available_balance excludes funds reserved by holds.
+func assetView(asset db.WalletAssetModel) map[string]interface{} {
+	precision := money.Precision(asset.Currency)
+	return map[string]interface{}{
+		"id":                asset.ID,
+		"walletId":          asset.WalletID,
+		"currency":          asset.Currency,
+		"balance":           asset.Balance.StringFixed(precision),
+		"ledger_balance":    asset.Balance.StringFixed(precision),
+		"held_balance":      asset.HeldBalance.StringFixed(precision),
+		"available_balance": repository.AvailableBalance(&asset).StringFixed(precision),
+	}
+}
+
+func assetViews(assets []db.WalletAssetModel) []map[string]interface{} {
+	views := make([]map[string]interface{}, 0, len(assets))
+	for _, asset := range assets {
+		views = append(views, assetView(asset))
+	}
+	return views
+}
+
 
 func (s *Server) FundWalletHandlerV1(w http.ResponseWriter, r *http.Request) {
 	//  Idempotency Check 
@@ -90,7 +260,8 @@ func (s *Server) FundWalletHandlerV1(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Currency == "" || req.Amount <= 0 {
+	amount, err := money.New(req.Amount, req.Currency)
+	if err != nil || !amount.IsPositive() {
 		utils.ErrorJSON(w, r, http.StatusBadRequest, errors.New("valid currency and amount greater than 0 are required"))
 		return
 	}
@@ -104,7 +275,7 @@ func (s *Server) FundWalletHandlerV1(w http.ResponseWriter, r *http.Request) {
 	}
 
 	
-	err := s.WalletService.FundWallet(r.Context(), userID, req.Currency, req.Amount, idempotencyKey, req.Description)
+	err = s.WalletService.FundWallet(r.Context(), userID, req.WalletID, amount, idempotencyKey, req.Description)
 	if err != nil {
 		if errors.Is(err, service.ErrTransactionAlreadyProcessed) {
 			utils.JSON(w, r, http.StatusOK, map[string]interface{}{
@@ -130,8 +301,8 @@ func (s *Server) FundWalletHandlerV1(w http.ResponseWriter, r *http.Request) {
 		"status":  "success",
 		"message": "wallet funded successfully",
 		"data": map[string]interface{}{
-			"amount":      req.Amount,
-			"currency":    req.Currency,
+			"amount":      amount.StringFixed(),
+			"currency":    amount.Currency(),
 			"reference":   idempotencyKey,
 		},
 	})
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/internals/service"
+	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// ListWebhookEventsHandler lists stored webhooks, newest first, optionally
+// filtered by ?provider= and ?status=.
+func (s *Server) ListWebhookEventsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	events, err := s.WebhookService.ListEvents(r.Context(), repository.WebhookEventFilter{
+		Provider: strings.ToUpper(q.Get("provider")),
+		Status:   db.WebhookEventStatus(strings.ToUpper(q.Get("status"))),
+		Limit:    limit,
+	})
+	if err != nil {
+		s.Logger.Error("failed to list webhook events", "error", err)
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "webhook events retrieved",
+		"data":    events,
+	})
+}
+
+func (s *Server) GetWebhookEventHandler(w http.ResponseWriter, r *http.Request) {
+	event, err := s.WebhookService.GetEvent(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, service.ErrWebhookEventNotFound) {
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.ErrorJSON(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "webhook event retrieved",
+		"data":    event,
+	})
+}
+
+// ReplayWebhookEventHandler processes a stored webhook again, now, and
+// returns it with its new status.
+func (s *Server) ReplayWebhookEventHandler(w http.ResponseWriter, r *http.Request) {
+	event, err := s.WebhookService.Replay(r.Context(), chi.URLParam(r, "id"))
+	switch {
+	case errors.Is(err, service.ErrWebhookEventNotFound):
+		utils.ErrorJSON(w, r, http.StatusNotFound, err)
+		return
+	case errors.Is(err, service.ErrWebhookNotReplayable):
+		utils.ErrorJSON(w, r, http.StatusConflict, err)
+		return
+	case err != nil:
+		s.Logger.Error("webhook replay failed", "event", chi.URLParam(r, "id"), "error", err)
+		utils.ErrorJSON(w, r, http.StatusUnprocessableEntity, fmt.Errorf("replay failed: %w", err))
+		return
+	}
+
+	utils.JSON(w, r, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "webhook event replayed",
+		"data":    event,
+	})
+}
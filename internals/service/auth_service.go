@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/theabdullahishola/mzl-payment-app/internals/config"
+	"github.com/theabdullahishola/mzl-payment-app/internals/notify"
 	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
 	"github.com/theabdullahishola/mzl-payment-app/internals/utils"
 	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
@@ -23,6 +25,7 @@ type AuthService interface {
 	VerifyTransactionPin(ctx context.Context, userID, plainPin string) error
 	SetTransactionPin(ctx context.Context, userID, plainPin string) error
 	RevokeRefreshToken(ctx context.Context, oldRefreshToken string) error
+	UpdateNotificationContact(ctx context.Context, userID string, contact repository.NotificationContact) (*db.UserModel, error)
 }
 
 var (
@@ -31,9 +34,10 @@ var (
 )
 
 type authService struct {
-	userRepo repository.UserRepository
-	config   *config.Config
-	redis    QueueService
+	userRepo      repository.UserRepository
+	config        *config.Config
+	redis         QueueService
+	notifications NotificationService
 }
 
 type UserCacheDTO struct {
@@ -64,8 +68,8 @@ func fromUserCache(c UserCacheDTO) *db.UserModel {
         },
     }
 }
-func NewAuthService(userRepo repository.UserRepository, cfg *config.Config, redis QueueService) AuthService {
-	return &authService{userRepo: userRepo, config: cfg, redis: redis}
+func NewAuthService(userRepo repository.UserRepository, cfg *config.Config, redis QueueService, notifications NotificationService) AuthService {
+	return &authService{userRepo: userRepo, config: cfg, redis: redis, notifications: notifications}
 }
 
 func (s *authService) Register(ctx context.Context, email, password, fullName string) (*db.UserModel, error) {
@@ -205,5 +209,22 @@ func (s *authService) SetTransactionPin(ctx context.Context, userID, plainPin st
 
 	cacheKey := fmt.Sprintf("user_profile:%s", userID)
 	s.redis.Delete(ctx, cacheKey)
-	return s.userRepo.UpdateTransactionPin(ctx, userID, string(hashedPin))
+	if err := s.userRepo.UpdateTransactionPin(ctx, userID, string(hashedPin)); err != nil {
+		return err
+	}
+
+	// The PIN is already changed, so a failure to queue the alert isn't the caller's problem.
+	if err := s.notifications.Notify(ctx, userID, notify.EventPinChanged, "", nil); err != nil {
+		slog.Default().Error("failed to queue pin change notification", "user_id", userID, "error", err)
+	}
+	return nil
+}
+
+func (s *authService) UpdateNotificationContact(ctx context.Context, userID string, contact repository.NotificationContact) (*db.UserModel, error) {
+	user, err := s.userRepo.UpdateNotificationContact(ctx, userID, contact)
+	if err != nil {
+		return nil, err
+	}
+	s.redis.Delete(ctx, fmt.Sprintf("user_profile:%s", userID))
+	return user, nil
 }
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+const (
+	maxCategoryNameLength = 50
+	maxRulePatternLength  = 100
+	maxTagNoteLength      = 280
+)
+
+var (
+	ErrCategoryNotFound     = errors.New("category not found")
+	ErrCategoryExists       = errors.New("you already have a category with this name")
+	ErrInvalidCategory      = errors.New("category name must be 1 to 50 characters and color a #rrggbb code")
+	ErrCategoryRuleNotFound = errors.New("category rule not found")
+	ErrInvalidCategoryRule  = errors.New("a rule needs a category, the text to look for (at most 100 characters) and a known transaction type if any")
+	ErrTaggedTxnNotFound    = errors.New("transaction not found")
+	ErrInvalidTag           = errors.New("a tag needs a category, a note (at most 280 characters) or both")
+)
+
+var categoryColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// TagRequest is what a user puts on one of their transactions.
+type TagRequest struct {
+	CategoryID string `json:"category_id"`
+	Note       string `json:"note"`
+}
+
+type CategoryService interface {
+	Categories(ctx context.Context, userID string) ([]db.CategoryModel, error)
+	CreateCategory(ctx context.Context, userID, name, color string) (*db.CategoryModel, error)
+	DeleteCategory(ctx context.Context, userID, id string) error
+
+	Rules(ctx context.Context, userID string) ([]db.CategoryRuleModel, error)
+	CreateRule(ctx context.Context, userID string, rule repository.NewCategoryRule) (*db.CategoryRuleModel, error)
+	DeleteRule(ctx context.Context, userID, id string) error
+
+	// Tag files the user's transaction with reference, replacing any tag it
+	// had; Untag removes it.
+	Tag(ctx context.Context, userID, reference string, req TagRequest) (*db.TransactionTagModel, error)
+	Untag(ctx context.Context, userID, reference string) error
+}
+
+type categoryService struct {
+	repo  repository.CategoryRepository
+	redis QueueService
+}
+
+func NewCategoryService(repo repository.CategoryRepository, redis QueueService) CategoryService {
+	return &categoryService{repo: repo, redis: redis}
+}
+
+func (s *categoryService) Categories(ctx context.Context, userID string) ([]db.CategoryModel, error) {
+	categories, err := s.repo.ListCategories(ctx, userID)
+	if categories == nil {
+		categories = []db.CategoryModel{}
+	}
+	return categories, err
+}
+
+func (s *categoryService) CreateCategory(ctx context.Context, userID, name, color string) (*db.CategoryModel, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxCategoryNameLength || (color != "" && !categoryColor.MatchString(color)) {
+		return nil, ErrInvalidCategory
+	}
+
+	category, err := s.repo.CreateCategory(ctx, userID, name, strings.ToLower(color))
+	if _, ok := db.IsErrUniqueConstraint(err); ok {
+		return nil, ErrCategoryExists
+	}
+	return category, err
+}
+
+func (s *categoryService) DeleteCategory(ctx context.Context, userID, id string) error {
+	err := s.repo.DeleteCategory(ctx, userID, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrCategoryNotFound
+	}
+	if err != nil {
+		return err
+	}
+	// The cached first page of history may show the category.
+	_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", userID))
+	return nil
+}
+
+func (s *categoryService) Rules(ctx context.Context, userID string) ([]db.CategoryRuleModel, error) {
+	rules, err := s.repo.ListCategoryRules(ctx, userID)
+	if rules == nil {
+		rules = []db.CategoryRuleModel{}
+	}
+	return rules, err
+}
+
+// CreateRule applies to transactions created from now on; existing ones
+// keep whatever category they have.
+func (s *categoryService) CreateRule(ctx context.Context, userID string, rule repository.NewCategoryRule) (*db.CategoryRuleModel, error) {
+	rule.DescriptionContains = strings.TrimSpace(rule.DescriptionContains)
+	if rule.CategoryID == "" || rule.DescriptionContains == "" ||
+		utf8.RuneCountInString(rule.DescriptionContains) > maxRulePatternLength {
+		return nil, ErrInvalidCategoryRule
+	}
+	switch rule.Type {
+	case "", db.TransactionTypeDeposit, db.TransactionTypeWithdrawal, db.TransactionTypeTransfer, db.TransactionTypeSwap, db.TransactionTypeChargeback, db.TransactionTypeVault, db.TransactionTypeInterest, db.TransactionTypeMove:
+	default:
+		return nil, ErrInvalidCategoryRule
+	}
+
+	if _, err := s.repo.GetCategory(ctx, userID, rule.CategoryID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return s.repo.CreateCategoryRule(ctx, userID, rule)
+}
+
+func (s *categoryService) DeleteRule(ctx context.Context, userID, id string) error {
+	err := s.repo.DeleteCategoryRule(ctx, userID, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrCategoryRuleNotFound
+	}
+	return err
+}
+
+func (s *categoryService) Tag(ctx context.Context, userID, reference string, req TagRequest) (*db.TransactionTagModel, error) {
+	req.Note = strings.TrimSpace(req.Note)
+	if (req.CategoryID == "" && req.Note == "") || utf8.RuneCountInString(req.Note) > maxTagNoteLength {
+		return nil, ErrInvalidTag
+	}
+
+	txn, err := s.userTransaction(ctx, userID, reference)
+	if err != nil {
+		return nil, err
+	}
+	var categoryID, note *string
+	if req.CategoryID != "" {
+		if _, err := s.repo.GetCategory(ctx, userID, req.CategoryID); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				return nil, ErrCategoryNotFound
+			}
+			return nil, err
+		}
+		categoryID = &req.CategoryID
+	}
+	if req.Note != "" {
+		note = &req.Note
+	}
+
+	tag, err := s.repo.TagTransaction(ctx, txn.ID, categoryID, note)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", userID))
+	return tag, nil
+}
+
+func (s *categoryService) Untag(ctx context.Context, userID, reference string) error {
+	txn, err := s.userTransaction(ctx, userID, reference)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UntagTransaction(ctx, txn.ID); err != nil {
+		return err
+	}
+	_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", userID))
+	return nil
+}
+
+func (s *categoryService) userTransaction(ctx context.Context, userID, reference string) (*db.TransactionModel, error) {
+	txn, err := s.repo.GetUserTransaction(ctx, userID, reference)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrTaggedTxnNotFound
+	}
+	return txn, err
+}
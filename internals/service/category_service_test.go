@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// fakeCategoryRepo keeps categories and tags in memory. Transactions are
+// keyed by reference and owned by the user in owners.
+type fakeCategoryRepo struct {
+	repository.CategoryRepository
+	categories map[string]string // id -> owner
+	owners     map[string]string // transaction reference -> owner
+	tags       map[string]db.InnerTransactionTag
+	rules      []repository.NewCategoryRule
+}
+
+func (r *fakeCategoryRepo) GetCategory(ctx context.Context, userID, id string) (*db.CategoryModel, error) {
+	if r.categories[id] != userID {
+		return nil, db.ErrNotFound
+	}
+	return &db.CategoryModel{InnerCategory: db.InnerCategory{ID: id, UserID: userID}}, nil
+}
+
+func (r *fakeCategoryRepo) CreateCategoryRule(ctx context.Context, userID string, rule repository.NewCategoryRule) (*db.CategoryRuleModel, error) {
+	r.rules = append(r.rules, rule)
+	return &db.CategoryRuleModel{}, nil
+}
+
+func (r *fakeCategoryRepo) GetUserTransaction(ctx context.Context, userID, reference string) (*db.TransactionModel, error) {
+	if r.owners[reference] != userID {
+		return nil, db.ErrNotFound
+	}
+	return &db.TransactionModel{InnerTransaction: db.InnerTransaction{ID: reference, Reference: reference}}, nil
+}
+
+func (r *fakeCategoryRepo) TagTransaction(ctx context.Context, transactionID string, categoryID, note *string) (*db.TransactionTagModel, error) {
+	tag := db.InnerTransactionTag{TransactionID: transactionID, CategoryID: categoryID, Note: note, Source: db.TagSourceManual}
+	r.tags[transactionID] = tag
+	return &db.TransactionTagModel{InnerTransactionTag: tag}, nil
+}
+
+func (r *fakeCategoryRepo) UntagTransaction(ctx context.Context, transactionID string) error {
+	delete(r.tags, transactionID)
+	return nil
+}
+
+func TestTagTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeCategoryRepo{
+		categories: map[string]string{"family": "u1", "theirs": "u2"},
+		owners:     map[string]string{"ref-DEBIT": "u1", "ref-CREDIT": "u2"},
+		tags:       map[string]db.InnerTransactionTag{},
+	}
+	queue := newJSONQueue()
+	svc := NewCategoryService(repo, queue)
+
+	if _, err := svc.Tag(ctx, "u1", "ref-DEBIT", TagRequest{Note: "  "}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected a blank tag to be refused, got %v", err)
+	}
+	if _, err := svc.Tag(ctx, "u1", "ref-CREDIT", TagRequest{CategoryID: "family"}); !errors.Is(err, ErrTaggedTxnNotFound) {
+		t.Fatalf("expected the other party's leg to be out of reach, got %v", err)
+	}
+	if _, err := svc.Tag(ctx, "u1", "ref-DEBIT", TagRequest{CategoryID: "theirs"}); !errors.Is(err, ErrCategoryNotFound) {
+		t.Fatalf("expected another user's category to be refused, got %v", err)
+	}
+
+	tag, err := svc.Tag(ctx, "u1", "ref-DEBIT", TagRequest{CategoryID: "family", Note: " Ada's school fees "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := tag.CategoryID(); id != "family" {
+		t.Fatalf("expected the family category, got %q", id)
+	}
+	if note, _ := tag.Note(); note != "Ada's school fees" {
+		t.Fatalf("expected the trimmed note, got %q", note)
+	}
+	if len(queue.deleted) != 1 || queue.deleted[0] != "tx_history:u1" {
+		t.Fatalf("expected the cached history to be dropped, got %v", queue.deleted)
+	}
+
+	if err := svc.Untag(ctx, "u1", "ref-DEBIT"); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.tags) != 0 {
+		t.Fatalf("expected the tag to be removed, got %v", repo.tags)
+	}
+}
+
+func TestCreateCategoryRule(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeCategoryRepo{categories: map[string]string{"family": "u1", "theirs": "u2"}}
+	svc := NewCategoryService(repo, newJSONQueue())
+
+	bad := []repository.NewCategoryRule{
+		{CategoryID: "family"},
+		{CategoryID: "family", DescriptionContains: "Ada", Type: "GIFT"},
+		{DescriptionContains: "Ada"},
+	}
+	for _, rule := range bad {
+		if _, err := svc.CreateRule(ctx, "u1", rule); !errors.Is(err, ErrInvalidCategoryRule) {
+			t.Fatalf("expected %+v to be refused, got %v", rule, err)
+		}
+	}
+	if _, err := svc.CreateRule(ctx, "u1", repository.NewCategoryRule{CategoryID: "theirs", DescriptionContains: "Ada"}); !errors.Is(err, ErrCategoryNotFound) {
+		t.Fatalf("expected another user's category to be refused, got %v", err)
+	}
+
+	_, err := svc.CreateRule(ctx, "u1", repository.NewCategoryRule{CategoryID: "family", DescriptionContains: " Transfer to Ada ", Type: db.TransactionTypeTransfer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.rules) != 1 || repo.rules[0].DescriptionContains != "Transfer to Ada" {
+		t.Fatalf("expected one trimmed rule, got %+v", repo.rules)
+	}
+}
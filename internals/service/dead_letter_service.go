@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+var (
+	ErrUnknownQueue     = errors.New("unknown queue")
+	ErrNothingSelected  = errors.New("pass the job ids to act on, or all: true")
+	ErrAmbiguousRequest = errors.New("pass either job ids or all: true, not both")
+)
+
+const (
+	AuditDeadLetterRequeue = "DLQ_REQUEUE"
+	AuditDeadLetterPurge   = "DLQ_PURGE"
+)
+
+// DeadLetterQueues are the queues whose failed jobs can be managed.
+var DeadLetterQueues = []string{WebhookQueue, WithdrawalQueue, DomainEventQueue, NotificationQueue, StatementQueue}
+
+// DeadLetter is a job that ran out of retries.
+type DeadLetter struct {
+	ID        string          `json:"id"`
+	Queue     string          `json:"queue"`
+	Payload   json.RawMessage `json:"payload"`
+	Retries   int             `json:"retries"`
+	LastError string          `json:"last_error,omitempty"`
+	FailedAt  *time.Time      `json:"failed_at,omitempty"`
+}
+
+// DeadLetterStore is where failed jobs end up; RedisQueue implements it.
+// An empty ids slice means every job in the queue.
+type DeadLetterStore interface {
+	DeadLetterDepth(ctx context.Context, queueName string) (int64, error)
+	ListDeadLetters(ctx context.Context, queueName string, offset, limit int) ([]DeadLetter, error)
+	RequeueDeadLetters(ctx context.Context, queueName string, ids []string) (int, error)
+	PurgeDeadLetters(ctx context.Context, queueName string, ids []string) (int, error)
+}
+
+// DeadLetterSelection picks jobs by id, or every job with All. Acting on
+// everything has to be asked for explicitly.
+type DeadLetterSelection struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+type DeadLetterService interface {
+	Depths(ctx context.Context) (map[string]int64, error)
+	List(ctx context.Context, queueName string, offset, limit int) ([]DeadLetter, error)
+	Requeue(ctx context.Context, actor, queueName string, sel DeadLetterSelection) (int, error)
+	Purge(ctx context.Context, actor, queueName string, sel DeadLetterSelection) (int, error)
+	AuditLog(ctx context.Context, limit int) ([]db.AuditLogModel, error)
+}
+
+type deadLetterService struct {
+	store  DeadLetterStore
+	audit  repository.AuditRepository
+	logger *slog.Logger
+}
+
+func NewDeadLetterService(store DeadLetterStore, audit repository.AuditRepository, logger *slog.Logger) DeadLetterService {
+	return &deadLetterService{store: store, audit: audit, logger: logger}
+}
+
+// Depths reports how many jobs sit in each dead-letter queue.
+func (s *deadLetterService) Depths(ctx context.Context) (map[string]int64, error) {
+	depths := make(map[string]int64, len(DeadLetterQueues))
+	for _, queueName := range DeadLetterQueues {
+		depth, err := s.store.DeadLetterDepth(ctx, queueName)
+		if err != nil {
+			return nil, err
+		}
+		depths[queueName] = depth
+	}
+	return depths, nil
+}
+
+func (s *deadLetterService) List(ctx context.Context, queueName string, offset, limit int) ([]DeadLetter, error) {
+	if err := checkQueue(queueName); err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.store.ListDeadLetters(ctx, queueName, offset, limit)
+}
+
+func (s *deadLetterService) Requeue(ctx context.Context, actor, queueName string, sel DeadLetterSelection) (int, error) {
+	return s.act(ctx, actor, AuditDeadLetterRequeue, queueName, sel, s.store.RequeueDeadLetters)
+}
+
+func (s *deadLetterService) Purge(ctx context.Context, actor, queueName string, sel DeadLetterSelection) (int, error) {
+	return s.act(ctx, actor, AuditDeadLetterPurge, queueName, sel, s.store.PurgeDeadLetters)
+}
+
+func (s *deadLetterService) AuditLog(ctx context.Context, limit int) ([]db.AuditLogModel, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.audit.ListAuditLogs(ctx, limit)
+}
+
+// act runs a requeue or purge and records it in the audit log, including
+// when it fails halfway.
+func (s *deadLetterService) act(ctx context.Context, actor, action, queueName string, sel DeadLetterSelection, fn func(context.Context, string, []string) (int, error)) (int, error) {
+	if err := checkQueue(queueName); err != nil {
+		return 0, err
+	}
+	switch {
+	case sel.All && len(sel.IDs) > 0:
+		return 0, ErrAmbiguousRequest
+	case !sel.All && len(sel.IDs) == 0:
+		return 0, ErrNothingSelected
+	}
+
+	count, err := fn(ctx, queueName, sel.IDs)
+
+	detail := map[string]interface{}{"ids": sel.IDs, "all": sel.All, "count": count}
+	if err != nil {
+		detail["error"] = err.Error()
+	}
+	auditErr := s.audit.RecordAudit(ctx, repository.AuditEntry{
+		Actor:  actor,
+		Action: action,
+		Target: queueName,
+		Detail: detail,
+	})
+	if auditErr != nil {
+		s.logger.Error("failed to write audit log", "action", action, "queue", queueName, "error", auditErr)
+	}
+
+	if err != nil {
+		return count, fmt.Errorf("%s on %s stopped after %d jobs: %w", action, queueName, count, err)
+	}
+	return count, nil
+}
+
+func checkQueue(queueName string) error {
+	for _, known := range DeadLetterQueues {
+		if queueName == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownQueue, queueName)
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type fakeDeadLetterStore struct {
+	letters map[string][]string // queue -> job ids
+	failAt  int                 // fail on this job (1-based); 0 never fails
+}
+
+func (f *fakeDeadLetterStore) DeadLetterDepth(ctx context.Context, queueName string) (int64, error) {
+	return int64(len(f.letters[queueName])), nil
+}
+
+func (f *fakeDeadLetterStore) ListDeadLetters(ctx context.Context, queueName string, offset, limit int) ([]DeadLetter, error) {
+	var out []DeadLetter
+	for _, id := range f.letters[queueName] {
+		out = append(out, DeadLetter{ID: id, Queue: queueName})
+	}
+	return out, nil
+}
+
+func (f *fakeDeadLetterStore) RequeueDeadLetters(ctx context.Context, queueName string, ids []string) (int, error) {
+	return f.remove(queueName, ids)
+}
+
+func (f *fakeDeadLetterStore) PurgeDeadLetters(ctx context.Context, queueName string, ids []string) (int, error) {
+	return f.remove(queueName, ids)
+}
+
+func (f *fakeDeadLetterStore) remove(queueName string, ids []string) (int, error) {
+	wanted := make(map[string]bool)
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var kept []string
+	done := 0
+	for _, id := range f.letters[queueName] {
+		if len(ids) > 0 && !wanted[id] {
+			kept = append(kept, id)
+			continue
+		}
+		if f.failAt > 0 && done+1 == f.failAt {
+			return done, errors.New("redis went away")
+		}
+		done++
+	}
+	f.letters[queueName] = kept
+	return done, nil
+}
+
+type fakeAuditRepo struct {
+	entries []repository.AuditEntry
+}
+
+func (f *fakeAuditRepo) RecordAudit(ctx context.Context, entry repository.AuditEntry) error {
+	f.entries = append(f.entries, entry)
+	return nil
+}
+
+func (f *fakeAuditRepo) ListAuditLogs(ctx context.Context, limit int) ([]db.AuditLogModel, error) {
+	return nil, nil
+}
+
+func newTestDeadLetterService() (DeadLetterService, *fakeDeadLetterStore, *fakeAuditRepo) {
+	store := &fakeDeadLetterStore{letters: map[string][]string{
+		WebhookQueue: {"a", "b", "c"},
+	}}
+	audit := &fakeAuditRepo{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewDeadLetterService(store, audit, logger), store, audit
+}
+
+func TestDeadLetterService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Depths", func(t *testing.T) {
+		svc, _, _ := newTestDeadLetterService()
+		depths, err := svc.Depths(ctx)
+		if err != nil || depths[WebhookQueue] != 3 || depths[WithdrawalQueue] != 0 {
+			t.Fatalf("unexpected depths %v, %v", depths, err)
+		}
+	})
+
+	t.Run("Rejects Bad Selections", func(t *testing.T) {
+		svc, store, audit := newTestDeadLetterService()
+		cases := []struct {
+			queue string
+			sel   DeadLetterSelection
+			want  error
+		}{
+			{"emails", DeadLetterSelection{All: true}, ErrUnknownQueue},
+			{WebhookQueue, DeadLetterSelection{}, ErrNothingSelected},
+			{WebhookQueue, DeadLetterSelection{IDs: []string{"a"}, All: true}, ErrAmbiguousRequest},
+		}
+		for _, tc := range cases {
+			if _, err := svc.Purge(ctx, "ops", tc.queue, tc.sel); !errors.Is(err, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, err)
+			}
+		}
+		if len(store.letters[WebhookQueue]) != 3 || len(audit.entries) != 0 {
+			t.Fatal("expected rejected requests to touch nothing")
+		}
+	})
+
+	t.Run("Requeue Is Audited", func(t *testing.T) {
+		svc, store, audit := newTestDeadLetterService()
+		count, err := svc.Requeue(ctx, "ops", WebhookQueue, DeadLetterSelection{IDs: []string{"b"}})
+		if err != nil || count != 1 || len(store.letters[WebhookQueue]) != 2 {
+			t.Fatalf("expected one job requeued, got %d, %v", count, err)
+		}
+		if len(audit.entries) != 1 {
+			t.Fatalf("expected one audit entry, got %d", len(audit.entries))
+		}
+		entry := audit.entries[0]
+		detail := entry.Detail.(map[string]interface{})
+		if entry.Actor != "ops" || entry.Action != AuditDeadLetterRequeue || entry.Target != WebhookQueue || detail["count"] != 1 {
+			t.Fatalf("unexpected audit entry %+v", entry)
+		}
+	})
+
+	t.Run("Failure Is Audited", func(t *testing.T) {
+		svc, store, audit := newTestDeadLetterService()
+		store.failAt = 2
+		count, err := svc.Purge(ctx, "ops", WebhookQueue, DeadLetterSelection{All: true})
+		if err == nil || count != 1 {
+			t.Fatalf("expected the purge to stop after one job, got %d, %v", count, err)
+		}
+		detail := audit.entries[0].Detail.(map[string]interface{})
+		if detail["count"] != 1 || detail["error"] == nil {
+			t.Fatalf("expected the partial purge in the audit log, got %v", detail)
+		}
+	})
+}
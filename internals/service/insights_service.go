@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+const (
+	DefaultInsightMonths = 6
+	MaxInsightMonths     = 24
+
+	// topCounterparties is how many counterparties are listed per currency.
+	topCounterparties = 5
+
+	// insightsMonthTTL bounds how long a completed month stays cached. The
+	// generation in its key already changes when the month does.
+	insightsMonthTTL = 30 * 24 * time.Hour
+)
+
+// Insights summarises a user's successful transactions month by month.
+// Swaps, vault moves and moves between wallets keep money with the user, so
+// they count towards ByType but not towards inflow and outflow.
+type Insights struct {
+	From              time.Time             `json:"from"`
+	To                time.Time             `json:"to"`
+	Months            []MonthlySummary      `json:"months"`
+	ByType            []TypeBreakdown       `json:"by_type"`
+	TopCounterparties []CounterpartySummary `json:"top_counterparties"`
+}
+
+type MonthlySummary struct {
+	Month        string          `json:"month"`
+	Currency     string          `json:"currency"`
+	Inflow       decimal.Decimal `json:"inflow"`
+	Outflow      decimal.Decimal `json:"outflow"`
+	Net          decimal.Decimal `json:"net"`
+	Transactions int             `json:"transactions"`
+
+	// Percent change from the month before; absent when that month was zero.
+	InflowChange  *decimal.Decimal `json:"inflow_change_pct,omitempty"`
+	OutflowChange *decimal.Decimal `json:"outflow_change_pct,omitempty"`
+}
+
+type TypeBreakdown struct {
+	Type      db.TransactionType      `json:"type"`
+	Direction db.TransactionDirection `json:"direction"`
+	Currency  string                  `json:"currency"`
+	Count     int                     `json:"count"`
+	Total     decimal.Decimal         `json:"total"`
+}
+
+type CounterpartySummary struct {
+	Name          string          `json:"name"`
+	AccountNumber string          `json:"account_number,omitempty"`
+	Currency      string          `json:"currency"`
+	Sent          decimal.Decimal `json:"sent"`
+	Received      decimal.Decimal `json:"received"`
+	Transactions  int             `json:"transactions"`
+}
+
+// insightsMonth is one month's aggregates as cached.
+type insightsMonth struct {
+	Totals         []repository.MonthlyTotal      `json:"totals"`
+	Counterparties []repository.CounterpartyTotal `json:"counterparties"`
+}
+
+type InsightsService interface {
+	// Insights covers the last months calendar months, this one included,
+	// in every currency or only currency when it is set.
+	Insights(ctx context.Context, userID string, months int, currency string) (*Insights, error)
+}
+
+type insightsService struct {
+	repo  repository.InsightsRepository
+	redis QueueService
+	now   func() time.Time
+}
+
+func NewInsightsService(repo repository.InsightsRepository, redis QueueService) InsightsService {
+	return &insightsService{repo: repo, redis: redis, now: time.Now}
+}
+
+// insightsGenerationKey holds a token that is part of every cached month's
+// key, so replacing it drops all of a user's cached months at once.
+func insightsGenerationKey(userID string) string {
+	return fmt.Sprintf("insights_gen:%s", userID)
+}
+
+func (s *insightsService) Insights(ctx context.Context, userID string, months int, currency string) (*Insights, error) {
+	if months <= 0 {
+		months = DefaultInsightMonths
+	}
+	months = min(months, MaxInsightMonths)
+
+	now := s.now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	first := current.AddDate(0, 1-months, 0)
+
+	// The month before the first is loaded too, for the first change.
+	data, err := s.loadMonths(ctx, userID, first.AddDate(0, -1, 0), current)
+	if err != nil {
+		return nil, err
+	}
+
+	insights := &Insights{
+		From:              first,
+		To:                current.AddDate(0, 1, 0),
+		Months:            []MonthlySummary{},
+		ByType:            []TypeBreakdown{},
+		TopCounterparties: []CounterpartySummary{},
+	}
+
+	type key struct{ month, currency string }
+	summaries := map[key]*MonthlySummary{}
+	currencies := map[string]bool{}
+	types := map[TypeBreakdown]*TypeBreakdown{}
+	for month, m := range data {
+		inRange := month >= first.Format("2006-01")
+		for _, t := range m.Totals {
+			if currency != "" && t.Currency != currency {
+				continue
+			}
+			k := key{month, t.Currency}
+			if summaries[k] == nil {
+				summaries[k] = &MonthlySummary{Month: month, Currency: t.Currency}
+			}
+			summary := summaries[k]
+			summary.Transactions += t.Count
+			if t.Type != db.TransactionTypeSwap && t.Type != db.TransactionTypeVault && t.Type != db.TransactionTypeMove {
+				if t.Direction == db.TransactionDirectionCredit {
+					summary.Inflow = summary.Inflow.Add(t.Total)
+				} else {
+					summary.Outflow = summary.Outflow.Add(t.Total)
+				}
+			}
+			if !inRange {
+				continue
+			}
+			currencies[t.Currency] = true
+			tk := TypeBreakdown{Type: t.Type, Direction: t.Direction, Currency: t.Currency}
+			if types[tk] == nil {
+				types[tk] = &TypeBreakdown{Type: t.Type, Direction: t.Direction, Currency: t.Currency}
+			}
+			types[tk].Count += t.Count
+			types[tk].Total = types[tk].Total.Add(t.Total)
+		}
+	}
+
+	// Every month gets a row per currency, so quiet months chart as zero.
+	for cur := range currencies {
+		for month := first; !month.After(current); month = month.AddDate(0, 1, 0) {
+			summary := summaries[key{month.Format("2006-01"), cur}]
+			if summary == nil {
+				summary = &MonthlySummary{Month: month.Format("2006-01"), Currency: cur}
+			}
+			summary.Net = summary.Inflow.Sub(summary.Outflow)
+			if prev := summaries[key{month.AddDate(0, -1, 0).Format("2006-01"), cur}]; prev != nil {
+				summary.InflowChange = percentChange(prev.Inflow, summary.Inflow)
+				summary.OutflowChange = percentChange(prev.Outflow, summary.Outflow)
+			}
+			insights.Months = append(insights.Months, *summary)
+		}
+	}
+	sort.Slice(insights.Months, func(i, j int) bool {
+		a, b := insights.Months[i], insights.Months[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		return a.Month < b.Month
+	})
+
+	for _, t := range types {
+		insights.ByType = append(insights.ByType, *t)
+	}
+	sort.Slice(insights.ByType, func(i, j int) bool {
+		a, b := insights.ByType[i], insights.ByType[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		return a.Total.GreaterThan(b.Total)
+	})
+
+	insights.TopCounterparties = topCounterpartiesIn(data, first.Format("2006-01"), currency)
+	return insights, nil
+}
+
+func topCounterpartiesIn(data map[string]*insightsMonth, from, currency string) []CounterpartySummary {
+	type key struct{ currency, name, account string }
+	byParty := map[key]*CounterpartySummary{}
+	for month, m := range data {
+		if month < from {
+			continue
+		}
+		for _, c := range m.Counterparties {
+			if currency != "" && c.Currency != currency {
+				continue
+			}
+			k := key{c.Currency, c.Name, c.AccountNumber}
+			if byParty[k] == nil {
+				byParty[k] = &CounterpartySummary{Name: c.Name, AccountNumber: c.AccountNumber, Currency: c.Currency}
+			}
+			party := byParty[k]
+			party.Transactions += c.Count
+			if c.Direction == db.TransactionDirectionDebit {
+				party.Sent = party.Sent.Add(c.Total)
+			} else {
+				party.Received = party.Received.Add(c.Total)
+			}
+		}
+	}
+
+	parties := make([]CounterpartySummary, 0, len(byParty))
+	for _, p := range byParty {
+		parties = append(parties, *p)
+	}
+	sort.Slice(parties, func(i, j int) bool {
+		a, b := parties[i], parties[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if ta, tb := a.Sent.Add(a.Received), b.Sent.Add(b.Received); !ta.Equal(tb) {
+			return ta.GreaterThan(tb)
+		}
+		return a.Name < b.Name
+	})
+
+	top := []CounterpartySummary{}
+	perCurrency := map[string]int{}
+	for _, p := range parties {
+		if perCurrency[p.Currency] < topCounterparties {
+			top = append(top, p)
+			perCurrency[p.Currency]++
+		}
+	}
+	return top
+}
+
+// loadMonths returns the aggregates of every month from first to last,
+// keyed "2006-01". Completed months come from the cache when they can; the
+// rest are aggregated in one pass over the span they cover and the
+// completed ones cached.
+func (s *insightsService) loadMonths(ctx context.Context, userID string, first, last time.Time) (map[string]*insightsMonth, error) {
+	var generation string
+	if err := s.redis.Get(ctx, insightsGenerationKey(userID), &generation); err != nil {
+		generation = "0"
+	}
+	cacheKey := func(month string) string {
+		return fmt.Sprintf("insights:%s:%s:%s", userID, generation, month)
+	}
+
+	data := map[string]*insightsMonth{}
+	var missing []time.Time
+	for month := first; !month.After(last); month = month.AddDate(0, 1, 0) {
+		if month.Before(last) {
+			var cached insightsMonth
+			if err := s.redis.Get(ctx, cacheKey(month.Format("2006-01")), &cached); err == nil {
+				data[month.Format("2006-01")] = &cached
+				continue
+			}
+		}
+		missing = append(missing, month)
+	}
+	if len(missing) == 0 {
+		return data, nil
+	}
+
+	from, to := missing[0], missing[len(missing)-1].AddDate(0, 1, 0)
+	totals, err := s.repo.MonthlyTotals(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	counterparties, err := s.repo.CounterpartyTotals(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := map[string]*insightsMonth{}
+	for _, month := range missing {
+		loaded[month.Format("2006-01")] = &insightsMonth{
+			Totals:         []repository.MonthlyTotal{},
+			Counterparties: []repository.CounterpartyTotal{},
+		}
+	}
+	for _, t := range totals {
+		if m := loaded[t.Month]; m != nil {
+			m.Totals = append(m.Totals, t)
+		}
+	}
+	for _, c := range counterparties {
+		if m := loaded[c.Month]; m != nil {
+			m.Counterparties = append(m.Counterparties, c)
+		}
+	}
+	currentMonth := last.Format("2006-01")
+	for month, m := range loaded {
+		data[month] = m
+		if month != currentMonth {
+			_ = s.redis.Set(ctx, cacheKey(month), m, insightsMonthTTL)
+		}
+	}
+	return data, nil
+}
+
+func percentChange(prev, cur decimal.Decimal) *decimal.Decimal {
+	if prev.IsZero() {
+		return nil
+	}
+	change := cur.Sub(prev).Div(prev).Mul(decimal.NewFromInt(100)).Round(2)
+	return &change
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// fakeInsightsRepo serves fixed aggregates and records the span of each query.
+type fakeInsightsRepo struct {
+	totals         []repository.MonthlyTotal
+	counterparties []repository.CounterpartyTotal
+	queried        []string // "from..to" months
+}
+
+func inMonths(month string, from, to time.Time) bool {
+	return month >= from.Format("2006-01") && month < to.Format("2006-01")
+}
+
+func (r *fakeInsightsRepo) MonthlyTotals(ctx context.Context, userID string, from, to time.Time) ([]repository.MonthlyTotal, error) {
+	r.queried = append(r.queried, from.Format("2006-01")+".."+to.Format("2006-01"))
+	var out []repository.MonthlyTotal
+	for _, t := range r.totals {
+		if inMonths(t.Month, from, to) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeInsightsRepo) CounterpartyTotals(ctx context.Context, userID string, from, to time.Time) ([]repository.CounterpartyTotal, error) {
+	var out []repository.CounterpartyTotal
+	for _, c := range r.counterparties {
+		if inMonths(c.Month, from, to) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func monthlyTotal(month string, txType db.TransactionType, direction db.TransactionDirection, total int64) repository.MonthlyTotal {
+	return repository.MonthlyTotal{Month: month, Currency: "NGN", Type: txType, Direction: direction, Count: 1, Total: decimal.NewFromInt(total)}
+}
+
+func TestInsights(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeInsightsRepo{
+		totals: []repository.MonthlyTotal{
+			monthlyTotal("2026-08", db.TransactionTypeDeposit, db.TransactionDirectionCredit, 10000),
+			monthlyTotal("2026-08", db.TransactionTypeTransfer, db.TransactionDirectionDebit, 4000),
+			monthlyTotal("2026-09", db.TransactionTypeDeposit, db.TransactionDirectionCredit, 15000),
+			monthlyTotal("2026-09", db.TransactionTypeTransfer, db.TransactionDirectionDebit, 2000),
+			monthlyTotal("2026-09", db.TransactionTypeSwap, db.TransactionDirectionDebit, 9000),
+			monthlyTotal("2026-10", db.TransactionTypeWithdrawal, db.TransactionDirectionDebit, 1000),
+		},
+		counterparties: []repository.CounterpartyTotal{
+			{Month: "2026-08", Currency: "NGN", Direction: db.TransactionDirectionDebit, Name: "Bola Ade", AccountNumber: "9876543210", Count: 1, Total: decimal.NewFromInt(4000)},
+			{Month: "2026-09", Currency: "NGN", Direction: db.TransactionDirectionDebit, Name: "Bola Ade", AccountNumber: "9876543210", Count: 1, Total: decimal.NewFromInt(2000)},
+			{Month: "2026-09", Currency: "NGN", Direction: db.TransactionDirectionCredit, Name: "PAYSTACK", Count: 1, Total: decimal.NewFromInt(15000)},
+		},
+	}
+	queue := newJSONQueue()
+	svc := &insightsService{repo: repo, redis: queue, now: func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }}
+
+	insights, err := svc.Insights(ctx, "u1", 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insights.Months) != 2 || insights.Months[0].Month != "2026-09" || insights.Months[1].Month != "2026-10" {
+		t.Fatalf("expected September and October, got %+v", insights.Months)
+	}
+	sept := insights.Months[0]
+	if !sept.Inflow.Equal(decimal.NewFromInt(15000)) || !sept.Outflow.Equal(decimal.NewFromInt(2000)) || sept.Transactions != 3 {
+		t.Fatalf("expected the swap left out of September's flows, got %+v", sept)
+	}
+	if sept.InflowChange == nil || !sept.InflowChange.Equal(decimal.NewFromInt(50)) || !sept.OutflowChange.Equal(decimal.NewFromInt(-50)) {
+		t.Fatalf("expected +50%% inflow and -50%% outflow against August, got %v and %v", sept.InflowChange, sept.OutflowChange)
+	}
+	if oct := insights.Months[1]; !oct.Net.Equal(decimal.NewFromInt(-1000)) || oct.InflowChange == nil || !oct.InflowChange.Equal(decimal.NewFromInt(-100)) {
+		t.Fatalf("unexpected October %+v", oct)
+	}
+	if len(insights.ByType) != 4 || insights.ByType[0].Type != db.TransactionTypeDeposit {
+		t.Fatalf("expected four types in range, largest first, got %+v", insights.ByType)
+	}
+	if len(insights.TopCounterparties) != 2 || insights.TopCounterparties[0].Name != "PAYSTACK" || !insights.TopCounterparties[1].Sent.Equal(decimal.NewFromInt(2000)) {
+		t.Fatalf("expected counterparties from the range only, got %+v", insights.TopCounterparties)
+	}
+
+	// Completed months are now cached; only the current one is aggregated again.
+	repo.queried = nil
+	if _, err := svc.Insights(ctx, "u1", 2, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.queried) != 1 || repo.queried[0] != "2026-10..2026-11" {
+		t.Fatalf("expected only October to be queried, got %v", repo.queried)
+	}
+
+	// A withdrawal settling can change a cached month, so it drops them all.
+	event := DomainEvent{ID: "e9", Type: repository.EventWithdrawalFailed, Data: repository.WalletEvent{UserID: "u1"}}
+	if err := InvalidateWalletCaches(queue)(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	repo.queried = nil
+	if _, err := svc.Insights(ctx, "u1", 2, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.queried) != 1 || repo.queried[0] != "2026-08..2026-11" {
+		t.Fatalf("expected every month to be queried again, got %v", repo.queried)
+	}
+
+	var generation string
+	_ = json.Unmarshal(queue.values[insightsGenerationKey("u1")], &generation)
+	if generation != "e9" {
+		t.Fatalf("expected the event to become the generation, got %q", generation)
+	}
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+var (
+	ErrInterestRunning = errors.New("an interest payout is already in progress")
+	ErrInterestMonth   = errors.New("that month hasn't ended yet")
+)
+
+const interestLock = "interest:payout"
+
+// Interest line statuses.
+const (
+	InterestPending     = "PENDING"      // previewed, not paid yet
+	InterestPaid        = "PAID"         // paid by this run
+	InterestAlreadyPaid = "ALREADY_PAID" // paid by another run
+	InterestCarried     = "CARRIED"      // under one minor unit, left for next month
+	InterestFailed      = "FAILED"       // retried on the next run
+)
+
+// InterestRateKey picks the rate for a currency and product: WALLET accounts
+// earn on spendable balances, VAULT accounts on savings.
+type InterestRateKey struct {
+	Currency string
+	Product  db.LedgerAccountType
+}
+
+// InterestRate is one configured annual rate, in percent.
+type InterestRate struct {
+	Currency   string               `json:"currency"`
+	Product    db.LedgerAccountType `json:"product"`
+	AnnualRate decimal.Decimal      `json:"annual_rate"`
+}
+
+// ParseInterestRates reads a comma-separated list of currency:product=percent,
+// e.g. "NGN:WALLET=2.5,NGN:VAULT=10". Accounts without a rate earn nothing.
+func ParseInterestRates(spec string) (map[InterestRateKey]decimal.Decimal, error) {
+	rates := map[InterestRateKey]decimal.Decimal{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		currency, product, ok2 := strings.Cut(key, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("interest rate %q: want currency:product=percent", entry)
+		}
+
+		k := InterestRateKey{
+			Currency: strings.ToUpper(strings.TrimSpace(currency)),
+			Product:  db.LedgerAccountType(strings.ToUpper(strings.TrimSpace(product))),
+		}
+		if k.Product != db.LedgerAccountTypeWallet && k.Product != db.LedgerAccountTypeVault {
+			return nil, fmt.Errorf("interest rate %q: product must be WALLET or VAULT", entry)
+		}
+		rate, err := decimal.NewFromString(strings.TrimSpace(value))
+		if err != nil || rate.IsNegative() || rate.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+			return nil, fmt.Errorf("interest rate %q: percent must be at least 0 and under 100", entry)
+		}
+		rates[k] = rate
+	}
+	return rates, nil
+}
+
+// DailyInterest is one day's interest on balance at an annual rate in
+// percent. Days are counted actual/actual: a day is 1/366 of a leap year and
+// 1/365 of any other.
+func DailyInterest(balance, rate decimal.Decimal, day time.Time) decimal.Decimal {
+	days := time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	return balance.Mul(rate).Div(decimal.NewFromInt(100 * int64(days))).Round(10)
+}
+
+// InterestLine is what a payout pays, or would pay, one account.
+type InterestLine struct {
+	repository.InterestDue
+	Amount    decimal.Decimal `json:"amount"` // Accrued rounded down to the currency's minor unit
+	Reference string          `json:"reference"`
+	Status    string          `json:"status"`
+}
+
+// InterestPayout covers the unpaid accruals of the days before Before.
+type InterestPayout struct {
+	Month  string                     `json:"month"` // "2006-01"
+	Before time.Time                  `json:"before"`
+	Lines  []InterestLine             `json:"lines"`
+	Totals map[string]decimal.Decimal `json:"totals"` // per currency, what is or would be paid
+}
+
+type InterestService interface {
+	Rates() []InterestRate
+	// Accrue records each day that has ended since the last accrual, on
+	// the balances the ledger showed at the end of it. It returns how many
+	// days it accrued.
+	Accrue(ctx context.Context) (int, error)
+	// Preview accrues and then shows what paying month would pay, without
+	// paying it. The current month shows what has accrued so far.
+	Preview(ctx context.Context, month time.Time) (*InterestPayout, error)
+	// Pay pays month's interest. Each account's payment has a reference
+	// made of the month and the account, so a month is never paid twice.
+	Pay(ctx context.Context, month time.Time) (*InterestPayout, error)
+	StartScheduler(ctx context.Context, interval time.Duration)
+}
+
+type interestService struct {
+	repo   repository.InterestRepository
+	rates  map[InterestRateKey]decimal.Decimal
+	redis  QueueService
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewInterestService(repo repository.InterestRepository, rates map[InterestRateKey]decimal.Decimal, redis QueueService, logger *slog.Logger) InterestService {
+	return &interestService{repo: repo, rates: rates, redis: redis, logger: logger, now: time.Now}
+}
+
+func (s *interestService) Rates() []InterestRate {
+	rates := make([]InterestRate, 0, len(s.rates))
+	for k, rate := range s.rates {
+		rates = append(rates, InterestRate{Currency: k.Currency, Product: k.Product, AnnualRate: rate})
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Currency != rates[j].Currency {
+			return rates[i].Currency < rates[j].Currency
+		}
+		return rates[i].Product > rates[j].Product // WALLET before VAULT
+	})
+	return rates
+}
+
+func (s *interestService) Accrue(ctx context.Context) (int, error) {
+	if len(s.rates) == 0 {
+		return 0, nil
+	}
+	today := startOfDay(s.now())
+
+	// Without an earlier accrual, start with yesterday rather than the
+	// beginning of the ledger.
+	day := today.AddDate(0, 0, -1)
+	if last, ok, err := s.repo.LastAccrualDay(ctx); err != nil {
+		return 0, err
+	} else if ok {
+		day = startOfDay(last).AddDate(0, 0, 1)
+	}
+
+	accrued := 0
+	for ; day.Before(today); day = day.AddDate(0, 0, 1) {
+		end := day.AddDate(0, 0, 1)
+		balances, err := s.repo.LedgerBalances(ctx, end)
+		if err != nil {
+			return accrued, err
+		}
+
+		var accruals []repository.NewInterestAccrual
+		for _, b := range balances {
+			rate, ok := s.rates[InterestRateKey{Currency: b.Currency, Product: b.AccountType}]
+			if !ok || !rate.IsPositive() {
+				continue
+			}
+			accruals = append(accruals, repository.NewInterestAccrual{
+				AccountBalance: b,
+				Day:            day,
+				AnnualRate:     rate,
+				Amount:         DailyInterest(b.Balance, rate, day),
+			})
+		}
+		if err := s.repo.RecordAccruals(ctx, accruals); err != nil {
+			return accrued, err
+		}
+		accrued++
+	}
+	return accrued, nil
+}
+
+func (s *interestService) Preview(ctx context.Context, month time.Time) (*InterestPayout, error) {
+	from := startOfMonth(month)
+	if from.After(s.now()) {
+		return nil, ErrInterestMonth
+	}
+	if _, err := s.Accrue(ctx); err != nil {
+		return nil, err
+	}
+	return s.payout(ctx, from)
+}
+
+func (s *interestService) Pay(ctx context.Context, month time.Time) (*InterestPayout, error) {
+	from := startOfMonth(month)
+	if from.AddDate(0, 1, 0).After(s.now()) {
+		return nil, ErrInterestMonth
+	}
+
+	locked, _ := s.redis.TryLockIdempotencyKey(ctx, interestLock, 30*time.Minute)
+	if !locked {
+		return nil, ErrInterestRunning
+	}
+	defer s.redis.Delete(context.Background(), "idemp:"+interestLock)
+
+	if _, err := s.Accrue(ctx); err != nil {
+		return nil, err
+	}
+	payout, err := s.payout(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Interest for %s", from.Format("January 2006"))
+	for i := range payout.Lines {
+		line := &payout.Lines[i]
+		if line.Status != InterestPending {
+			continue
+		}
+		err := s.repo.PayInterest(ctx, repository.InterestPayment{
+			InterestDue: line.InterestDue,
+			Before:      payout.Before,
+			Amount:      money.Round(line.Amount, line.Currency),
+			Reference:   line.Reference,
+			Description: description,
+		})
+		if _, ok := db.IsErrUniqueConstraint(err); ok {
+			line.Status = InterestAlreadyPaid
+			continue
+		}
+		if err != nil {
+			s.logger.Error("failed to pay interest", "reference", line.Reference, "error", err)
+			line.Status = InterestFailed
+			payout.Totals[line.Currency] = payout.Totals[line.Currency].Sub(line.Amount)
+			continue
+		}
+		line.Status = InterestPaid
+	}
+	return payout, nil
+}
+
+// payout lists the unpaid accruals of the days before the end of month,
+// or before today for the current month.
+func (s *interestService) payout(ctx context.Context, month time.Time) (*InterestPayout, error) {
+	before := month.AddDate(0, 1, 0)
+	if today := startOfDay(s.now()); today.Before(before) {
+		before = today
+	}
+
+	due, err := s.repo.InterestDue(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	payout := &InterestPayout{
+		Month:  month.Format("2006-01"),
+		Before: before,
+		Lines:  make([]InterestLine, 0, len(due)),
+		Totals: map[string]decimal.Decimal{},
+	}
+	for _, d := range due {
+		line := InterestLine{
+			InterestDue: d,
+			Amount:      d.Accrued.Truncate(money.Precision(d.Currency)),
+			Reference:   fmt.Sprintf("INTEREST-%s-%s-%s", payout.Month, d.Account, d.Currency),
+			Status:      InterestPending,
+		}
+		if !line.Amount.IsPositive() {
+			line.Amount = decimal.Zero
+			line.Status = InterestCarried
+		}
+		payout.Totals[d.Currency] = payout.Totals[d.Currency].Add(line.Amount)
+		payout.Lines = append(payout.Lines, line)
+	}
+	return payout, nil
+}
+
+// StartScheduler accrues the days that have ended and pays last month's
+// interest on every tick; both are no-ops once done.
+func (s *interestService) StartScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || len(s.rates) == 0 {
+		s.logger.Info("interest schedule disabled")
+		return
+	}
+
+	s.logger.Info("interest scheduled", "interval", interval.String())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			payout, err := s.Pay(ctx, startOfMonth(s.now()).AddDate(0, -1, 0))
+			if errors.Is(err, ErrInterestRunning) {
+				continue
+			}
+			if err != nil {
+				s.logger.Error("interest run failed", "error", err)
+				continue
+			}
+			paid := 0
+			for _, line := range payout.Lines {
+				if line.Status == InterestPaid {
+					paid++
+				}
+			}
+			if paid > 0 {
+				s.logger.Info("interest paid", "month", payout.Month, "accounts", paid)
+			}
+		}
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// interestLedger keeps accruals in memory. Every account holds the same
+// balances on every day.
+type interestLedger struct {
+	repository.InterestRepository
+	balances []repository.AccountBalance
+	accruals []repository.NewInterestAccrual
+	carried  map[int]bool   // accrual index -> a remainder carried by PayInterest
+	paid     map[int]string // accrual index -> reference
+	payments []repository.InterestPayment
+}
+
+func (l *interestLedger) LedgerBalances(ctx context.Context, at time.Time) ([]repository.AccountBalance, error) {
+	return l.balances, nil
+}
+
+func (l *interestLedger) LastAccrualDay(ctx context.Context) (time.Time, bool, error) {
+	for i := len(l.accruals) - 1; i >= 0; i-- {
+		if !l.carried[i] {
+			return l.accruals[i].Day, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+func (l *interestLedger) RecordAccruals(ctx context.Context, accruals []repository.NewInterestAccrual) error {
+	l.accruals = append(l.accruals, accruals...)
+	return nil
+}
+
+func (l *interestLedger) InterestDue(ctx context.Context, before time.Time) ([]repository.InterestDue, error) {
+	var due []repository.InterestDue
+	index := map[string]int{}
+	for i, a := range l.accruals {
+		if _, paid := l.paid[i]; paid || !a.Day.Before(before) {
+			continue
+		}
+		if _, ok := index[a.Account]; !ok {
+			index[a.Account] = len(due)
+			due = append(due, repository.InterestDue{Account: a.Account, AccountType: a.AccountType, Currency: a.Currency, WalletID: a.WalletID})
+		}
+		d := &due[index[a.Account]]
+		if !l.carried[i] {
+			d.Days++
+		}
+		d.Accrued = d.Accrued.Add(a.Amount)
+	}
+	return due, nil
+}
+
+func (l *interestLedger) PayInterest(ctx context.Context, p repository.InterestPayment) error {
+	for i, a := range l.accruals {
+		if _, paid := l.paid[i]; !paid && a.Account == p.Account && a.Day.Before(p.Before) {
+			l.paid[i] = p.Reference
+		}
+	}
+	if carry := p.Accrued.Sub(p.Amount.Amount()); carry.IsPositive() {
+		l.carried[len(l.accruals)] = true
+		l.accruals = append(l.accruals, repository.NewInterestAccrual{
+			AccountBalance: repository.AccountBalance{Account: p.Account, AccountType: p.AccountType, Currency: p.Currency, WalletID: p.WalletID},
+			Day:            p.Before,
+			Amount:         carry,
+		})
+	}
+	l.payments = append(l.payments, p)
+	return nil
+}
+
+func TestParseInterestRates(t *testing.T) {
+	rates, err := ParseInterestRates(" ngn:wallet=2.5, NGN:VAULT=10 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || !rates[InterestRateKey{"NGN", db.LedgerAccountTypeVault}].Equal(decimal.NewFromInt(10)) {
+		t.Fatalf("expected NGN wallet and vault rates, got %v", rates)
+	}
+
+	for _, spec := range []string{"NGN=2", "NGN:SYSTEM=2", "NGN:VAULT=-1", "NGN:VAULT=100", "NGN:VAULT=lots"} {
+		if _, err := ParseInterestRates(spec); err == nil {
+			t.Fatalf("expected %q to be refused", spec)
+		}
+	}
+}
+
+func TestDailyInterestCountsActualDays(t *testing.T) {
+	balance, rate := decimal.NewFromInt(36500), decimal.NewFromInt(10)
+
+	if got := DailyInterest(balance, rate, time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC)); !got.Equal(decimal.NewFromInt(10)) {
+		t.Fatalf("expected 10 a day in a 365-day year, got %s", got)
+	}
+	if got := DailyInterest(balance, rate, time.Date(2028, 6, 1, 0, 0, 0, 0, time.UTC)); got.String() != "9.9726775956" {
+		t.Fatalf("expected 3650/366 a day in a leap year, got %s", got)
+	}
+}
+
+func TestInterestAccruesAndPaysOncePerMonth(t *testing.T) {
+	ctx := context.Background()
+	ledger := &interestLedger{
+		balances: []repository.AccountBalance{
+			{Account: "WALLET:w1", AccountType: db.LedgerAccountTypeWallet, Currency: "NGN", WalletID: "w1", Balance: decimal.NewFromInt(36500)},
+			{Account: "VAULT:v1", AccountType: db.LedgerAccountTypeVault, Currency: "NGN", WalletID: "w1", VaultID: "v1", Balance: decimal.NewFromInt(73000)},
+			{Account: "WALLET:w2", AccountType: db.LedgerAccountTypeWallet, Currency: "NGN", WalletID: "w2", Balance: decimal.NewFromInt(10)},
+			{Account: "WALLET:w1", AccountType: db.LedgerAccountTypeWallet, Currency: "USD", WalletID: "w1", Balance: decimal.NewFromInt(500)},
+		},
+		carried: map[int]bool{},
+		paid:    map[int]string{},
+	}
+	rates := map[InterestRateKey]decimal.Decimal{
+		{"NGN", db.LedgerAccountTypeWallet}: decimal.NewFromInt(1),
+		{"NGN", db.LedgerAccountTypeVault}:  decimal.NewFromInt(10),
+	}
+	svc := NewInterestService(ledger, rates, newJSONQueue(), slog.New(slog.NewTextHandler(io.Discard, nil))).(*interestService)
+
+	// The first run only accrues the day before.
+	svc.now = func() time.Time { return time.Date(2027, 9, 21, 8, 0, 0, 0, time.UTC) }
+	if days, err := svc.Accrue(ctx); err != nil || days != 1 {
+		t.Fatalf("expected one day accrued, got %d (%v)", days, err)
+	}
+	if _, err := svc.Pay(ctx, time.Date(2027, 9, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrInterestMonth) {
+		t.Fatalf("expected the running month to be refused, got %v", err)
+	}
+
+	// Twelve days later the missed days, 21 September to 2 October, are
+	// caught up before September is paid.
+	svc.now = func() time.Time { return time.Date(2027, 10, 3, 8, 0, 0, 0, time.UTC) }
+	payout, err := svc.Pay(ctx, time.Date(2027, 9, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// USD has no rate, so three accounts over 13 days.
+	if len(ledger.accruals) != 3*13 {
+		t.Fatalf("expected 39 accruals, got %d", len(ledger.accruals))
+	}
+
+	// 11 September days: 1 a day on the wallet, 20 a day in the vault, and
+	// 10 * 1% / 365 a day on w2, which is under a kobo.
+	want := map[string]string{"WALLET:w1": "11", "VAULT:v1": "220", "WALLET:w2": "0"}
+	for _, line := range payout.Lines {
+		if line.Amount.String() != want[line.Account] || line.Days != 11 {
+			t.Fatalf("expected %s over 11 days on %s, got %s over %d", want[line.Account], line.Account, line.Amount, line.Days)
+		}
+		if line.Account == "WALLET:w2" && line.Status != InterestCarried {
+			t.Fatalf("expected the sub-kobo amount to be carried, got %s", line.Status)
+		}
+	}
+	if len(ledger.payments) != 2 || ledger.payments[0].Reference != "INTEREST-2027-09-WALLET:w1-NGN" {
+		t.Fatalf("expected two payments with per-period references, got %+v", ledger.payments)
+	}
+	if !payout.Totals["NGN"].Equal(decimal.NewFromInt(231)) {
+		t.Fatalf("expected 231 NGN paid, got %s", payout.Totals["NGN"])
+	}
+
+	again, err := svc.Pay(ctx, time.Date(2027, 9, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.payments) != 2 || len(again.Lines) != 1 || again.Lines[0].Status != InterestCarried {
+		t.Fatalf("expected a re-run to pay nothing new, got %+v", again.Lines)
+	}
+
+	preview, err := svc.Preview(ctx, time.Date(2027, 10, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range preview.Lines {
+		if line.Status == InterestPaid {
+			t.Fatalf("expected a preview to pay nothing, got %+v", line)
+		}
+	}
+	if len(ledger.payments) != 2 {
+		t.Fatalf("expected a preview to pay nothing, got %d payments", len(ledger.payments))
+	}
+}
+
+func TestInterestRemainderCarriesToNextMonth(t *testing.T) {
+	ctx := context.Background()
+	ledger := &interestLedger{
+		balances: []repository.AccountBalance{
+			{Account: "WALLET:w1", AccountType: db.LedgerAccountTypeWallet, Currency: "NGN", WalletID: "w1", Balance: decimal.NewFromInt(1000)},
+		},
+		carried: map[int]bool{},
+		paid:    map[int]string{},
+	}
+	rates := map[InterestRateKey]decimal.Decimal{{"NGN", db.LedgerAccountTypeWallet}: decimal.NewFromInt(10)}
+	svc := NewInterestService(ledger, rates, newJSONQueue(), slog.New(slog.NewTextHandler(io.Discard, nil))).(*interestService)
+
+	// 1000 at 10% is 0.2739726027 a day, so every month leaves part of a kobo.
+	pay := func(month time.Month) InterestLine {
+		t.Helper()
+		svc.now = func() time.Time { return time.Date(2027, month+1, 1, 8, 0, 0, 0, time.UTC) }
+		payout, err := svc.Pay(ctx, time.Date(2027, month, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil || len(payout.Lines) != 1 || payout.Lines[0].Status != InterestPaid {
+			t.Fatalf("expected %s paid, got %+v, %v", month, payout, err)
+		}
+		return payout.Lines[0]
+	}
+
+	svc.now = func() time.Time { return time.Date(2027, 9, 1, 8, 0, 0, 0, time.UTC) }
+	if _, err := svc.Accrue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	pay(time.August) // 31 August alone: 0.27, carrying 0.0039726027
+
+	// 30 days make 8.219178081; with the carry that is 8.22 rather than 8.21.
+	september := pay(time.September)
+	if september.Amount.StringFixed(2) != "8.22" || september.Days != 30 {
+		t.Fatalf("expected 8.22 over 30 days, got %s over %d", september.Amount, september.Days)
+	}
+
+	october := pay(time.October)
+	paid := decimal.Zero
+	for _, p := range ledger.payments {
+		paid = paid.Add(p.Amount.Amount())
+	}
+	accrued := decimal.Zero
+	for i, a := range ledger.accruals {
+		if !ledger.carried[i] {
+			accrued = accrued.Add(a.Amount)
+		}
+	}
+	// Over 62 days nothing is lost but what is still carried.
+	if october.Amount.StringFixed(2) != "8.49" || !paid.Equal(accrued.Truncate(2)) {
+		t.Fatalf("expected 8.49 in October and %s paid in all, got %s and %s", accrued.Truncate(2), october.Amount, paid)
+	}
+}
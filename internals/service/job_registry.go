@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoJobHandler = errors.New("no handler registered for job type")
+
+// JobHandler runs one job of a registered type.
+type JobHandler func(ctx context.Context, payload []byte) error
+
+// JobStats counts what happened to jobs of one type since the process started.
+type JobStats struct {
+	Processed     int64      `json:"processed"`
+	Failed        int64      `json:"failed"`
+	Retried       int64      `json:"retried"`
+	DeadLettered  int64      `json:"dead_lettered"`
+	Reclaimed     int64      `json:"reclaimed"`
+	AvgDurationMs float64    `json:"avg_duration_ms"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+
+	totalDuration time.Duration
+}
+
+// Job outcomes that happen outside the handler itself.
+const (
+	JobRetried      = "retried"
+	JobDeadLettered = "dead_lettered"
+	JobReclaimed    = "reclaimed"
+)
+
+type registeredJob struct {
+	handler JobHandler
+	opts    WorkerOptions
+}
+
+// JobRegistry maps job types to their handlers and worker options, and keeps
+// per-type stats. Queue implementations dispatch through it.
+type JobRegistry struct {
+	mu    sync.Mutex
+	jobs  map[string]registeredJob
+	stats map[string]*JobStats
+}
+
+func NewJobRegistry() *JobRegistry {
+	return &JobRegistry{
+		jobs:  make(map[string]registeredJob),
+		stats: make(map[string]*JobStats),
+	}
+}
+
+// Register sets the handler for a job type, replacing any earlier one.
+func (r *JobRegistry) Register(jobType string, handler JobHandler, opts WorkerOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[jobType] = registeredJob{handler: handler, opts: opts}
+	if r.stats[jobType] == nil {
+		r.stats[jobType] = &JobStats{}
+	}
+}
+
+// Types lists the registered job types in name order.
+func (r *JobRegistry) Types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	types := make([]string, 0, len(r.jobs))
+	for jobType := range r.jobs {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func (r *JobRegistry) Options(jobType string) WorkerOptions {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.jobs[jobType].opts
+}
+
+// Run hands the payload to the job type's handler and records the result.
+func (r *JobRegistry) Run(ctx context.Context, jobType string, payload []byte) error {
+	r.mu.Lock()
+	job, ok := r.jobs[jobType]
+	r.mu.Unlock()
+	if !ok {
+		err := fmt.Errorf("%w: %q", ErrNoJobHandler, jobType)
+		r.recordRun(jobType, 0, err)
+		return err
+	}
+
+	start := time.Now()
+	err := job.handler(ctx, payload)
+	r.recordRun(jobType, time.Since(start), err)
+	return err
+}
+
+func (r *JobRegistry) recordRun(jobType string, took time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.statsFor(jobType)
+	if err != nil {
+		now := time.Now().UTC()
+		stats.Failed++
+		stats.LastError = err.Error()
+		stats.LastErrorAt = &now
+		return
+	}
+	stats.Processed++
+	stats.totalDuration += took
+	stats.AvgDurationMs = float64(stats.totalDuration) / float64(stats.Processed) / float64(time.Millisecond)
+}
+
+// Record counts an outcome decided by the queue rather than the handler:
+// JobRetried, JobDeadLettered or JobReclaimed.
+func (r *JobRegistry) Record(jobType, outcome string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.statsFor(jobType)
+	switch outcome {
+	case JobRetried:
+		stats.Retried++
+	case JobDeadLettered:
+		stats.DeadLettered++
+	case JobReclaimed:
+		stats.Reclaimed++
+	}
+}
+
+// Stats returns a copy of every job type's stats.
+func (r *JobRegistry) Stats() map[string]JobStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]JobStats, len(r.stats))
+	for jobType, stats := range r.stats {
+		out[jobType] = *stats
+	}
+	return out
+}
+
+// statsFor also tracks types nobody registered, so stray jobs show up.
+// Callers hold r.mu.
+func (r *JobRegistry) statsFor(jobType string) *JobStats {
+	stats := r.stats[jobType]
+	if stats == nil {
+		stats = &JobStats{}
+		r.stats[jobType] = stats
+	}
+	return stats
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestJobRegistry(t *testing.T) {
+	ctx := context.Background()
+	r := NewJobRegistry()
+
+	var got []string
+	r.Register("emails", func(ctx context.Context, payload []byte) error {
+		got = append(got, string(payload))
+		return nil
+	}, WorkerOptions{Concurrency: 2})
+	r.Register("payouts", func(ctx context.Context, payload []byte) error {
+		return errors.New("gateway down")
+	}, WorkerOptions{})
+
+	if types := r.Types(); len(types) != 2 || types[0] != "emails" || types[1] != "payouts" {
+		t.Fatalf("unexpected types %v", types)
+	}
+	if r.Options("emails").Concurrency != 2 {
+		t.Fatal("expected the options registered with the handler")
+	}
+
+	if err := r.Run(ctx, "emails", []byte("hello")); err != nil || len(got) != 1 || got[0] != "hello" {
+		t.Fatalf("expected the emails handler to run, got %v, %v", got, err)
+	}
+	if err := r.Run(ctx, "payouts", nil); err == nil {
+		t.Fatal("expected the payouts error to be returned")
+	}
+	if err := r.Run(ctx, "sms", nil); !errors.Is(err, ErrNoJobHandler) {
+		t.Fatalf("expected ErrNoJobHandler, got %v", err)
+	}
+	r.Record("payouts", JobRetried)
+	r.Record("payouts", JobDeadLettered)
+
+	stats := r.Stats()
+	if stats["emails"].Processed != 1 || stats["emails"].Failed != 0 {
+		t.Errorf("unexpected emails stats %+v", stats["emails"])
+	}
+	if p := stats["payouts"]; p.Failed != 1 || p.Retried != 1 || p.DeadLettered != 1 || p.LastError != "gateway down" {
+		t.Errorf("unexpected payouts stats %+v", p)
+	}
+	if stats["sms"].Failed != 1 {
+		t.Errorf("expected the unregistered type to be counted, got %+v", stats["sms"])
+	}
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/notify"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+const (
+	// NotificationQueue carries one notificationJob per user, event and channel.
+	NotificationQueue = "notifications"
+
+	// notificationSentTTL is how long a delivered notification is remembered
+	// so a repeated domain event doesn't notify twice.
+	notificationSentTTL = 7 * 24 * time.Hour
+)
+
+type notificationJob struct {
+	UserID  string            `json:"user_id"`
+	Event   string            `json:"event"`
+	Channel string            `json:"channel"`
+	Data    map[string]string `json:"data"`
+
+	// Key identifies what caused the notification, e.g. a domain event id.
+	// Jobs with the same key, event and channel are only delivered once.
+	Key string `json:"key,omitempty"`
+}
+
+var (
+	ErrNotificationNotFound          = errors.New("notification not found")
+	ErrUnknownNotificationPreference = errors.New("no such notification event or channel")
+)
+
+type NotificationService interface {
+	// Notify queues event for every channel it has a template for and the
+	// user hasn't turned off.
+	Notify(ctx context.Context, userID, event, key string, data map[string]string) error
+	// Deliver is the NotificationQueue job handler.
+	Deliver(ctx context.Context, payload []byte) error
+	// OnDomainEvent notifies the users a ledger change concerns.
+	OnDomainEvent(ctx context.Context, event DomainEvent) error
+
+	Inbox(ctx context.Context, userID string, offset, limit int) ([]InboxNotification, error)
+	UnreadCount(ctx context.Context, userID string) (int, error)
+	MarkRead(ctx context.Context, userID, id string) error
+	MarkAllRead(ctx context.Context, userID string) (int, error)
+
+	// Preferences maps each event to the channels it can go out on and
+	// whether the user receives it there.
+	Preferences(ctx context.Context, userID string) (map[string]map[string]bool, error)
+	UpdatePreferences(ctx context.Context, userID string, prefs map[string]map[string]bool) error
+}
+
+// InboxNotification is an entry in a user's in-app inbox.
+type InboxNotification struct {
+	ID        string            `json:"id"`
+	Event     string            `json:"event"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	Data      map[string]string `json:"data,omitempty"`
+	Read      bool              `json:"read"`
+	ReadAt    *time.Time        `json:"read_at,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type notificationService struct {
+	users     repository.UserRepository
+	repo      repository.NotificationRepository
+	templates *notify.Templates
+	senders   map[string]notify.Sender
+	redis     QueueService
+	logger    *slog.Logger
+}
+
+// NewNotificationService sends on the channels in senders, keyed by
+// notify.ChannelEmail, notify.ChannelSMS, notify.ChannelPush or
+// notify.ChannelInbox.
+func NewNotificationService(users repository.UserRepository, repo repository.NotificationRepository, templates *notify.Templates, senders map[string]notify.Sender, redis QueueService, logger *slog.Logger) NotificationService {
+	return &notificationService{users: users, repo: repo, templates: templates, senders: senders, redis: redis, logger: logger}
+}
+
+// channels are the channels event can go out on, in a stable order.
+func (s *notificationService) channels(event string) []string {
+	var channels []string
+	for _, channel := range notify.Channels {
+		if s.senders[channel] != nil && s.templates.Supports(event, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+func (s *notificationService) Notify(ctx context.Context, userID, event, key string, data map[string]string) error {
+	prefs, err := s.repo.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("load notification preferences: %w", err)
+	}
+	disabled := make(map[string]bool)
+	for _, p := range prefs {
+		if p.Event == event && !p.Enabled {
+			disabled[p.Channel] = true
+		}
+	}
+
+	for _, channel := range s.channels(event) {
+		if disabled[channel] {
+			continue
+		}
+		job, err := json.Marshal(notificationJob{UserID: userID, Event: event, Channel: channel, Data: data, Key: key})
+		if err != nil {
+			return err
+		}
+		if err := s.redis.Enqueue(ctx, NotificationQueue, job); err != nil {
+			return fmt.Errorf("queue %s %s notification: %w", event, channel, err)
+		}
+	}
+	return nil
+}
+
+func (s *notificationService) Deliver(ctx context.Context, payload []byte) error {
+	var job notificationJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return fmt.Errorf("unreadable notification job: %w", err)
+	}
+
+	sentKey := ""
+	if job.Key != "" {
+		sentKey = fmt.Sprintf("notified:%s:%s:%s", job.Key, job.Event, job.Channel)
+		var sent bool
+		if err := s.redis.Get(ctx, sentKey, &sent); err == nil {
+			return nil
+		}
+	}
+
+	sender := s.senders[job.Channel]
+	if sender == nil {
+		return fmt.Errorf("no sender for channel %q", job.Channel)
+	}
+
+	user, err := s.users.FindUserByID(ctx, job.UserID)
+	if errors.Is(err, db.ErrNotFound) {
+		s.logger.Warn("dropping notification for unknown user", "user_id", job.UserID, "event", job.Event)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	to := recipient(user, job.Channel)
+	if to == "" {
+		// Nowhere to send it on this channel; that isn't worth retrying.
+		return nil
+	}
+
+	data := make(map[string]string, len(job.Data)+1)
+	for k, v := range job.Data {
+		data[k] = v
+	}
+	data["name"] = user.Name
+
+	subject, body, err := s.templates.Render(user.Locale, job.Event, job.Channel, data)
+	if err != nil {
+		return err
+	}
+	msg := notify.Message{Channel: job.Channel, Event: job.Event, To: to, Subject: subject, Body: body, Data: job.Data}
+	if job.Key != "" {
+		msg.Key = job.Key + ":" + job.Event
+	}
+	if err := sender.Send(ctx, msg); err != nil {
+		return fmt.Errorf("send %s %s: %w", job.Event, job.Channel, err)
+	}
+
+	if sentKey != "" {
+		_ = s.redis.Set(ctx, sentKey, true, notificationSentTTL)
+	}
+	return nil
+}
+
+func recipient(user *db.UserModel, channel string) string {
+	switch channel {
+	case notify.ChannelEmail:
+		return user.Email
+	case notify.ChannelSMS:
+		phone, _ := user.Phone()
+		return phone
+	case notify.ChannelPush:
+		token, _ := user.PushToken()
+		return token
+	case notify.ChannelInbox:
+		return user.ID
+	}
+	return ""
+}
+
+func (s *notificationService) OnDomainEvent(ctx context.Context, event DomainEvent) error {
+	e := event.Data
+	data := map[string]string{
+		"amount":      e.Amount.StringFixed(money.Precision(e.Currency)),
+		"currency":    e.Currency,
+		"reference":   e.Reference,
+		"description": e.Description,
+	}
+
+	switch event.Type {
+	case repository.EventWalletCredited:
+		if e.Provider == "" {
+			return nil // not a deposit, e.g. a manual credit
+		}
+		return s.Notify(ctx, e.UserID, notify.EventDepositReceived, event.ID, data)
+
+	case repository.EventTransferCompleted:
+		if sender, err := s.users.FindUserByID(ctx, e.UserID); err == nil {
+			data["sender"] = sender.Name
+		}
+		return s.Notify(ctx, e.CounterpartyUserID, notify.EventTransferReceived, event.ID, data)
+
+	case repository.EventWithdrawalSucceeded:
+		return s.Notify(ctx, e.UserID, notify.EventWithdrawalSucceeded, event.ID, data)
+
+	case repository.EventWithdrawalFailed, repository.EventWithdrawalReversed:
+		return s.Notify(ctx, e.UserID, notify.EventWithdrawalFailed, event.ID, data)
+	}
+	return nil
+}
+
+func (s *notificationService) Inbox(ctx context.Context, userID string, offset, limit int) ([]InboxNotification, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	rows, err := s.repo.ListInbox(ctx, userID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	inbox := make([]InboxNotification, 0, len(rows))
+	for _, row := range rows {
+		n := InboxNotification{
+			ID:        row.ID,
+			Event:     row.Event,
+			Title:     row.Title,
+			Body:      row.Body,
+			CreatedAt: row.CreatedAt,
+		}
+		if readAt, ok := row.ReadAt(); ok {
+			n.Read, n.ReadAt = true, &readAt
+		}
+		if data, ok := row.Data(); ok {
+			_ = json.Unmarshal([]byte(data), &n.Data)
+		}
+		inbox = append(inbox, n)
+	}
+	return inbox, nil
+}
+
+func (s *notificationService) UnreadCount(ctx context.Context, userID string) (int, error) {
+	return s.repo.CountUnread(ctx, userID)
+}
+
+func (s *notificationService) MarkRead(ctx context.Context, userID, id string) error {
+	err := s.repo.MarkRead(ctx, userID, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrNotificationNotFound
+	}
+	return err
+}
+
+func (s *notificationService) MarkAllRead(ctx context.Context, userID string) (int, error) {
+	return s.repo.MarkAllRead(ctx, userID)
+}
+
+func (s *notificationService) Preferences(ctx context.Context, userID string) (map[string]map[string]bool, error) {
+	prefs := make(map[string]map[string]bool)
+	for _, event := range notify.Events {
+		for _, channel := range s.channels(event) {
+			if prefs[event] == nil {
+				prefs[event] = make(map[string]bool)
+			}
+			prefs[event][channel] = true
+		}
+	}
+
+	saved, err := s.repo.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range saved {
+		if _, ok := prefs[p.Event][p.Channel]; ok {
+			prefs[p.Event][p.Channel] = p.Enabled
+		}
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences changes the given event and channel pairs, leaving the
+// rest as they are. Nothing is changed if any pair is unknown.
+func (s *notificationService) UpdatePreferences(ctx context.Context, userID string, prefs map[string]map[string]bool) error {
+	current, err := s.Preferences(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for event, channels := range prefs {
+		for channel := range channels {
+			if _, ok := current[event][channel]; !ok {
+				return fmt.Errorf("%w: %s/%s", ErrUnknownNotificationPreference, event, channel)
+			}
+		}
+	}
+
+	for event, channels := range prefs {
+		for channel, enabled := range channels {
+			if err := s.repo.SetNotificationPreference(ctx, userID, event, channel, enabled); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type inboxSender struct {
+	repo repository.NotificationRepository
+}
+
+// NewInboxSender delivers notifications to the user's in-app inbox. It
+// expects msg.To to be the user id.
+func NewInboxSender(repo repository.NotificationRepository) notify.Sender {
+	return &inboxSender{repo: repo}
+}
+
+func (s *inboxSender) Send(ctx context.Context, msg notify.Message) error {
+	return s.repo.CreateInboxEntry(ctx, repository.InboxEntry{
+		UserID: msg.To,
+		Event:  msg.Event,
+		Title:  msg.Subject,
+		Body:   msg.Body,
+		Data:   msg.Data,
+		Key:    msg.Key,
+	})
+}
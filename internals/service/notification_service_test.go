@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/notify"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type notifyUsers struct {
+	repository.UserRepository
+	users map[string]*db.UserModel
+}
+
+func (f notifyUsers) FindUserByID(ctx context.Context, id string) (*db.UserModel, error) {
+	if user, ok := f.users[id]; ok {
+		return user, nil
+	}
+	return nil, db.ErrNotFound
+}
+
+type fakeNotificationRepo struct {
+	repository.NotificationRepository
+	prefs []db.NotificationPreferenceModel
+	inbox []repository.InboxEntry
+}
+
+func (f *fakeNotificationRepo) ListNotificationPreferences(ctx context.Context, userID string) ([]db.NotificationPreferenceModel, error) {
+	var out []db.NotificationPreferenceModel
+	for _, p := range f.prefs {
+		if p.UserID == userID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeNotificationRepo) SetNotificationPreference(ctx context.Context, userID, event, channel string, enabled bool) error {
+	for i, p := range f.prefs {
+		if p.UserID == userID && p.Event == event && p.Channel == channel {
+			f.prefs[i].Enabled = enabled
+			return nil
+		}
+	}
+	f.prefs = append(f.prefs, db.NotificationPreferenceModel{InnerNotificationPreference: db.InnerNotificationPreference{
+		UserID: userID, Event: event, Channel: channel, Enabled: enabled,
+	}})
+	return nil
+}
+
+func (f *fakeNotificationRepo) CreateInboxEntry(ctx context.Context, entry repository.InboxEntry) error {
+	for _, e := range f.inbox {
+		if entry.Key != "" && e.Key == entry.Key {
+			return nil
+		}
+	}
+	f.inbox = append(f.inbox, entry)
+	return nil
+}
+
+type recordingSender struct {
+	sent []notify.Message
+	err  error
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg notify.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func newTestNotificationService(t *testing.T) (*notificationService, *jsonQueue, *recordingSender, *recordingSender, *fakeNotificationRepo) {
+	templates, err := notify.DefaultTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone := "+2348000000000"
+	receiver := &db.UserModel{InnerUser: db.InnerUser{ID: "receiver", Name: "Ada", Email: "ada@example.com", Locale: "en"}}
+	receiver.InnerUser.Phone = &phone
+
+	email, sms := &recordingSender{}, &recordingSender{}
+	queue := newJSONQueue()
+	repo := &fakeNotificationRepo{}
+	svc := &notificationService{
+		users: notifyUsers{users: map[string]*db.UserModel{
+			"receiver": receiver,
+			"sender":   {InnerUser: db.InnerUser{ID: "sender", Name: "Bola", Email: "bola@example.com", Locale: "en"}},
+		}},
+		repo:      repo,
+		templates: templates,
+		senders: map[string]notify.Sender{
+			notify.ChannelEmail: email,
+			notify.ChannelSMS:   sms,
+			notify.ChannelPush:  &recordingSender{},
+			notify.ChannelInbox: NewInboxSender(repo),
+		},
+		redis:  queue,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	return svc, queue, email, sms, repo
+}
+
+func TestNotificationServiceTransferReceived(t *testing.T) {
+	ctx := context.Background()
+	svc, queue, email, sms, repo := newTestNotificationService(t)
+
+	err := svc.OnDomainEvent(ctx, DomainEvent{ID: "e1", Type: repository.EventTransferCompleted, Data: repository.WalletEvent{
+		UserID:             "sender",
+		CounterpartyUserID: "receiver",
+		Amount:             decimal.NewFromInt(2500),
+		Currency:           "NGN",
+		Reference:          "TRF-1",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.jobs) != 4 {
+		t.Fatalf("expected a job per channel, got %d", len(queue.jobs))
+	}
+
+	for _, job := range queue.jobs {
+		if err := svc.Deliver(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(email.sent) != 1 || email.sent[0].To != "ada@example.com" || email.sent[0].Subject != "You received NGN 2500.00 from Bola" {
+		t.Fatalf("unexpected email %+v", email.sent)
+	}
+	if len(sms.sent) != 1 || sms.sent[0].To != "+2348000000000" {
+		t.Fatalf("unexpected sms %+v", sms.sent)
+	}
+	if len(repo.inbox) != 1 || repo.inbox[0].UserID != "receiver" || repo.inbox[0].Title != "Money received" || repo.inbox[0].Data["reference"] != "TRF-1" {
+		t.Fatalf("unexpected inbox %+v", repo.inbox)
+	}
+
+	// A redelivered job must not notify the user again.
+	if err := svc.Deliver(ctx, queue.jobs[0]); err != nil || len(email.sent) != 1 {
+		t.Fatalf("expected the repeat to be skipped, got %d emails, %v", len(email.sent), err)
+	}
+}
+
+func TestNotificationServiceAmountPrecision(t *testing.T) {
+	svc, queue, _, _, _ := newTestNotificationService(t)
+
+	for _, tt := range []struct{ currency, amount, want string }{
+		{"JPY", "2500", "2500"},
+		{"KWD", "12.5", "12.500"},
+	} {
+		queue.jobs = nil
+		err := svc.OnDomainEvent(context.Background(), DomainEvent{ID: "e-" + tt.currency, Type: repository.EventTransferCompleted, Data: repository.WalletEvent{
+			UserID:             "sender",
+			CounterpartyUserID: "receiver",
+			Amount:             decimal.RequireFromString(tt.amount),
+			Currency:           tt.currency,
+		}})
+		if err != nil || len(queue.jobs) == 0 {
+			t.Fatalf("expected jobs for %s, got %v", tt.currency, err)
+		}
+		var job notificationJob
+		if err := json.Unmarshal(queue.jobs[0], &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Data["amount"] != tt.want {
+			t.Fatalf("expected %s %s, got %q", tt.currency, tt.want, job.Data["amount"])
+		}
+	}
+}
+
+func TestNotificationServiceDeliver(t *testing.T) {
+	ctx := context.Background()
+	svc, _, email, _, _ := newTestNotificationService(t)
+
+	job := func(userID, channel string) []byte {
+		payload, _ := json.Marshal(notificationJob{UserID: userID, Event: notify.EventPinChanged, Channel: channel, Key: "k-" + userID})
+		return payload
+	}
+
+	// The sender has no phone number and an unknown user has nowhere to go: both are dropped.
+	if err := svc.Deliver(ctx, job("sender", notify.ChannelSMS)); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Deliver(ctx, job("ghost", notify.ChannelEmail)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed send is returned so the queue retries it.
+	email.err = errors.New("smtp: connection refused")
+	if err := svc.Deliver(ctx, job("receiver", notify.ChannelEmail)); err == nil {
+		t.Fatal("expected the send failure to be returned")
+	}
+	email.err = nil
+	if err := svc.Deliver(ctx, job("receiver", notify.ChannelEmail)); err != nil || len(email.sent) != 1 {
+		t.Fatalf("expected the retry to send, got %d, %v", len(email.sent), err)
+	}
+}
+
+func TestNotificationServiceIgnoresManualCredits(t *testing.T) {
+	svc, queue, _, _, _ := newTestNotificationService(t)
+
+	err := svc.OnDomainEvent(context.Background(), DomainEvent{ID: "e1", Type: repository.EventWalletCredited, Data: repository.WalletEvent{UserID: "receiver"}})
+	if err != nil || len(queue.jobs) != 0 {
+		t.Fatalf("expected no notification, got %d jobs, %v", len(queue.jobs), err)
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	ctx := context.Background()
+	svc, queue, _, _, _ := newTestNotificationService(t)
+
+	prefs, err := svc.Preferences(ctx, "receiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prefs[notify.EventTransferReceived][notify.ChannelSMS] || len(prefs[notify.EventTransferReceived]) != 4 {
+		t.Fatalf("expected every channel on by default, got %v", prefs[notify.EventTransferReceived])
+	}
+	if _, ok := prefs[notify.EventWithdrawalSucceeded][notify.ChannelSMS]; ok {
+		t.Fatal("expected no sms preference for an event without an sms template")
+	}
+
+	err = svc.UpdatePreferences(ctx, "receiver", map[string]map[string]bool{
+		notify.EventTransferReceived: {notify.ChannelSMS: false, notify.ChannelEmail: false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.UpdatePreferences(ctx, "receiver", map[string]map[string]bool{notify.EventWithdrawalSucceeded: {notify.ChannelSMS: false}})
+	if !errors.Is(err, ErrUnknownNotificationPreference) {
+		t.Fatalf("expected an unknown preference to be rejected, got %v", err)
+	}
+
+	if err := svc.Notify(ctx, "receiver", notify.EventTransferReceived, "e1", nil); err != nil {
+		t.Fatal(err)
+	}
+	var channels []string
+	for _, payload := range queue.jobs {
+		var job notificationJob
+		_ = json.Unmarshal(payload, &job)
+		channels = append(channels, job.Channel)
+	}
+	if len(channels) != 2 || channels[0] != notify.ChannelPush || channels[1] != notify.ChannelInbox {
+		t.Fatalf("expected only push and inbox, got %v", channels)
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+const (
+	// DomainEventQueue carries published outbox events as DomainEvent jobs.
+	DomainEventQueue = "domain_events"
+
+	outboxRelayLock  = "outbox:relay"
+	outboxRelayBatch = 100
+
+	// outboxMaxAttempts is how many times an event may fail before it is
+	// parked, so one bad event can't hold back every event after it.
+	outboxMaxAttempts = 5
+)
+
+// ErrOutboxEventNotParked is returned when requeueing an event that isn't
+// parked: it doesn't exist, is already published or is still being retried.
+var ErrOutboxEventNotParked = errors.New("outbox event not found or not parked")
+
+// errRelayUnavailable wraps failures of the queue or database rather than of
+// the event. They fail every event alike, so they never get one parked.
+var errRelayUnavailable = errors.New("outbox relay unavailable")
+
+// DomainEvent is an outbox event as consumers receive it. Delivery is
+// at-least-once, so consumers should use ID to ignore repeats.
+type DomainEvent struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	CreatedAt time.Time              `json:"created_at"`
+	Data      repository.WalletEvent `json:"data"`
+}
+
+// DomainEventHandler reacts to one domain event.
+type DomainEventHandler func(ctx context.Context, event DomainEvent) error
+
+// DispatchDomainEvents is the DomainEventQueue job handler. Every handler
+// sees every event; if one fails the job is retried for all of them.
+func DispatchDomainEvents(handlers ...DomainEventHandler) JobHandler {
+	return func(ctx context.Context, payload []byte) error {
+		var event DomainEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return fmt.Errorf("unreadable domain event: %w", err)
+		}
+		for _, handle := range handlers {
+			if err := handle(ctx, event); err != nil {
+				return fmt.Errorf("%s %s: %w", event.Type, event.ID, err)
+			}
+		}
+		return nil
+	}
+}
+
+// InvalidateWalletCaches drops the cached balances and history of everyone
+// an event touched.
+func InvalidateWalletCaches(redis QueueService) DomainEventHandler {
+	return func(ctx context.Context, event DomainEvent) error {
+		for _, userID := range []string{event.Data.UserID, event.Data.CounterpartyUserID} {
+			if userID == "" {
+				continue
+			}
+			if err := redis.Delete(ctx, fmt.Sprintf("wallet:%s", userID)); err != nil {
+				return err
+			}
+			if err := redis.Delete(ctx, fmt.Sprintf("tx_history:%s", userID)); err != nil {
+				return err
+			}
+		}
+
+		switch event.Type {
+		case repository.EventWithdrawalSucceeded, repository.EventWithdrawalFailed, repository.EventWithdrawalReversed:
+			// The withdrawal may belong to a month whose insights are cached.
+			if err := redis.Set(ctx, insightsGenerationKey(event.Data.UserID), event.ID, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+type OutboxRelay interface {
+	RelayOnce(ctx context.Context) (int, error)
+	Start(ctx context.Context, interval time.Duration)
+	// ListParked and Requeue let an admin see the events the relay gave up
+	// on and, once the cause is fixed, have them relayed again.
+	ListParked(ctx context.Context, limit int) ([]db.OutboxEventModel, error)
+	Requeue(ctx context.Context, id string) error
+}
+
+type outboxRelay struct {
+	repo   repository.OutboxRepository
+	redis  QueueService
+	logger *slog.Logger
+}
+
+func NewOutboxRelay(repo repository.OutboxRepository, redis QueueService, logger *slog.Logger) OutboxRelay {
+	return &outboxRelay{repo: repo, redis: redis, logger: logger}
+}
+
+// RelayOnce queues unpublished outbox events oldest first and returns how
+// many it published. It stops at the first failure so events keep their
+// order, until an event has failed outboxMaxAttempts times on its own
+// account: that one is parked and logged and the rest go out without it.
+// An event is only marked published after it is on the queue.
+func (s *outboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	locked, _ := s.redis.TryLockIdempotencyKey(ctx, outboxRelayLock, time.Minute)
+	if !locked {
+		return 0, nil
+	}
+	defer s.redis.Delete(context.Background(), "idemp:"+outboxRelayLock)
+
+	published := 0
+	for {
+		events, err := s.repo.ListUnpublishedOutbox(ctx, outboxRelayBatch)
+		if err != nil {
+			return published, err
+		}
+
+		for _, e := range events {
+			if err := s.publish(ctx, e.ID, e.Type, e.CreatedAt, e.Payload); err != nil {
+				park := e.Attempts+1 >= outboxMaxAttempts && !errors.Is(err, errRelayUnavailable)
+				if recordErr := s.repo.RecordOutboxFailure(ctx, e.ID, err.Error(), park); recordErr != nil {
+					s.logger.Error("failed to record outbox failure", "event", e.ID, "error", recordErr)
+					return published, err
+				}
+				if !park {
+					return published, err
+				}
+				s.logger.Error("outbox event parked after repeated failures",
+					"event", e.ID, "type", e.Type, "wallet_id", e.WalletID, "attempts", e.Attempts+1, "error", err)
+				continue
+			}
+			published++
+		}
+
+		if len(events) < outboxRelayBatch {
+			return published, nil
+		}
+	}
+}
+
+func (s *outboxRelay) publish(ctx context.Context, id, eventType string, createdAt time.Time, payload string) error {
+	event := DomainEvent{ID: id, Type: eventType, CreatedAt: createdAt}
+	if err := json.Unmarshal([]byte(payload), &event.Data); err != nil {
+		return fmt.Errorf("unreadable outbox payload: %w", err)
+	}
+	job, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := s.redis.Enqueue(ctx, DomainEventQueue, job); err != nil {
+		return fmt.Errorf("%w: %v", errRelayUnavailable, err)
+	}
+	// If this fails the event is published again next time, which consumers
+	// already have to tolerate.
+	if err := s.repo.MarkOutboxPublished(ctx, id); err != nil {
+		return fmt.Errorf("%w: %v", errRelayUnavailable, err)
+	}
+	return nil
+}
+
+func (s *outboxRelay) ListParked(ctx context.Context, limit int) ([]db.OutboxEventModel, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	return s.repo.ListParkedOutbox(ctx, limit)
+}
+
+// Requeue unparks an event with a fresh set of attempts. It keeps its place
+// in line, so it goes out before any event created after it.
+func (s *outboxRelay) Requeue(ctx context.Context, id string) error {
+	err := s.repo.UnparkOutbox(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrOutboxEventNotParked
+	}
+	return err
+}
+
+// Start relays every interval until ctx is cancelled. A non-positive
+// interval disables it.
+func (s *outboxRelay) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		s.logger.Info("outbox relay disabled")
+		return
+	}
+
+	s.logger.Info("outbox relay scheduled", "interval", interval.String())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.RelayOnce(ctx); err != nil {
+				s.logger.Error("outbox relay failed", "error", err)
+			}
+		}
+	}
+}
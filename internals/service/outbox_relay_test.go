@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type fakeOutboxRepo struct {
+	events []*db.OutboxEventModel
+}
+
+func (f *fakeOutboxRepo) add(id, eventType, userID string) {
+	payload, _ := json.Marshal(repository.WalletEvent{UserID: userID, WalletID: "w-" + userID})
+	f.events = append(f.events, &db.OutboxEventModel{InnerOutboxEvent: db.InnerOutboxEvent{
+		ID:        id,
+		Type:      eventType,
+		WalletID:  "w-" + userID,
+		Payload:   string(payload),
+		CreatedAt: time.Now(),
+	}})
+}
+
+func (f *fakeOutboxRepo) ListUnpublishedOutbox(ctx context.Context, limit int) ([]db.OutboxEventModel, error) {
+	var out []db.OutboxEventModel
+	for _, e := range f.events {
+		_, published := e.PublishedAt()
+		_, parked := e.FailedAt()
+		if !published && !parked && len(out) < limit {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeOutboxRepo) MarkOutboxPublished(ctx context.Context, id string) error {
+	for _, e := range f.events {
+		if e.ID == id {
+			now := time.Now()
+			e.InnerOutboxEvent.PublishedAt = &now
+			e.Attempts++
+		}
+	}
+	return nil
+}
+
+func (f *fakeOutboxRepo) RecordOutboxFailure(ctx context.Context, id, message string, park bool) error {
+	for _, e := range f.events {
+		if e.ID == id {
+			e.Attempts++
+			e.InnerOutboxEvent.LastError = &message
+			if park {
+				now := time.Now()
+				e.InnerOutboxEvent.FailedAt = &now
+			}
+		}
+	}
+	return nil
+}
+
+func (f *fakeOutboxRepo) ListParkedOutbox(ctx context.Context, limit int) ([]db.OutboxEventModel, error) {
+	var out []db.OutboxEventModel
+	for _, e := range f.events {
+		_, published := e.PublishedAt()
+		_, parked := e.FailedAt()
+		if !published && parked && len(out) < limit {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeOutboxRepo) UnparkOutbox(ctx context.Context, id string) error {
+	for _, e := range f.events {
+		_, published := e.PublishedAt()
+		_, parked := e.FailedAt()
+		if e.ID == id && !published && parked {
+			e.InnerOutboxEvent.FailedAt = nil
+			e.Attempts = 0
+			return nil
+		}
+	}
+	return db.ErrNotFound
+}
+
+// relayQueue lets every relay run take the lock and refuses jobs once
+// failAfter of them were queued; 0 never refuses.
+type relayQueue struct {
+	*jsonQueue
+	failAfter int
+}
+
+func newRelayQueue(failAfter int) *relayQueue {
+	return &relayQueue{jsonQueue: newJSONQueue(), failAfter: failAfter}
+}
+
+func (q *relayQueue) Enqueue(ctx context.Context, jobType string, payload []byte) error {
+	if q.failAfter > 0 && len(q.jobs) >= q.failAfter {
+		return errors.New("redis unavailable")
+	}
+	return q.recordingQueue.Enqueue(ctx, jobType, payload)
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repo := &fakeOutboxRepo{}
+	repo.add("e1", repository.EventWalletCredited, "u1")
+	repo.add("e2", repository.EventTransferCompleted, "u2")
+	repo.add("e3", repository.EventWithdrawalFailed, "u3")
+	queue := newRelayQueue(2)
+	relay := NewOutboxRelay(repo, queue, logger)
+
+	published, err := relay.RelayOnce(ctx)
+	if err == nil || published != 2 {
+		t.Fatalf("expected the relay to stop after 2 events, got %d, %v", published, err)
+	}
+	if _, ok := repo.events[2].PublishedAt(); ok {
+		t.Fatal("expected the refused event to stay unpublished")
+	}
+	if msg, _ := repo.events[2].LastError(); msg == "" {
+		t.Fatal("expected the failure to be recorded on the event")
+	}
+
+	queue.failAfter = 0
+	if published, err := relay.RelayOnce(ctx); err != nil || published != 1 {
+		t.Fatalf("expected the remaining event to be published, got %d, %v", published, err)
+	}
+
+	var first DomainEvent
+	if err := json.Unmarshal(queue.jobs[0], &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != "e1" || first.Type != repository.EventWalletCredited || first.Data.UserID != "u1" {
+		t.Fatalf("expected e1 first, got %+v", first)
+	}
+	if published, _ := relay.RelayOnce(ctx); published != 0 || len(queue.jobs) != 3 {
+		t.Fatalf("expected nothing left to publish, got %d", published)
+	}
+}
+
+func TestOutboxRelayParksPoisonEvents(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repo := &fakeOutboxRepo{}
+	repo.add("bad", repository.EventWalletCredited, "u1")
+	repo.events[0].Payload = "{not json"
+	repo.add("good", repository.EventWalletCredited, "u2")
+	queue := newRelayQueue(0)
+	relay := NewOutboxRelay(repo, queue, logger)
+
+	for i := 1; i < outboxMaxAttempts; i++ {
+		if published, err := relay.RelayOnce(ctx); err == nil || published != 0 {
+			t.Fatalf("run %d: expected the bad event to hold the good one back, got %d, %v", i, published, err)
+		}
+	}
+	if published, err := relay.RelayOnce(ctx); err != nil || published != 1 {
+		t.Fatalf("expected the bad event parked and the good one published, got %d, %v", published, err)
+	}
+	if _, parked := repo.events[0].FailedAt(); !parked || repo.events[0].Attempts != outboxMaxAttempts {
+		t.Fatalf("expected the bad event parked after %d attempts, got %+v", outboxMaxAttempts, repo.events[0].InnerOutboxEvent)
+	}
+	if _, ok := repo.events[1].PublishedAt(); !ok || len(queue.jobs) != 1 {
+		t.Fatal("expected the good event on the queue")
+	}
+	if published, err := relay.RelayOnce(ctx); err != nil || published != 0 {
+		t.Fatalf("expected the parked event to be left alone, got %d, %v", published, err)
+	}
+
+	// A queue that is down fails every event alike and parks none of them.
+	repo.add("late", repository.EventWalletCredited, "u3")
+	queue.failAfter = 1
+	for i := 0; i <= outboxMaxAttempts; i++ {
+		_, _ = relay.RelayOnce(ctx)
+	}
+	if _, parked := repo.events[2].FailedAt(); parked {
+		t.Fatal("expected an outage not to park the event")
+	}
+}
+
+func TestOutboxRelayRequeuesParkedEvents(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repo := &fakeOutboxRepo{}
+	repo.add("bad", repository.EventWalletCredited, "u1")
+	repo.events[0].Payload = "{not json"
+	queue := newRelayQueue(0)
+	relay := NewOutboxRelay(repo, queue, logger)
+
+	for i := 0; i < outboxMaxAttempts; i++ {
+		_, _ = relay.RelayOnce(ctx)
+	}
+	parked, err := relay.ListParked(ctx, 0)
+	if err != nil || len(parked) != 1 || parked[0].ID != "bad" {
+		t.Fatalf("expected the bad event listed as parked, got %+v, %v", parked, err)
+	}
+
+	if err := relay.Requeue(ctx, "missing"); !errors.Is(err, ErrOutboxEventNotParked) {
+		t.Fatalf("expected ErrOutboxEventNotParked for an unknown event, got %v", err)
+	}
+
+	// Once the cause is fixed the event is relayed again.
+	repo.events[0].Payload = `{"user_id":"u1"}`
+	if err := relay.Requeue(ctx, "bad"); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if published, err := relay.RelayOnce(ctx); err != nil || published != 1 {
+		t.Fatalf("expected the requeued event published, got %d, %v", published, err)
+	}
+	if err := relay.Requeue(ctx, "bad"); !errors.Is(err, ErrOutboxEventNotParked) {
+		t.Fatalf("expected a published event not to be requeued, got %v", err)
+	}
+}
+
+func TestDispatchDomainEvents(t *testing.T) {
+	ctx := context.Background()
+	queue := newJSONQueue()
+	var seen []string
+	handler := DispatchDomainEvents(
+		InvalidateWalletCaches(queue),
+		func(ctx context.Context, event DomainEvent) error {
+			seen = append(seen, event.ID)
+			return nil
+		},
+	)
+
+	job, _ := json.Marshal(DomainEvent{ID: "e1", Type: repository.EventTransferCompleted, Data: repository.WalletEvent{
+		UserID:             "sender",
+		CounterpartyUserID: "receiver",
+	}})
+	if err := handler(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1 || len(queue.deleted) != 4 {
+		t.Fatalf("expected both handlers to run and both users' caches dropped, got %v and %v", seen, queue.deleted)
+	}
+
+	if err := handler(ctx, []byte("not json")); err == nil {
+		t.Fatal("expected an unreadable event to fail")
+	}
+}
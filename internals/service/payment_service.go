@@ -1,399 +1,223 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha512"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
 	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
 	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
 )
 
+// WebhookQueue carries WebhookJob payloads to ProcessWebhookEvent.
+const WebhookQueue = "payment_webhooks"
 
-type PaystackInitResponse struct {
-    Status  bool   `json:"status"`
-    Message string `json:"message"`
-    Data    struct {
-        AuthorizationURL string `json:"authorization_url"`
-        AccessCode       string `json:"access_code"`
-        Reference        string `json:"reference"`
-    } `json:"data"`
-}
-
-type PaystackRecipientResponse struct {
-    Status  bool   `json:"status"`
-    Message string `json:"message"`
-    Data    struct {
-        RecipientCode string `json:"recipient_code"`
-    } `json:"data"`
-}
-
-type PaystackTransferResponse struct {
-    Status  bool   `json:"status"`
-    Message string `json:"message"`
-    Data    struct {
-        TransferCode string `json:"transfer_code"`
-        Reference    string `json:"reference"`
-        Status       string `json:"status"` 
-    } `json:"data"`
+// WebhookJob is a verified webhook body and the provider that sent it.
+type WebhookJob struct {
+	Provider string          `json:"provider"`
+	Body     json.RawMessage `json:"body"`
 }
 
 type PaymentService interface {
-    InitializeTransaction(email, currency string, amount float64) (*PaystackInitResponse, error)
-	VerifyPaystackSignature(payload []byte, signature string) bool
-	ProcessPaystackEvent(ctx context.Context, payload []byte) error
-	GetBankList(ctx context.Context,currency string) ([]map[string]interface{}, error) 
-    ResolveBankAccount(accountNumber, bankCode string) (string, error)
-	CreateTransferRecipient(name, accountNum, bankCode, currency string) (string, error)
-    InitiateTransfer(amount float64, recipientCode, reference, reason string) (*PaystackTransferResponse, error)
-    VerifyAndCredit(ctx context.Context, reference string) (*db.TransactionModel, error)
+	InitializeTransaction(ctx context.Context, provider, email string, amount money.Money) (*gateway.Charge, error)
+	VerifyWebhook(provider string, header http.Header, payload []byte) bool
+	ParseWebhook(provider string, payload []byte) (*gateway.WebhookEvent, error)
+	ProcessWebhookEvent(ctx context.Context, payload []byte) error
+	GetBankList(ctx context.Context, provider, currency string) ([]gateway.Bank, error)
+	ResolveBankAccount(ctx context.Context, provider, accountNumber, bankCode string) (string, error)
+	VerifyAndCredit(ctx context.Context, provider, reference string) (*db.TransactionModel, error)
 }
 
 type paymentService struct {
-    repo repository.WalletRepository
-    paystackClient Client 
-    userRepo repository.UserRepository
-    redis   QueueService
+	repo     repository.WalletRepository
+	gateways *gateway.Registry
+	userRepo repository.UserRepository
+	redis    QueueService
 }
 
-func NewPaymentService(repo repository.WalletRepository, client Client, redis QueueService, userRepo repository.UserRepository) PaymentService {
-    return &paymentService{repo: repo, paystackClient: client, redis:redis,userRepo: userRepo,}
+func NewPaymentService(repo repository.WalletRepository, gateways *gateway.Registry, redis QueueService, userRepo repository.UserRepository) PaymentService {
+	return &paymentService{repo: repo, gateways: gateways, redis: redis, userRepo: userRepo}
 }
 
-func (s *paymentService) InitializeTransaction(email, currency string, amount float64) (*PaystackInitResponse, error) {
-    secret := os.Getenv("PAYSTACK_SECRET_KEY")
-    if secret == "" {
-        return nil, fmt.Errorf("paystack secret key is missing")
-    }
-    
-
-    callbackURL := os.Getenv("PAYSTACK_CALLBACK_URL")
-    if callbackURL == "" {
-        callbackURL = "http://localhost:8080/webhooks/paystack" // Default fallback
-    }
-
-    amountInSubunits := amount * 100 
-    
-    var channels []string
-    switch currency {
-    case "NGN":
-        channels = []string{"card", "bank_transfer", "ussd", "bank", "qr", "mobile_money", "apple_pay"}
-    case "GHS":
-        channels = []string{"card", "mobile_money"}
-    case "ZAR":
-        channels = []string{"card", "eft"}
-    case "USD":
-        channels = []string{"card", "apple_pay"}
-    default:
-        channels = []string{"card"}
-    }
-
-    payload := map[string]interface{}{
-        "email":        email,
-        "amount":       amountInSubunits,
-        "currency":     currency,
-        "callback_url": callbackURL, 
-        "channels":     channels,
-    }
-
-    jsonPayload, _ := json.Marshal(payload)
-
-    req, err := http.NewRequest("POST", "https://api.paystack.co/transaction/initialize", bytes.NewBuffer(jsonPayload))
-    if err != nil { return nil, err }
-
-    req.Header.Set("Authorization", "Bearer "+secret)
-    req.Header.Set("Content-Type", "application/json")
-
-    client := &http.Client{}
-    resp, err := client.Do(req)
-    if err != nil { return nil, err }
-    defer resp.Body.Close()
-
-    var result PaystackInitResponse
-    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        return nil, err
-    }
-
-    if !result.Status {
-        return nil, fmt.Errorf("paystack error: %s", result.Message)
-    }
-
-    return &result, nil
+func (s *paymentService) InitializeTransaction(ctx context.Context, provider, email string, amount money.Money) (*gateway.Charge, error) {
+	gw, err := s.gateways.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	return gw.InitializeCharge(ctx, gateway.ChargeRequest{Email: email, Amount: amount})
 }
 
-func (s *paymentService) VerifyPaystackSignature(payload []byte, signature string) bool {
-	secret := os.Getenv("PAYSTACK_SECRET_KEY")
-	if secret == "" {
+func (s *paymentService) VerifyWebhook(provider string, header http.Header, payload []byte) bool {
+	gw, err := s.gateways.Get(provider)
+	if err != nil {
 		return false
 	}
-	
-	h := hmac.New(sha512.New, []byte(secret))
-	h.Write(payload)
-	expectedSignature := hex.EncodeToString(h.Sum(nil))
-
-	return expectedSignature == signature
+	return gw.VerifyWebhook(header, payload)
 }
 
+func (s *paymentService) ParseWebhook(provider string, payload []byte) (*gateway.WebhookEvent, error) {
+	gw, err := s.gateways.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	return gw.ParseWebhook(payload)
+}
 
-func (s *paymentService) ProcessPaystackEvent(ctx context.Context, payload []byte) error {
-
-    var event struct {
-        Event string `json:"event"`
-        Data  struct {
-            Reference    string  `json:"reference"`
-            TransferCode string  `json:"transfer_code"`
-            Amount       float64 `json:"amount"`
-            Currency     string  `json:"currency"`
-            Status       string  `json:"status"`
-            Customer     struct {
-                Email string `json:"email"`
-            } `json:"customer"`
-        } `json:"data"`
-    }
-
-    if err := json.Unmarshal(payload, &event); err != nil {
-        return fmt.Errorf("failed to parse webhook body: %w", err)
-    }
-
-    if event.Event == "charge.success" && event.Data.Status == "success" {
-        user, err := s.userRepo.FindUserByEmail(ctx, event.Data.Customer.Email)
-        if err == nil && user != nil {
-            cacheKey := fmt.Sprintf("wallet:%s", user.ID)
-            _ = s.redis.Delete(ctx, cacheKey)
-            _ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", user.ID))
-        }
-        
-        actualAmount := event.Data.Amount / 100.0
-        description := fmt.Sprintf("Deposit via Paystack (%s)", event.Data.Currency)
-
-        return s.repo.CreditWalletByEmail(
-            ctx,
-            event.Data.Customer.Email,
-            event.Data.Currency,
-            actualAmount,
-            event.Data.Reference,
-            description,
-            "PAYSTACK",
-        )
-    }
+// ProcessWebhookEvent applies a queued webhook. Jobs queued before webhooks
+// were wrapped in a WebhookJob are raw Paystack bodies.
+func (s *paymentService) ProcessWebhookEvent(ctx context.Context, payload []byte) error {
+	var job WebhookJob
+	if err := json.Unmarshal(payload, &job); err != nil || job.Provider == "" {
+		job = WebhookJob{Provider: gateway.ProviderPaystack, Body: payload}
+	}
 
-    if event.Event == "transfer.success" {
-        return s.repo.UpdateTransactionStatus(
-            ctx,
-            event.Data.TransferCode,
-            db.TransactionStatusSuccess,
-        )
-    }
+	gw, err := s.gateways.Get(job.Provider)
+	if err != nil {
+		return err
+	}
+	event, err := gw.ParseWebhook(job.Body)
+	if err != nil {
+		return err
+	}
 
-    if event.Event == "transfer.failed" || event.Event == "transfer.reversed" {
-        return s.repo.RefundWithdrawal(ctx, event.Data.TransferCode)
-    }
+	switch event.Type {
+	case gateway.EventChargeSuccess:
+		user, err := s.userRepo.FindUserByEmail(ctx, event.Email)
+		if err == nil && user != nil {
+			_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", user.ID))
+			_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", user.ID))
+		}
+
+		description := fmt.Sprintf("Deposit via %s (%s)", providerLabel(gw.Name()), event.Amount.Currency())
+		return s.repo.CreditWalletByEmail(ctx, event.Email, event.Amount, event.Reference, description, gw.Name())
+
+	case gateway.EventTransferSuccess:
+		if err := s.repo.CaptureWithdrawal(ctx, event.Reference, event.TransferCode); err != nil {
+			return err
+		}
+
+	case gateway.EventTransferFailed, gateway.EventTransferReversed:
+		outcome := db.WithdrawalStatusFailed
+		if event.Type == gateway.EventTransferReversed {
+			outcome = db.WithdrawalStatusReversed
+		}
+		if err := s.repo.ReleaseWithdrawal(ctx, event.Reference, event.TransferCode, outcome); err != nil {
+			return err
+		}
+
+	case gateway.EventDisputeOpened:
+		if err := s.repo.HoldDisputedDeposit(ctx, event.Reference); err != nil {
+			return err
+		}
+
+	case gateway.EventDisputeResolved:
+		resolve := s.repo.ReleaseHold
+		if event.DisputeLost {
+			resolve = s.repo.CaptureHold
+		}
+		err := resolve(ctx, repository.DisputeHoldReference(event.Reference))
+		if err != nil && !errors.Is(err, repository.ErrHoldNotFound) {
+			return err
+		}
+
+	default:
+		return nil
+	}
 
-    return nil
+	s.invalidateWallet(ctx, event.Reference)
+	return nil
 }
-func (s *paymentService) GetBankList(ctx context.Context,currency string) ([]map[string]interface{}, error) {
-    secret := os.Getenv("PAYSTACK_SECRET_KEY")
-    
-    if currency == "" {
-        currency = "NGN"
-    }
-    cacheKey := fmt.Sprintf("banks:%s", currency)
-    var banks []map[string]interface{}
-    if err := s.redis.Get(ctx, cacheKey, &banks); err == nil {
-        return banks, nil
-    }
-   
-    url := fmt.Sprintf("https://api.paystack.co/bank?currency=%s", currency)
-    
-    req, _ := http.NewRequest("GET", url, nil)
-    req.Header.Set("Authorization", "Bearer "+secret)
-
-    client := &http.Client{}
-    resp, err := client.Do(req)
-    if err != nil { return nil, err }
-    defer resp.Body.Close()
 
-    var result struct {
-        Status  bool `json:"status"`
-        Message string `json:"message"`
-        Data    []map[string]interface{} `json:"data"`
-    }
-    
-    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        return nil, err
-    }
-
-    if !result.Status {
-        return nil, fmt.Errorf("paystack error: %s", result.Message)
-    }
-    _ = s.redis.Set(ctx, cacheKey, result.Data, 24*time.Hour)
-    return result.Data, nil
+// invalidateWallet drops the cached wallet of whoever owns the transaction.
+func (s *paymentService) invalidateWallet(ctx context.Context, reference string) {
+	txn, err := s.repo.GetTransactionByReference(ctx, reference)
+	if err != nil || txn == nil {
+		return
+	}
+	if wallet := txn.RelationsTransaction.Wallet; wallet != nil {
+		_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", wallet.UserID))
+		_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", wallet.UserID))
+	}
 }
 
-func (s *paymentService) ResolveBankAccount(accountNumber, bankCode string) (string, error) {
-    secret := os.Getenv("PAYSTACK_SECRET_KEY")
-  
-    url := fmt.Sprintf("https://api.paystack.co/bank/resolve?account_number=%s&bank_code=%s", accountNumber, bankCode)
-    
-    req, err:= http.NewRequest("GET", url, nil)
+func (s *paymentService) GetBankList(ctx context.Context, provider, currency string) ([]gateway.Bank, error) {
+	gw, err := s.gateways.Get(provider)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-    req.Header.Set("Authorization", "Bearer "+secret)
 
-    client := &http.Client{}
-    resp, err := client.Do(req)
-    if err != nil { return "", err }
-    defer resp.Body.Close()
-
-    var result struct {
-        Status  bool `json:"status"`
-        Message string `json:"message"`
-        Data    struct {
-            AccountName string `json:"account_name"`
-            AccountNumber string `json:"account_number"`
-        } `json:"data"`
-    }
-
-    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        return "", err
-    }
-
-    if !result.Status {
-        return "", fmt.Errorf("could not resolve account: %s", result.Message)
-    }
+	if currency == "" {
+		currency = "NGN"
+	}
+	cacheKey := fmt.Sprintf("banks:%s:%s", gw.Name(), currency)
+	var banks []gateway.Bank
+	if err := s.redis.Get(ctx, cacheKey, &banks); err == nil {
+		return banks, nil
+	}
 
-    return result.Data.AccountName, nil
+	banks, err = gw.ListBanks(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.redis.Set(ctx, cacheKey, banks, 24*time.Hour)
+	return banks, nil
 }
 
-func (s *paymentService) CreateTransferRecipient(name, accountNum, bankCode, currency string) (string, error) {
-    secret := os.Getenv("PAYSTACK_SECRET_KEY")
-
-    var recipientType string
-
-    switch currency {
-    case "NGN":
-        recipientType = "nuban" // Nigeria Bank Account
-    case "GHS":
-        recipientType = "mobile_money" // Ghana Mobile Money
-    case "ZAR":
-        recipientType = "basa" // South Africa Bank Account
-    default:
-        recipientType = "nuban" // Default fallback
-    }
-
- 
-    payload := map[string]interface{}{
-        "type":           recipientType, 
-        "name":           name,
-        "account_number": accountNum,
-        "bank_code":      bankCode,
-        "currency":       currency,
-    }
-
-    jsonPayload, _ := json.Marshal(payload)
-
-    req, err:= http.NewRequest("POST", "https://api.paystack.co/transferrecipient", bytes.NewBuffer(jsonPayload))
+func (s *paymentService) ResolveBankAccount(ctx context.Context, provider, accountNumber, bankCode string) (string, error) {
+	gw, err := s.gateways.Get(provider)
 	if err != nil {
 		return "", err
 	}
-    req.Header.Set("Authorization", "Bearer "+secret)
-    req.Header.Set("Content-Type", "application/json")
-
-    client := &http.Client{}
-    resp, err := client.Do(req)
-    if err != nil { return "", err }
-    defer resp.Body.Close()
-
-    var result PaystackRecipientResponse
-    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        return "", err
-    }
-
-    if !result.Status {
-        return "", fmt.Errorf("paystack recipient error: %s", result.Message)
-    }
-
-    return result.Data.RecipientCode, nil
+	return gw.ResolveAccount(ctx, accountNumber, bankCode)
 }
 
-func (s *paymentService) InitiateTransfer(amount float64, recipientCode, reference, reason string) (*PaystackTransferResponse, error) {
-    secret := os.Getenv("PAYSTACK_SECRET_KEY")
-    
-    amountInSubunits := amount * 100
-
-    payload := map[string]interface{}{
-        "source":    "balance", 
-        "amount":    amountInSubunits,
-        "recipient": recipientCode,
-        "reference": reference,
-        "reason":    reason,
-    }
-
-    jsonPayload, _ := json.Marshal(payload)
-
-    req, err:= http.NewRequest("POST", "https://api.paystack.co/transfer", bytes.NewBuffer(jsonPayload))
+func (s *paymentService) VerifyAndCredit(ctx context.Context, provider, reference string) (*db.TransactionModel, error) {
+	gw, err := s.gateways.Get(provider)
 	if err != nil {
 		return nil, err
 	}
-    req.Header.Set("Authorization", "Bearer "+secret)
-    req.Header.Set("Content-Type", "application/json")
-
-    client := &http.Client{}
-    resp, err := client.Do(req)
-    if err != nil { return nil, err }
-    defer resp.Body.Close()
 
-    var result PaystackTransferResponse
-    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        return nil, err
-    }
-
-    if !result.Status {
-        return nil, fmt.Errorf("transfer error: %s", result.Message)
-    }
-
-    return &result, nil
-}
-
-func (s *paymentService) VerifyAndCredit(ctx context.Context, reference string) (*db.TransactionModel, error) {
-	
-	paystackData, err := s.paystackClient.VerifyTransaction(reference)
+	charge, err := gw.VerifyCharge(ctx, reference)
 	if err != nil {
-		return nil, fmt.Errorf("paystack verification failed: %w", err)
+		return nil, fmt.Errorf("%s verification failed: %w", providerLabel(gw.Name()), err)
 	}
 
-	if paystackData.Data.Status != "success" {
+	if charge.Status != gateway.StatusSuccess {
 		return nil, fmt.Errorf("payment failed or abandoned")
 	}
 
-	actualAmount := paystackData.Data.Amount / 100.0
-	currency := paystackData.Data.Currency
-	email := paystackData.Data.Customer.Email
-	description := fmt.Sprintf("Deposit via Paystack (%s)", currency)
+	description := fmt.Sprintf("Deposit via %s (%s)", providerLabel(gw.Name()), charge.Amount.Currency())
 
 	err = s.repo.CreditWalletByEmail(
 		ctx,
-		email,
-		currency,
-		actualAmount,
+		charge.Email,
+		charge.Amount,
 		reference,
 		description,
-		"PAYSTACK",
+		gw.Name(),
 	)
 	if err != nil {
 		return nil, err
 	}
-    user, err := s.userRepo.FindUserByEmail(ctx, email)
-    if err == nil && user != nil {
-         _ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", user.ID))
-    }
+	user, err := s.userRepo.FindUserByEmail(ctx, charge.Email)
+	if err == nil && user != nil {
+		_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", user.ID))
+	}
 	return s.repo.GetTransactionByReference(ctx, reference)
 }
+
+// providerLabel turns "PAYSTACK" into "Paystack" for descriptions.
+func providerLabel(name string) string {
+	switch name {
+	case gateway.ProviderPaystack:
+		return "Paystack"
+	case gateway.ProviderFlutterwave:
+		return "Flutterwave"
+	}
+	return name
+}
@@ -1,65 +0,0 @@
-package service
-
-import (
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"time"
-)
-
-type Client struct {
-	SecretKey  string
-	HttpClient *http.Client
-}
-
-func NewClient(secretKey string) *Client {
-	return &Client{
-		SecretKey: secretKey,
-		HttpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}
-}
-
-type VerifyResponse struct {
-	Status  bool   `json:"status"`
-	Message string `json:"message"`
-	Data    struct {
-		Status    string  `json:"status"`
-		Reference string  `json:"reference"`
-		Amount    float64 `json:"amount"`
-		Currency  string  `json:"currency"`
-		Customer  struct {
-			Email string `json:"email"`
-		} `json:"customer"`
-	} `json:"data"`
-}
-
-func (c *Client) VerifyTransaction(reference string) (*VerifyResponse, error) {
-	url := fmt.Sprintf("https://api.paystack.co/transaction/verify/%s", reference)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+c.SecretKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.HttpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("paystack returned non-200 status: %d", resp.StatusCode)
-	}
-
-	var result VerifyResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	return &result, nil
-}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway/fakepaystack"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// fakeLedger credits each reference once and ignores repeats, like
+// CreditWalletByEmail does through the unique reference. Everything lands on
+// one asset, where disputes are held.
+type fakeLedger struct {
+	repository.WalletRepository
+	credits map[string]money.Money // by reference
+	repeats int
+	asset   db.WalletAssetModel
+	holds   map[string]decimal.Decimal // active, by hold reference
+}
+
+func (f *fakeLedger) CreditWalletByEmail(ctx context.Context, email string, amount money.Money, reference, description, provider string) error {
+	if _, done := f.credits[reference]; done {
+		f.repeats++
+		return nil
+	}
+	f.credits[reference] = amount
+	f.asset.Balance = f.asset.Balance.Add(amount.Amount())
+	return nil
+}
+
+func (f *fakeLedger) HoldDisputedDeposit(ctx context.Context, reference string) error {
+	held := decimal.Min(f.credits[reference].Amount(), repository.AvailableBalance(&f.asset))
+	f.holds[repository.DisputeHoldReference(reference)] = held
+	f.asset.HeldBalance = f.asset.HeldBalance.Add(held)
+	return nil
+}
+
+func (f *fakeLedger) CaptureHold(ctx context.Context, reference string) error {
+	held, ok := f.holds[reference]
+	if !ok {
+		return repository.ErrHoldNotFound
+	}
+	delete(f.holds, reference)
+	f.asset.Balance = f.asset.Balance.Sub(held)
+	f.asset.HeldBalance = f.asset.HeldBalance.Sub(held)
+	return nil
+}
+
+func (f *fakeLedger) ReleaseHold(ctx context.Context, reference string) error {
+	held, ok := f.holds[reference]
+	if !ok {
+		return repository.ErrHoldNotFound
+	}
+	delete(f.holds, reference)
+	f.asset.HeldBalance = f.asset.HeldBalance.Sub(held)
+	return nil
+}
+
+func (f *fakeLedger) GetTransactionByReference(ctx context.Context, reference string) (*db.TransactionModel, error) {
+	amount, ok := f.credits[reference]
+	if !ok {
+		return nil, db.ErrNotFound
+	}
+	return &db.TransactionModel{InnerTransaction: db.InnerTransaction{
+		Reference: reference,
+		Amount:    amount.Amount(),
+		Currency:  amount.Currency(),
+		Status:    db.TransactionStatusSuccess,
+	}}, nil
+}
+
+type fakeUsers struct {
+	repository.UserRepository
+}
+
+func (fakeUsers) FindUserByEmail(ctx context.Context, email string) (*db.UserModel, error) {
+	return nil, db.ErrNotFound
+}
+
+// startPaystack runs the fake Paystack with its webhooks going through
+// WebhookService, the queued job being processed as soon as it is queued.
+func startPaystack(t *testing.T, payments PaymentService) (*fakepaystack.Fake, *gateway.Registry) {
+	fake := fakepaystack.New("sk_test")
+	api := fake.Start()
+	t.Cleanup(api.Close)
+
+	queue := &recordingQueue{}
+	webhooks := &webhookService{repo: &fakeWebhookRepo{}, payments: payments, redis: queue, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if _, err := webhooks.Receive(r.Context(), gateway.ProviderPaystack, r.Header, body); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		for _, job := range queue.jobs {
+			if err := webhooks.ProcessQueued(r.Context(), job); err != nil {
+				t.Errorf("webhook failed: %v", err)
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}
+		queue.jobs = nil
+	}))
+	t.Cleanup(receiver.Close)
+	fake.WebhookURL = receiver.URL
+
+	return fake, gateway.NewRegistry(gateway.ProviderPaystack, gateway.NewPaystack("sk_test", api.URL, ""))
+}
+
+func TestDepositCreditsWallet(t *testing.T) {
+	ledger := &fakeLedger{credits: make(map[string]money.Money)}
+	svc := &paymentService{repo: ledger, redis: fakeCache{}, userRepo: fakeUsers{}}
+	fake, gateways := startPaystack(t, svc)
+	svc.gateways = gateways
+
+	ctx := context.Background()
+	amount, _ := money.New(decimal.RequireFromString("2500.75"), "NGN")
+	charge, err := svc.InitializeTransaction(ctx, "", "ada@example.com", amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fake.CompleteCharge(charge.Reference); err != nil {
+		t.Fatal(err)
+	}
+	if credited, ok := ledger.credits[charge.Reference]; !ok || !credited.Equal(amount) {
+		t.Fatalf("expected %s credited by the webhook, got %v", amount.StringFixed(), ledger.credits)
+	}
+
+	// The callback page verifying the same charge must not credit it twice.
+	txn, err := svc.VerifyAndCredit(ctx, "", charge.Reference)
+	if err != nil || txn.Reference != charge.Reference {
+		t.Fatalf("expected the deposit back, got %+v, %v", txn, err)
+	}
+	if len(ledger.credits) != 1 || ledger.repeats != 1 {
+		t.Fatalf("expected one credit and one ignored repeat, got %v and %d", ledger.credits, ledger.repeats)
+	}
+}
+
+func TestFailedTransferReleasesWithdrawal(t *testing.T) {
+	repo := &fakeWithdrawalRepo{withdrawal: pendingWithdrawal(0)}
+	repo.withdrawal.AccountName = "ADA LOVELACE"
+	repo.withdrawal.AccountNumber = "0123456789"
+	repo.withdrawal.BankCode = "058"
+
+	payments := &paymentService{repo: repo, redis: fakeCache{}, userRepo: fakeUsers{}}
+	fake, gateways := startPaystack(t, payments)
+	payments.gateways = gateways
+	withdrawals := &withdrawalService{repo: repo, gateways: gateways, redis: fakeCache{}, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	payload, _ := json.Marshal(withdrawalJob{Reference: repo.withdrawal.Reference})
+	if err := withdrawals.ProcessWithdrawal(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+	transfer, ok := fake.Transfer(repo.withdrawal.Reference)
+	if !ok || transfer.Amount != 5000 || repo.transferCode != transfer.Code {
+		t.Fatalf("expected a 5000 kobo transfer recorded as %q, got %+v", repo.transferCode, transfer)
+	}
+
+	if err := fake.SettleTransfer(repo.withdrawal.Reference, "failed"); err != nil {
+		t.Fatal(err)
+	}
+	if repo.released != db.WithdrawalStatusFailed || repo.captured {
+		t.Fatalf("expected transfer.failed to release the hold, got %+v", repo)
+	}
+}
+
+func TestDisputedDepositIsHeld(t *testing.T) {
+	ledger := &fakeLedger{credits: make(map[string]money.Money), holds: make(map[string]decimal.Decimal)}
+	svc := &paymentService{repo: ledger, redis: fakeCache{}, userRepo: fakeUsers{}}
+	fake, gateways := startPaystack(t, svc)
+	svc.gateways = gateways
+
+	ctx := context.Background()
+	deposit := func(amount string) string {
+		charge, err := svc.InitializeTransaction(ctx, "", "ada@example.com", money.Round(decimal.RequireFromString(amount), "NGN"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fake.CompleteCharge(charge.Reference); err != nil {
+			t.Fatal(err)
+		}
+		return charge.Reference
+	}
+	available := func() string { return repository.AvailableBalance(&ledger.asset).StringFixed(2) }
+
+	won, lost := deposit("3000"), deposit("1000")
+	if err := fake.OpenDispute(won); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.OpenDispute(lost); err != nil {
+		t.Fatal(err)
+	}
+	if available() != "0.00" || ledger.asset.Balance.StringFixed(2) != "4000.00" {
+		t.Fatalf("expected both disputed deposits held out of 4000.00, got %s available", available())
+	}
+
+	if err := fake.ResolveDispute(won, "declined"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.ResolveDispute(lost, "merchant-accepted"); err != nil {
+		t.Fatal(err)
+	}
+	if available() != "3000.00" || ledger.asset.Balance.StringFixed(2) != "3000.00" || len(ledger.holds) != 0 {
+		t.Fatalf("expected the won deposit freed and the lost one charged back, got %s of %s available, holds %v",
+			available(), ledger.asset.Balance.StringFixed(2), ledger.holds)
+	}
+
+	// A repeated resolution finds no hold and is not an error.
+	if err := fake.ResolveDispute(lost, "merchant-accepted"); err != nil {
+		t.Fatal(err)
+	}
+}
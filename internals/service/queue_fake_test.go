@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jsonQueue is a QueueService whose cache round-trips values through JSON,
+// as redis does. Idempotency locks are taken on "idemp:<key>" only when it
+// isn't set, jobs are recorded and so are deleted keys.
+type jsonQueue struct {
+	recordingQueue
+	values  map[string][]byte
+	deleted []string
+}
+
+func newJSONQueue() *jsonQueue {
+	return &jsonQueue{values: make(map[string][]byte)}
+}
+
+func (q *jsonQueue) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	encoded, err := json.Marshal(value)
+	q.values[key] = encoded
+	return err
+}
+
+func (q *jsonQueue) Get(ctx context.Context, key string, dest interface{}) error {
+	encoded, ok := q.values[key]
+	if !ok {
+		return errors.New("redis: nil")
+	}
+	return json.Unmarshal(encoded, dest)
+}
+
+func (q *jsonQueue) Delete(ctx context.Context, key string) error {
+	q.deleted = append(q.deleted, key)
+	delete(q.values, key)
+	return nil
+}
+
+func (q *jsonQueue) TryLockIdempotencyKey(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if _, taken := q.values["idemp:"+key]; taken {
+		return false, nil
+	}
+	return true, q.Set(ctx, "idemp:"+key, "processing", ttl)
+}
+
+// completed returns the idempotency keys marked done, sorted.
+func (q *jsonQueue) completed() []string {
+	var keys []string
+	for key, value := range q.values {
+		if strings.HasPrefix(key, "idemp:") && string(value) == `"completed"` {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
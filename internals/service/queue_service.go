@@ -7,11 +7,24 @@ import (
 
 type QueueService interface {
 
-	Enqueue(ctx context.Context, queueName string, payload []byte) error
-	StartWorker(ctx context.Context, queueName string)
+	Enqueue(ctx context.Context, jobType string, payload []byte) error
+	EnqueueAt(ctx context.Context, jobType string, payload []byte, at time.Time) error
+	RegisterHandler(jobType string, handler JobHandler, opts WorkerOptions)
+	Run(ctx context.Context)
+	JobStats() map[string]JobStats
 	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
     Get(ctx context.Context, key string, dest interface{}) error
     Delete(ctx context.Context, key string) error
 	TryLockIdempotencyKey(ctx context.Context, key string, ttl time.Duration) (bool, error)
 	Ping(ctx context.Context) error
-}
\ No newline at end of file
+}
+
+// WorkerOptions tune the workers for one job type. Workers keep renewing
+// their claim on a running job; one left unrenewed for VisibilityTimeout is
+// assumed lost with its worker and handed to another. Failed jobs are
+// retried according to Retry.
+type WorkerOptions struct {
+	Concurrency       int
+	VisibilityTimeout time.Duration
+	Retry             RetryPolicy
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/pdf"
+)
+
+// fixedAmount prints amount with as many decimals as currency's minor unit.
+func fixedAmount(amount decimal.Decimal, currency string) string {
+	return amount.StringFixed(money.Precision(currency))
+}
+
+var receiptHTML = template.Must(template.New("receipt").Funcs(template.FuncMap{"fixed": fixedAmount}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Receipt {{.Reference}}</title>
+<style>
+body { font-family: sans-serif; max-width: 32rem; margin: 2rem auto; color: #222; }
+table { width: 100%; border-collapse: collapse; }
+th { text-align: left; font-weight: normal; color: #666; padding: .4rem 0; }
+td { text-align: right; padding: .4rem 0; }
+.amount { font-size: 2rem; margin: .5rem 0 1.5rem; }
+.code { font-family: monospace; font-size: 1.2rem; }
+</style>
+</head>
+<body>
+<h1>Transaction receipt</h1>
+<div class="amount">{{.Currency}} {{fixed .Amount .Currency}}</div>
+<table>
+<tr><th>Status</th><td>{{.Status}}</td></tr>
+<tr><th>Type</th><td>{{.Type}}</td></tr>
+<tr><th>Reference</th><td>{{.Reference}}</td></tr>
+<tr><th>Date</th><td>{{.CreatedAt.UTC.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th>From</th><td>{{.Sender.Name}}<br>{{.Sender.AccountNumber}} {{.Sender.Institution}}</td></tr>
+<tr><th>To</th><td>{{.Recipient.Name}}<br>{{.Recipient.AccountNumber}} {{.Recipient.Institution}}</td></tr>
+{{- if .ToAmount}}
+<tr><th>Received</th><td>{{.ToCurrency}} {{fixed .ToAmount .ToCurrency}}</td></tr>
+<tr><th>Exchange rate</th><td>1 {{.Currency}} = {{.FXRate}} {{.ToCurrency}}</td></tr>
+{{- end}}
+<tr><th>Fee</th><td>{{.Currency}} {{fixed .Fee .Currency}}</td></tr>
+<tr><th>Verification code</th><td class="code">{{.VerificationCode}}</td></tr>
+</table>
+<p>Check this receipt at <a href="{{.VerifyURL}}">{{.VerifyURL}}</a>.</p>
+</body>
+</html>
+`))
+
+func RenderReceiptHTML(r *Receipt) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := receiptHTML.Execute(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func RenderReceiptPDF(r *Receipt) []byte {
+	doc := pdf.New(pdf.A4Portrait, 11)
+	row := func(label, value string) { doc.Line(fmt.Sprintf("%-20s %s", label, value)) }
+
+	doc.Bold("TRANSACTION RECEIPT")
+	doc.Blank()
+	doc.Bold(fmt.Sprintf("%s %s", r.Currency, fixedAmount(r.Amount, r.Currency)))
+	doc.Blank()
+	row("Status", string(r.Status))
+	row("Type", string(r.Type))
+	row("Reference", r.Reference)
+	row("Date", r.CreatedAt.UTC().Format("2006-01-02 15:04:05 MST"))
+	doc.Blank()
+	row("From", r.Sender.Name)
+	row("", fmt.Sprintf("%s %s", r.Sender.AccountNumber, r.Sender.Institution))
+	row("To", r.Recipient.Name)
+	row("", fmt.Sprintf("%s %s", r.Recipient.AccountNumber, r.Recipient.Institution))
+	doc.Blank()
+	if r.ToAmount != nil {
+		row("Received", fmt.Sprintf("%s %s", r.ToCurrency, fixedAmount(*r.ToAmount, r.ToCurrency)))
+		row("Exchange rate", fmt.Sprintf("1 %s = %s %s", r.Currency, r.FXRate, r.ToCurrency))
+	}
+	row("Fee", fmt.Sprintf("%s %s", r.Currency, fixedAmount(r.Fee, r.Currency)))
+	doc.Blank()
+	row("Verification code", r.VerificationCode)
+	doc.Line("Check this receipt at " + r.VerifyURL)
+	return doc.Bytes()
+}
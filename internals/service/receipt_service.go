@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// walletInstitution names our own wallets on receipts.
+const walletInstitution = "MZL Wallet"
+
+var (
+	ErrReceiptNotFound    = errors.New("receipt not found")
+	ErrInvalidReceiptCode = errors.New("receipt could not be verified")
+)
+
+// Receipt describes one payment as both parties see it. Names and account
+// numbers are masked, and descriptions are left out, so it can be shared.
+type Receipt struct {
+	Reference string               `json:"reference"`
+	Type      db.TransactionType   `json:"type"`
+	Status    db.TransactionStatus `json:"status"`
+	Amount    decimal.Decimal      `json:"amount"`
+	Currency  string               `json:"currency"`
+	Fee       decimal.Decimal      `json:"fee"`
+
+	// What a swap bought, and at what rate: ToCurrency per unit of Currency.
+	ToAmount   *decimal.Decimal `json:"to_amount,omitempty"`
+	ToCurrency string           `json:"to_currency,omitempty"`
+	FXRate     *decimal.Decimal `json:"fx_rate,omitempty"`
+
+	Sender    ReceiptParty `json:"sender"`
+	Recipient ReceiptParty `json:"recipient"`
+	CreatedAt time.Time    `json:"created_at"`
+
+	// VerificationCode signs the parts of the receipt that never change, so
+	// it still verifies after a pending payment settles.
+	VerificationCode string `json:"verification_code"`
+	VerifyURL        string `json:"verify_url"`
+}
+
+type ReceiptParty struct {
+	Name          string `json:"name"`
+	AccountNumber string `json:"account_number,omitempty"`
+	Institution   string `json:"institution"`
+}
+
+type ReceiptService interface {
+	// Receipt returns the receipt for a payment the user is a party to.
+	// reference may be a transfer or swap's own reference or either leg's.
+	Receipt(ctx context.Context, userID, reference string) (*Receipt, error)
+	// Verify returns the receipt for reference if code is its verification
+	// code, and ErrInvalidReceiptCode otherwise.
+	Verify(ctx context.Context, reference, code string) (*Receipt, error)
+}
+
+type receiptService struct {
+	repo    repository.ReceiptRepository
+	key     []byte
+	baseURL string
+}
+
+// NewReceiptService signs receipts with signingKey. Verify links are made
+// absolute with baseURL, e.g. "https://api.example.com", when it is set.
+func NewReceiptService(repo repository.ReceiptRepository, signingKey, baseURL string) ReceiptService {
+	return &receiptService{repo: repo, key: []byte(signingKey), baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (s *receiptService) Receipt(ctx context.Context, userID, reference string) (*Receipt, error) {
+	receipt, parties, err := s.load(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+	for _, party := range parties {
+		if party == userID {
+			return receipt, nil
+		}
+	}
+	// Someone else's payment looks the same as one that doesn't exist.
+	return nil, ErrReceiptNotFound
+}
+
+func (s *receiptService) Verify(ctx context.Context, reference, code string) (*Receipt, error) {
+	receipt, _, err := s.load(ctx, reference)
+	if errors.Is(err, ErrReceiptNotFound) {
+		return nil, ErrInvalidReceiptCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(normalizeReceiptCode(code)), []byte(normalizeReceiptCode(receipt.VerificationCode))) {
+		return nil, ErrInvalidReceiptCode
+	}
+	return receipt, nil
+}
+
+// legs maps the two-legged transaction types to the reference suffixes of
+// their outgoing and incoming sides.
+var legs = map[db.TransactionType][2]string{
+	db.TransactionTypeTransfer: {"-DEBIT", "-CREDIT"},
+	db.TransactionTypeMove:     {"-DEBIT", "-CREDIT"},
+	db.TransactionTypeSwap:     {"-OUT", "-IN"},
+}
+
+// load builds the receipt for reference and returns the ids of the users
+// party to it.
+func (s *receiptService) load(ctx context.Context, reference string) (*Receipt, []string, error) {
+	txn, err := s.find(ctx, reference, "", "-DEBIT", "-OUT")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	base := txn.Reference
+	out, in := txn, txn
+	if suffixes, ok := legs[txn.Type]; ok {
+		base = strings.TrimSuffix(strings.TrimSuffix(txn.Reference, suffixes[0]), suffixes[1])
+		if out, err = s.find(ctx, base+suffixes[0]); err != nil {
+			return nil, nil, err
+		}
+		if in, err = s.find(ctx, base+suffixes[1]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	receipt := &Receipt{
+		Reference: base,
+		Type:      out.Type,
+		Status:    out.Status,
+		Amount:    out.Amount,
+		Currency:  out.Currency,
+		Fee:       decimal.Zero,
+		CreatedAt: out.CreatedAt,
+	}
+	owner := walletParty(out)
+	parties := []string{out.Wallet().UserID}
+
+	switch out.Type {
+	case db.TransactionTypeTransfer:
+		receipt.Sender, receipt.Recipient = owner, walletParty(in)
+		parties = append(parties, in.Wallet().UserID)
+
+	case db.TransactionTypeSwap:
+		receipt.Sender, receipt.Recipient = owner, owner
+		receipt.ToAmount, receipt.ToCurrency = &in.Amount, in.Currency
+		rate := swapRate(out, in)
+		receipt.FXRate = &rate
+
+	case db.TransactionTypeMove:
+		receipt.Sender, receipt.Recipient = owner, walletParty(in)
+
+	case db.TransactionTypeVault:
+		receipt.Sender, receipt.Recipient = owner, owner
+
+	case db.TransactionTypeInterest:
+		receipt.Sender = ReceiptParty{Name: "Interest", Institution: "MZL"}
+		receipt.Recipient = owner
+
+	case db.TransactionTypeDeposit:
+		receipt.Sender = externalParty(out)
+		receipt.Recipient = owner
+
+	case db.TransactionTypeChargeback:
+		receipt.Sender = owner
+		receipt.Recipient = externalParty(out)
+
+	case db.TransactionTypeWithdrawal:
+		receipt.Sender = owner
+		receipt.Recipient = ReceiptParty{Name: "Bank account", Institution: "Bank"}
+		withdrawal, err := s.repo.GetWithdrawal(ctx, out.Reference)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return nil, nil, err
+		}
+		if withdrawal != nil {
+			receipt.Recipient = ReceiptParty{
+				Name:          maskName(withdrawal.AccountName),
+				AccountNumber: maskAccountNumber(withdrawal.AccountNumber),
+				Institution:   "Bank " + withdrawal.BankCode,
+			}
+		}
+	}
+
+	postings, err := s.repo.ListJournalPostings(ctx, base)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range postings {
+		if p.Account != repository.AccountFees || p.Currency != receipt.Currency {
+			continue
+		}
+		if p.Direction == db.PostingDirectionCredit {
+			receipt.Fee = receipt.Fee.Add(p.Amount)
+		} else {
+			receipt.Fee = receipt.Fee.Sub(p.Amount)
+		}
+	}
+
+	receipt.VerificationCode = s.sign(receipt)
+	receipt.VerifyURL = s.baseURL + "/api/v1/receipts/verify?" + url.Values{
+		"reference": {receipt.Reference},
+		"code":      {receipt.VerificationCode},
+	}.Encode()
+	return receipt, parties, nil
+}
+
+// find returns the first transaction found by reference with one of the
+// suffixes appended.
+func (s *receiptService) find(ctx context.Context, reference string, suffixes ...string) (*db.TransactionModel, error) {
+	if len(suffixes) == 0 {
+		suffixes = []string{""}
+	}
+	for _, suffix := range suffixes {
+		txn, err := s.repo.GetReceiptTransaction(ctx, reference+suffix)
+		if err == nil {
+			return txn, nil
+		}
+		if !errors.Is(err, db.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, ErrReceiptNotFound
+}
+
+// sign returns the receipt's verification code: the first 80 bits of an
+// HMAC-SHA256 over its fixed fields, as four groups of base32.
+func (s *receiptService) sign(r *Receipt) string {
+	mac := hmac.New(sha256.New, s.key)
+	fields := []string{"receipt.v1", r.Reference, string(r.Type), r.Amount.StringFixed(4), r.Currency,
+		r.Sender.AccountNumber, r.Recipient.AccountNumber, fmt.Sprint(r.CreatedAt.Unix())}
+	if r.ToAmount != nil {
+		fields = append(fields, r.ToAmount.StringFixed(4), r.ToCurrency)
+	}
+	mac.Write([]byte(strings.Join(fields, "|")))
+	code := base32.StdEncoding.EncodeToString(mac.Sum(nil)[:10])
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+}
+
+func normalizeReceiptCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func walletParty(txn *db.TransactionModel) ReceiptParty {
+	wallet := txn.Wallet()
+	return ReceiptParty{
+		Name:          maskName(wallet.User().Name),
+		AccountNumber: maskAccountNumber(wallet.AccountNumber),
+		Institution:   walletInstitution,
+	}
+}
+
+// externalParty is the payment provider on the far side of a deposit or
+// chargeback.
+func externalParty(txn *db.TransactionModel) ReceiptParty {
+	provider, _ := txn.Provider()
+	label := providerLabel(provider)
+	if label == "" {
+		label = "External"
+	}
+	return ReceiptParty{Name: "Card or bank payment", Institution: label}
+}
+
+// swapRate is the rate the swap was priced at, as recorded in its
+// description by SwapFunds, or failing that the rate the amounts imply.
+func swapRate(out, in *db.TransactionModel) decimal.Decimal {
+	description, _ := out.Description()
+	if _, rate, ok := strings.Cut(description, " @ "); ok {
+		if d, err := decimal.NewFromString(strings.TrimSpace(rate)); err == nil {
+			return d
+		}
+	}
+	if out.Amount.IsZero() {
+		return decimal.Zero
+	}
+	return in.Amount.DivRound(out.Amount, 8)
+}
+
+// maskName keeps the first letter of each name: "Ada Obi" is "A*** O***".
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		words[i] = string([]rune(w)[:1]) + "***"
+	}
+	return strings.Join(words, " ")
+}
+
+// maskAccountNumber keeps the last four digits.
+func maskAccountNumber(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type fakeReceiptRepo struct {
+	txns     map[string]*db.TransactionModel
+	postings map[string][]db.PostingModel
+}
+
+func (r *fakeReceiptRepo) GetReceiptTransaction(ctx context.Context, reference string) (*db.TransactionModel, error) {
+	if txn, ok := r.txns[reference]; ok {
+		return txn, nil
+	}
+	return nil, db.ErrNotFound
+}
+
+func (r *fakeReceiptRepo) GetWithdrawal(ctx context.Context, reference string) (*db.WithdrawalModel, error) {
+	return nil, db.ErrNotFound
+}
+
+func (r *fakeReceiptRepo) ListJournalPostings(ctx context.Context, reference string) ([]db.PostingModel, error) {
+	return r.postings[reference], nil
+}
+
+func (r *fakeReceiptRepo) add(ref string, txType db.TransactionType, amount, currency, userID, name, account, description string) {
+	r.txns[ref] = &db.TransactionModel{
+		InnerTransaction: db.InnerTransaction{
+			Reference:   ref,
+			Type:        txType,
+			Status:      db.TransactionStatusSuccess,
+			Amount:      decimal.RequireFromString(amount),
+			Currency:    currency,
+			Description: &description,
+			CreatedAt:   time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC),
+		},
+		RelationsTransaction: db.RelationsTransaction{Wallet: &db.WalletModel{
+			InnerWallet:     db.InnerWallet{UserID: userID, AccountNumber: account},
+			RelationsWallet: db.RelationsWallet{User: &db.UserModel{InnerUser: db.InnerUser{ID: userID, Name: name}}},
+		}},
+	}
+}
+
+func newTestReceiptService() ReceiptService {
+	repo := &fakeReceiptRepo{txns: map[string]*db.TransactionModel{}, postings: map[string][]db.PostingModel{}}
+	repo.add("TRF-1-DEBIT", db.TransactionTypeTransfer, "2500", "NGN", "sender", "Ada Obi", "0123456789", "Transfer to Bola Ade")
+	repo.add("TRF-1-CREDIT", db.TransactionTypeTransfer, "2500", "NGN", "receiver", "Bola Ade", "9876543210", "Received from Ada Obi")
+	repo.postings["TRF-1"] = []db.PostingModel{{InnerPosting: db.InnerPosting{
+		Account: repository.AccountFees, Currency: "NGN", Direction: db.PostingDirectionCredit, Amount: decimal.NewFromInt(25),
+	}}}
+	repo.add("SWP-1-OUT", db.TransactionTypeSwap, "1500", "NGN", "sender", "Ada Obi", "0123456789", "Swap NGN to USD @ 0.00066")
+	repo.add("SWP-1-IN", db.TransactionTypeSwap, "0.99", "USD", "sender", "Ada Obi", "0123456789", "Swap NGN to USD @ 0.00066")
+	repo.add("SWP-2-OUT", db.TransactionTypeSwap, "1500", "JPY", "sender", "Ada Obi", "0123456789", "Swap JPY to KWD @ 0.00205")
+	repo.add("SWP-2-IN", db.TransactionTypeSwap, "3.075", "KWD", "sender", "Ada Obi", "0123456789", "Swap JPY to KWD @ 0.00205")
+	return NewReceiptService(repo, "test-key", "https://api.example.com/")
+}
+
+func TestTransferReceipt(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestReceiptService()
+
+	receipt, err := svc.Receipt(ctx, "sender", "TRF-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Reference != "TRF-1" || receipt.Sender.Name != "A*** O***" || receipt.Recipient.AccountNumber != "******3210" {
+		t.Fatalf("unexpected receipt %+v", receipt)
+	}
+	if !receipt.Fee.Equal(decimal.NewFromInt(25)) {
+		t.Fatalf("expected the fee from the journal, got %s", receipt.Fee)
+	}
+	if !strings.HasPrefix(receipt.VerifyURL, "https://api.example.com/api/v1/receipts/verify?") {
+		t.Fatalf("unexpected verify url %s", receipt.VerifyURL)
+	}
+
+	// The recipient gets the same receipt from their own leg's reference.
+	theirs, err := svc.Receipt(ctx, "receiver", "TRF-1-CREDIT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theirs.VerificationCode != receipt.VerificationCode {
+		t.Fatal("expected both parties to get the same verification code")
+	}
+
+	if _, err := svc.Receipt(ctx, "someone-else", "TRF-1"); !errors.Is(err, ErrReceiptNotFound) {
+		t.Fatalf("expected ErrReceiptNotFound for a stranger, got %v", err)
+	}
+
+	html, err := RenderReceiptHTML(receipt)
+	if err != nil || !bytes.Contains(html, []byte(receipt.VerificationCode)) || bytes.Contains(html, []byte("Bola Ade")) {
+		t.Fatalf("expected a masked html receipt with its code, %v", err)
+	}
+}
+
+func TestVerifyReceipt(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestReceiptService()
+	receipt, err := svc.Receipt(ctx, "sender", "TRF-1-DEBIT")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := strings.ToLower(strings.ReplaceAll(receipt.VerificationCode, "-", ""))
+	if got, err := svc.Verify(ctx, "TRF-1", code); err != nil || got.Status != db.TransactionStatusSuccess {
+		t.Fatalf("expected the code to verify however it's typed, got %v", err)
+	}
+	if _, err := svc.Verify(ctx, "SWP-1", receipt.VerificationCode); !errors.Is(err, ErrInvalidReceiptCode) {
+		t.Fatalf("expected another receipt's code to fail, got %v", err)
+	}
+	if _, err := svc.Verify(ctx, "NOPE", receipt.VerificationCode); !errors.Is(err, ErrInvalidReceiptCode) {
+		t.Fatalf("expected an unknown reference to fail the same way, got %v", err)
+	}
+
+	other := NewReceiptService(&fakeReceiptRepo{txns: map[string]*db.TransactionModel{}}, "other-key", "")
+	if _, err := other.Verify(ctx, "TRF-1", receipt.VerificationCode); !errors.Is(err, ErrInvalidReceiptCode) {
+		t.Fatalf("expected ErrInvalidReceiptCode, got %v", err)
+	}
+}
+
+func TestSwapReceipt(t *testing.T) {
+	receipt, err := newTestReceiptService().Receipt(context.Background(), "sender", "SWP-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.ToCurrency != "USD" || !receipt.ToAmount.Equal(decimal.RequireFromString("0.99")) || !receipt.FXRate.Equal(decimal.RequireFromString("0.00066")) {
+		t.Fatalf("unexpected swap receipt %+v", receipt)
+	}
+	if doc := RenderReceiptPDF(receipt); !bytes.HasPrefix(doc, []byte("%PDF-")) {
+		t.Fatal("expected a pdf")
+	}
+
+	// Each amount has as many decimals as its own currency.
+	receipt, err = newTestReceiptService().Receipt(context.Background(), "sender", "SWP-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := RenderReceiptHTML(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"JPY 1500<", "KWD 3.075<", "JPY 0<"} {
+		if !bytes.Contains(html, []byte(want)) {
+			t.Fatalf("expected %q in:\n%s", want, html)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+var ErrReconciliationRunning = errors.New("a reconciliation run is already in progress")
+
+const (
+	TriggerScheduled = "SCHEDULED"
+	TriggerManual    = "MANUAL"
+
+	IssueBalanceDrift            = "BALANCE_DRIFT"
+	IssueHeldDrift               = "HELD_DRIFT"
+	IssueUnclassifiedTransaction = "UNCLASSIFIED_TRANSACTION"
+
+	ActionFrozen = "FROZEN"
+
+	balanceReconLock = "reconciliation:balance"
+)
+
+type ReconciliationService interface {
+	RunBalanceReconciliation(ctx context.Context, trigger string, freeze bool) (*db.ReconciliationRunModel, error)
+	ListRuns(ctx context.Context, kind db.ReconciliationKind, limit int) ([]db.ReconciliationRunModel, error)
+	GetRun(ctx context.Context, runID string) (*db.ReconciliationRunModel, error)
+	UnfreezeWallet(ctx context.Context, walletID string) (*db.WalletModel, error)
+	StartBalanceScheduler(ctx context.Context, interval time.Duration, freeze bool)
+
+	RunSettlementReconciliation(ctx context.Context, trigger string, from, to time.Time, autoFix bool) (*db.ReconciliationRunModel, error)
+	StartSettlementScheduler(ctx context.Context, interval, lookback time.Duration, autoFix bool)
+}
+
+type reconciliationService struct {
+	repo       repository.ReconciliationRepository
+	walletRepo repository.WalletRepository
+	paystack   *gateway.Paystack
+	redis      QueueService
+	logger     *slog.Logger
+}
+
+func NewReconciliationService(repo repository.ReconciliationRepository, walletRepo repository.WalletRepository, paystack *gateway.Paystack, redis QueueService, logger *slog.Logger) ReconciliationService {
+	return &reconciliationService{repo: repo, walletRepo: walletRepo, paystack: paystack, redis: redis, logger: logger}
+}
+
+// balanceEffect returns how much a transaction moved its wallet asset's
+// ledger balance, following the rules the wallet repository writes them with:
+//   - DEPOSIT: credited only once SUCCESS.
+//   - WITHDRAWAL: debited on SUCCESS. A PENDING one is only reserved when
+//     onHold; older withdrawals were debited up front. FAILED ones net to zero.
+//   - CHARGEBACK: a captured dispute hold, debited on SUCCESS.
+//   - TRANSFER: "<ref>-DEBIT" leaves the sender, "<ref>-CREDIT" reaches the receiver.
+//   - SWAP: "<ref>-OUT" leaves the source currency, "<ref>-IN" reaches the target.
+//   - VAULT: a DEBIT moves money into a vault, a CREDIT brings it back.
+//   - INTEREST: credited on SUCCESS, unless it was paid into a vault.
+//
+// ok is false when the transaction doesn't follow any known convention.
+func balanceEffect(txn db.TransactionModel, onHold bool) (delta decimal.Decimal, ok bool) {
+	switch txn.Type {
+	case db.TransactionTypeChargeback:
+		if txn.Status == db.TransactionStatusSuccess {
+			return txn.Amount.Neg(), true
+		}
+		return decimal.Zero, true
+
+	case db.TransactionTypeDeposit:
+		if txn.Status == db.TransactionStatusSuccess {
+			return txn.Amount, true
+		}
+		return decimal.Zero, true
+
+	case db.TransactionTypeWithdrawal:
+		if txn.Status == db.TransactionStatusFailed || (txn.Status == db.TransactionStatusPending && onHold) {
+			return decimal.Zero, true
+		}
+		return txn.Amount.Neg(), true
+
+	case db.TransactionTypeTransfer, db.TransactionTypeMove, db.TransactionTypeSwap:
+		if txn.Status != db.TransactionStatusSuccess {
+			return decimal.Zero, true
+		}
+		switch {
+		case strings.HasSuffix(txn.Reference, "-DEBIT"), strings.HasSuffix(txn.Reference, "-OUT"):
+			return txn.Amount.Neg(), true
+		case strings.HasSuffix(txn.Reference, "-CREDIT"), strings.HasSuffix(txn.Reference, "-IN"):
+			return txn.Amount, true
+		}
+
+	case db.TransactionTypeVault:
+		if txn.Status != db.TransactionStatusSuccess {
+			return decimal.Zero, true
+		}
+		switch txn.Direction {
+		case db.TransactionDirectionDebit:
+			return txn.Amount.Neg(), true
+		case db.TransactionDirectionCredit:
+			return txn.Amount, true
+		}
+
+	case db.TransactionTypeInterest:
+		if _, toVault := txn.VaultID(); toVault || txn.Status != db.TransactionStatusSuccess {
+			return decimal.Zero, true
+		}
+		return txn.Amount, true
+	}
+	return decimal.Zero, false
+}
+
+func (s *reconciliationService) RunBalanceReconciliation(ctx context.Context, trigger string, freeze bool) (*db.ReconciliationRunModel, error) {
+	// Only one run at a time across every instance of the API.
+	locked, _ := s.redis.TryLockIdempotencyKey(ctx, balanceReconLock, 30*time.Minute)
+	if !locked {
+		return nil, ErrReconciliationRunning
+	}
+	defer s.redis.Delete(context.Background(), "idemp:"+balanceReconLock)
+
+	run, err := s.repo.CreateRun(ctx, db.ReconciliationKindBalance, trigger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start reconciliation run: %w", err)
+	}
+
+	checked, findings, err := s.checkBalances(ctx, run.ID, freeze)
+	if err == nil {
+		err = s.repo.AddFindings(ctx, run.ID, findings)
+	}
+	if err != nil {
+		_, _ = s.repo.FinishRun(ctx, run.ID, db.ReconciliationStatusFailed, checked, len(findings), err)
+		return nil, fmt.Errorf("reconciliation run %s failed: %w", run.ID, err)
+	}
+
+	return s.repo.FinishRun(ctx, run.ID, db.ReconciliationStatusCompleted, checked, len(findings), nil)
+}
+
+// checkBalances replays every wallet's transaction history and compares the
+// result with each stored WalletAsset balance. The assets are all listed up
+// front, so a wallet that moved since can look off; its drift only counts
+// once confirmDrift finds it again.
+func (s *reconciliationService) checkBalances(ctx context.Context, runID string, freeze bool) (int, []repository.Finding, error) {
+	assets, err := s.repo.ListWalletAssets(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	byWallet := map[string][]db.WalletAssetModel{}
+	var walletIDs []string
+	for _, asset := range assets {
+		if _, seen := byWallet[asset.WalletID]; !seen {
+			walletIDs = append(walletIDs, asset.WalletID)
+		}
+		byWallet[asset.WalletID] = append(byWallet[asset.WalletID], asset)
+	}
+
+	var findings []repository.Finding
+	for _, walletID := range walletIDs {
+		walletAssets := byWallet[walletID]
+
+		walletFindings, err := s.checkWallet(ctx, walletID, walletAssets)
+		if err != nil {
+			return len(assets), findings, err
+		}
+		walletFindings, err = s.confirmDrift(ctx, walletID, walletFindings)
+		if err != nil {
+			return len(assets), findings, err
+		}
+
+		if freeze && hasDrift(walletFindings) {
+			wallet := walletAssets[0].Wallet()
+			if !wallet.Frozen {
+				reason := fmt.Sprintf("balance drift found by reconciliation run %s", runID)
+				if _, err := s.repo.SetWalletFrozen(ctx, walletID, true, reason); err != nil {
+					return len(assets), findings, err
+				}
+				_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", wallet.UserID))
+				for i := range walletFindings {
+					if walletFindings[i].Issue == IssueBalanceDrift {
+						walletFindings[i].Action = ActionFrozen
+					}
+				}
+			}
+		}
+
+		findings = append(findings, walletFindings...)
+	}
+
+	return len(assets), findings, nil
+}
+
+// checkWallet replays one wallet's transactions and active holds, read after
+// walletAssets, and compares them with the assets' stored balances.
+func (s *reconciliationService) checkWallet(ctx context.Context, walletID string, walletAssets []db.WalletAssetModel) ([]repository.Finding, error) {
+	txns, err := s.repo.GetWalletTransactions(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	holds, err := s.repo.ListActiveHolds(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	onHold := map[string]bool{}
+	expectedHeld := map[string]decimal.Decimal{}
+	for _, hold := range holds {
+		onHold[hold.Reference] = true
+		expectedHeld[hold.Currency] = expectedHeld[hold.Currency].Add(hold.Amount)
+	}
+
+	var walletFindings []repository.Finding
+	expected := map[string]decimal.Decimal{}
+	for _, txn := range txns {
+		delta, ok := balanceEffect(txn, onHold[txn.Reference])
+		if !ok {
+			walletFindings = append(walletFindings, repository.Finding{
+				Issue:     IssueUnclassifiedTransaction,
+				WalletID:  walletID,
+				Currency:  txn.Currency,
+				Reference: txn.Reference,
+				Detail:    fmt.Sprintf("%s transaction with status %s does not follow a known reference convention", txn.Type, txn.Status),
+			})
+			continue
+		}
+		expected[txn.Currency] = expected[txn.Currency].Add(delta)
+	}
+
+	stored := map[string]bool{}
+	for _, asset := range walletAssets {
+		stored[asset.Currency] = true
+		if held := expectedHeld[asset.Currency]; !held.Equal(asset.HeldBalance) {
+			walletFindings = append(walletFindings, repository.Finding{
+				Issue:    IssueHeldDrift,
+				WalletID: walletID,
+				Currency: asset.Currency,
+				Expected: decimalPtr(held),
+				Actual:   decimalPtr(asset.HeldBalance),
+				Detail:   "held balance does not match the sum of active holds",
+			})
+		}
+		want := expected[asset.Currency]
+		if want.Equal(asset.Balance) {
+			continue
+		}
+		walletFindings = append(walletFindings, driftFinding(walletID, asset.Currency, want, asset.Balance))
+	}
+	for currency, want := range expected {
+		if !stored[currency] && !want.IsZero() {
+			walletFindings = append(walletFindings, driftFinding(walletID, currency, want, decimal.Zero))
+		}
+	}
+	return walletFindings, nil
+}
+
+// confirmDrift checks a wallet with drift again, re-reading its assets right
+// before its history, and keeps the findings of that second look. A drift is only
+// kept if the first look found the same difference: one caused by money
+// that moved between reading the assets and the history doesn't repeat.
+func (s *reconciliationService) confirmDrift(ctx context.Context, walletID string, first []repository.Finding) ([]repository.Finding, error) {
+	offsets := map[string]decimal.Decimal{}
+	for _, f := range first {
+		if isDriftIssue(f.Issue) {
+			offsets[f.Issue+":"+f.Currency] = f.Actual.Sub(*f.Expected)
+		}
+	}
+	if len(offsets) == 0 {
+		return first, nil
+	}
+
+	assets, err := s.repo.GetWalletAssets(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	again, err := s.checkWallet(ctx, walletID, assets)
+	if err != nil {
+		return nil, err
+	}
+
+	var confirmed []repository.Finding
+	for _, f := range again {
+		if isDriftIssue(f.Issue) {
+			offset, seen := offsets[f.Issue+":"+f.Currency]
+			if !seen || !offset.Equal(f.Actual.Sub(*f.Expected)) {
+				continue
+			}
+		}
+		confirmed = append(confirmed, f)
+	}
+	return confirmed, nil
+}
+
+func isDriftIssue(issue string) bool {
+	return issue == IssueBalanceDrift || issue == IssueHeldDrift
+}
+
+func driftFinding(walletID, currency string, expected, actual decimal.Decimal) repository.Finding {
+	return repository.Finding{
+		Issue:    IssueBalanceDrift,
+		WalletID: walletID,
+		Currency: currency,
+		Expected: &expected,
+		Actual:   &actual,
+		Detail:   fmt.Sprintf("stored balance is off by %s", actual.Sub(expected)),
+	}
+}
+
+func hasDrift(findings []repository.Finding) bool {
+	for _, f := range findings {
+		if f.Issue == IssueBalanceDrift {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *reconciliationService) ListRuns(ctx context.Context, kind db.ReconciliationKind, limit int) ([]db.ReconciliationRunModel, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return s.repo.ListRuns(ctx, kind, limit)
+}
+
+func (s *reconciliationService) GetRun(ctx context.Context, runID string) (*db.ReconciliationRunModel, error) {
+	return s.repo.GetRun(ctx, runID)
+}
+
+func (s *reconciliationService) UnfreezeWallet(ctx context.Context, walletID string) (*db.WalletModel, error) {
+	wallet, err := s.repo.SetWalletFrozen(ctx, walletID, false, "")
+	if err != nil {
+		return nil, err
+	}
+	_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", wallet.UserID))
+	return wallet, nil
+}
+
+// StartBalanceScheduler runs the balance reconciliation every interval until
+// ctx is cancelled. A non-positive interval disables it.
+func (s *reconciliationService) StartBalanceScheduler(ctx context.Context, interval time.Duration, freeze bool) {
+	s.runEvery(ctx, "balance", interval, func() (*db.ReconciliationRunModel, error) {
+		return s.RunBalanceReconciliation(ctx, TriggerScheduled, freeze)
+	})
+}
+
+// runEvery calls job on every tick and logs the outcome of each run.
+func (s *reconciliationService) runEvery(ctx context.Context, name string, interval time.Duration, job func() (*db.ReconciliationRunModel, error)) {
+	if interval <= 0 {
+		s.logger.Info("reconciliation schedule disabled", "job", name)
+		return
+	}
+
+	s.logger.Info("reconciliation scheduled", "job", name, "interval", interval.String())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run, err := job()
+			if errors.Is(err, ErrReconciliationRunning) {
+				continue
+			}
+			if err != nil {
+				s.logger.Error("reconciliation failed", "job", name, "error", err)
+				continue
+			}
+			if run.Mismatches > 0 {
+				s.logger.Warn("reconciliation found mismatches", "job", name, "run_id", run.ID, "mismatches", run.Mismatches)
+			} else {
+				s.logger.Info("reconciliation clean", "job", name, "run_id", run.ID, "checked", run.Checked)
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+func txn(txType db.TransactionType, status db.TransactionStatus, reference, amount, currency string) db.TransactionModel {
+	return db.TransactionModel{InnerTransaction: db.InnerTransaction{
+		WalletID:  "w1",
+		Type:      txType,
+		Status:    status,
+		Reference: reference,
+		Amount:    decimal.RequireFromString(amount),
+		Currency:  currency,
+	}}
+}
+
+func TestBalanceEffect(t *testing.T) {
+	cases := []struct {
+		name string
+		txn  db.TransactionModel
+		want string
+		hold bool
+		ok   bool
+	}{
+		{"Successful Deposit", txn(db.TransactionTypeDeposit, db.TransactionStatusSuccess, "PSK-1", "100.50", "NGN"), "100.5", false, true},
+		{"Pending Deposit", txn(db.TransactionTypeDeposit, db.TransactionStatusPending, "PSK-2", "100", "NGN"), "0", false, true},
+		{"Legacy Pending Withdrawal", txn(db.TransactionTypeWithdrawal, db.TransactionStatusPending, "WDR-1", "40", "NGN"), "-40", false, true},
+		{"Held Pending Withdrawal", txn(db.TransactionTypeWithdrawal, db.TransactionStatusPending, "WDR-1", "40", "NGN"), "0", true, true},
+		{"Successful Withdrawal", txn(db.TransactionTypeWithdrawal, db.TransactionStatusSuccess, "WDR-3", "40", "NGN"), "-40", false, true},
+		{"Failed Withdrawal", txn(db.TransactionTypeWithdrawal, db.TransactionStatusFailed, "WDR-2", "40", "NGN"), "0", false, true},
+		{"Chargeback", txn(db.TransactionTypeChargeback, db.TransactionStatusSuccess, "DSP-1", "10", "NGN"), "-10", false, true},
+		{"Transfer Debit", txn(db.TransactionTypeTransfer, db.TransactionStatusSuccess, "k1-DEBIT", "25", "NGN"), "-25", false, true},
+		{"Transfer Credit", txn(db.TransactionTypeTransfer, db.TransactionStatusSuccess, "k1-CREDIT", "25", "NGN"), "25", false, true},
+		{"Swap Out", txn(db.TransactionTypeSwap, db.TransactionStatusSuccess, "k2-OUT", "1500", "NGN"), "-1500", false, true},
+		{"Swap In", txn(db.TransactionTypeSwap, db.TransactionStatusSuccess, "k2-IN", "1", "USD"), "1", false, true},
+		{"Interest", txn(db.TransactionTypeInterest, db.TransactionStatusSuccess, "INTEREST-2026-09-WALLET:w1-NGN", "3.12", "NGN"), "3.12", false, true},
+		{"Unknown Suffix", txn(db.TransactionTypeTransfer, db.TransactionStatusSuccess, "k3", "5", "NGN"), "0", false, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := balanceEffect(tc.txn, tc.hold)
+			if ok != tc.ok {
+				t.Fatalf("expected ok=%t, got %t", tc.ok, ok)
+			}
+			if !got.Equal(decimal.RequireFromString(tc.want)) {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+type fakeReconRepo struct {
+	repository.ReconciliationRepository
+	assets []db.WalletAssetModel
+	// current, when set, is what the assets read as once they were listed.
+	current []db.WalletAssetModel
+	txns    map[string][]db.TransactionModel
+	holds   map[string][]db.FundHoldModel
+	frozen  map[string]bool
+}
+
+func (f *fakeReconRepo) ListWalletAssets(ctx context.Context) ([]db.WalletAssetModel, error) {
+	return f.assets, nil
+}
+
+func (f *fakeReconRepo) GetWalletAssets(ctx context.Context, walletID string) ([]db.WalletAssetModel, error) {
+	assets := f.assets
+	if f.current != nil {
+		assets = f.current
+	}
+	var out []db.WalletAssetModel
+	for _, asset := range assets {
+		if asset.WalletID == walletID {
+			out = append(out, asset)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeReconRepo) GetWalletTransactions(ctx context.Context, walletID string) ([]db.TransactionModel, error) {
+	return f.txns[walletID], nil
+}
+
+func (f *fakeReconRepo) ListActiveHolds(ctx context.Context, walletID string) ([]db.FundHoldModel, error) {
+	return f.holds[walletID], nil
+}
+
+func (f *fakeReconRepo) SetWalletFrozen(ctx context.Context, walletID string, frozen bool, reason string) (*db.WalletModel, error) {
+	f.frozen[walletID] = frozen
+	return &db.WalletModel{InnerWallet: db.InnerWallet{ID: walletID, Frozen: frozen}}, nil
+}
+
+type fakeCache struct {
+	QueueService
+}
+
+func (fakeCache) Delete(ctx context.Context, key string) error { return nil }
+
+func reconAsset(currency, balance, held string) db.WalletAssetModel {
+	return db.WalletAssetModel{
+		InnerWalletAsset: db.InnerWalletAsset{
+			WalletID:    "w1",
+			Currency:    currency,
+			Balance:     decimal.RequireFromString(balance),
+			HeldBalance: decimal.RequireFromString(held),
+		},
+		RelationsWalletAsset: db.RelationsWalletAsset{Wallet: &db.WalletModel{InnerWallet: db.InnerWallet{ID: "w1", UserID: "u1"}}},
+	}
+}
+
+func TestCheckBalancesFlagsDrift(t *testing.T) {
+	repo := &fakeReconRepo{
+		assets: []db.WalletAssetModel{reconAsset("NGN", "60", "0"), reconAsset("USD", "2", "0")},
+		txns: map[string][]db.TransactionModel{
+			"w1": {
+				txn(db.TransactionTypeDeposit, db.TransactionStatusSuccess, "PSK-1", "100", "NGN"),
+				txn(db.TransactionTypeWithdrawal, db.TransactionStatusPending, "WDR-1", "40", "NGN"),
+				txn(db.TransactionTypeSwap, db.TransactionStatusSuccess, "k2-IN", "1", "USD"),
+			},
+		},
+		frozen: map[string]bool{},
+	}
+	svc := &reconciliationService{repo: repo, redis: fakeCache{}}
+
+	checked, findings, err := svc.checkBalances(context.Background(), "run-1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked != 2 {
+		t.Fatalf("expected 2 assets checked, got %d", checked)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("expected only the USD asset to drift, got %+v", findings)
+	}
+
+	f := findings[0]
+	if f.Currency != "USD" || !f.Expected.Equal(decimal.NewFromInt(1)) || !f.Actual.Equal(decimal.NewFromInt(2)) {
+		t.Fatalf("unexpected finding: %+v", f)
+	}
+	if f.Action != ActionFrozen || !repo.frozen["w1"] {
+		t.Fatalf("expected wallet w1 to be frozen")
+	}
+}
+
+func TestCheckBalancesIgnoresMovesDuringTheRun(t *testing.T) {
+	// The deposit landed after the assets were listed but before the history
+	// was read: the listed NGN balance looks 50 short until it is read again.
+	repo := &fakeReconRepo{
+		assets:  []db.WalletAssetModel{reconAsset("NGN", "100", "0")},
+		current: []db.WalletAssetModel{reconAsset("NGN", "150", "0")},
+		txns: map[string][]db.TransactionModel{
+			"w1": {
+				txn(db.TransactionTypeDeposit, db.TransactionStatusSuccess, "PSK-1", "100", "NGN"),
+				txn(db.TransactionTypeDeposit, db.TransactionStatusSuccess, "PSK-2", "50", "NGN"),
+			},
+		},
+		frozen: map[string]bool{},
+	}
+	svc := &reconciliationService{repo: repo, redis: fakeCache{}}
+
+	_, findings, err := svc.checkBalances(context.Background(), "run-1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 || repo.frozen["w1"] {
+		t.Fatalf("expected no drift and no freeze, got %+v", findings)
+	}
+
+	// A difference that changes between the two reads isn't confirmed
+	// either; the next run looks again.
+	repo.current = []db.WalletAssetModel{reconAsset("NGN", "140", "0")}
+	_, findings, err = svc.checkBalances(context.Background(), "run-2", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Fatalf("expected an unconfirmed drift to be left for the next run, got %+v", findings)
+	}
+}
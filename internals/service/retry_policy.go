@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy decides how often a failed job is retried and how long to
+// wait before each attempt.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used for queues without a policy of their own.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 5 * time.Second, MaxDelay: 5 * time.Minute}
+
+// DefaultRetryPolicies give webhooks long enough to outlast a short gateway
+// outage; withdrawals are slower to retry since each attempt calls the gateway.
+// Notifications back off furthest, as a provider outage tends to last.
+var DefaultRetryPolicies = map[string]RetryPolicy{
+	WebhookQueue:      {MaxAttempts: 6, BaseDelay: 10 * time.Second, MaxDelay: 15 * time.Minute},
+	WithdrawalQueue:   {MaxAttempts: 4, BaseDelay: 30 * time.Second, MaxDelay: 15 * time.Minute},
+	NotificationQueue: {MaxAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: 30 * time.Minute},
+}
+
+// Delay is how long to wait before retrying after the given number of
+// failures: BaseDelay doubled per failure and capped at MaxDelay, with the
+// upper half randomised so jobs that failed together don't retry together.
+func (p RetryPolicy) Delay(failures int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// RetryPolicies returns the defaults overridden by spec, a comma-separated
+// list of queue=attempts:base:max, e.g. "withdrawals=5:1m:30m".
+func RetryPolicies(spec string) (map[string]RetryPolicy, error) {
+	policies := make(map[string]RetryPolicy, len(DefaultRetryPolicies))
+	for queueName, p := range DefaultRetryPolicies {
+		policies[queueName] = p
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		queueName, rule, ok := strings.Cut(entry, "=")
+		parts := strings.Split(rule, ":")
+		if !ok || len(parts) != 3 {
+			return nil, fmt.Errorf("retry policy %q: want queue=attempts:base:max", entry)
+		}
+
+		attempts, err := strconv.Atoi(parts[0])
+		if err != nil || attempts < 1 {
+			return nil, fmt.Errorf("retry policy %q: attempts must be a positive number", entry)
+		}
+		base, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("retry policy %q: %w", entry, err)
+		}
+		max, err := time.ParseDuration(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("retry policy %q: %w", entry, err)
+		}
+		policies[strings.TrimSpace(queueName)] = RetryPolicy{MaxAttempts: attempts, BaseDelay: base, MaxDelay: max}
+	}
+	return policies, nil
+}
+
+// RetryPolicyFor returns the queue's policy, or DefaultRetryPolicy.
+func RetryPolicyFor(policies map[string]RetryPolicy, queueName string) RetryPolicy {
+	if p, ok := policies[queueName]; ok {
+		return p
+	}
+	return DefaultRetryPolicy
+}
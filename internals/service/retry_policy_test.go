@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	cases := []struct {
+		failures int
+		ceiling  time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{6, 10 * time.Second},
+	}
+	for _, tc := range cases {
+		for i := 0; i < 50; i++ {
+			d := p.Delay(tc.failures)
+			if d < tc.ceiling/2 || d > tc.ceiling {
+				t.Fatalf("failure %d: delay %s outside [%s, %s]", tc.failures, d, tc.ceiling/2, tc.ceiling)
+			}
+		}
+	}
+}
+
+func TestRetryPolicies(t *testing.T) {
+	policies, err := RetryPolicies("withdrawals=5:1m:30m, emails=2:1s:1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := policies[WithdrawalQueue]; got != (RetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: 30 * time.Minute}) {
+		t.Errorf("expected the withdrawals override, got %+v", got)
+	}
+	if got := policies[WebhookQueue]; got != DefaultRetryPolicies[WebhookQueue] {
+		t.Errorf("expected the webhook default to survive, got %+v", got)
+	}
+	if got := RetryPolicyFor(policies, "unknown"); got != DefaultRetryPolicy {
+		t.Errorf("expected the fallback policy, got %+v", got)
+	}
+
+	for _, bad := range []string{"withdrawals", "withdrawals=0:1s:1s", "withdrawals=3:soon:1m", "withdrawals=3:1s"} {
+		if _, err := RetryPolicies(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
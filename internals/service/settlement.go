@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+const (
+	IssueMissingDeposit   = "MISSING_DEPOSIT"  // Paystack charged the customer, we never credited
+	IssueNotAtProvider    = "NOT_AT_PROVIDER"  // we credited a deposit Paystack doesn't confirm
+	IssueStaleWithdrawal  = "STALE_WITHDRAWAL" // still PENDING here, finished at Paystack
+	IssueUnknownTransfer  = "UNKNOWN_TRANSFER" // payout at Paystack with no local record
+	IssueStatusMismatch   = "STATUS_MISMATCH"  // both sides finished, with different outcomes
+	IssueAmountMismatch   = "AMOUNT_MISMATCH"
+	IssueCurrencyMismatch = "CURRENCY_MISMATCH"
+
+	ActionCredited      = "CREDITED"
+	ActionMarkedSuccess = "MARKED_SUCCESS"
+	ActionRefunded      = "REFUNDED"
+
+	settlementReconLock = "reconciliation:settlement"
+	maxSettlementPages  = 50
+)
+
+// ErrSettlementWindowTooLarge fails a settlement run whose window holds more
+// Paystack records than it pages through; reconcile a shorter window instead.
+var ErrSettlementWindowTooLarge = errors.New("settlement window is too large")
+
+func (s *reconciliationService) RunSettlementReconciliation(ctx context.Context, trigger string, from, to time.Time, autoFix bool) (*db.ReconciliationRunModel, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("settlement window is empty: %s to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	locked, _ := s.redis.TryLockIdempotencyKey(ctx, settlementReconLock, 30*time.Minute)
+	if !locked {
+		return nil, ErrReconciliationRunning
+	}
+	defer s.redis.Delete(context.Background(), "idemp:"+settlementReconLock)
+
+	run, err := s.repo.CreateRun(ctx, db.ReconciliationKindSettlement, trigger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start reconciliation run: %w", err)
+	}
+
+	checked, findings, err := s.checkSettlement(ctx, from, to, autoFix)
+	if err == nil {
+		err = s.repo.AddFindings(ctx, run.ID, findings)
+	}
+	if err != nil {
+		_, _ = s.repo.FinishRun(ctx, run.ID, db.ReconciliationStatusFailed, checked, len(findings), err)
+		return nil, fmt.Errorf("reconciliation run %s failed: %w", run.ID, err)
+	}
+
+	return s.repo.FinishRun(ctx, run.ID, db.ReconciliationStatusCompleted, checked, len(findings), nil)
+}
+
+func (s *reconciliationService) checkSettlement(ctx context.Context, from, to time.Time, autoFix bool) (int, []repository.Finding, error) {
+	checked := 0
+
+	charges, err := s.fetchTransactions(ctx, from, to)
+	if err != nil {
+		return checked, nil, err
+	}
+	var findings []repository.Finding
+	seen := map[string]bool{}
+	for _, charge := range charges {
+		checked++
+		seen[charge.Reference] = true
+		f, err := s.matchCharge(ctx, charge, autoFix)
+		if err != nil {
+			return checked, findings, err
+		}
+		findings = append(findings, f...)
+	}
+
+	transfers, err := s.fetchTransfers(ctx, from, to)
+	if err != nil {
+		return checked, findings, err
+	}
+	for _, transfer := range transfers {
+		checked++
+		f, err := s.matchTransfer(ctx, transfer, autoFix)
+		if err != nil {
+			return checked, findings, err
+		}
+		findings = append(findings, f...)
+	}
+
+	// Deposits we credited in the window that Paystack didn't list. The list
+	// filters on Paystack's own timestamps, so confirm each one before flagging.
+	local, err := s.repo.ListProviderDeposits(ctx, "PAYSTACK", from, to)
+	if err != nil {
+		return checked, findings, err
+	}
+	for _, txn := range local {
+		if seen[txn.Reference] || txn.Status != db.TransactionStatusSuccess {
+			continue
+		}
+		checked++
+		verified, err := s.paystack.VerifyTransaction(ctx, txn.Reference)
+		if err == nil && verified.Data.Status == "success" {
+			continue
+		}
+		detail := "Paystack does not report this charge as successful"
+		if err != nil {
+			detail = fmt.Sprintf("Paystack lookup failed: %v", err)
+		}
+		findings = append(findings, repository.Finding{
+			Issue:     IssueNotAtProvider,
+			WalletID:  txn.WalletID,
+			Currency:  txn.Currency,
+			Reference: txn.Reference,
+			Expected:  decimalPtr(decimal.Zero),
+			Actual:    decimalPtr(txn.Amount),
+			Detail:    detail,
+		})
+	}
+
+	return checked, findings, nil
+}
+
+func (s *reconciliationService) matchCharge(ctx context.Context, charge gateway.PaystackTransaction, autoFix bool) ([]repository.Finding, error) {
+	if charge.Status != "success" {
+		return nil, nil
+	}
+	amount := money.FromMinor(charge.Amount, charge.Currency)
+
+	local, err := s.repo.FindTransaction(ctx, charge.Reference, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if local == nil {
+		f := repository.Finding{
+			Issue:     IssueMissingDeposit,
+			Currency:  amount.Currency(),
+			Reference: charge.Reference,
+			Expected:  decimalPtr(amount.Amount()),
+			Actual:    decimalPtr(decimal.Zero),
+			Detail:    fmt.Sprintf("charge for %s was never credited", charge.Customer.Email),
+		}
+		if autoFix && charge.Customer.Email != "" {
+			description := fmt.Sprintf("Deposit via Paystack (%s)", amount.Currency())
+			if err := s.walletRepo.CreditWalletByEmail(ctx, charge.Customer.Email, amount, charge.Reference, description, "PAYSTACK"); err != nil {
+				f.Detail += fmt.Sprintf("; auto credit failed: %v", err)
+			} else {
+				f.Action = ActionCredited
+				s.invalidateWalletFor(ctx, charge.Reference)
+			}
+		}
+		return []repository.Finding{f}, nil
+	}
+
+	return compareAmounts(local, amount), nil
+}
+
+func (s *reconciliationService) matchTransfer(ctx context.Context, transfer gateway.PaystackTransfer, autoFix bool) ([]repository.Finding, error) {
+	amount := money.FromMinor(transfer.Amount, transfer.Currency)
+
+	local, err := s.repo.FindTransaction(ctx, transfer.Reference, transfer.TransferCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if local == nil {
+		return []repository.Finding{{
+			Issue:     IssueUnknownTransfer,
+			Currency:  amount.Currency(),
+			Reference: firstNonEmpty(transfer.Reference, transfer.TransferCode),
+			Expected:  decimalPtr(decimal.Zero),
+			Actual:    decimalPtr(amount.Amount()),
+			Detail:    fmt.Sprintf("Paystack transfer %s (%s) has no local withdrawal", transfer.TransferCode, transfer.Status),
+		}}, nil
+	}
+
+	findings := compareAmounts(local, amount)
+	gatewayRef, _ := local.GatewayRef()
+	paystackStatus := strings.ToLower(transfer.Status)
+	finished := paystackStatus == "success" || paystackStatus == "failed" || paystackStatus == "reversed"
+
+	switch {
+	case !finished:
+		return findings, nil
+
+	case local.Status == db.TransactionStatusPending:
+		f := repository.Finding{
+			Issue:     IssueStaleWithdrawal,
+			WalletID:  local.WalletID,
+			Currency:  local.Currency,
+			Reference: local.Reference,
+			Detail:    fmt.Sprintf("withdrawal is PENDING locally but %s at Paystack", paystackStatus),
+		}
+		// A mismatched amount is never safe to settle automatically.
+		if autoFix && len(findings) == 0 {
+			f.Action, f.Detail = s.settleWithdrawal(ctx, local, gatewayRef, paystackStatus, f.Detail)
+		}
+		findings = append(findings, f)
+
+	case (paystackStatus == "success") != (local.Status == db.TransactionStatusSuccess):
+		findings = append(findings, repository.Finding{
+			Issue:     IssueStatusMismatch,
+			WalletID:  local.WalletID,
+			Currency:  local.Currency,
+			Reference: local.Reference,
+			Detail:    fmt.Sprintf("withdrawal is %s locally but %s at Paystack", local.Status, paystackStatus),
+		})
+	}
+
+	return findings, nil
+}
+
+// settleWithdrawal applies the outcome Paystack reports for a PENDING
+// withdrawal, exactly as the transfer.* webhooks would have.
+func (s *reconciliationService) settleWithdrawal(ctx context.Context, local *db.TransactionModel, gatewayRef, paystackStatus, detail string) (string, string) {
+	var action string
+	var err error
+	if paystackStatus == "success" {
+		action = ActionMarkedSuccess
+		err = s.walletRepo.CaptureWithdrawal(ctx, local.Reference, gatewayRef)
+	} else {
+		outcome := db.WithdrawalStatusFailed
+		if paystackStatus == "reversed" {
+			outcome = db.WithdrawalStatusReversed
+		}
+		action = ActionRefunded
+		err = s.walletRepo.ReleaseWithdrawal(ctx, local.Reference, gatewayRef, outcome)
+	}
+	if err != nil {
+		return "", detail + fmt.Sprintf("; auto fix failed: %v", err)
+	}
+	s.invalidateWalletFor(ctx, local.Reference)
+	return action, detail
+}
+
+func compareAmounts(local *db.TransactionModel, remote money.Money) []repository.Finding {
+	if local.Currency != remote.Currency() {
+		return []repository.Finding{{
+			Issue:     IssueCurrencyMismatch,
+			WalletID:  local.WalletID,
+			Currency:  local.Currency,
+			Reference: local.Reference,
+			Detail:    fmt.Sprintf("recorded in %s, Paystack has %s", local.Currency, remote.Currency()),
+		}}
+	}
+	if !local.Amount.Equal(remote.Amount()) {
+		return []repository.Finding{{
+			Issue:     IssueAmountMismatch,
+			WalletID:  local.WalletID,
+			Currency:  local.Currency,
+			Reference: local.Reference,
+			Expected:  decimalPtr(remote.Amount()),
+			Actual:    decimalPtr(local.Amount),
+			Detail:    fmt.Sprintf("recorded %s, Paystack has %s", local.Amount.StringFixed(money.Precision(local.Currency)), remote.StringFixed()),
+		}}
+	}
+	return nil
+}
+
+func (s *reconciliationService) invalidateWalletFor(ctx context.Context, reference string) {
+	txn, err := s.repo.FindTransaction(ctx, reference, "")
+	if err != nil || txn == nil {
+		return
+	}
+	if wallet := txn.RelationsTransaction.Wallet; wallet != nil {
+		_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", wallet.UserID))
+		_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", wallet.UserID))
+	}
+}
+
+// fetchTransactions lists every Paystack charge in the window. A window
+// with more than maxSettlementPages pages fails rather than being checked in
+// part, as the charges left out would go unreconciled.
+func (s *reconciliationService) fetchTransactions(ctx context.Context, from, to time.Time) ([]gateway.PaystackTransaction, error) {
+	var all []gateway.PaystackTransaction
+	for page := 1; ; page++ {
+		if page > maxSettlementPages {
+			return nil, fmt.Errorf("%w: more than %d pages of paystack transactions", ErrSettlementWindowTooLarge, maxSettlementPages)
+		}
+		resp, err := s.paystack.ListTransactions(ctx, from, to, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list paystack transactions: %w", err)
+		}
+		all = append(all, resp.Data...)
+		if len(resp.Data) == 0 || page >= resp.Meta.PageCount {
+			break
+		}
+	}
+	return all, nil
+}
+
+// fetchTransfers lists every Paystack payout in the window, failing like
+// fetchTransactions when there are too many pages.
+func (s *reconciliationService) fetchTransfers(ctx context.Context, from, to time.Time) ([]gateway.PaystackTransfer, error) {
+	var all []gateway.PaystackTransfer
+	for page := 1; ; page++ {
+		if page > maxSettlementPages {
+			return nil, fmt.Errorf("%w: more than %d pages of paystack transfers", ErrSettlementWindowTooLarge, maxSettlementPages)
+		}
+		resp, err := s.paystack.ListTransfers(ctx, from, to, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list paystack transfers: %w", err)
+		}
+		all = append(all, resp.Data...)
+		if len(resp.Data) == 0 || page >= resp.Meta.PageCount {
+			break
+		}
+	}
+	return all, nil
+}
+
+// StartSettlementScheduler reconciles the trailing lookback window against
+// Paystack every interval. A non-positive interval disables it.
+func (s *reconciliationService) StartSettlementScheduler(ctx context.Context, interval, lookback time.Duration, autoFix bool) {
+	s.runEvery(ctx, "settlement", interval, func() (*db.ReconciliationRunModel, error) {
+		to := time.Now()
+		return s.RunSettlementReconciliation(ctx, TriggerScheduled, to.Add(-lookback), to, autoFix)
+	})
+}
+
+func decimalPtr(d decimal.Decimal) *decimal.Decimal {
+	return &d
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type fakeSettlementRepo struct {
+	repository.ReconciliationRepository
+	byReference map[string]*db.TransactionModel
+}
+
+func (f *fakeSettlementRepo) FindTransaction(ctx context.Context, reference, gatewayRef string) (*db.TransactionModel, error) {
+	if txn, ok := f.byReference[reference]; ok {
+		return txn, nil
+	}
+	for _, txn := range f.byReference {
+		if ref, ok := txn.GatewayRef(); ok && gatewayRef != "" && ref == gatewayRef {
+			return txn, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeSettlementRepo) ListProviderDeposits(ctx context.Context, provider string, from, to time.Time) ([]db.TransactionModel, error) {
+	return nil, nil
+}
+
+type fakeSettlementWallets struct {
+	repository.WalletRepository
+	credited map[string]money.Money
+	settled  map[string]db.TransactionStatus
+}
+
+func (f *fakeSettlementWallets) CreditWalletByEmail(ctx context.Context, email string, amount money.Money, reference, description, provider string) error {
+	f.credited[reference] = amount
+	return nil
+}
+
+func (f *fakeSettlementWallets) CaptureWithdrawal(ctx context.Context, reference, transferCode string) error {
+	f.settled[transferCode] = db.TransactionStatusSuccess
+	return nil
+}
+
+func TestCheckSettlement(t *testing.T) {
+	paystack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/transaction":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": true,
+				"data": []map[string]interface{}{
+					{"status": "success", "reference": "PSK-MISSED", "amount": 250075, "currency": "NGN", "customer": map[string]string{"email": "ada@example.com"}},
+					{"status": "success", "reference": "PSK-SHORT", "amount": 100000, "currency": "NGN", "customer": map[string]string{"email": "ada@example.com"}},
+					{"status": "abandoned", "reference": "PSK-ABANDONED", "amount": 5000, "currency": "NGN"},
+				},
+				"meta": map[string]int{"page": 1, "pageCount": 1},
+			})
+		case "/transfer":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": true,
+				"data": []map[string]interface{}{
+					{"status": "success", "reference": "WDR-1", "transfer_code": "TRF_1", "amount": 5000, "currency": "NGN"},
+				},
+				"meta": map[string]int{"page": 1, "pageCount": 1},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer paystack.Close()
+
+	transferCode := "TRF_1"
+	repo := &fakeSettlementRepo{byReference: map[string]*db.TransactionModel{
+		"PSK-SHORT": {InnerTransaction: db.InnerTransaction{
+			Reference: "PSK-SHORT", Type: db.TransactionTypeDeposit, Status: db.TransactionStatusSuccess,
+			Amount: decimal.RequireFromString("900"), Currency: "NGN",
+		}},
+		"WDR-1": {InnerTransaction: db.InnerTransaction{
+			Reference: "WDR-1", Type: db.TransactionTypeWithdrawal, Status: db.TransactionStatusPending,
+			Amount: decimal.RequireFromString("50"), Currency: "NGN", GatewayRef: &transferCode,
+		}},
+	}}
+	wallets := &fakeSettlementWallets{credited: map[string]money.Money{}, settled: map[string]db.TransactionStatus{}}
+	svc := &reconciliationService{
+		repo:       repo,
+		walletRepo: wallets,
+		paystack:   gateway.NewPaystack("sk_test", paystack.URL, ""),
+		redis:      fakeCache{},
+	}
+
+	to := time.Now()
+	checked, findings, err := svc.checkSettlement(context.Background(), to.Add(-24*time.Hour), to, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked != 4 {
+		t.Fatalf("expected 4 records checked, got %d", checked)
+	}
+
+	issues := map[string]repository.Finding{}
+	for _, f := range findings {
+		issues[f.Issue] = f
+	}
+	if len(findings) != 3 {
+		t.Fatalf("expected 3 findings, got %+v", findings)
+	}
+
+	if f := issues[IssueMissingDeposit]; f.Action != ActionCredited {
+		t.Fatalf("expected missed deposit to be credited, got %+v", f)
+	}
+	if got := wallets.credited["PSK-MISSED"]; !got.Equal(money.FromMinor(250075, "NGN")) {
+		t.Fatalf("expected 2500.75 NGN credited, got %s", got)
+	}
+
+	if f := issues[IssueAmountMismatch]; f.Reference != "PSK-SHORT" || f.Action != "" {
+		t.Fatalf("expected an unfixed amount mismatch on PSK-SHORT, got %+v", f)
+	}
+
+	if f := issues[IssueStaleWithdrawal]; f.Action != ActionMarkedSuccess {
+		t.Fatalf("expected stale withdrawal to be marked successful, got %+v", f)
+	}
+	if wallets.settled["TRF_1"] != db.TransactionStatusSuccess {
+		t.Fatalf("expected TRF_1 to be marked SUCCESS")
+	}
+}
+
+func TestCheckSettlementRefusesTruncatedWindow(t *testing.T) {
+	pages := 0
+	paystack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": true,
+			"data":   []map[string]interface{}{{"status": "abandoned", "reference": "PSK-1", "amount": 5000, "currency": "NGN"}},
+			"meta":   map[string]int{"pageCount": maxSettlementPages + 1},
+		})
+	}))
+	defer paystack.Close()
+
+	svc := &reconciliationService{
+		repo:     &fakeSettlementRepo{},
+		paystack: gateway.NewPaystack("sk_test", paystack.URL, ""),
+		redis:    fakeCache{},
+	}
+	to := time.Now()
+	_, _, err := svc.checkSettlement(context.Background(), to.Add(-24*time.Hour), to, true)
+	if !errors.Is(err, ErrSettlementWindowTooLarge) {
+		t.Fatalf("expected ErrSettlementWindowTooLarge, got %v", err)
+	}
+	if pages != maxSettlementPages {
+		t.Fatalf("expected %d pages fetched, got %d", maxSettlementPages, pages)
+	}
+}
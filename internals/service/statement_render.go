@@ -0,0 +1,110 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/pdf"
+)
+
+func renderStatement(stmt *Statement, format string) (*StatementFile, error) {
+	name := fmt.Sprintf("statement-%s-%s-%s-%s", stmt.AccountNumber, stmt.Currency,
+		stmt.From.Format(time.DateOnly), stmt.To.Format(time.DateOnly))
+	switch format {
+	case StatementCSV:
+		content, err := statementCSV(stmt)
+		if err != nil {
+			return nil, err
+		}
+		return &StatementFile{Name: name + ".csv", ContentType: "text/csv", Content: content}, nil
+	case StatementPDF:
+		return &StatementFile{Name: name + ".pdf", ContentType: "application/pdf", Content: statementPDF(stmt)}, nil
+	}
+	return nil, fmt.Errorf("%w: format must be csv or pdf", ErrInvalidStatementRequest)
+}
+
+// statementCSV writes the header as key/value rows, a blank row, then one
+// row per line between the opening and closing balances, so a spreadsheet
+// can sum the amounts column directly.
+func statementCSV(stmt *Statement) ([]byte, error) {
+	places := money.Precision(stmt.Currency)
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	rows := [][]string{
+		{"Account number", stmt.AccountNumber},
+		{"Account holder", stmt.HolderName},
+		{"Currency", stmt.Currency},
+		{"From", stmt.From.Format(time.RFC3339)},
+		{"To", stmt.To.Format(time.RFC3339)},
+		{"Generated", stmt.GeneratedAt.Format(time.RFC3339)},
+		{},
+		{"Date", "Reference", "Type", "Description", "Amount", "Balance"},
+		{stmt.From.Format(time.RFC3339), "", "", "Opening balance", "", stmt.OpeningBalance.StringFixed(places)},
+	}
+	for _, line := range stmt.Lines {
+		rows = append(rows, []string{
+			line.Date.Format(time.RFC3339),
+			line.Reference,
+			string(line.Type),
+			line.Description,
+			line.Amount.StringFixed(places),
+			line.Balance.StringFixed(places),
+		})
+	}
+	rows = append(rows, []string{stmt.To.Format(time.RFC3339), "", "", "Closing balance", "", stmt.ClosingBalance.StringFixed(places)})
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func statementPDF(stmt *Statement) []byte {
+	places := money.Precision(stmt.Currency)
+	doc := pdf.New(pdf.A4Landscape, 8)
+	const row = "%-16s  %-28s  %-10s  %-36s  %16s  %16s  %16s"
+
+	doc.Bold("ACCOUNT STATEMENT")
+	doc.Blank()
+	doc.Line(fmt.Sprintf("Account holder:  %s", stmt.HolderName))
+	doc.Line(fmt.Sprintf("Account number:  %s", stmt.AccountNumber))
+	doc.Line(fmt.Sprintf("Currency:        %s", stmt.Currency))
+	doc.Line(fmt.Sprintf("Period:          %s to %s", stmt.From.Format("2006-01-02 15:04"), stmt.To.Format("2006-01-02 15:04 MST")))
+	doc.Line(fmt.Sprintf("Generated:       %s", stmt.GeneratedAt.Format("2006-01-02 15:04 MST")))
+	doc.Blank()
+	doc.Line(fmt.Sprintf("Opening balance: %s %s", stmt.Currency, stmt.OpeningBalance.StringFixed(places)))
+	doc.Line(fmt.Sprintf("Total credits:   %s %s", stmt.Currency, stmt.TotalCredits.StringFixed(places)))
+	doc.Line(fmt.Sprintf("Total debits:    %s %s", stmt.Currency, stmt.TotalDebits.StringFixed(places)))
+	doc.Line(fmt.Sprintf("Closing balance: %s %s", stmt.Currency, stmt.ClosingBalance.StringFixed(places)))
+	doc.Blank()
+	doc.Bold(fmt.Sprintf(row, "Date", "Reference", "Type", "Description", "Debit", "Credit", "Balance"))
+	doc.Line(fmt.Sprintf(row, stmt.From.Format("2006-01-02 15:04"), "", "", "Opening balance", "", "", stmt.OpeningBalance.StringFixed(places)))
+	for _, line := range stmt.Lines {
+		debit, credit := "", ""
+		if line.Amount.IsNegative() {
+			debit = line.Amount.Neg().StringFixed(places)
+		} else {
+			credit = line.Amount.StringFixed(places)
+		}
+		doc.Line(fmt.Sprintf(row,
+			line.Date.Format("2006-01-02 15:04"),
+			truncate(line.Reference, 28),
+			truncate(string(line.Type), 10),
+			truncate(line.Description, 36),
+			debit, credit, line.Balance.StringFixed(places)))
+	}
+	doc.Line(fmt.Sprintf(row, stmt.To.Format("2006-01-02 15:04"), "", "", "Closing balance", "", "", stmt.ClosingBalance.StringFixed(places)))
+	return doc.Bytes()
+}
+
+// truncate shortens s to n characters, marking the cut with "...".
+func truncate(s string, n int) string {
+	r := []rune(strings.TrimSpace(s))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n-3]) + "..."
+}
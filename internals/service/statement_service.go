@@ -0,0 +1,369 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+const (
+	// StatementQueue carries one statementJob per statement too large to
+	// generate while the client waits.
+	StatementQueue = "statements"
+
+	// MaxSyncStatementRange is the longest period generated in the request;
+	// longer statements go through StatementQueue.
+	MaxSyncStatementRange = 93 * 24 * time.Hour
+
+	// statementTTL is how long a queued statement can be downloaded.
+	statementTTL = 24 * time.Hour
+)
+
+// Statement formats.
+const (
+	StatementCSV = "csv"
+	StatementPDF = "pdf"
+)
+
+// Queued statement statuses.
+const (
+	StatementPending = "PENDING"
+	StatementReady   = "READY"
+	StatementFailed  = "FAILED"
+)
+
+var (
+	ErrInvalidStatementRequest = errors.New("invalid statement request")
+	ErrStatementNotFound       = errors.New("statement not found")
+)
+
+// StatementRequest asks for the statement of one currency over [From, To),
+// on the default wallet unless WalletID names another of the user's.
+type StatementRequest struct {
+	WalletID string    `json:"wallet_id,omitempty"`
+	Currency string    `json:"currency"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Format   string    `json:"format"`
+}
+
+// Large reports whether the statement should be generated through the queue.
+// A missing To means now.
+func (req StatementRequest) Large() bool {
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	return !req.From.IsZero() && to.Sub(req.From) > MaxSyncStatementRange
+}
+
+// Statement is one wallet asset's activity over a period. Balances are the
+// ledger balance, so funds held for a pending withdrawal are still in them.
+type Statement struct {
+	AccountNumber  string          `json:"account_number"`
+	HolderName     string          `json:"holder_name"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+	ClosingBalance decimal.Decimal `json:"closing_balance"`
+	TotalCredits   decimal.Decimal `json:"total_credits"`
+	TotalDebits    decimal.Decimal `json:"total_debits"`
+	Lines          []StatementLine `json:"lines"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+}
+
+// StatementLine is a transaction that moved the balance. Amount is negative
+// for debits.
+type StatementLine struct {
+	Date        time.Time          `json:"date"`
+	Reference   string             `json:"reference"`
+	Type        db.TransactionType `json:"type"`
+	Description string             `json:"description"`
+	Amount      decimal.Decimal    `json:"amount"`
+	Balance     decimal.Decimal    `json:"balance"`
+}
+
+// StatementFile is a rendered statement.
+type StatementFile struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Content     []byte `json:"content"`
+}
+
+// StatementExport tracks a statement generated through the queue.
+type StatementExport struct {
+	ID        string           `json:"id"`
+	UserID    string           `json:"-"`
+	Status    string           `json:"status"`
+	Request   StatementRequest `json:"request"`
+	Error     string           `json:"error,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
+
+// storedExport is how an export is kept in redis; the file is stored
+// separately so polling for the status doesn't load it.
+type storedExport struct {
+	StatementExport
+	UserID string `json:"user_id"`
+}
+
+type statementJob struct {
+	ExportID string `json:"export_id"`
+}
+
+type StatementService interface {
+	// Build loads the statement for the user's asset in req.Currency.
+	Build(ctx context.Context, userID string, req StatementRequest) (*Statement, error)
+	// Generate builds and renders a statement.
+	Generate(ctx context.Context, userID string, req StatementRequest) (*StatementFile, error)
+	// Queue generates a statement in the background.
+	Queue(ctx context.Context, userID string, req StatementRequest) (*StatementExport, error)
+	// Export returns a queued statement and, once it's ready, its file.
+	Export(ctx context.Context, userID, id string) (*StatementExport, *StatementFile, error)
+	// ProcessQueued is the StatementQueue job handler.
+	ProcessQueued(ctx context.Context, payload []byte) error
+}
+
+type statementService struct {
+	repo   repository.StatementRepository
+	redis  QueueService
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewStatementService(repo repository.StatementRepository, redis QueueService, logger *slog.Logger) StatementService {
+	return &statementService{repo: repo, redis: redis, logger: logger, now: time.Now}
+}
+
+// validate checks req and fills in its defaults: the last 30 days up to now,
+// as CSV. To is clamped to now.
+func (s *statementService) validate(req *StatementRequest) error {
+	now := s.now()
+	if req.Currency == "" {
+		return fmt.Errorf("%w: currency is required", ErrInvalidStatementRequest)
+	}
+	if req.Format == "" {
+		req.Format = StatementCSV
+	}
+	if req.Format != StatementCSV && req.Format != StatementPDF {
+		return fmt.Errorf("%w: format must be csv or pdf", ErrInvalidStatementRequest)
+	}
+	if req.To.IsZero() || req.To.After(now) {
+		req.To = now
+	}
+	if req.From.IsZero() {
+		req.From = req.To.AddDate(0, 0, -30)
+	}
+	if !req.From.Before(req.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidStatementRequest)
+	}
+	return nil
+}
+
+// Build works back from the current balance rather than forward from the
+// first transaction, so only the transactions since req.From are loaded
+// and the closing balance of a statement ending now matches the wallet.
+func (s *statementService) Build(ctx context.Context, userID string, req StatementRequest) (*Statement, error) {
+	if err := s.validate(&req); err != nil {
+		return nil, err
+	}
+
+	asset, err := s.repo.GetStatementAccount(ctx, userID, req.WalletID, req.Currency)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrWalletNotFound
+		}
+		return nil, err
+	}
+	wallet := asset.Wallet()
+
+	txns, err := s.repo.ListTransactionsSince(ctx, asset.WalletID, asset.Currency, req.From)
+	if err != nil {
+		return nil, err
+	}
+	holds, err := s.repo.ListActiveHolds(ctx, asset.ID)
+	if err != nil {
+		return nil, err
+	}
+	onHold := make(map[string]bool, len(holds))
+	for _, hold := range holds {
+		onHold[hold.Reference] = true
+	}
+
+	stmt := &Statement{
+		AccountNumber:  wallet.AccountNumber,
+		HolderName:     wallet.User().Name,
+		Currency:       asset.Currency,
+		From:           req.From,
+		To:             req.To,
+		OpeningBalance: asset.Balance,
+		TotalCredits:   decimal.Zero,
+		TotalDebits:    decimal.Zero,
+		Lines:          []StatementLine{},
+		GeneratedAt:    s.now(),
+	}
+	for _, txn := range txns {
+		delta, ok := balanceEffect(txn, onHold[txn.Reference])
+		if !ok {
+			s.logger.Warn("unclassified transaction left off statement", "reference", txn.Reference, "type", txn.Type)
+			continue
+		}
+		stmt.OpeningBalance = stmt.OpeningBalance.Sub(delta)
+		if !txn.CreatedAt.Before(req.To) || delta.IsZero() {
+			continue
+		}
+		description, _ := txn.Description()
+		if description == "" {
+			description = string(txn.Type)
+		}
+		stmt.Lines = append(stmt.Lines, StatementLine{
+			Date:        txn.CreatedAt,
+			Reference:   txn.Reference,
+			Type:        txn.Type,
+			Description: description,
+			Amount:      delta,
+		})
+	}
+
+	balance := stmt.OpeningBalance
+	for i := range stmt.Lines {
+		line := &stmt.Lines[i]
+		balance = balance.Add(line.Amount)
+		line.Balance = balance
+		if line.Amount.IsPositive() {
+			stmt.TotalCredits = stmt.TotalCredits.Add(line.Amount)
+		} else {
+			stmt.TotalDebits = stmt.TotalDebits.Sub(line.Amount)
+		}
+	}
+	stmt.ClosingBalance = balance
+	return stmt, nil
+}
+
+func (s *statementService) Generate(ctx context.Context, userID string, req StatementRequest) (*StatementFile, error) {
+	if err := s.validate(&req); err != nil {
+		return nil, err
+	}
+	stmt, err := s.Build(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	return renderStatement(stmt, req.Format)
+}
+
+func (s *statementService) Queue(ctx context.Context, userID string, req StatementRequest) (*StatementExport, error) {
+	if err := s.validate(&req); err != nil {
+		return nil, err
+	}
+	// Make sure there is an account before promising a statement for it.
+	if _, err := s.repo.GetStatementAccount(ctx, userID, req.WalletID, req.Currency); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, ErrWalletNotFound
+		}
+		return nil, err
+	}
+
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	export := StatementExport{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Status:    StatementPending,
+		Request:   req,
+		CreatedAt: now,
+		ExpiresAt: now.Add(statementTTL),
+	}
+	if err := s.saveExport(ctx, &export); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(statementJob{ExportID: export.ID})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redis.Enqueue(ctx, StatementQueue, payload); err != nil {
+		return nil, fmt.Errorf("failed to queue statement: %w", err)
+	}
+	return &export, nil
+}
+
+func (s *statementService) Export(ctx context.Context, userID, id string) (*StatementExport, *StatementFile, error) {
+	export, err := s.loadExport(ctx, id)
+	if err != nil || export.UserID != userID {
+		return nil, nil, ErrStatementNotFound
+	}
+	if export.Status != StatementReady {
+		return export, nil, nil
+	}
+	var file StatementFile
+	if err := s.redis.Get(ctx, statementFileKey(id), &file); err != nil {
+		return nil, nil, ErrStatementNotFound
+	}
+	return export, &file, nil
+}
+
+// ProcessQueued builds the statement and stores its file next to the
+// export. A statement that can't be built, as opposed to one that failed to
+// load, is marked FAILED without a retry.
+func (s *statementService) ProcessQueued(ctx context.Context, payload []byte) error {
+	var job statementJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return fmt.Errorf("invalid statement job: %w", err)
+	}
+	export, err := s.loadExport(ctx, job.ExportID)
+	if err != nil {
+		// Expired before a worker got to it.
+		s.logger.Warn("statement export gone before it was generated", "id", job.ExportID)
+		return nil
+	}
+	if export.Status == StatementReady {
+		return nil
+	}
+
+	file, err := s.Generate(ctx, export.UserID, export.Request)
+	if errors.Is(err, ErrWalletNotFound) || errors.Is(err, ErrInvalidStatementRequest) {
+		export.Status = StatementFailed
+		export.Error = err.Error()
+		return s.saveExport(ctx, export)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.redis.Set(ctx, statementFileKey(export.ID), file, export.ExpiresAt.Sub(s.now())); err != nil {
+		return err
+	}
+	export.Status = StatementReady
+	return s.saveExport(ctx, export)
+}
+
+func (s *statementService) saveExport(ctx context.Context, export *StatementExport) error {
+	return s.redis.Set(ctx, statementKey(export.ID), storedExport{StatementExport: *export, UserID: export.UserID}, export.ExpiresAt.Sub(s.now()))
+}
+
+func (s *statementService) loadExport(ctx context.Context, id string) (*StatementExport, error) {
+	var stored storedExport
+	if err := s.redis.Get(ctx, statementKey(id), &stored); err != nil {
+		return nil, err
+	}
+	export := stored.StatementExport
+	export.UserID = stored.UserID
+	return &export, nil
+}
+
+func statementKey(id string) string     { return "statement:" + id }
+func statementFileKey(id string) string { return "statement:" + id + ":file" }
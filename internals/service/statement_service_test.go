@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type fakeStatementRepo struct {
+	asset *db.WalletAssetModel
+	txns  []db.TransactionModel
+	holds []db.FundHoldModel
+}
+
+func (r *fakeStatementRepo) GetStatementAccount(ctx context.Context, userID, walletID, currency string) (*db.WalletAssetModel, error) {
+	if userID != "u1" || currency != r.asset.Currency {
+		return nil, db.ErrNotFound
+	}
+	return r.asset, nil
+}
+
+func (r *fakeStatementRepo) ListTransactionsSince(ctx context.Context, walletID, currency string, since time.Time) ([]db.TransactionModel, error) {
+	var out []db.TransactionModel
+	for _, txn := range r.txns {
+		if !txn.CreatedAt.Before(since) {
+			out = append(out, txn)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeStatementRepo) ListActiveHolds(ctx context.Context, assetID string) ([]db.FundHoldModel, error) {
+	return r.holds, nil
+}
+
+var statementNow = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+func statementTxn(ref string, txType db.TransactionType, status db.TransactionStatus, amount int64, day int) db.TransactionModel {
+	return db.TransactionModel{InnerTransaction: db.InnerTransaction{
+		ID:        ref,
+		Reference: ref,
+		Type:      txType,
+		Status:    status,
+		Amount:    decimal.NewFromInt(amount),
+		Currency:  "NGN",
+		CreatedAt: time.Date(2026, 10, day, 9, 0, 0, 0, time.UTC),
+	}}
+}
+
+func newTestStatementService() (*statementService, *jsonQueue) {
+	repo := &fakeStatementRepo{
+		// 10000 + 5000 - 2000 + 1000 - 3000 (held, not yet debited) = 14000
+		asset: &db.WalletAssetModel{
+			InnerWalletAsset: db.InnerWalletAsset{ID: "a1", WalletID: "w1", Currency: "NGN", Balance: decimal.NewFromInt(14000)},
+			RelationsWalletAsset: db.RelationsWalletAsset{Wallet: &db.WalletModel{
+				InnerWallet:     db.InnerWallet{ID: "w1", AccountNumber: "0123456789"},
+				RelationsWallet: db.RelationsWallet{User: &db.UserModel{InnerUser: db.InnerUser{Name: "Ada Obi"}}},
+			}},
+		},
+		txns: []db.TransactionModel{
+			statementTxn("DEP-1", db.TransactionTypeDeposit, db.TransactionStatusSuccess, 10000, 1),
+			statementTxn("DEP-2", db.TransactionTypeDeposit, db.TransactionStatusSuccess, 5000, 3),
+			statementTxn("DEP-3", db.TransactionTypeDeposit, db.TransactionStatusFailed, 7000, 4),
+			statementTxn("TRF-1-DEBIT", db.TransactionTypeTransfer, db.TransactionStatusSuccess, 2000, 5),
+			statementTxn("TRF-2-CREDIT", db.TransactionTypeTransfer, db.TransactionStatusSuccess, 1000, 12),
+			statementTxn("WD-1", db.TransactionTypeWithdrawal, db.TransactionStatusPending, 3000, 14),
+		},
+		holds: []db.FundHoldModel{{InnerFundHold: db.InnerFundHold{Reference: "WD-1"}}},
+	}
+	queue := newJSONQueue()
+	svc := &statementService{repo: repo, redis: queue, logger: slog.New(slog.NewTextHandler(io.Discard, nil)), now: func() time.Time { return statementNow }}
+	return svc, queue
+}
+
+func TestBuildStatementBalances(t *testing.T) {
+	svc, _ := newTestStatementService()
+
+	stmt, err := svc.Build(context.Background(), "u1", StatementRequest{
+		Currency: "NGN",
+		From:     time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !stmt.OpeningBalance.Equal(decimal.NewFromInt(10000)) || !stmt.ClosingBalance.Equal(decimal.NewFromInt(13000)) {
+		t.Fatalf("expected 10000 to 13000, got %s to %s", stmt.OpeningBalance, stmt.ClosingBalance)
+	}
+	if len(stmt.Lines) != 2 || stmt.Lines[0].Reference != "DEP-2" || stmt.Lines[1].Reference != "TRF-1-DEBIT" {
+		t.Fatalf("expected the deposit and the transfer only, got %+v", stmt.Lines)
+	}
+	if !stmt.Lines[0].Balance.Equal(decimal.NewFromInt(15000)) || !stmt.Lines[1].Amount.Equal(decimal.NewFromInt(-2000)) {
+		t.Fatalf("unexpected running balance %+v", stmt.Lines)
+	}
+	if !stmt.TotalCredits.Equal(decimal.NewFromInt(5000)) || !stmt.TotalDebits.Equal(decimal.NewFromInt(2000)) {
+		t.Fatalf("unexpected totals %s and %s", stmt.TotalCredits, stmt.TotalDebits)
+	}
+	if stmt.AccountNumber != "0123456789" || stmt.HolderName != "Ada Obi" {
+		t.Fatalf("unexpected header %s %s", stmt.AccountNumber, stmt.HolderName)
+	}
+
+	// A statement up to now closes on the wallet's balance.
+	stmt, err = svc.Build(context.Background(), "u1", StatementRequest{Currency: "NGN", From: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stmt.OpeningBalance.IsZero() || !stmt.ClosingBalance.Equal(decimal.NewFromInt(14000)) || len(stmt.Lines) != 4 {
+		t.Fatalf("expected 0 to 14000 over 4 lines, got %s to %s over %d", stmt.OpeningBalance, stmt.ClosingBalance, len(stmt.Lines))
+	}
+
+	if _, err := svc.Build(context.Background(), "u1", StatementRequest{Currency: "USD"}); !errors.Is(err, ErrWalletNotFound) {
+		t.Fatalf("expected ErrWalletNotFound, got %v", err)
+	}
+}
+
+func TestGenerateStatementCSV(t *testing.T) {
+	svc, _ := newTestStatementService()
+
+	file, err := svc.Generate(context.Background(), "u1", StatementRequest{Currency: "NGN", From: time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.ContentType != "text/csv" || !strings.HasPrefix(file.Name, "statement-0123456789-NGN-2026-10-02") {
+		t.Fatalf("unexpected file %s %s", file.Name, file.ContentType)
+	}
+	r := csv.NewReader(bytes.NewReader(file.Content))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := rows[len(rows)-1]
+	if rows[1][1] != "Ada Obi" || last[3] != "Closing balance" || last[5] != "14000.00" {
+		t.Fatalf("unexpected csv:\n%s", file.Content)
+	}
+
+	if _, err := svc.Generate(context.Background(), "u1", StatementRequest{Currency: "NGN", Format: "xlsx"}); !errors.Is(err, ErrInvalidStatementRequest) {
+		t.Fatalf("expected ErrInvalidStatementRequest, got %v", err)
+	}
+
+	// Amounts have as many decimals as the currency's minor unit.
+	for _, tt := range []struct{ currency, amount, want string }{{"JPY", "1500", "1500"}, {"KWD", "1500.25", "1500.250"}} {
+		amount := decimal.RequireFromString(tt.amount)
+		file, err := renderStatement(&Statement{
+			Currency:       tt.currency,
+			ClosingBalance: amount,
+			Lines:          []StatementLine{{Amount: amount, Balance: amount}},
+		}, StatementCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(file.Content), ","+tt.want+","+tt.want+"\n") {
+			t.Fatalf("expected %s %s, got:\n%s", tt.currency, tt.want, file.Content)
+		}
+	}
+}
+
+func TestQueuedStatement(t *testing.T) {
+	ctx := context.Background()
+	svc, queue := newTestStatementService()
+
+	req := StatementRequest{Currency: "NGN", From: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Format: StatementPDF}
+	if !req.Large() {
+		t.Fatal("expected a year-long statement to be queued")
+	}
+	export, err := svc.Queue(ctx, "u1", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Status != StatementPending || len(queue.jobs) != 1 {
+		t.Fatalf("expected a pending export and one job, got %s and %d", export.Status, len(queue.jobs))
+	}
+	if _, file, err := svc.Export(ctx, "u1", export.ID); err != nil || file != nil {
+		t.Fatalf("expected no file before the job runs, got %v", err)
+	}
+
+	if err := svc.ProcessQueued(ctx, queue.jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	got, file, err := svc.Export(ctx, "u1", export.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatementReady || file == nil || !bytes.HasPrefix(file.Content, []byte("%PDF-")) {
+		t.Fatalf("expected a ready pdf, got %s", got.Status)
+	}
+
+	if _, _, err := svc.Export(ctx, "someone-else", export.ID); !errors.Is(err, ErrStatementNotFound) {
+		t.Fatalf("expected another user's export to be hidden, got %v", err)
+	}
+}
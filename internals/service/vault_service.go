@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+const maxVaultNameLength = 50
+
+// DefaultVaultBreakPenalty is the early-break penalty, in percent, used when
+// the configured one isn't at least 0 and under 100.
+var DefaultVaultBreakPenalty = decimal.NewFromInt(1)
+
+var (
+	ErrVaultNotFound   = errors.New("vault not found")
+	ErrInvalidVault    = errors.New("a vault needs a name of 1 to 50 characters, a currency, a positive target if any and a lock date in the future if any")
+	ErrVaultClosed     = errors.New("vault is closed")
+	ErrVaultLocked     = errors.New("vault is locked until its lock date; break it to withdraw early")
+	ErrVaultBalanceLow = errors.New("vault balance is too low")
+	ErrVaultCurrency   = errors.New("amount must be in the vault's currency")
+	ErrVaultChanged    = repository.ErrVaultChanged
+)
+
+// VaultBreak is the outcome of breaking a vault.
+type VaultBreak struct {
+	Vault    *db.VaultModel  `json:"vault"`
+	Amount   decimal.Decimal `json:"amount"`   // what was in the vault
+	Penalty  decimal.Decimal `json:"penalty"`  // kept for breaking before the lock date
+	Credited decimal.Decimal `json:"credited"` // what reached the wallet
+}
+
+type VaultService interface {
+	Vaults(ctx context.Context, userID string, includeClosed bool) ([]db.VaultModel, error)
+	// Vault returns the vault with its latest moves.
+	Vault(ctx context.Context, userID, id string) (*db.VaultModel, error)
+	CreateVault(ctx context.Context, userID string, v repository.NewVault) (*db.VaultModel, error)
+
+	// Deposit and Withdraw move amount between the vault and the wallet
+	// asset in its currency; reference makes them idempotent. A locked vault
+	// can only be emptied with Break.
+	Deposit(ctx context.Context, userID, vaultID string, amount money.Money, reference string) (*db.VaultModel, error)
+	Withdraw(ctx context.Context, userID, vaultID string, amount money.Money, reference string) (*db.VaultModel, error)
+	// Break moves everything back to the wallet and closes the vault. Before
+	// the lock date the early-break penalty is kept as a fee.
+	Break(ctx context.Context, userID, vaultID, reference string) (*VaultBreak, error)
+}
+
+type vaultService struct {
+	repo    repository.WalletRepository
+	redis   QueueService
+	penalty decimal.Decimal // percent
+	now     func() time.Time
+}
+
+// NewVaultService takes the early-break penalty in percent.
+func NewVaultService(repo repository.WalletRepository, redis QueueService, penalty decimal.Decimal) VaultService {
+	if penalty.IsNegative() || penalty.GreaterThanOrEqual(decimal.NewFromInt(100)) {
+		penalty = DefaultVaultBreakPenalty
+	}
+	return &vaultService{repo: repo, redis: redis, penalty: penalty, now: time.Now}
+}
+
+// VaultLocked reports whether the vault's lock date is still ahead of now.
+func VaultLocked(vault *db.VaultModel, now time.Time) bool {
+	until, ok := vault.LockedUntil()
+	return ok && now.Before(until)
+}
+
+func (s *vaultService) Vaults(ctx context.Context, userID string, includeClosed bool) ([]db.VaultModel, error) {
+	vaults, err := s.repo.ListVaults(ctx, userID, includeClosed)
+	if vaults == nil {
+		vaults = []db.VaultModel{}
+	}
+	return vaults, err
+}
+
+func (s *vaultService) Vault(ctx context.Context, userID, id string) (*db.VaultModel, error) {
+	vault, err := s.repo.GetVault(ctx, userID, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrVaultNotFound
+	}
+	return vault, err
+}
+
+func (s *vaultService) CreateVault(ctx context.Context, userID string, v repository.NewVault) (*db.VaultModel, error) {
+	v.Name = strings.TrimSpace(v.Name)
+	v.Currency = strings.ToUpper(strings.TrimSpace(v.Currency))
+	if v.Name == "" || utf8.RuneCountInString(v.Name) > maxVaultNameLength || v.Currency == "" {
+		return nil, ErrInvalidVault
+	}
+	if v.TargetAmount != nil {
+		target, err := money.New(*v.TargetAmount, v.Currency)
+		if err != nil || !target.IsPositive() {
+			return nil, ErrInvalidVault
+		}
+	}
+	if v.LockedUntil != nil && !v.LockedUntil.After(s.now()) {
+		return nil, ErrInvalidVault
+	}
+
+	wallet, err := s.repo.GetWalletWithAssets(ctx, userID, v.WalletID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrUnknownWallet
+	}
+	if err != nil {
+		return nil, err
+	}
+	vault, err := s.repo.CreateVault(ctx, wallet.ID, v)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", userID))
+	return vault, nil
+}
+
+func (s *vaultService) Deposit(ctx context.Context, userID, vaultID string, amount money.Money, reference string) (*db.VaultModel, error) {
+	vault, err := s.activeVault(ctx, userID, vaultID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Moved to vault %s", vault.Name)
+	err = s.idempotent(ctx, userID, reference, func() error {
+		return s.repo.DepositToVault(ctx, vault, amount, reference, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Vault(ctx, userID, vaultID)
+}
+
+func (s *vaultService) Withdraw(ctx context.Context, userID, vaultID string, amount money.Money, reference string) (*db.VaultModel, error) {
+	vault, err := s.activeVault(ctx, userID, vaultID, amount)
+	if err != nil {
+		return nil, err
+	}
+	if VaultLocked(vault, s.now()) {
+		return nil, ErrVaultLocked
+	}
+	if vault.Balance.LessThan(amount.Amount()) {
+		return nil, ErrVaultBalanceLow
+	}
+
+	err = s.idempotent(ctx, userID, reference, func() error {
+		return s.repo.WithdrawFromVault(ctx, vault, repository.VaultWithdrawal{
+			Amount:      amount,
+			Penalty:     money.Zero(vault.Currency),
+			Reference:   reference,
+			Description: fmt.Sprintf("Moved from vault %s", vault.Name),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Vault(ctx, userID, vaultID)
+}
+
+func (s *vaultService) Break(ctx context.Context, userID, vaultID, reference string) (*VaultBreak, error) {
+	vault, err := s.Vault(ctx, userID, vaultID)
+	if err != nil {
+		return nil, err
+	}
+	if vault.Status != db.VaultStatusActive {
+		return nil, ErrVaultClosed
+	}
+
+	result := &VaultBreak{Amount: vault.Balance, Penalty: decimal.Zero, Credited: decimal.Zero}
+	if vault.Balance.IsZero() {
+		// Nothing to move, so there is no transaction to make idempotent.
+		if err := s.repo.CloseVault(ctx, vault.ID); err != nil {
+			return nil, err
+		}
+	} else {
+		amount := money.Round(vault.Balance, vault.Currency)
+		penalty := money.Zero(vault.Currency)
+		description := fmt.Sprintf("Closed vault %s", vault.Name)
+		if VaultLocked(vault, s.now()) {
+			// Truncated, so the penalty never rounds up past the percentage.
+			cut := vault.Balance.Mul(s.penalty).Div(decimal.NewFromInt(100)).Truncate(money.Precision(vault.Currency))
+			penalty = money.Round(cut, vault.Currency)
+			description = fmt.Sprintf("Broke vault %s early, penalty %s", vault.Name, penalty.StringFixed())
+		}
+		credited, err := amount.Sub(penalty)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.idempotent(ctx, userID, reference, func() error {
+			return s.repo.WithdrawFromVault(ctx, vault, repository.VaultWithdrawal{
+				Amount:      amount,
+				Penalty:     penalty,
+				Close:       true,
+				Reference:   reference,
+				Description: description,
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Penalty, result.Credited = penalty.Amount(), credited.Amount()
+	}
+
+	_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", userID))
+	if result.Vault, err = s.Vault(ctx, userID, vaultID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// activeVault loads one of the user's open vaults for a move of amount.
+func (s *vaultService) activeVault(ctx context.Context, userID, vaultID string, amount money.Money) (*db.VaultModel, error) {
+	vault, err := s.Vault(ctx, userID, vaultID)
+	if err != nil {
+		return nil, err
+	}
+	if vault.Status != db.VaultStatusActive {
+		return nil, ErrVaultClosed
+	}
+	if amount.Currency() != vault.Currency {
+		return nil, ErrVaultCurrency
+	}
+	return vault, nil
+}
+
+// idempotent runs move under the reference's idempotency lock, the same way
+// swaps and transfers are, and drops the caches it changes.
+func (s *vaultService) idempotent(ctx context.Context, userID, reference string, move func() error) error {
+	locked, _ := s.redis.TryLockIdempotencyKey(ctx, reference, 5*time.Minute)
+	if !locked {
+		return ErrTransactionAlreadyProcessed
+	}
+
+	if err := move(); err != nil {
+		if _, ok := db.IsErrUniqueConstraint(err); ok {
+			return ErrTransactionAlreadyProcessed
+		}
+		_ = s.redis.Delete(ctx, "idemp:"+reference)
+		return err
+	}
+
+	_ = s.redis.Set(ctx, "idemp:"+reference, "completed", 24*time.Hour)
+	_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", userID))
+	_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", userID))
+	return nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// vaultLedger holds one user's vaults and records the withdrawals made from
+// them.
+type vaultLedger struct {
+	repository.WalletRepository
+	vaults      map[string]*db.VaultModel
+	withdrawals []repository.VaultWithdrawal
+	closed      []string
+}
+
+func (l *vaultLedger) GetVault(ctx context.Context, userID, id string) (*db.VaultModel, error) {
+	vault, ok := l.vaults[id]
+	if !ok || userID != "u1" {
+		return nil, db.ErrNotFound
+	}
+	return vault, nil
+}
+
+func (l *vaultLedger) WithdrawFromVault(ctx context.Context, vault *db.VaultModel, w repository.VaultWithdrawal) error {
+	l.withdrawals = append(l.withdrawals, w)
+	vault.Balance = vault.Balance.Sub(w.Amount.Amount())
+	if w.Close {
+		vault.Status = db.VaultStatusClosed
+	}
+	return nil
+}
+
+func (l *vaultLedger) CloseVault(ctx context.Context, vaultID string) error {
+	l.closed = append(l.closed, vaultID)
+	l.vaults[vaultID].Status = db.VaultStatusClosed
+	return nil
+}
+
+func testVault(id, balance string, lockedUntil *time.Time) *db.VaultModel {
+	return &db.VaultModel{InnerVault: db.InnerVault{
+		ID:          id,
+		WalletID:    "w1",
+		Name:        "Rent",
+		Currency:    "NGN",
+		Balance:     decimal.RequireFromString(balance),
+		Status:      db.VaultStatusActive,
+		LockedUntil: lockedUntil,
+	}}
+}
+
+func TestVaultWithdrawRules(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(30 * 24 * time.Hour)
+	ledger := &vaultLedger{vaults: map[string]*db.VaultModel{
+		"locked": testVault("locked", "1000", &later),
+		"open":   testVault("open", "1000", nil),
+	}}
+	queue := newJSONQueue()
+	svc := NewVaultService(ledger, queue, DefaultVaultBreakPenalty).(*vaultService)
+	svc.now = func() time.Time { return now }
+
+	ngn := money.Round(decimal.NewFromInt(100), "NGN")
+	if _, err := svc.Withdraw(ctx, "u1", "locked", ngn, "ref-1"); !errors.Is(err, ErrVaultLocked) {
+		t.Fatalf("expected a locked vault to refuse withdrawals, got %v", err)
+	}
+	if _, err := svc.Withdraw(ctx, "u1", "open", money.Round(decimal.NewFromInt(100), "USD"), "ref-2"); !errors.Is(err, ErrVaultCurrency) {
+		t.Fatalf("expected a USD withdrawal from an NGN vault to be refused, got %v", err)
+	}
+	if _, err := svc.Withdraw(ctx, "u1", "open", money.Round(decimal.NewFromInt(1001), "NGN"), "ref-3"); !errors.Is(err, ErrVaultBalanceLow) {
+		t.Fatalf("expected an overdraw to be refused, got %v", err)
+	}
+	if _, err := svc.Withdraw(ctx, "u2", "open", ngn, "ref-4"); !errors.Is(err, ErrVaultNotFound) {
+		t.Fatalf("expected another user's vault to be out of reach, got %v", err)
+	}
+
+	vault, err := svc.Withdraw(ctx, "u1", "open", ngn, "ref-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vault.Balance.Equal(decimal.NewFromInt(900)) || len(ledger.withdrawals) != 1 || ledger.withdrawals[0].Penalty.IsPositive() {
+		t.Fatalf("expected one penalty-free withdrawal leaving 900, got %s and %+v", vault.Balance, ledger.withdrawals)
+	}
+	if done := queue.completed(); len(done) != 1 || done[0] != "idemp:ref-5" {
+		t.Fatalf("expected only the withdrawal to be marked done, got %v", done)
+	}
+}
+
+func TestBreakVault(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(30 * 24 * time.Hour)
+	earlier := now.Add(-time.Hour)
+	ledger := &vaultLedger{vaults: map[string]*db.VaultModel{
+		"locked":  testVault("locked", "1234.56", &later),
+		"matured": testVault("matured", "500", &earlier),
+		"empty":   testVault("empty", "0", &later),
+	}}
+	svc := NewVaultService(ledger, newJSONQueue(), decimal.RequireFromString("2.5")).(*vaultService)
+	svc.now = func() time.Time { return now }
+
+	// 2.5% of 1234.56 is 30.864, which must not round up to 30.87.
+	result, err := svc.Break(ctx, "u1", "locked", "ref-locked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Penalty.String() != "30.86" || result.Credited.StringFixed(2) != "1203.70" {
+		t.Fatalf("expected a 30.86 penalty and 1203.70 credited, got %s and %s", result.Penalty, result.Credited)
+	}
+	if w := ledger.withdrawals[0]; !w.Close || w.Amount.Amount().String() != "1234.56" {
+		t.Fatalf("expected the whole balance to be withdrawn and the vault closed, got %+v", w)
+	}
+
+	result, err = svc.Break(ctx, "u1", "matured", "ref-matured")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Penalty.IsZero() || !result.Credited.Equal(decimal.NewFromInt(500)) {
+		t.Fatalf("expected no penalty once the lock date has passed, got %s", result.Penalty)
+	}
+
+	if _, err := svc.Break(ctx, "u1", "empty", "ref-empty"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.closed) != 1 || len(ledger.withdrawals) != 2 {
+		t.Fatalf("expected an empty vault to be closed without a transaction, got %v and %d withdrawals", ledger.closed, len(ledger.withdrawals))
+	}
+	if _, err := svc.Break(ctx, "u1", "empty", "ref-again"); !errors.Is(err, ErrVaultClosed) {
+		t.Fatalf("expected a closed vault to stay closed, got %v", err)
+	}
+}
@@ -2,12 +2,17 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
 	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
 	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
 )
@@ -15,27 +20,47 @@ import (
 var (
 	ErrTransactionAlreadyProcessed = errors.New("transaction with this reference already exists")
 	ErrWalletNotFound              = errors.New("wallet not found for this currency")
+	ErrWalletFrozen                = repository.ErrWalletFrozen
+	ErrInvalidCursor               = errors.New("invalid cursor")
+	ErrUnknownWallet               = errors.New("wallet not found")
+	ErrInvalidWallet               = errors.New("a wallet needs a name of 1 to 40 characters and a kind of PERSONAL, BUSINESS or PROJECT")
+	ErrWalletNameTaken             = errors.New("you already have a wallet with that name")
+	ErrTooManyWallets              = errors.New("you can't open more than 10 wallets")
+	ErrSameWallet                  = errors.New("cannot transfer money to the same wallet")
+	ErrHoldNotFound                = repository.ErrHoldNotFound
+
+	// ErrTransferUnderReview is returned, with the recipient's name, for a
+	// transfer that was accepted but is held until an admin reviews it.
+	ErrTransferUnderReview = errors.New("transfer is held for review")
 )
 
+const (
+	maxWalletNameLength = 40
+	maxWallets          = 10
+)
+
+// Every walletID below is one of the user's wallets, or the default wallet
+// when empty.
 type WalletService interface {
-	GetWalletWithAssets(ctx context.Context, userID string) (*db.WalletModel, error)
-	GetAssetByCurrency(ctx context.Context, userID string, currency string) (*db.WalletAssetModel, error)
-	FundWallet(ctx context.Context, userID, currency string, amount float64, reference, description string) error
-	WithdrawFunds(ctx context.Context, userID string, req WithdrawalRequest) error
+	// ListWallets returns the user's wallets, the default one first.
+	ListWallets(ctx context.Context, userID string) ([]db.WalletModel, error)
+	CreateWallet(ctx context.Context, userID, name string, kind db.WalletKind) (*db.WalletModel, error)
+	GetWalletWithAssets(ctx context.Context, userID, walletID string) (*db.WalletModel, error)
+	GetAssetByCurrency(ctx context.Context, userID, walletID, currency string) (*db.WalletAssetModel, error)
+	FundWallet(ctx context.Context, userID, walletID string, amount money.Money, reference, description string) error
 	LookupUser(ctx context.Context, query string) (*UserLookupResult, error)
-	SwapFunds(ctx context.Context, userID, fromCurrency, toCurrency string, amount float64, reference string) (*map[string]interface{}, error)
-	GetTransactionHistory(ctx context.Context, userID string) ([]db.TransactionModel, error)
-	TransferFunds(ctx context.Context, userID, toAccount, currency string, amount float64, description string, reference string) (string, error)
-}
-
-type WithdrawalRequest struct {
-    Amount        float64 `json:"amount"`
-    AccountNumber string  `json:"account_number"`
-    AccountName   string  `json:"account_name"`
-    BankCode      string  `json:"bank_code"`
-    Currency      string  `json:"currency"`
-    Pin           string  `json:"pin"`
-    Reason        string  `json:"reason"`
+	SwapFunds(ctx context.Context, userID, walletID string, amount money.Money, toCurrency, reference string) (*map[string]interface{}, error)
+	GetTransactionHistory(ctx context.Context, userID string, filter repository.TransactionFilter, cursor string) (*TransactionPage, error)
+	// TransferFunds sends amount from the user's wallet to an account
+	// number. An account number of another of the user's wallets moves the
+	// money there instead, free of charge and straight away.
+	TransferFunds(ctx context.Context, userID, fromWalletID, toAccount string, amount money.Money, description string, reference string) (string, error)
+	// MoveFunds moves amount between two of the user's wallets.
+	MoveFunds(ctx context.Context, userID, fromWalletID, toWalletID string, amount money.Money, description, reference string) error
+	// CaptureHold and ReleaseHold resolve a hold by its reference: a
+	// withdrawal, a disputed deposit or a transfer held for review.
+	CaptureHold(ctx context.Context, reference string) error
+	ReleaseHold(ctx context.Context, reference string) error
 }
 
 type walletService struct {
@@ -43,40 +68,117 @@ type walletService struct {
 	paymentService PaymentService
 	userRepo       repository.UserRepository
 	redis          QueueService // This is your Redis wrapper
+
+	// reviewLimits are the amounts, per currency, from which a transfer to
+	// someone else is held for review. Currencies without one never are.
+	reviewLimits map[string]decimal.Decimal
 }
 
 type ExchangeRateResponse struct {
-	Rates map[string]float64 `json:"rates"`
+	Rates map[string]decimal.Decimal `json:"rates"`
 }
 
-func NewWalletService(repo repository.WalletRepository, paymentService PaymentService, userRepo repository.UserRepository, redis QueueService) WalletService {
-	return &walletService{repo: repo, paymentService: paymentService, userRepo: userRepo, redis: redis}
+func NewWalletService(repo repository.WalletRepository, paymentService PaymentService, userRepo repository.UserRepository, redis QueueService, reviewLimits map[string]decimal.Decimal) WalletService {
+	return &walletService{repo: repo, paymentService: paymentService, userRepo: userRepo, redis: redis, reviewLimits: reviewLimits}
+}
+
+// ParseReviewLimits reads a comma-separated list of currency=amount, e.g.
+// "NGN=5000000,USD=5000".
+func ParseReviewLimits(spec string) (map[string]decimal.Decimal, error) {
+	limits := map[string]decimal.Decimal{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		currency, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("review limit %q: want currency=amount", entry)
+		}
+		limit, err := decimal.NewFromString(strings.TrimSpace(value))
+		if err != nil || !limit.IsPositive() {
+			return nil, fmt.Errorf("review limit %q: amount must be positive", entry)
+		}
+		limits[strings.ToUpper(strings.TrimSpace(currency))] = limit
+	}
+	return limits, nil
 }
 
-func (s *walletService) GetWalletWithAssets(ctx context.Context, userID string) (*db.WalletModel, error) {
+// ListWallets caches all of a user's wallets under one key, so every change
+// that already drops wallet:<user> keeps each of them fresh.
+func (s *walletService) ListWallets(ctx context.Context, userID string) ([]db.WalletModel, error) {
 	cacheKey := fmt.Sprintf("wallet:%s", userID)
 
-	var cachedWallet db.WalletModel
-	err := s.redis.Get(ctx, cacheKey, &cachedWallet)
+	var cachedWallets []db.WalletModel
+	err := s.redis.Get(ctx, cacheKey, &cachedWallets)
 	if err == nil {
-		return &cachedWallet, nil
+		return cachedWallets, nil
 	}
 
-	wallet, err := s.repo.GetWalletWithAssets(ctx, userID)
+	wallets, err := s.repo.ListWallets(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	if wallet == nil {
+	if len(wallets) == 0 {
 		return nil, errors.New("wallet does not exist")
 	}
 
-	_ = s.redis.Set(ctx, cacheKey, wallet, 5*time.Minute)
+	_ = s.redis.Set(ctx, cacheKey, wallets, 5*time.Minute)
+
+	return wallets, nil
+}
+
+// GetWalletWithAssets reads the wallet from the database rather than the
+// cached list, since it is what clients check funds against before they
+// move them.
+func (s *walletService) GetWalletWithAssets(ctx context.Context, userID, walletID string) (*db.WalletModel, error) {
+	wallet, err := s.repo.GetWalletWithAssets(ctx, userID, walletID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrUnknownWallet
+	}
+	return wallet, err
+}
+
+func (s *walletService) CreateWallet(ctx context.Context, userID, name string, kind db.WalletKind) (*db.WalletModel, error) {
+	name = strings.TrimSpace(name)
+	if kind == "" {
+		kind = db.WalletKindPersonal
+	}
+	if name == "" || utf8.RuneCountInString(name) > maxWalletNameLength {
+		return nil, ErrInvalidWallet
+	}
+	switch kind {
+	case db.WalletKindPersonal, db.WalletKindBusiness, db.WalletKindProject:
+	default:
+		return nil, ErrInvalidWallet
+	}
+
+	wallets, err := s.repo.ListWallets(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(wallets) >= maxWallets {
+		return nil, ErrTooManyWallets
+	}
+	for _, w := range wallets {
+		if strings.EqualFold(w.Name, name) {
+			return nil, ErrWalletNameTaken
+		}
+	}
 
+	wallet, err := s.repo.CreateWallet(ctx, userID, repository.NewWallet{Name: name, Kind: kind})
+	if _, ok := db.IsErrUniqueConstraint(err); ok {
+		return nil, ErrWalletNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", userID))
 	return wallet, nil
 }
 
-func (s *walletService) GetAssetByCurrency(ctx context.Context, userID string, currency string) (*db.WalletAssetModel, error) {
-	asset, err := s.repo.GetAssetByCurrency(ctx, userID, currency)
+func (s *walletService) GetAssetByCurrency(ctx context.Context, userID, walletID, currency string) (*db.WalletAssetModel, error) {
+	asset, err := s.repo.GetAssetByCurrency(ctx, userID, walletID, currency)
 	if err != nil {
 		return nil, err
 	}
@@ -86,19 +188,22 @@ func (s *walletService) GetAssetByCurrency(ctx context.Context, userID string, c
 	return asset, nil
 }
 
-func (s *walletService) FundWallet(ctx context.Context, userID, currency string, amount float64, reference, description string) error {
+func (s *walletService) FundWallet(ctx context.Context, userID, walletID string, amount money.Money, reference, description string) error {
     locked, _ := s.redis.TryLockIdempotencyKey(ctx, reference, 5*time.Minute)
     if !locked {
         return ErrTransactionAlreadyProcessed
     }
 
-    asset, err := s.repo.GetAssetByCurrency(ctx, userID, currency)
+    asset, err := s.repo.GetAssetByCurrency(ctx, userID, walletID, amount.Currency())
     if err != nil {
         _ = s.redis.Delete(ctx, "idemp:"+reference)
+        if errors.Is(err, db.ErrNotFound) {
+            return ErrWalletNotFound
+        }
         return err
     }
 
-    err = s.repo.CreditWallet(ctx, asset.WalletID, currency, amount, reference, description, db.TransactionTypeDeposit)
+    err = s.repo.CreditWallet(ctx, asset.WalletID, amount, reference, description, db.TransactionTypeDeposit)
     if err != nil {
         if _, ok := db.IsErrUniqueConstraint(err); ok {
             return ErrTransactionAlreadyProcessed
@@ -112,49 +217,56 @@ func (s *walletService) FundWallet(ctx context.Context, userID, currency string,
     return nil
 }
 
-func (s *walletService) fetchExchangeRate(fromCurrency, toCurrency string) (float64, error) {
+func (s *walletService) fetchExchangeRate(fromCurrency, toCurrency string) (decimal.Decimal, error) {
 	if fromCurrency == toCurrency {
-		return 1.0, nil
+		return decimal.NewFromInt(1), nil
 	}
 
 	url := fmt.Sprintf("https://open.er-api.com/v6/latest/%s", fromCurrency)
 
 	resp, err := http.Get(url)
 	if err != nil {
-		return 0, errors.New("failed to fetch exchange rates")
+		return decimal.Zero, errors.New("failed to fetch exchange rates")
 	}
 	defer resp.Body.Close()
 
 	var result ExchangeRateResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, errors.New("failed to parse exchange rates")
+		return decimal.Zero, errors.New("failed to parse exchange rates")
 	}
 
 	rate, exists := result.Rates[toCurrency]
 	if !exists {
-		return 0, errors.New("currency pair not supported")
+		return decimal.Zero, errors.New("currency pair not supported")
 	}
 
 	return rate, nil
 }
 
-func (s *walletService) SwapFunds(ctx context.Context, userID, fromCurrency, toCurrency string, amountIn float64, reference string) (*map[string]interface{}, error) {
+func (s *walletService) SwapFunds(ctx context.Context, userID, walletID string, amountIn money.Money, toCurrency, reference string) (*map[string]interface{}, error) {
 	// Idempotency lock
 	locked, _ := s.redis.TryLockIdempotencyKey(ctx, reference, 5*time.Minute)
 	if !locked {
 		return nil, ErrTransactionAlreadyProcessed
 	}
 
+	fromCurrency := amountIn.Currency()
 	rate, err := s.fetchExchangeRate(fromCurrency, toCurrency)
 	if err != nil {
 		_ = s.redis.Delete(ctx, "idemp:"+reference)
 		return nil, err
 	}
-	amountOut := amountIn * rate
+	// The customer receives the converted amount rounded half-to-even to the
+	// destination currency's minor unit.
+	amountOut := amountIn.Convert(rate, toCurrency)
+	if !amountOut.IsPositive() {
+		_ = s.redis.Delete(ctx, "idemp:"+reference)
+		return nil, errors.New("amount is too small to convert")
+	}
 
-	description := fmt.Sprintf("Swap %s to %s @ %f", fromCurrency, toCurrency, rate)
+	description := fmt.Sprintf("Swap %s to %s @ %s", fromCurrency, toCurrency, rate)
 
-	err = s.repo.SwapFunds(ctx, userID, fromCurrency, toCurrency, amountIn, amountOut, reference, description)
+	err = s.repo.SwapFunds(ctx, userID, walletID, amountIn, amountOut, reference, description)
 	if err != nil {
 		if _, ok := db.IsErrUniqueConstraint(err); ok {
 			return nil, ErrTransactionAlreadyProcessed
@@ -169,14 +281,14 @@ func (s *walletService) SwapFunds(ctx context.Context, userID, fromCurrency, toC
 
 	return &map[string]interface{}{
 		"source_currency": fromCurrency,
-		"source_amount":   amountIn,
-		"dest_currency":   toCurrency,
-		"dest_amount":     amountOut,
-		"rate":            rate,
+		"source_amount":   amountIn.StringFixed(),
+		"dest_currency":   amountOut.Currency(),
+		"dest_amount":     amountOut.StringFixed(),
+		"rate":            rate.String(),
 	}, nil
 }
 
-func (s *walletService) TransferFunds(ctx context.Context, userID, toAccount, currency string, amount float64, userDesc, reference string) (string, error) {
+func (s *walletService) TransferFunds(ctx context.Context, userID, fromWalletID, toAccount string, amount money.Money, userDesc, reference string) (string, error) {
 	// Idempotency lock
 	locked, _ := s.redis.TryLockIdempotencyKey(ctx, reference, 5*time.Minute)
 	if !locked {
@@ -194,6 +306,15 @@ func (s *walletService) TransferFunds(ctx context.Context, userID, toAccount, cu
 		senderName = sender.Name
 	}
 
+	source, err := s.repo.GetWalletWithAssets(ctx, userID, fromWalletID)
+	if err != nil {
+		_ = s.redis.Delete(ctx, "idemp:"+reference)
+		if errors.Is(err, db.ErrNotFound) {
+			return "", ErrUnknownWallet
+		}
+		return "", err
+	}
+
 	receiverWallet, err := s.repo.GetWalletByAccountNumber(ctx, toAccount)
 	if err != nil {
 		_ = s.redis.Delete(ctx, "idemp:"+reference)
@@ -204,9 +325,9 @@ func (s *walletService) TransferFunds(ctx context.Context, userID, toAccount, cu
 		return "", errors.New("recipient account number not found")
 	}
 
-	if receiverWallet.UserID == userID {
+	if receiverWallet.ID == source.ID {
 		_ = s.redis.Delete(ctx, "idemp:"+reference)
-		return "", errors.New("cannot transfer money to yourself")
+		return "", ErrSameWallet
 	}
 
 	receiverUser := receiverWallet.User()
@@ -216,16 +337,20 @@ func (s *walletService) TransferFunds(ctx context.Context, userID, toAccount, cu
 	}
 
 	descSender := fmt.Sprintf("Transfer to %s", receiverName)
-	if userDesc != "" {
-		descSender += fmt.Sprintf(" / %s", userDesc)
-	}
-
 	descReceiver := fmt.Sprintf("Received from %s", senderName)
+	if receiverWallet.UserID == userID {
+		descSender = fmt.Sprintf("Moved to %s", receiverWallet.Name)
+		descReceiver = fmt.Sprintf("Moved from %s", source.Name)
+	}
 	if userDesc != "" {
+		descSender += fmt.Sprintf(" / %s", userDesc)
 		descReceiver += fmt.Sprintf(" /DESCRIPTION: %s", userDesc)
 	}
 
-	err = s.repo.TransferFunds(ctx, userID, toAccount, currency, amount, reference, descSender, descReceiver)
+	limit, hasLimit := s.reviewLimits[amount.Currency()]
+	review := receiverWallet.UserID != userID && hasLimit && amount.Amount().GreaterThanOrEqual(limit)
+
+	err = s.repo.TransferFunds(ctx, userID, source.ID, toAccount, amount, reference, descSender, descReceiver, review)
 	if err != nil {
 		if _, ok := db.IsErrUniqueConstraint(err); ok {
 			return "", ErrTransactionAlreadyProcessed
@@ -239,69 +364,119 @@ func (s *walletService) TransferFunds(ctx context.Context, userID, toAccount, cu
 	_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", receiverWallet.UserID))
 	_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", userID))
 
+	if review {
+		return receiverName, ErrTransferUnderReview
+	}
 	return receiverName, nil
 }
 
-func (s *walletService) GetTransactionHistory(ctx context.Context, userID string) ([]db.TransactionModel, error) {
-	cacheKey := fmt.Sprintf("tx_history:%s", userID)
-
-	var cachedBytes []byte
-	if err := s.redis.Get(ctx, cacheKey, &cachedBytes); err == nil {
-		var transactions []db.TransactionModel
-		if err := json.Unmarshal(cachedBytes, &transactions); err == nil {
-			return transactions, nil
-		}
+func (s *walletService) MoveFunds(ctx context.Context, userID, fromWalletID, toWalletID string, amount money.Money, description, reference string) error {
+	to, err := s.repo.GetWalletWithAssets(ctx, userID, toWalletID)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrUnknownWallet
 	}
-	transactions, err := s.repo.GetTransactions(ctx, userID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	_ = s.redis.Set(ctx, cacheKey, transactions, 10*time.Minute)
+	_, err = s.TransferFunds(ctx, userID, fromWalletID, to.AccountNumber, amount, description, reference)
+	return err
+}
 
-	return transactions, nil
+func (s *walletService) CaptureHold(ctx context.Context, reference string) error {
+	return s.resolveHold(ctx, reference, s.repo.CaptureHold)
 }
 
-func (s *walletService) WithdrawFunds(ctx context.Context, userID string, req WithdrawalRequest) error {
-	reference := fmt.Sprintf("WDR-%d", time.Now().UnixNano())
+func (s *walletService) ReleaseHold(ctx context.Context, reference string) error {
+	return s.resolveHold(ctx, reference, s.repo.ReleaseHold)
+}
 
-	recipientCode, err := s.paymentService.CreateTransferRecipient(
-		req.AccountName,
-		req.AccountNumber,
-		req.BankCode,
-		req.Currency,
-	)
+// resolveHold runs resolve and drops the cached wallet of the hold's owner.
+// The recipient of a captured review hold is dropped by its domain event.
+func (s *walletService) resolveHold(ctx context.Context, reference string, resolve func(context.Context, string) error) error {
+	hold, err := s.repo.GetHold(ctx, reference)
+	if errors.Is(err, db.ErrNotFound) {
+		return ErrHoldNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create paystack recipient: %w", err)
+		return err
+	}
+	if err := resolve(ctx, reference); err != nil {
+		return err
 	}
 
-	transferResp, err := s.paymentService.InitiateTransfer(
-		req.Amount,
-		recipientCode,
-		reference,
-		req.Reason,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to initiate paystack transfer: %w", err)
-	}
-
-	err = s.repo.DebitForWithdrawal(
-		ctx,
-		userID,
-		req.Currency,
-		req.Amount,
-		reference,
-		transferResp.Data.TransferCode,
-		req.Reason,
-	)
+	if wallet := hold.Asset().RelationsWalletAsset.Wallet; wallet != nil {
+		_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", wallet.UserID))
+		_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", wallet.UserID))
+	}
+	return nil
+}
+
+// TransactionPage is one page of a user's transaction history.
+type TransactionPage struct {
+	Transactions []db.TransactionModel `json:"transactions"`
+	// NextCursor fetches the page after this one; empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+const (
+	defaultHistoryPageSize = 20
+	maxHistoryPageSize     = 100
+)
+
+// GetTransactionHistory returns the page of the user's transactions after
+// cursor, newest first. Only the unfiltered first page is cached: it is what
+// the app loads on every visit, and it lives under the tx_history key that
+// every balance change already invalidates.
+func (s *walletService) GetTransactionHistory(ctx context.Context, userID string, filter repository.TransactionFilter, cursor string) (*TransactionPage, error) {
+	if filter.Limit <= 0 || filter.Limit > maxHistoryPageSize {
+		filter.Limit = defaultHistoryPageSize
+	}
+	if cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil || len(after) == 0 {
+			return nil, ErrInvalidCursor
+		}
+		filter.After = string(after)
+	}
+
+	cacheKey := ""
+	if cursor == "" && filter.Limit == defaultHistoryPageSize && unfiltered(filter) {
+		cacheKey = fmt.Sprintf("tx_history:%s", userID)
+		var cached TransactionPage
+		if err := s.redis.Get(ctx, cacheKey, &cached); err == nil {
+			return &cached, nil
+		}
+	}
+
+	// One extra row tells us whether there is another page.
+	limit := filter.Limit
+	filter.Limit++
+	transactions, err := s.repo.ListTransactions(ctx, userID, filter)
 	if err != nil {
-		return fmt.Errorf("withdrawal successful but db update failed: %w", err)
+		return nil, err
 	}
 
-	cacheKey := fmt.Sprintf("wallet:%s", userID)
-	_ = s.redis.Delete(ctx, cacheKey)
-	_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", userID))
-	return nil
+	page := &TransactionPage{Transactions: transactions}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(transactions[limit-1].ID))
+	}
+	if page.Transactions == nil {
+		page.Transactions = []db.TransactionModel{}
+	}
+
+	if cacheKey != "" {
+		_ = s.redis.Set(ctx, cacheKey, page, 10*time.Minute)
+	}
+	return page, nil
 }
+
+func unfiltered(f repository.TransactionFilter) bool {
+	return f.WalletID == "" && len(f.Types) == 0 && len(f.Statuses) == 0 && f.Direction == "" && f.Currency == "" &&
+		f.From.IsZero() && f.To.IsZero() && f.MinAmount == nil && f.MaxAmount == nil && f.Search == "" &&
+		len(f.Categories) == 0
+}
+
 type UserLookupResult struct {
 	Name          string
 	AccountNumber string
@@ -313,10 +488,18 @@ func (s *walletService) LookupUser(ctx context.Context, query string) (*UserLook
 		return nil, err
 	}
 
-	wallet, ok := user.Wallet()
-	if !ok {
+	// A search by account number finds that wallet; one by email finds the
+	// default wallet, which is listed first.
+	wallets := user.Wallets()
+	if len(wallets) == 0 {
 		return nil, db.ErrNotFound
 	}
+	wallet := wallets[0]
+	for _, w := range wallets {
+		if w.AccountNumber == query {
+			wallet = w
+		}
+	}
 	return &UserLookupResult{
 		Name:          user.Name,
 		AccountNumber: wallet.AccountNumber,
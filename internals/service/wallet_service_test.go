@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// historyLedger pages through a fixed, newest-first list of transactions.
+type historyLedger struct {
+	repository.WalletRepository
+	transactions []db.TransactionModel
+	queries      []repository.TransactionFilter
+}
+
+func (l *historyLedger) ListTransactions(ctx context.Context, userID string, filter repository.TransactionFilter) ([]db.TransactionModel, error) {
+	l.queries = append(l.queries, filter)
+	start := 0
+	if filter.After != "" {
+		for i, txn := range l.transactions {
+			if txn.ID == filter.After {
+				start = i + 1
+			}
+		}
+	}
+	end := min(start+filter.Limit, len(l.transactions))
+	return l.transactions[start:end], nil
+}
+
+func TestGetTransactionHistoryPages(t *testing.T) {
+	ctx := context.Background()
+	ledger := &historyLedger{}
+	for i := 45; i > 0; i-- {
+		ledger.transactions = append(ledger.transactions, db.TransactionModel{InnerTransaction: db.InnerTransaction{ID: fmt.Sprintf("tx-%02d", i)}})
+	}
+	cache := newJSONQueue()
+	svc := &walletService{repo: ledger, redis: cache}
+
+	var seen []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("expected the history to end after 3 pages")
+		}
+		page, err := svc.GetTransactionHistory(ctx, "u1", repository.TransactionFilter{}, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, txn := range page.Transactions {
+			seen = append(seen, txn.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 45 || seen[0] != "tx-45" || seen[44] != "tx-01" {
+		t.Fatalf("expected every transaction once, newest first, got %d from %s", len(seen), seen[0])
+	}
+
+	// The first page is now cached; filtered and later pages never are.
+	queries := len(ledger.queries)
+	if _, err := svc.GetTransactionHistory(ctx, "u1", repository.TransactionFilter{}, ""); err != nil || len(ledger.queries) != queries {
+		t.Fatalf("expected the first page from the cache, %v", err)
+	}
+	if _, err := svc.GetTransactionHistory(ctx, "u1", repository.TransactionFilter{Currency: "NGN"}, ""); err != nil || len(ledger.queries) != queries+1 {
+		t.Fatalf("expected a filtered page from the ledger, %v", err)
+	}
+	if len(cache.values) != 1 {
+		t.Fatalf("expected only the first page cached, got %d entries", len(cache.values))
+	}
+
+	if _, err := svc.GetTransactionHistory(ctx, "u1", repository.TransactionFilter{}, "%%%"); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// walletsLedger holds a few users' wallets and records the transfers made
+// between them.
+type walletsLedger struct {
+	repository.WalletRepository
+	wallets   []db.WalletModel
+	transfers []string // "from-wallet -> account: sender description | receiver description", " (review)" when held
+}
+
+func testWallet(id, userID, name string, isDefault bool) db.WalletModel {
+	return db.WalletModel{
+		InnerWallet:     db.InnerWallet{ID: id, UserID: userID, AccountNumber: "acc-" + id, Name: name, Kind: db.WalletKindPersonal, IsDefault: isDefault},
+		RelationsWallet: db.RelationsWallet{User: &db.UserModel{InnerUser: db.InnerUser{ID: userID, Name: "User " + userID}}},
+	}
+}
+
+func (l *walletsLedger) ListWallets(ctx context.Context, userID string) ([]db.WalletModel, error) {
+	var wallets []db.WalletModel
+	for _, w := range l.wallets {
+		if w.UserID == userID {
+			wallets = append(wallets, w)
+		}
+	}
+	return wallets, nil
+}
+
+func (l *walletsLedger) CreateWallet(ctx context.Context, userID string, w repository.NewWallet) (*db.WalletModel, error) {
+	wallet := testWallet(fmt.Sprintf("w%d", len(l.wallets)+1), userID, w.Name, false)
+	wallet.Kind = w.Kind
+	l.wallets = append(l.wallets, wallet)
+	return &wallet, nil
+}
+
+func (l *walletsLedger) GetWalletWithAssets(ctx context.Context, userID, walletID string) (*db.WalletModel, error) {
+	for _, w := range l.wallets {
+		if w.UserID == userID && (w.ID == walletID || (walletID == "" && w.IsDefault)) {
+			return &w, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
+func (l *walletsLedger) GetWalletByAccountNumber(ctx context.Context, accountNumber string) (*db.WalletModel, error) {
+	for _, w := range l.wallets {
+		if w.AccountNumber == accountNumber {
+			return &w, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
+func (l *walletsLedger) GetUserByID(ctx context.Context, userID string) (*db.UserModel, error) {
+	return &db.UserModel{InnerUser: db.InnerUser{ID: userID, Name: "User " + userID}}, nil
+}
+
+func (l *walletsLedger) TransferFunds(ctx context.Context, fromUserID, fromWalletID, toAccountNumber string, amount money.Money, reference, descSender, descReceiver string, review bool) error {
+	transfer := fmt.Sprintf("%s -> %s: %s | %s", fromWalletID, toAccountNumber, descSender, descReceiver)
+	if review {
+		transfer += " (review)"
+	}
+	l.transfers = append(l.transfers, transfer)
+	return nil
+}
+
+func TestCreateWalletRules(t *testing.T) {
+	ctx := context.Background()
+	ledger := &walletsLedger{wallets: []db.WalletModel{testWallet("w1", "u1", "Personal", true)}}
+	queue := newJSONQueue()
+	svc := &walletService{repo: ledger, redis: queue}
+
+	wallet, err := svc.CreateWallet(ctx, "u1", "  Side hustle ", db.WalletKindBusiness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Name != "Side hustle" || wallet.Kind != db.WalletKindBusiness || wallet.IsDefault {
+		t.Fatalf("expected a trimmed, non-default business wallet, got %+v", wallet.InnerWallet)
+	}
+	if len(queue.deleted) != 1 || queue.deleted[0] != "wallet:u1" {
+		t.Fatalf("expected the cached wallets to be dropped, got %v", queue.deleted)
+	}
+
+	if _, err := svc.CreateWallet(ctx, "u1", "SIDE HUSTLE", ""); !errors.Is(err, ErrWalletNameTaken) {
+		t.Fatalf("expected names to be unique regardless of case, got %v", err)
+	}
+	for _, kind := range []db.WalletKind{"SAVINGS", "personal"} {
+		if _, err := svc.CreateWallet(ctx, "u1", "Trip", kind); !errors.Is(err, ErrInvalidWallet) {
+			t.Fatalf("expected kind %q to be refused, got %v", kind, err)
+		}
+	}
+	if _, err := svc.CreateWallet(ctx, "u1", " ", ""); !errors.Is(err, ErrInvalidWallet) {
+		t.Fatalf("expected a blank name to be refused, got %v", err)
+	}
+
+	for i := len(ledger.wallets); i < maxWallets; i++ {
+		if _, err := svc.CreateWallet(ctx, "u1", fmt.Sprintf("Project %d", i), db.WalletKindProject); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := svc.CreateWallet(ctx, "u1", "One too many", ""); !errors.Is(err, ErrTooManyWallets) {
+		t.Fatalf("expected the eleventh wallet to be refused, got %v", err)
+	}
+}
+
+func TestGetWalletWithAssetsSkipsTheCache(t *testing.T) {
+	ctx := context.Background()
+	ledger := &walletsLedger{wallets: []db.WalletModel{testWallet("w1", "u1", "Personal", true)}}
+	svc := &walletService{repo: ledger, redis: newJSONQueue()}
+
+	if _, err := svc.ListWallets(ctx, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	ledger.wallets[0].Name = "Renamed"
+
+	wallet, err := svc.GetWalletWithAssets(ctx, "u1", "")
+	if err != nil || wallet.Name != "Renamed" {
+		t.Fatalf("expected the wallet as stored, got %+v, %v", wallet, err)
+	}
+	if _, err := svc.GetWalletWithAssets(ctx, "u2", "w1"); !errors.Is(err, ErrUnknownWallet) {
+		t.Fatalf("expected another user's wallet to be unknown, got %v", err)
+	}
+}
+
+func TestMoveFundsBetweenOwnWallets(t *testing.T) {
+	ctx := context.Background()
+	ledger := &walletsLedger{wallets: []db.WalletModel{
+		testWallet("w1", "u1", "Personal", true),
+		testWallet("w2", "u1", "Business", false),
+		testWallet("w3", "u2", "Personal", true),
+	}}
+	queue := newJSONQueue()
+	svc := &walletService{repo: ledger, redis: queue}
+	ngn := money.Round(decimal.NewFromInt(500), "NGN")
+
+	// No source wallet means the default one.
+	if err := svc.MoveFunds(ctx, "u1", "", "w2", ngn, "", "ref-1"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "w1 -> acc-w2: Moved to Business | Moved from Personal"; len(ledger.transfers) != 1 || ledger.transfers[0] != want {
+		t.Fatalf("expected %q, got %v", want, ledger.transfers)
+	}
+
+	if err := svc.MoveFunds(ctx, "u1", "w2", "w2", ngn, "", "ref-2"); !errors.Is(err, ErrSameWallet) {
+		t.Fatalf("expected a move to the same wallet to be refused, got %v", err)
+	}
+	if err := svc.MoveFunds(ctx, "u1", "w1", "w3", ngn, "", "ref-3"); !errors.Is(err, ErrUnknownWallet) {
+		t.Fatalf("expected another user's wallet to be out of reach, got %v", err)
+	}
+	if err := svc.MoveFunds(ctx, "u2", "w1", "w3", ngn, "", "ref-4"); !errors.Is(err, ErrUnknownWallet) {
+		t.Fatalf("expected another user's wallet to be out of reach as a source, got %v", err)
+	}
+
+	// Paying someone else names them, from whichever wallet it leaves.
+	if _, err := svc.TransferFunds(ctx, "u1", "w2", "acc-w3", ngn, "rent", "ref-5"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "w2 -> acc-w3: Transfer to User u2 / rent | Received from User u1 /DESCRIPTION: rent"; ledger.transfers[1] != want {
+		t.Fatalf("expected %q, got %q", want, ledger.transfers[1])
+	}
+	if done := queue.completed(); len(done) != 2 || done[0] != "idemp:ref-1" || done[1] != "idemp:ref-5" {
+		t.Fatalf("expected only the two transfers to be marked done, got %v", done)
+	}
+}
+
+func TestLargeTransfersHeldForReview(t *testing.T) {
+	ctx := context.Background()
+	ledger := &walletsLedger{wallets: []db.WalletModel{
+		testWallet("w1", "u1", "Personal", true),
+		testWallet("w2", "u1", "Business", false),
+		testWallet("w3", "u2", "Personal", true),
+	}}
+	limits, err := ParseReviewLimits(" ngn=100000 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := &walletService{repo: ledger, redis: newJSONQueue(), reviewLimits: limits}
+
+	small := money.Round(decimal.NewFromInt(99999), "NGN")
+	large := money.Round(decimal.NewFromInt(100000), "NGN")
+	if _, err := svc.TransferFunds(ctx, "u1", "", "acc-w3", small, "", "ref-1"); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := svc.TransferFunds(ctx, "u1", "", "acc-w3", large, "", "ref-2"); !errors.Is(err, ErrTransferUnderReview) || name != "User u2" {
+		t.Fatalf("expected the transfer to User u2 held for review, got %q, %v", name, err)
+	}
+	// Moving between one's own wallets is never reviewed, nor is a currency without a limit.
+	if err := svc.MoveFunds(ctx, "u1", "", "w2", large, "", "ref-3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.TransferFunds(ctx, "u1", "", "acc-w3", money.Round(decimal.NewFromInt(100000), "USD"), "", "ref-4"); err != nil {
+		t.Fatal(err)
+	}
+
+	var held []string
+	for _, transfer := range ledger.transfers {
+		if strings.HasSuffix(transfer, " (review)") {
+			held = append(held, transfer)
+		}
+	}
+	if len(held) != 1 || !strings.HasPrefix(held[0], "w1 -> acc-w3") {
+		t.Fatalf("expected only the large NGN transfer held, got %v", ledger.transfers)
+	}
+
+	for _, spec := range []string{"NGN", "NGN=0", "NGN=abc"} {
+		if _, err := ParseReviewLimits(spec); err == nil {
+			t.Fatalf("expected %q to be refused", spec)
+		}
+	}
+}
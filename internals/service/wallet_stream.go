@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+)
+
+// WalletUpdatesChannel is the pub/sub channel every instance relays to the
+// wallet streams open on it.
+const WalletUpdatesChannel = "wallet_updates"
+
+// Wallet update types sent to a user's open streams.
+const (
+	UpdateBalanceUpdated     = "balance.updated"
+	UpdateTransactionCreated = "transaction.created"
+)
+
+// PubSub broadcasts messages to every server instance.
+type PubSub interface {
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe calls fn with each message published on channel until ctx
+	// is done.
+	Subscribe(ctx context.Context, channel string, fn func(payload []byte))
+}
+
+// WalletUpdate is one message on a user's stream. EventID is the domain
+// event it came from, so a client can spot the repeats at-least-once
+// delivery allows.
+type WalletUpdate struct {
+	UserID    string          `json:"user_id"`
+	Type      string          `json:"type"`
+	EventID   string          `json:"event_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// BalanceUpdate is the data of a balance.updated update.
+type BalanceUpdate struct {
+	WalletID  string          `json:"wallet_id"`
+	Currency  string          `json:"currency"`
+	Balance   decimal.Decimal `json:"balance"`
+	Held      decimal.Decimal `json:"held"`
+	Available decimal.Decimal `json:"available"`
+}
+
+// TransactionUpdate is the data of a transaction.created update.
+type TransactionUpdate struct {
+	WalletID    string           `json:"wallet_id"`
+	Reference   string           `json:"reference"`
+	Kind        string           `json:"kind"`      // the domain event, e.g. "transfer.completed"
+	Direction   string           `json:"direction"` // "credit" or "debit"
+	Amount      decimal.Decimal  `json:"amount"`
+	Currency    string           `json:"currency"`
+	Description string           `json:"description,omitempty"`
+	ToAmount    *decimal.Decimal `json:"to_amount,omitempty"`
+	ToCurrency  string           `json:"to_currency,omitempty"`
+}
+
+type WalletStream interface {
+	// Publish sends update to the user's streams on every instance.
+	Publish(ctx context.Context, update WalletUpdate) error
+	// Subscribe opens a stream for userID on this instance. Updates are
+	// dropped rather than queued when the stream falls behind.
+	Subscribe(userID string) (updates <-chan WalletUpdate, unsubscribe func())
+	// Run relays published updates to this instance's streams until ctx is done.
+	Run(ctx context.Context)
+}
+
+type walletStream struct {
+	pubsub PubSub
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	streams map[string]map[chan WalletUpdate]struct{} // user id -> open streams
+}
+
+func NewWalletStream(pubsub PubSub, logger *slog.Logger) WalletStream {
+	return &walletStream{pubsub: pubsub, logger: logger, streams: make(map[string]map[chan WalletUpdate]struct{})}
+}
+
+func (s *walletStream) Publish(ctx context.Context, update WalletUpdate) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	return s.pubsub.Publish(ctx, WalletUpdatesChannel, payload)
+}
+
+func (s *walletStream) Subscribe(userID string) (<-chan WalletUpdate, func()) {
+	ch := make(chan WalletUpdate, 16)
+
+	s.mu.Lock()
+	if s.streams[userID] == nil {
+		s.streams[userID] = make(map[chan WalletUpdate]struct{})
+	}
+	s.streams[userID][ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.streams[userID], ch)
+			if len(s.streams[userID]) == 0 {
+				delete(s.streams, userID)
+			}
+			close(ch)
+		})
+	}
+}
+
+func (s *walletStream) Run(ctx context.Context) {
+	s.pubsub.Subscribe(ctx, WalletUpdatesChannel, func(payload []byte) {
+		var update WalletUpdate
+		if err := json.Unmarshal(payload, &update); err != nil {
+			s.logger.Error("unreadable wallet update", "error", err)
+			return
+		}
+		s.dispatch(update)
+	})
+}
+
+func (s *walletStream) dispatch(update WalletUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.streams[update.UserID] {
+		select {
+		case ch <- update:
+		default:
+			s.logger.Warn("dropping wallet update for a slow stream", "user_id", update.UserID, "type", update.Type)
+		}
+	}
+}
+
+// StreamWalletUpdates publishes a transaction.created update for each side
+// of a new transaction and a balance.updated update for each balance an
+// event changed. It runs alongside InvalidateWalletCaches, so a stream hears
+// about a change at the same point cached wallets are dropped.
+func StreamWalletUpdates(stream WalletStream, wallets repository.WalletRepository) DomainEventHandler {
+	return func(ctx context.Context, event DomainEvent) error {
+		e := event.Data
+		publish := func(userID, updateType string, data interface{}) error {
+			encoded, err := json.Marshal(data)
+			if err != nil {
+				return err
+			}
+			return stream.Publish(ctx, WalletUpdate{UserID: userID, Type: updateType, EventID: event.ID, Data: encoded, CreatedAt: event.CreatedAt})
+		}
+
+		txn := TransactionUpdate{
+			WalletID:    e.WalletID,
+			Reference:   e.Reference,
+			Kind:        event.Type,
+			Amount:      e.Amount,
+			Currency:    e.Currency,
+			Description: e.Description,
+		}
+		type transaction struct {
+			userID string
+			update TransactionUpdate
+		}
+		type balance struct{ userID, walletID, currency string }
+		var created []transaction
+		var balances []balance
+
+		switch event.Type {
+		case repository.EventWalletCredited, repository.EventVaultWithdrawn, repository.EventInterestPaid:
+			txn.Direction = "credit"
+			created = append(created, transaction{e.UserID, txn})
+			balances = append(balances, balance{e.UserID, e.WalletID, e.Currency})
+
+		case repository.EventWalletDebited, repository.EventWithdrawalRequested, repository.EventVaultDeposited:
+			txn.Direction = "debit"
+			created = append(created, transaction{e.UserID, txn})
+			balances = append(balances, balance{e.UserID, e.WalletID, e.Currency})
+
+		case repository.EventTransferCompleted, repository.EventWalletMoved:
+			sent, received := txn, txn
+			sent.Direction, received.Direction = "debit", "credit"
+			received.WalletID = e.CounterpartyWalletID
+			created = append(created, transaction{e.UserID, sent}, transaction{e.CounterpartyUserID, received})
+			balances = append(balances, balance{e.UserID, e.WalletID, e.Currency}, balance{e.CounterpartyUserID, e.CounterpartyWalletID, e.Currency})
+
+		case repository.EventSwapCompleted:
+			txn.Direction = "debit"
+			txn.ToAmount, txn.ToCurrency = e.ToAmount, e.ToCurrency
+			created = append(created, transaction{e.UserID, txn})
+			balances = append(balances, balance{e.UserID, e.WalletID, e.Currency}, balance{e.UserID, e.WalletID, e.ToCurrency})
+
+		case repository.EventWithdrawalSucceeded, repository.EventWithdrawalFailed, repository.EventWithdrawalReversed:
+			// The withdrawal's transaction already exists; only the held or
+			// refunded amount changed.
+			balances = append(balances, balance{e.UserID, e.WalletID, e.Currency})
+		}
+
+		for _, t := range created {
+			if t.userID == "" {
+				continue
+			}
+			if err := publish(t.userID, UpdateTransactionCreated, t.update); err != nil {
+				return err
+			}
+		}
+		for _, b := range balances {
+			if b.userID == "" || b.currency == "" {
+				continue
+			}
+			asset, err := wallets.GetAssetByCurrency(ctx, b.userID, b.walletID, b.currency)
+			if err != nil {
+				return fmt.Errorf("load %s balance for %s: %w", b.currency, b.userID, err)
+			}
+			held := asset.HeldBalance
+			if err := publish(b.userID, UpdateBalanceUpdated, BalanceUpdate{
+				WalletID:  asset.WalletID,
+				Currency:  asset.Currency,
+				Balance:   asset.Balance,
+				Held:      held,
+				Available: decimal.Max(asset.Balance.Sub(held), decimal.Zero),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
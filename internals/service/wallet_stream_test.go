@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// loopbackPubSub delivers every publish straight to its subscriber, as if
+// all instances shared one process.
+type loopbackPubSub struct {
+	fn func(payload []byte)
+}
+
+func (p *loopbackPubSub) Publish(ctx context.Context, channel string, payload []byte) error {
+	if p.fn != nil {
+		p.fn(payload)
+	}
+	return nil
+}
+
+func (p *loopbackPubSub) Subscribe(ctx context.Context, channel string, fn func(payload []byte)) {
+	p.fn = fn
+}
+
+type balanceLedger struct {
+	repository.WalletRepository
+	balances map[string]decimal.Decimal // by user id
+}
+
+func (l balanceLedger) GetAssetByCurrency(ctx context.Context, userID, walletID, currency string) (*db.WalletAssetModel, error) {
+	return &db.WalletAssetModel{InnerWalletAsset: db.InnerWalletAsset{
+		Currency:    currency,
+		Balance:     l.balances[userID],
+		HeldBalance: decimal.NewFromInt(100),
+	}}, nil
+}
+
+func newTestWalletStream() WalletStream {
+	stream := NewWalletStream(&loopbackPubSub{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	stream.Run(context.Background())
+	return stream
+}
+
+func TestStreamWalletUpdatesTransfer(t *testing.T) {
+	stream := newTestWalletStream()
+	sender, stopSender := stream.Subscribe("sender")
+	defer stopSender()
+	receiver, stopReceiver := stream.Subscribe("receiver")
+	defer stopReceiver()
+	other, stopOther := stream.Subscribe("someone-else")
+	defer stopOther()
+
+	handler := StreamWalletUpdates(stream, balanceLedger{balances: map[string]decimal.Decimal{
+		"sender":   decimal.NewFromInt(500),
+		"receiver": decimal.NewFromInt(2500),
+	}})
+	err := handler(context.Background(), DomainEvent{ID: "e1", Type: repository.EventTransferCompleted, Data: repository.WalletEvent{
+		UserID:             "sender",
+		CounterpartyUserID: "receiver",
+		Amount:             decimal.NewFromInt(2000),
+		Currency:           "NGN",
+		Reference:          "TRF-1",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender) != 2 || len(receiver) != 2 || len(other) != 0 {
+		t.Fatalf("expected two updates for each side only, got %d, %d and %d", len(sender), len(receiver), len(other))
+	}
+
+	created := <-receiver
+	var txn TransactionUpdate
+	if err := json.Unmarshal(created.Data, &txn); err != nil {
+		t.Fatal(err)
+	}
+	if created.Type != UpdateTransactionCreated || created.EventID != "e1" || txn.Direction != "credit" || txn.Reference != "TRF-1" {
+		t.Fatalf("unexpected transaction update %+v %+v", created, txn)
+	}
+
+	updated := <-receiver
+	var balance BalanceUpdate
+	if err := json.Unmarshal(updated.Data, &balance); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Type != UpdateBalanceUpdated || !balance.Balance.Equal(decimal.NewFromInt(2500)) || !balance.Available.Equal(decimal.NewFromInt(2400)) {
+		t.Fatalf("unexpected balance update %+v %+v", updated, balance)
+	}
+
+	if sent := <-sender; sent.Type != UpdateTransactionCreated {
+		t.Fatalf("expected the sender's debit first, got %s", sent.Type)
+	}
+}
+
+func TestWalletStreamDropsForSlowStreams(t *testing.T) {
+	stream := newTestWalletStream()
+	updates, unsubscribe := stream.Subscribe("u1")
+
+	for i := 0; i < 20; i++ {
+		if err := stream.Publish(context.Background(), WalletUpdate{UserID: "u1", Type: UpdateBalanceUpdated}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(updates) != cap(updates) {
+		t.Fatalf("expected the stream to fill up and drop the rest, got %d", len(updates))
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if err := stream.Publish(context.Background(), WalletUpdate{UserID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+var (
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrUnreadableWebhook       = errors.New("unreadable webhook body")
+	ErrWebhookEventNotFound    = errors.New("webhook event not found")
+	ErrWebhookNotReplayable    = errors.New("webhook failed its signature check and can't be replayed")
+)
+
+// webhookEventJob is what goes on WebhookQueue: the id of a stored event.
+type webhookEventJob struct {
+	EventID string `json:"event_id"`
+}
+
+type WebhookService interface {
+	Receive(ctx context.Context, provider string, header http.Header, body []byte) (*db.WebhookEventModel, error)
+	ProcessQueued(ctx context.Context, payload []byte) error
+	ListEvents(ctx context.Context, filter repository.WebhookEventFilter) ([]db.WebhookEventModel, error)
+	GetEvent(ctx context.Context, id string) (*db.WebhookEventModel, error)
+	Replay(ctx context.Context, id string) (*db.WebhookEventModel, error)
+}
+
+type webhookService struct {
+	repo     repository.WebhookRepository
+	payments PaymentService
+	redis    QueueService
+	logger   *slog.Logger
+}
+
+func NewWebhookService(repo repository.WebhookRepository, payments PaymentService, redis QueueService, logger *slog.Logger) WebhookService {
+	return &webhookService{repo: repo, payments: payments, redis: redis, logger: logger}
+}
+
+// Receive stores a delivery, signature check result included, and queues it
+// when it is both verified and new. Repeats of an event that never finished
+// processing are queued again rather than dropped. A delivery that fails the
+// signature check is recorded without its body, which anyone could have sent.
+func (s *webhookService) Receive(ctx context.Context, provider string, header http.Header, body []byte) (*db.WebhookEventModel, error) {
+	if !s.payments.VerifyWebhook(provider, header, body) {
+		stored, err := s.repo.RecordWebhookEvent(ctx, repository.NewWebhookEvent{
+			Provider:  provider,
+			EventType: "unknown",
+			Detail:    fmt.Sprintf("signature check failed; %d-byte body not kept", len(body)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return stored, ErrInvalidWebhookSignature
+	}
+
+	record := repository.NewWebhookEvent{
+		Provider:       provider,
+		EventType:      "unknown",
+		Payload:        body,
+		SignatureValid: true,
+	}
+
+	event, parseErr := s.payments.ParseWebhook(provider, body)
+	if parseErr == nil {
+		record.EventType = event.Name
+		record.Reference = event.Reference
+		record.ProviderEventID = event.ID
+		if record.ProviderEventID == "" {
+			record.ProviderEventID = event.Reference
+		}
+	}
+
+	stored, err := s.repo.RecordWebhookEvent(ctx, record)
+	if errors.Is(err, repository.ErrDuplicateWebhook) {
+		existing, err := s.repo.FindWebhookEvent(ctx, provider, record.EventType, record.ProviderEventID)
+		if err != nil {
+			return nil, err
+		}
+		if existing.Status == db.WebhookEventStatusProcessed {
+			s.logger.Info("ignoring duplicate webhook", "provider", provider, "event", existing.ID)
+			return existing, nil
+		}
+		return existing, s.enqueue(ctx, existing.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if parseErr != nil {
+		_, _ = s.repo.FinishWebhookEvent(ctx, stored.ID, parseErr)
+		return stored, fmt.Errorf("%w: %v", ErrUnreadableWebhook, parseErr)
+	}
+	return stored, s.enqueue(ctx, stored.ID)
+}
+
+func (s *webhookService) enqueue(ctx context.Context, eventID string) error {
+	payload, err := json.Marshal(webhookEventJob{EventID: eventID})
+	if err != nil {
+		return err
+	}
+	return s.redis.Enqueue(ctx, WebhookQueue, payload)
+}
+
+// ProcessQueued handles a WebhookQueue job. Events already processed are
+// skipped, so a job queued twice only takes effect once.
+func (s *webhookService) ProcessQueued(ctx context.Context, payload []byte) error {
+	var job webhookEventJob
+	if err := json.Unmarshal(payload, &job); err != nil || job.EventID == "" {
+		// Jobs queued before events were stored carry the body itself.
+		return s.payments.ProcessWebhookEvent(ctx, payload)
+	}
+
+	event, err := s.repo.GetWebhookEvent(ctx, job.EventID)
+	if errors.Is(err, db.ErrNotFound) {
+		s.logger.Warn("dropping job for missing webhook event", "event", job.EventID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if event.Status == db.WebhookEventStatusProcessed || !event.SignatureValid {
+		return nil
+	}
+
+	_, err = s.process(ctx, event)
+	return err
+}
+
+func (s *webhookService) ListEvents(ctx context.Context, filter repository.WebhookEventFilter) ([]db.WebhookEventModel, error) {
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 20
+	}
+	return s.repo.ListWebhookEvents(ctx, filter)
+}
+
+func (s *webhookService) GetEvent(ctx context.Context, id string) (*db.WebhookEventModel, error) {
+	event, err := s.repo.GetWebhookEvent(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrWebhookEventNotFound
+	}
+	return event, err
+}
+
+// Replay processes a stored event again, whatever its status. The effects are
+// idempotent per reference, so replaying a processed event changes nothing.
+func (s *webhookService) Replay(ctx context.Context, id string) (*db.WebhookEventModel, error) {
+	event, err := s.GetEvent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !event.SignatureValid {
+		return event, ErrWebhookNotReplayable
+	}
+	return s.process(ctx, event)
+}
+
+func (s *webhookService) process(ctx context.Context, event *db.WebhookEventModel) (*db.WebhookEventModel, error) {
+	if _, err := s.repo.StartWebhookAttempt(ctx, event.ID); err != nil {
+		return nil, err
+	}
+
+	job, err := json.Marshal(WebhookJob{Provider: event.Provider, Body: json.RawMessage(event.Payload)})
+	if err != nil {
+		err = fmt.Errorf("stored payload is not valid JSON: %w", err)
+	} else {
+		err = s.payments.ProcessWebhookEvent(ctx, job)
+	}
+
+	updated, finishErr := s.repo.FinishWebhookEvent(ctx, event.ID, err)
+	if err != nil {
+		return updated, err
+	}
+	return updated, finishErr
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway/fakepaystack"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+// fakeWebhookRepo keeps events in memory with the same uniqueness rule as
+// the WebhookEvent table.
+type fakeWebhookRepo struct {
+	events []*db.WebhookEventModel
+}
+
+func (f *fakeWebhookRepo) RecordWebhookEvent(ctx context.Context, e repository.NewWebhookEvent) (*db.WebhookEventModel, error) {
+	if e.SignatureValid && e.ProviderEventID != "" {
+		if _, err := f.FindWebhookEvent(ctx, e.Provider, e.EventType, e.ProviderEventID); err == nil {
+			return nil, repository.ErrDuplicateWebhook
+		}
+	}
+
+	event := &db.WebhookEventModel{InnerWebhookEvent: db.InnerWebhookEvent{
+		ID:             fmt.Sprintf("evt-%d", len(f.events)+1),
+		Provider:       e.Provider,
+		EventType:      e.EventType,
+		Payload:        string(e.Payload),
+		SignatureValid: e.SignatureValid,
+		Status:         db.WebhookEventStatusReceived,
+	}}
+	if e.Detail != "" {
+		detail := e.Detail
+		event.InnerWebhookEvent.LastError = &detail
+	}
+	if !e.SignatureValid {
+		event.Status = db.WebhookEventStatusRejected
+	} else if e.ProviderEventID != "" {
+		id := e.ProviderEventID
+		event.InnerWebhookEvent.ProviderEventID = &id
+	}
+	f.events = append(f.events, event)
+	return event, nil
+}
+
+func (f *fakeWebhookRepo) FindWebhookEvent(ctx context.Context, provider, eventType, providerEventID string) (*db.WebhookEventModel, error) {
+	for _, e := range f.events {
+		if id, ok := e.ProviderEventID(); ok && id == providerEventID && e.Provider == provider && e.EventType == eventType {
+			return e, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
+func (f *fakeWebhookRepo) GetWebhookEvent(ctx context.Context, id string) (*db.WebhookEventModel, error) {
+	for _, e := range f.events {
+		if e.ID == id {
+			return e, nil
+		}
+	}
+	return nil, db.ErrNotFound
+}
+
+func (f *fakeWebhookRepo) ListWebhookEvents(ctx context.Context, filter repository.WebhookEventFilter) ([]db.WebhookEventModel, error) {
+	return nil, nil
+}
+
+func (f *fakeWebhookRepo) StartWebhookAttempt(ctx context.Context, id string) (*db.WebhookEventModel, error) {
+	e, err := f.GetWebhookEvent(ctx, id)
+	if err == nil {
+		e.Attempts++
+	}
+	return e, err
+}
+
+func (f *fakeWebhookRepo) FinishWebhookEvent(ctx context.Context, id string, processErr error) (*db.WebhookEventModel, error) {
+	e, err := f.GetWebhookEvent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	e.Status = db.WebhookEventStatusProcessed
+	if processErr != nil {
+		msg := processErr.Error()
+		e.Status = db.WebhookEventStatusFailed
+		e.InnerWebhookEvent.LastError = &msg
+	}
+	return e, nil
+}
+
+// recordingQueue keeps whatever is enqueued instead of sending it to Redis.
+type recordingQueue struct {
+	QueueService
+	jobs [][]byte
+}
+
+func (q *recordingQueue) Enqueue(ctx context.Context, queueName string, payload []byte) error {
+	q.jobs = append(q.jobs, payload)
+	return nil
+}
+
+func (q *recordingQueue) Delete(ctx context.Context, key string) error { return nil }
+
+func signedPaystackHeader(body []byte) http.Header {
+	header := http.Header{}
+	header.Set("x-paystack-signature", fakepaystack.Sign("sk_test", body))
+	return header
+}
+
+func newTestWebhookService() (*webhookService, *fakeWebhookRepo, *recordingQueue, *fakeLedger) {
+	ledger := &fakeLedger{credits: make(map[string]money.Money)}
+	queue := &recordingQueue{}
+	payments := &paymentService{
+		repo:     ledger,
+		gateways: gateway.NewRegistry(gateway.ProviderPaystack, gateway.NewPaystack("sk_test", "", "")),
+		redis:    queue,
+		userRepo: fakeUsers{},
+	}
+	repo := &fakeWebhookRepo{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return &webhookService{repo: repo, payments: payments, redis: queue, logger: logger}, repo, queue, ledger
+}
+
+func TestReceiveWebhook(t *testing.T) {
+	ctx := context.Background()
+	charge := []byte(`{"event":"charge.success","data":{"id":301,"reference":"dep-1","amount":10000,"currency":"NGN","status":"success","customer":{"email":"a@b.com"}}}`)
+
+	t.Run("Rejects Bad Signature", func(t *testing.T) {
+		svc, repo, queue, _ := newTestWebhookService()
+		header := http.Header{}
+		header.Set("x-paystack-signature", "forged")
+
+		event, err := svc.Receive(ctx, gateway.ProviderPaystack, header, charge)
+		if !errors.Is(err, ErrInvalidWebhookSignature) || event.Status != db.WebhookEventStatusRejected {
+			t.Fatalf("expected a stored REJECTED event, got %+v, %v", event, err)
+		}
+		if len(queue.jobs) != 0 || len(repo.events) != 1 {
+			t.Fatalf("expected nothing queued, got %d jobs", len(queue.jobs))
+		}
+		if detail, _ := event.LastError(); event.Payload != "" || !strings.Contains(detail, fmt.Sprintf("%d-byte", len(charge))) {
+			t.Fatalf("expected only the body's size kept, got %q and %q", event.Payload, detail)
+		}
+
+		// A forged copy must not stop the real delivery from being accepted.
+		if _, err := svc.Receive(ctx, gateway.ProviderPaystack, signedPaystackHeader(charge), charge); err != nil || len(queue.jobs) != 1 {
+			t.Fatalf("expected the signed delivery to be queued, got %v", err)
+		}
+	})
+
+	t.Run("Processes Once", func(t *testing.T) {
+		svc, repo, queue, ledger := newTestWebhookService()
+
+		for i := 0; i < 2; i++ {
+			if _, err := svc.Receive(ctx, gateway.ProviderPaystack, signedPaystackHeader(charge), charge); err != nil {
+				t.Fatal(err)
+			}
+			for len(queue.jobs) > 0 {
+				job := queue.jobs[0]
+				queue.jobs = queue.jobs[1:]
+				if err := svc.ProcessQueued(ctx, job); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		if len(repo.events) != 1 || repo.events[0].Status != db.WebhookEventStatusProcessed || repo.events[0].Attempts != 1 {
+			t.Fatalf("expected one event processed once, got %+v", repo.events)
+		}
+		if len(ledger.credits) != 1 || ledger.repeats != 0 {
+			t.Fatalf("expected a single credit, got %v and %d repeats", ledger.credits, ledger.repeats)
+		}
+	})
+
+	t.Run("Same Reference Different Event", func(t *testing.T) {
+		svc, repo, queue, _ := newTestWebhookService()
+		success := []byte(`{"event":"transfer.success","data":{"id":77,"reference":"wdr-1","transfer_code":"TRF_1"}}`)
+		reversed := []byte(`{"event":"transfer.reversed","data":{"id":77,"reference":"wdr-1","transfer_code":"TRF_1"}}`)
+
+		for _, body := range [][]byte{success, reversed} {
+			if _, err := svc.Receive(ctx, gateway.ProviderPaystack, signedPaystackHeader(body), body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(repo.events) != 2 || len(queue.jobs) != 2 {
+			t.Fatalf("expected both transfer events queued, got %d events and %d jobs", len(repo.events), len(queue.jobs))
+		}
+	})
+}
+
+func TestReplayWebhook(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, _, ledger := newTestWebhookService()
+	body := []byte(`{"event":"charge.success","data":{"id":302,"reference":"dep-2","amount":5000,"currency":"NGN","status":"success","customer":{"email":"a@b.com"}}}`)
+
+	event, err := svc.Receive(ctx, gateway.ProviderPaystack, signedPaystackHeader(body), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := svc.Replay(ctx, event.ID)
+	if err != nil || replayed.Status != db.WebhookEventStatusProcessed {
+		t.Fatalf("expected the replay to process the event, got %+v, %v", replayed, err)
+	}
+	if _, ok := ledger.credits["dep-2"]; !ok {
+		t.Fatal("expected the replay to credit dep-2")
+	}
+
+	forged, _ := svc.Receive(ctx, gateway.ProviderPaystack, http.Header{}, body)
+	if _, err := svc.Replay(ctx, forged.ID); !errors.Is(err, ErrWebhookNotReplayable) {
+		t.Fatalf("expected ErrWebhookNotReplayable, got %v", err)
+	}
+	if _, err := svc.Replay(ctx, "missing"); !errors.Is(err, ErrWebhookEventNotFound) {
+		t.Fatalf("expected ErrWebhookEventNotFound, got %v", err)
+	}
+	if len(repo.events) != 2 {
+		t.Fatalf("expected both deliveries stored, got %d", len(repo.events))
+	}
+}
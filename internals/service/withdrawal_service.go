@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
+	"github.com/theabdullahishola/mzl-payment-app/internals/money"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+var (
+	ErrInvalidWithdrawalReference = errors.New("Idempotency-Key must be 16 to 50 lowercase letters, digits, '-' or '_'")
+	ErrWithdrawalNotFound         = errors.New("withdrawal not found")
+)
+
+const (
+	// WithdrawalQueue carries {"reference": ...} jobs that start gateway payouts.
+	WithdrawalQueue = "withdrawals"
+
+	// maxWithdrawalAttempts is how often a transfer is attempted before the
+	// withdrawal is failed and its hold released.
+	maxWithdrawalAttempts = 3
+	stuckWithdrawalBatch  = 100
+)
+
+// Paystack only accepts these as transfer references, and Flutterwave is
+// happy with them too.
+var withdrawalReference = regexp.MustCompile(`^[a-z0-9_-]{16,50}$`)
+
+type WithdrawalRequest struct {
+	WalletID      string          `json:"wallet_id"` // optional, the default wallet otherwise
+	Amount        decimal.Decimal `json:"amount"`
+	AccountNumber string          `json:"account_number"`
+	AccountName   string          `json:"account_name"`
+	BankCode      string          `json:"bank_code"`
+	Currency      string          `json:"currency"`
+	Pin           string          `json:"pin"`
+	Reason        string          `json:"reason"`
+	Provider      string          `json:"provider"` // optional, the default gateway otherwise
+}
+
+type withdrawalJob struct {
+	Reference string `json:"reference"`
+}
+
+type WithdrawalService interface {
+	RequestWithdrawal(ctx context.Context, userID, reference string, req WithdrawalRequest) (*db.WithdrawalModel, error)
+	GetWithdrawal(ctx context.Context, userID, reference string) (*db.WithdrawalModel, error)
+	ProcessWithdrawal(ctx context.Context, payload []byte) error
+	ResolveStuckWithdrawals(ctx context.Context, stuckAfter time.Duration) (int, error)
+	StartPoller(ctx context.Context, interval, stuckAfter time.Duration)
+}
+
+type withdrawalService struct {
+	repo     repository.WalletRepository
+	gateways *gateway.Registry
+	redis    QueueService
+	logger   *slog.Logger
+}
+
+func NewWithdrawalService(repo repository.WalletRepository, gateways *gateway.Registry, redis QueueService, logger *slog.Logger) WithdrawalService {
+	return &withdrawalService{repo: repo, gateways: gateways, redis: redis, logger: logger}
+}
+
+// RequestWithdrawal holds the funds, records the withdrawal as PENDING and
+// queues the payout. Repeating a request with the same reference
+// returns the withdrawal created the first time.
+func (s *withdrawalService) RequestWithdrawal(ctx context.Context, userID, reference string, req WithdrawalRequest) (*db.WithdrawalModel, error) {
+	if !withdrawalReference.MatchString(reference) {
+		return nil, ErrInvalidWithdrawalReference
+	}
+
+	amount, err := money.New(req.Amount, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	gw, err := s.gateways.Get(req.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetWithdrawal(ctx, reference)
+	if err == nil {
+		if existing.Wallet().UserID != userID || existing.Currency != amount.Currency() || !existing.Amount.Equal(amount.Amount()) {
+			return nil, ErrTransactionAlreadyProcessed
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, db.ErrNotFound) {
+		return nil, err
+	}
+
+	withdrawal, err := s.repo.CreateWithdrawal(ctx, userID, repository.NewWithdrawal{
+		WalletID:      req.WalletID,
+		Provider:      gw.Name(),
+		Amount:        amount,
+		Reference:     reference,
+		AccountNumber: req.AccountNumber,
+		AccountName:   req.AccountName,
+		BankCode:      req.BankCode,
+		Reason:        req.Reason,
+	})
+	if err != nil {
+		if _, ok := db.IsErrUniqueConstraint(err); ok {
+			return nil, ErrTransactionAlreadyProcessed
+		}
+		return nil, err
+	}
+
+	_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", userID))
+	_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", userID))
+
+	// If this fails the poller finds the withdrawal still PENDING and queues it.
+	if err := s.enqueue(ctx, reference); err != nil {
+		s.logger.Warn("failed to queue withdrawal", "reference", reference, "error", err)
+	}
+	return withdrawal, nil
+}
+
+func (s *withdrawalService) GetWithdrawal(ctx context.Context, userID, reference string) (*db.WithdrawalModel, error) {
+	withdrawal, err := s.repo.GetWithdrawal(ctx, reference)
+	if errors.Is(err, db.ErrNotFound) || (err == nil && withdrawal.Wallet().UserID != userID) {
+		return nil, ErrWithdrawalNotFound
+	}
+	return withdrawal, err
+}
+
+func (s *withdrawalService) enqueue(ctx context.Context, reference string) error {
+	payload, err := json.Marshal(withdrawalJob{Reference: reference})
+	if err != nil {
+		return err
+	}
+	return s.redis.Enqueue(ctx, WithdrawalQueue, payload)
+}
+
+// ProcessWithdrawal starts the payout for a queued withdrawal. It is safe to
+// run more than once: the gateway refuses a second transfer with the same
+// reference, and a transfer that already exists is looked up instead.
+// Returning an error puts the job back on the queue.
+func (s *withdrawalService) ProcessWithdrawal(ctx context.Context, payload []byte) error {
+	var job withdrawalJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return fmt.Errorf("failed to parse withdrawal job: %w", err)
+	}
+
+	w, err := s.repo.StartWithdrawalAttempt(ctx, job.Reference)
+	if errors.Is(err, repository.ErrWithdrawalSettled) || errors.Is(err, db.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, started := w.TransferCode(); started {
+		// Waiting on the transfer.* webhook or the poller.
+		return nil
+	}
+
+	amount, err := money.New(w.Amount, w.Currency)
+	if err != nil {
+		return err
+	}
+	gw, err := s.gateways.Get(w.Provider)
+	if err != nil {
+		return err
+	}
+
+	recipientCode, ok := w.RecipientCode()
+	if !ok {
+		recipientCode, err = gw.CreateRecipient(ctx, gateway.Recipient{
+			Name:          w.AccountName,
+			AccountNumber: w.AccountNumber,
+			BankCode:      w.BankCode,
+			Currency:      w.Currency,
+		})
+		if err != nil {
+			return s.attemptFailed(ctx, w, fmt.Errorf("failed to create recipient: %w", err))
+		}
+		if err := s.repo.SetWithdrawalRecipient(ctx, w.Reference, recipientCode); err != nil {
+			return err
+		}
+	}
+
+	reason, _ := w.Reason()
+	transfer, err := gw.Payout(ctx, gateway.PayoutRequest{
+		Amount:        amount,
+		RecipientCode: recipientCode,
+		Reference:     w.Reference,
+		Reason:        reason,
+	})
+	if err != nil {
+		// The transfer may exist even though its response never reached us.
+		existing, verifyErr := gw.VerifyPayout(ctx, w.Reference, "")
+		switch {
+		case errors.Is(verifyErr, gateway.ErrNotFound):
+			return s.attemptFailed(ctx, w, fmt.Errorf("failed to initiate transfer: %w", err))
+		case verifyErr != nil:
+			// We can't tell whether money moved; leave it for a retry or the poller.
+			_ = s.repo.RecordWithdrawalError(ctx, w.Reference, err.Error())
+			return fmt.Errorf("failed to initiate transfer: %w", err)
+		}
+		transfer = existing
+	}
+
+	if err := s.repo.SetWithdrawalTransfer(ctx, w.Reference, transfer.Code); err != nil {
+		return err
+	}
+	return s.applyTransferStatus(ctx, w, transfer)
+}
+
+// attemptFailed records a failure that happened before any transfer existed.
+// Once the attempts run out the withdrawal is failed and the funds released.
+func (s *withdrawalService) attemptFailed(ctx context.Context, w *db.WithdrawalModel, cause error) error {
+	_ = s.repo.RecordWithdrawalError(ctx, w.Reference, cause.Error())
+	if w.Attempts < maxWithdrawalAttempts {
+		return cause
+	}
+
+	s.logger.Warn("withdrawal failed", "reference", w.Reference, "attempts", w.Attempts, "error", cause)
+	if err := s.repo.ReleaseWithdrawal(ctx, w.Reference, "", db.WithdrawalStatusFailed); err != nil {
+		return err
+	}
+	s.invalidateWallet(ctx, w)
+	return nil
+}
+
+// applyTransferStatus settles the withdrawal once the gateway reports a final
+// status for its transfer. Anything else leaves it PROCESSING.
+func (s *withdrawalService) applyTransferStatus(ctx context.Context, w *db.WithdrawalModel, transfer *gateway.Transfer) error {
+	var err error
+	switch transfer.Status {
+	case gateway.StatusSuccess:
+		err = s.repo.CaptureWithdrawal(ctx, w.Reference, transfer.Code)
+	case gateway.StatusFailed:
+		err = s.repo.ReleaseWithdrawal(ctx, w.Reference, transfer.Code, db.WithdrawalStatusFailed)
+	case gateway.StatusReversed:
+		err = s.repo.ReleaseWithdrawal(ctx, w.Reference, transfer.Code, db.WithdrawalStatusReversed)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.invalidateWallet(ctx, w)
+	return nil
+}
+
+// ResolveStuckWithdrawals asks the gateway about every withdrawal left
+// unfinished for longer than stuckAfter. Finished transfers are settled;
+// withdrawals the gateway has never seen are queued again.
+func (s *withdrawalService) ResolveStuckWithdrawals(ctx context.Context, stuckAfter time.Duration) (int, error) {
+	stuck, err := s.repo.ListStuckWithdrawals(ctx, time.Now().Add(-stuckAfter), stuckWithdrawalBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	resolved := 0
+	for i := range stuck {
+		w := &stuck[i]
+		gw, err := s.gateways.Get(w.Provider)
+		if err != nil {
+			s.logger.Warn("failed to verify withdrawal", "reference", w.Reference, "error", err)
+			continue
+		}
+		code, _ := w.TransferCode()
+		verified, err := gw.VerifyPayout(ctx, w.Reference, code)
+		if errors.Is(err, gateway.ErrNotFound) {
+			if err := s.enqueue(ctx, w.Reference); err != nil {
+				s.logger.Warn("failed to requeue withdrawal", "reference", w.Reference, "error", err)
+			}
+			continue
+		}
+		if err != nil {
+			s.logger.Warn("failed to verify withdrawal", "reference", w.Reference, "error", err)
+			continue
+		}
+
+		if code == "" && verified.Code != "" {
+			if err := s.repo.SetWithdrawalTransfer(ctx, w.Reference, verified.Code); err != nil {
+				return resolved, err
+			}
+		}
+		if err := s.applyTransferStatus(ctx, w, verified); err != nil {
+			s.logger.Error("failed to settle withdrawal", "reference", w.Reference, "error", err)
+			continue
+		}
+		resolved++
+	}
+	return resolved, nil
+}
+
+// StartPoller resolves stuck withdrawals every interval until ctx is
+// cancelled. A non-positive interval disables it.
+func (s *withdrawalService) StartPoller(ctx context.Context, interval, stuckAfter time.Duration) {
+	if interval <= 0 {
+		s.logger.Info("withdrawal poller disabled")
+		return
+	}
+
+	s.logger.Info("withdrawal poller scheduled", "interval", interval.String(), "stuck_after", stuckAfter.String())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ResolveStuckWithdrawals(ctx, stuckAfter); err != nil {
+				s.logger.Error("withdrawal poller failed", "error", err)
+			}
+		}
+	}
+}
+
+// invalidateWallet drops the owner's cached wallet and history, loading the
+// wallet first if w came without it.
+func (s *withdrawalService) invalidateWallet(ctx context.Context, w *db.WithdrawalModel) {
+	wallet := w.RelationsWithdrawal.Wallet
+	if wallet == nil {
+		loaded, err := s.repo.GetWithdrawal(ctx, w.Reference)
+		if err != nil || loaded.RelationsWithdrawal.Wallet == nil {
+			s.logger.Warn("failed to load wallet to invalidate", "reference", w.Reference, "error", err)
+			return
+		}
+		wallet = loaded.RelationsWithdrawal.Wallet
+	}
+	_ = s.redis.Delete(ctx, fmt.Sprintf("wallet:%s", wallet.UserID))
+	_ = s.redis.Delete(ctx, fmt.Sprintf("tx_history:%s", wallet.UserID))
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/theabdullahishola/mzl-payment-app/internals/gateway"
+	"github.com/theabdullahishola/mzl-payment-app/internals/repository"
+	"github.com/theabdullahishola/mzl-payment-app/prisma/db"
+)
+
+type fakeWithdrawalRepo struct {
+	repository.WalletRepository
+	withdrawal   *db.WithdrawalModel
+	transferCode string
+	captured     bool
+	released     db.WithdrawalStatus
+}
+
+func (f *fakeWithdrawalRepo) StartWithdrawalAttempt(ctx context.Context, reference string) (*db.WithdrawalModel, error) {
+	f.withdrawal.Attempts++
+	return f.withdrawal, nil
+}
+
+func (f *fakeWithdrawalRepo) SetWithdrawalRecipient(ctx context.Context, reference, recipientCode string) error {
+	f.withdrawal.InnerWithdrawal.RecipientCode = &recipientCode
+	return nil
+}
+
+func (f *fakeWithdrawalRepo) SetWithdrawalTransfer(ctx context.Context, reference, transferCode string) error {
+	f.transferCode = transferCode
+	return nil
+}
+
+func (f *fakeWithdrawalRepo) RecordWithdrawalError(ctx context.Context, reference, message string) error {
+	f.withdrawal.InnerWithdrawal.LastError = &message
+	return nil
+}
+
+func (f *fakeWithdrawalRepo) CaptureWithdrawal(ctx context.Context, reference, transferCode string) error {
+	f.captured = true
+	return nil
+}
+
+func (f *fakeWithdrawalRepo) ReleaseWithdrawal(ctx context.Context, reference, transferCode string, outcome db.WithdrawalStatus) error {
+	f.released = outcome
+	return nil
+}
+
+func (f *fakeWithdrawalRepo) GetWithdrawal(ctx context.Context, reference string) (*db.WithdrawalModel, error) {
+	withdrawal := *f.withdrawal
+	withdrawal.RelationsWithdrawal.Wallet = &db.WalletModel{InnerWallet: db.InnerWallet{ID: "w1", UserID: "u1"}}
+	return &withdrawal, nil
+}
+
+func (f *fakeWithdrawalRepo) ListStuckWithdrawals(ctx context.Context, before time.Time, limit int) ([]db.WithdrawalModel, error) {
+	return []db.WithdrawalModel{*f.withdrawal}, nil
+}
+
+func (f *fakeWithdrawalRepo) GetTransactionByReference(ctx context.Context, reference string) (*db.TransactionModel, error) {
+	return nil, db.ErrNotFound
+}
+
+// fakeTransfers is the Paystack adapter with recipient and payout calls
+// stubbed; VerifyPayout still goes to the fake Paystack server.
+type fakeTransfers struct {
+	*gateway.Paystack
+	recipientErr error
+	transferErr  error
+	initiated    int
+}
+
+func (f *fakeTransfers) CreateRecipient(ctx context.Context, recipient gateway.Recipient) (string, error) {
+	return "RCP_1", f.recipientErr
+}
+
+func (f *fakeTransfers) Payout(ctx context.Context, req gateway.PayoutRequest) (*gateway.Transfer, error) {
+	f.initiated++
+	if f.transferErr != nil {
+		return nil, f.transferErr
+	}
+	return &gateway.Transfer{Reference: req.Reference, Code: "TRF_NEW", Status: gateway.StatusPending, Amount: req.Amount}, nil
+}
+
+func withdrawalServiceWith(repo repository.WalletRepository, transfers *fakeTransfers, logger *slog.Logger) *withdrawalService {
+	gateways := gateway.NewRegistry(gateway.ProviderPaystack, transfers)
+	return &withdrawalService{repo: repo, gateways: gateways, redis: fakeCache{}, logger: logger}
+}
+
+func pendingWithdrawal(attempts int) *db.WithdrawalModel {
+	return &db.WithdrawalModel{InnerWithdrawal: db.InnerWithdrawal{
+		Reference: "wdr-0001-0002-0003",
+		Amount:    decimal.RequireFromString("50"),
+		Currency:  "NGN",
+		Status:    db.WithdrawalStatusPending,
+		Attempts:  attempts,
+	}}
+}
+
+func TestProcessWithdrawal(t *testing.T) {
+	// A fake Paystack that already has the transfer for our reference.
+	paystack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transfer/verify/wdr-0001-0002-0003" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": true,
+			"data":   map[string]interface{}{"reference": "wdr-0001-0002-0003", "transfer_code": "TRF_EXISTING", "status": "success"},
+		})
+	}))
+	defer paystack.Close()
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	payload, _ := json.Marshal(withdrawalJob{Reference: "wdr-0001-0002-0003"})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("Starts Transfer", func(t *testing.T) {
+		repo := &fakeWithdrawalRepo{withdrawal: pendingWithdrawal(0)}
+		transfers := &fakeTransfers{Paystack: gateway.NewPaystack("sk_test", missing.URL, "")}
+		svc := withdrawalServiceWith(repo, transfers, logger)
+
+		if err := svc.ProcessWithdrawal(context.Background(), payload); err != nil {
+			t.Fatal(err)
+		}
+		if repo.transferCode != "TRF_NEW" || repo.captured || repo.released != "" {
+			t.Fatalf("expected a pending transfer TRF_NEW, got %+v", repo)
+		}
+	})
+
+	t.Run("Adopts Existing Transfer", func(t *testing.T) {
+		repo := &fakeWithdrawalRepo{withdrawal: pendingWithdrawal(0)}
+		transfers := &fakeTransfers{Paystack: gateway.NewPaystack("sk_test", paystack.URL, ""), transferErr: errors.New("timeout")}
+		svc := withdrawalServiceWith(repo, transfers, logger)
+
+		if err := svc.ProcessWithdrawal(context.Background(), payload); err != nil {
+			t.Fatal(err)
+		}
+		if repo.transferCode != "TRF_EXISTING" || !repo.captured {
+			t.Fatalf("expected TRF_EXISTING to be captured, got %+v", repo)
+		}
+	})
+
+	t.Run("Retries Then Fails", func(t *testing.T) {
+		repo := &fakeWithdrawalRepo{withdrawal: pendingWithdrawal(0)}
+		transfers := &fakeTransfers{Paystack: gateway.NewPaystack("sk_test", missing.URL, ""), recipientErr: errors.New("invalid account")}
+		svc := withdrawalServiceWith(repo, transfers, logger)
+
+		for attempt := 1; attempt < maxWithdrawalAttempts; attempt++ {
+			if err := svc.ProcessWithdrawal(context.Background(), payload); err == nil {
+				t.Fatalf("attempt %d: expected an error so the job is retried", attempt)
+			}
+		}
+		if err := svc.ProcessWithdrawal(context.Background(), payload); err != nil {
+			t.Fatalf("expected the last attempt to settle the withdrawal, got %v", err)
+		}
+		if repo.released != db.WithdrawalStatusFailed || transfers.initiated != 0 {
+			t.Fatalf("expected the hold released without any transfer, got %+v", repo)
+		}
+	})
+}
+
+func TestResolveStuckWithdrawalsDropsCaches(t *testing.T) {
+	paystack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": true,
+			"data":   map[string]interface{}{"reference": "wdr-0001-0002-0003", "transfer_code": "TRF_1", "status": "success"},
+		})
+	}))
+	defer paystack.Close()
+
+	// The withdrawal comes without its wallet, as the poller might load it.
+	withdrawal := pendingWithdrawal(1)
+	withdrawal.Status = db.WithdrawalStatusProcessing
+	repo := &fakeWithdrawalRepo{withdrawal: withdrawal}
+	queue := newJSONQueue()
+	gateways := gateway.NewRegistry(gateway.ProviderPaystack, gateway.NewPaystack("sk_test", paystack.URL, ""))
+	svc := &withdrawalService{repo: repo, gateways: gateways, redis: queue, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	resolved, err := svc.ResolveStuckWithdrawals(context.Background(), time.Minute)
+	if err != nil || resolved != 1 || !repo.captured {
+		t.Fatalf("expected the withdrawal captured, got %d, %v", resolved, err)
+	}
+	if len(queue.deleted) != 2 || queue.deleted[0] != "wallet:u1" || queue.deleted[1] != "tx_history:u1" {
+		t.Fatalf("expected the owner's caches dropped, got %v", queue.deleted)
+	}
+}
+
+func TestFlutterwavePayoutFailureReleasesHold(t *testing.T) {
+	// A fake Flutterwave that refuses the transfer and so has none on record.
+	var listed int
+	flutterwave := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/beneficiaries":
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": map[string]interface{}{"id": 42}})
+		case r.Method == "POST" && r.URL.Path == "/transfers":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "error", "message": "insufficient balance"})
+		case r.Method == "GET" && r.URL.Path == "/transfers" && r.URL.Query().Get("reference") == "wdr-0001-0002-0003":
+			listed++
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": []interface{}{}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer flutterwave.Close()
+
+	withdrawal := pendingWithdrawal(0)
+	withdrawal.Provider = gateway.ProviderFlutterwave
+	repo := &fakeWithdrawalRepo{withdrawal: withdrawal}
+	gateways := gateway.NewRegistry(gateway.ProviderPaystack, gateway.NewFlutterwave("FLWSECK_TEST", "", flutterwave.URL, ""))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := &withdrawalService{repo: repo, gateways: gateways, redis: fakeCache{}, logger: logger}
+
+	payload, _ := json.Marshal(withdrawalJob{Reference: "wdr-0001-0002-0003"})
+	for attempt := 1; attempt < maxWithdrawalAttempts; attempt++ {
+		if err := svc.ProcessWithdrawal(context.Background(), payload); err == nil {
+			t.Fatalf("attempt %d: expected an error so the job is retried", attempt)
+		}
+	}
+	if err := svc.ProcessWithdrawal(context.Background(), payload); err != nil {
+		t.Fatalf("expected the last attempt to settle the withdrawal, got %v", err)
+	}
+	if repo.released != db.WithdrawalStatusFailed || repo.transferCode != "" || listed != maxWithdrawalAttempts {
+		t.Fatalf("expected the hold released once no transfer was found, got %+v after %d lookups", repo, listed)
+	}
+}
+
+func TestRequestWithdrawalRejectsBadReference(t *testing.T) {
+	svc := &withdrawalService{gateways: gateway.NewRegistry(gateway.ProviderPaystack)}
+	_, err := svc.RequestWithdrawal(context.Background(), "u1", "WDR 1", WithdrawalRequest{Amount: decimal.NewFromInt(10), Currency: "NGN"})
+	if !errors.Is(err, ErrInvalidWithdrawalReference) {
+		t.Fatalf("expected ErrInvalidWithdrawalReference, got %v", err)
+	}
+}
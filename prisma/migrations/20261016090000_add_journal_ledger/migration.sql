@@ -0,0 +1,64 @@
+-- CreateEnum
+CREATE TYPE "PostingDirection" AS ENUM ('DEBIT', 'CREDIT');
+
+-- CreateEnum
+CREATE TYPE "LedgerAccountType" AS ENUM ('WALLET', 'SYSTEM');
+
+-- CreateTable
+CREATE TABLE "JournalEntry" (
+    "id" TEXT NOT NULL,
+    "reference" TEXT NOT NULL,
+    "description" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "JournalEntry_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "Posting" (
+    "id" TEXT NOT NULL,
+    "entryId" TEXT NOT NULL,
+    "account" TEXT NOT NULL,
+    "accountType" "LedgerAccountType" NOT NULL,
+    "currency" TEXT NOT NULL,
+    "direction" "PostingDirection" NOT NULL,
+    "amount" DOUBLE PRECISION NOT NULL,
+    "walletId" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "Posting_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "JournalEntry_reference_key" ON "JournalEntry"("reference");
+
+-- CreateIndex
+CREATE INDEX "Posting_account_currency_idx" ON "Posting"("account", "currency");
+
+-- CreateIndex
+CREATE INDEX "Posting_walletId_currency_idx" ON "Posting"("walletId", "currency");
+
+-- AddForeignKey
+ALTER TABLE "Posting" ADD CONSTRAINT "Posting_entryId_fkey" FOREIGN KEY ("entryId") REFERENCES "JournalEntry"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "Posting" ADD CONSTRAINT "Posting_walletId_fkey" FOREIGN KEY ("walletId") REFERENCES "Wallet"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+ALTER TABLE "Posting" ADD CONSTRAINT "positive_posting_amount" CHECK (amount > 0);
+
+-- Open the journal with the balances that existed before it, so wallet
+-- accounts in the ledger agree with WalletAsset.balance from day one.
+INSERT INTO "JournalEntry" ("id", "reference", "description")
+SELECT gen_random_uuid()::text, 'OPENING-' || a."id", 'Opening balance'
+FROM "WalletAsset" a
+WHERE a."balance" > 0;
+
+INSERT INTO "Posting" ("id", "entryId", "account", "accountType", "currency", "direction", "amount", "walletId")
+SELECT gen_random_uuid()::text, e."id", 'SYSTEM:SUSPENSE', 'SYSTEM', a."currency", 'DEBIT', a."balance", NULL
+FROM "WalletAsset" a
+JOIN "JournalEntry" e ON e."reference" = 'OPENING-' || a."id";
+
+INSERT INTO "Posting" ("id", "entryId", "account", "accountType", "currency", "direction", "amount", "walletId")
+SELECT gen_random_uuid()::text, e."id", 'WALLET:' || a."walletId", 'WALLET', a."currency", 'CREDIT', a."balance", a."walletId"
+FROM "WalletAsset" a
+JOIN "JournalEntry" e ON e."reference" = 'OPENING-' || a."id";
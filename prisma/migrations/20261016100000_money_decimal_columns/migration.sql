@@ -0,0 +1,34 @@
+-- Move money columns off DOUBLE PRECISION. Existing values are rounded to the
+-- minor unit of their currency, matching money.Precision in Go:
+-- 0 decimals for JPY/KRW/UGX/XAF/XOF, 3 for BHD/JOD/KWD/OMR/TND, 2 otherwise.
+-- Halves go to the even unit as money.Round does; Postgres ROUND would take
+-- them away from zero.
+CREATE FUNCTION "currency_precision"(currency TEXT) RETURNS INTEGER AS $$
+    SELECT CASE
+        WHEN currency IN ('JPY', 'KRW', 'UGX', 'XAF', 'XOF') THEN 0
+        WHEN currency IN ('BHD', 'JOD', 'KWD', 'OMR', 'TND') THEN 3
+        ELSE 2
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE FUNCTION "round_half_even"(value NUMERIC, places INTEGER) RETURNS NUMERIC AS $$
+    SELECT CASE
+        WHEN MOD(ABS(value * POWER(10::numeric, places)), 1) = 0.5
+            THEN ROUND(2 * ROUND(value * POWER(10::numeric, places) / 2) / POWER(10::numeric, places), places)
+        ELSE ROUND(value, places)
+    END
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- AlterTable
+ALTER TABLE "WalletAsset" ALTER COLUMN "balance" DROP DEFAULT;
+ALTER TABLE "WalletAsset" ALTER COLUMN "balance" SET DATA TYPE DECIMAL(20,4)
+    USING "round_half_even"("balance"::numeric, "currency_precision"("currency"));
+ALTER TABLE "WalletAsset" ALTER COLUMN "balance" SET DEFAULT 0;
+
+-- AlterTable
+ALTER TABLE "Transaction" ALTER COLUMN "amount" SET DATA TYPE DECIMAL(20,4)
+    USING "round_half_even"("amount"::numeric, "currency_precision"("currency"));
+
+-- AlterTable
+ALTER TABLE "Posting" ALTER COLUMN "amount" SET DATA TYPE DECIMAL(20,4)
+    USING "round_half_even"("amount"::numeric, "currency_precision"("currency"));
@@ -0,0 +1,50 @@
+-- CreateEnum
+CREATE TYPE "ReconciliationKind" AS ENUM ('BALANCE');
+
+-- CreateEnum
+CREATE TYPE "ReconciliationStatus" AS ENUM ('RUNNING', 'COMPLETED', 'FAILED');
+
+-- AlterTable
+ALTER TABLE "Wallet" ADD COLUMN     "frozen" BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN     "frozenReason" TEXT;
+
+-- CreateTable
+CREATE TABLE "ReconciliationRun" (
+    "id" TEXT NOT NULL,
+    "kind" "ReconciliationKind" NOT NULL,
+    "status" "ReconciliationStatus" NOT NULL DEFAULT 'RUNNING',
+    "trigger" TEXT NOT NULL,
+    "checked" INTEGER NOT NULL DEFAULT 0,
+    "mismatches" INTEGER NOT NULL DEFAULT 0,
+    "error" TEXT,
+    "startedAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "finishedAt" TIMESTAMP(3),
+
+    CONSTRAINT "ReconciliationRun_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateTable
+CREATE TABLE "ReconciliationItem" (
+    "id" TEXT NOT NULL,
+    "runId" TEXT NOT NULL,
+    "issue" TEXT NOT NULL,
+    "walletId" TEXT,
+    "currency" TEXT,
+    "reference" TEXT,
+    "expected" DECIMAL(20,4),
+    "actual" DECIMAL(20,4),
+    "detail" TEXT,
+    "action" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "ReconciliationItem_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "ReconciliationRun_kind_startedAt_idx" ON "ReconciliationRun"("kind", "startedAt");
+
+-- CreateIndex
+CREATE INDEX "ReconciliationItem_runId_idx" ON "ReconciliationItem"("runId");
+
+-- AddForeignKey
+ALTER TABLE "ReconciliationItem" ADD CONSTRAINT "ReconciliationItem_runId_fkey" FOREIGN KEY ("runId") REFERENCES "ReconciliationRun"("id") ON DELETE CASCADE ON UPDATE CASCADE;
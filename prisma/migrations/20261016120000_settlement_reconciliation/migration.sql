@@ -0,0 +1,2 @@
+-- AlterEnum
+ALTER TYPE "ReconciliationKind" ADD VALUE 'SETTLEMENT';
@@ -0,0 +1,41 @@
+-- AlterEnum
+ALTER TYPE "TransactionType" ADD VALUE 'CHARGEBACK';
+
+-- CreateEnum
+CREATE TYPE "HoldReason" AS ENUM ('WITHDRAWAL', 'DISPUTE', 'REVIEW');
+
+-- CreateEnum
+CREATE TYPE "HoldStatus" AS ENUM ('ACTIVE', 'CAPTURED', 'RELEASED');
+
+-- AlterTable
+ALTER TABLE "WalletAsset" ADD COLUMN     "heldBalance" DECIMAL(20,4) NOT NULL DEFAULT 0;
+
+-- CreateTable
+CREATE TABLE "FundHold" (
+    "id" TEXT NOT NULL,
+    "assetId" TEXT NOT NULL,
+    "amount" DECIMAL(20,4) NOT NULL,
+    "currency" TEXT NOT NULL,
+    "reason" "HoldReason" NOT NULL,
+    "status" "HoldStatus" NOT NULL DEFAULT 'ACTIVE',
+    "reference" TEXT NOT NULL,
+    "description" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "resolvedAt" TIMESTAMP(3),
+
+    CONSTRAINT "FundHold_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "FundHold_reference_key" ON "FundHold"("reference");
+
+-- CreateIndex
+CREATE INDEX "FundHold_assetId_status_idx" ON "FundHold"("assetId", "status");
+
+-- AddForeignKey
+ALTER TABLE "FundHold" ADD CONSTRAINT "FundHold_assetId_fkey" FOREIGN KEY ("assetId") REFERENCES "WalletAsset"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- Holds can never reserve more than the ledger balance.
+ALTER TABLE "WalletAsset" ADD CONSTRAINT "non_negative_held_balance" CHECK ("heldBalance" >= 0);
+ALTER TABLE "WalletAsset" ADD CONSTRAINT "non_negative_available_balance" CHECK ("balance" - "heldBalance" >= 0);
+ALTER TABLE "FundHold" ADD CONSTRAINT "positive_hold_amount" CHECK ("amount" > 0);
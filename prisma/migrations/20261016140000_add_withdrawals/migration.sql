@@ -0,0 +1,35 @@
+-- CreateEnum
+CREATE TYPE "WithdrawalStatus" AS ENUM ('PENDING', 'PROCESSING', 'SUCCESS', 'FAILED', 'REVERSED');
+
+-- CreateTable
+CREATE TABLE "Withdrawal" (
+    "id" TEXT NOT NULL,
+    "walletId" TEXT NOT NULL,
+    "reference" TEXT NOT NULL,
+    "amount" DECIMAL(20,4) NOT NULL,
+    "currency" TEXT NOT NULL,
+    "status" "WithdrawalStatus" NOT NULL DEFAULT 'PENDING',
+    "accountNumber" TEXT NOT NULL,
+    "accountName" TEXT NOT NULL,
+    "bankCode" TEXT NOT NULL,
+    "reason" TEXT,
+    "recipientCode" TEXT,
+    "transferCode" TEXT,
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "lastError" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updatedAt" TIMESTAMP(3) NOT NULL,
+
+    CONSTRAINT "Withdrawal_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "Withdrawal_reference_key" ON "Withdrawal"("reference");
+
+-- CreateIndex
+CREATE INDEX "Withdrawal_status_updatedAt_idx" ON "Withdrawal"("status", "updatedAt");
+
+-- AddForeignKey
+ALTER TABLE "Withdrawal" ADD CONSTRAINT "Withdrawal_walletId_fkey" FOREIGN KEY ("walletId") REFERENCES "Wallet"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE "Withdrawal" ADD CONSTRAINT "positive_withdrawal_amount" CHECK ("amount" > 0);
@@ -0,0 +1,2 @@
+-- AlterTable
+ALTER TABLE "Withdrawal" ADD COLUMN     "provider" TEXT NOT NULL DEFAULT 'PAYSTACK';
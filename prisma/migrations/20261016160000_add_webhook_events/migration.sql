@@ -0,0 +1,29 @@
+-- CreateEnum
+CREATE TYPE "WebhookEventStatus" AS ENUM ('RECEIVED', 'PROCESSED', 'FAILED', 'REJECTED');
+
+-- CreateTable
+CREATE TABLE "WebhookEvent" (
+    "id" TEXT NOT NULL,
+    "provider" TEXT NOT NULL,
+    "eventType" TEXT NOT NULL,
+    "providerEventId" TEXT,
+    "reference" TEXT,
+    "payload" TEXT NOT NULL,
+    "signatureValid" BOOLEAN NOT NULL,
+    "status" "WebhookEventStatus" NOT NULL DEFAULT 'RECEIVED',
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "lastError" TEXT,
+    "receivedAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "processedAt" TIMESTAMP(3),
+
+    CONSTRAINT "WebhookEvent_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "WebhookEvent_provider_eventType_providerEventId_key" ON "WebhookEvent"("provider", "eventType", "providerEventId");
+
+-- CreateIndex
+CREATE INDEX "WebhookEvent_status_receivedAt_idx" ON "WebhookEvent"("status", "receivedAt");
+
+-- CreateIndex
+CREATE INDEX "WebhookEvent_reference_idx" ON "WebhookEvent"("reference");
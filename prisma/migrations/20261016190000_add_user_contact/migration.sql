@@ -0,0 +1,4 @@
+-- AlterTable
+ALTER TABLE "User" ADD COLUMN     "phone" TEXT,
+ADD COLUMN     "pushToken" TEXT,
+ADD COLUMN     "locale" TEXT NOT NULL DEFAULT 'en';
@@ -0,0 +1,19 @@
+-- CreateEnum
+CREATE TYPE "TransactionDirection" AS ENUM ('CREDIT', 'DEBIT');
+
+-- AlterTable
+ALTER TABLE "Transaction" ADD COLUMN "direction" "TransactionDirection";
+
+-- Backfill: transfers and swaps are recorded as a pair told apart by their
+-- reference suffix.
+UPDATE "Transaction" SET "direction" = CASE
+    WHEN "type" = 'DEPOSIT' THEN 'CREDIT'
+    WHEN "type" IN ('WITHDRAWAL', 'CHARGEBACK') THEN 'DEBIT'
+    WHEN "reference" LIKE '%-CREDIT' OR "reference" LIKE '%-IN' THEN 'CREDIT'
+    ELSE 'DEBIT'
+END::"TransactionDirection";
+
+ALTER TABLE "Transaction" ALTER COLUMN "direction" SET NOT NULL;
+
+-- CreateIndex
+CREATE INDEX "Transaction_walletId_createdAt_id_idx" ON "Transaction"("walletId", "createdAt", "id");
@@ -0,0 +1,45 @@
+-- AlterEnum
+ALTER TYPE "TransactionType" ADD VALUE 'VAULT';
+
+-- AlterEnum
+ALTER TYPE "LedgerAccountType" ADD VALUE 'VAULT';
+
+-- CreateEnum
+CREATE TYPE "VaultStatus" AS ENUM ('ACTIVE', 'CLOSED');
+
+-- AlterTable
+ALTER TABLE "Transaction" ADD COLUMN "vaultId" TEXT;
+
+-- CreateTable
+CREATE TABLE "Vault" (
+    "id" TEXT NOT NULL,
+    "walletId" TEXT NOT NULL,
+    "name" TEXT NOT NULL,
+    "currency" TEXT NOT NULL,
+    "balance" DECIMAL(20,4) NOT NULL DEFAULT 0,
+    "targetAmount" DECIMAL(20,4),
+    "lockedUntil" TIMESTAMP(3),
+    "status" "VaultStatus" NOT NULL DEFAULT 'ACTIVE',
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updatedAt" TIMESTAMP(3) NOT NULL,
+    "closedAt" TIMESTAMP(3),
+
+    CONSTRAINT "Vault_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE INDEX "Transaction_vaultId_idx" ON "Transaction"("vaultId");
+
+-- CreateIndex
+CREATE INDEX "Vault_walletId_status_idx" ON "Vault"("walletId", "status");
+
+-- AddForeignKey
+ALTER TABLE "Transaction" ADD CONSTRAINT "Transaction_vaultId_fkey" FOREIGN KEY ("vaultId") REFERENCES "Vault"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "Vault" ADD CONSTRAINT "Vault_walletId_fkey" FOREIGN KEY ("walletId") REFERENCES "Wallet"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- A vault can't go below zero, and a closed one must be empty, so a move
+-- racing a close rolls back instead of stranding money.
+ALTER TABLE "Vault" ADD CONSTRAINT "non_negative_vault_balance" CHECK ("balance" >= 0);
+ALTER TABLE "Vault" ADD CONSTRAINT "closed_vault_empty" CHECK ("status" = 'ACTIVE' OR "balance" = 0);
@@ -0,0 +1,33 @@
+-- AlterEnum
+ALTER TYPE "TransactionType" ADD VALUE 'INTEREST';
+
+-- CreateTable
+CREATE TABLE "InterestAccrual" (
+    "id" TEXT NOT NULL,
+    "account" TEXT NOT NULL,
+    "accountType" "LedgerAccountType" NOT NULL,
+    "currency" TEXT NOT NULL,
+    "day" DATE NOT NULL,
+    "walletId" TEXT NOT NULL,
+    "vaultId" TEXT,
+    "balance" DECIMAL(20,4) NOT NULL,
+    "annualRate" DECIMAL(9,4) NOT NULL,
+    "amount" DECIMAL(24,10) NOT NULL,
+    "carry" BOOLEAN NOT NULL DEFAULT false,
+    "reference" TEXT,
+    "createdAt" TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT "InterestAccrual_pkey" PRIMARY KEY ("id")
+);
+
+-- CreateIndex
+CREATE UNIQUE INDEX "InterestAccrual_account_currency_day_carry_key" ON "InterestAccrual"("account", "currency", "day", "carry");
+
+-- CreateIndex
+CREATE INDEX "InterestAccrual_reference_day_idx" ON "InterestAccrual"("reference", "day");
+
+-- AddForeignKey
+ALTER TABLE "InterestAccrual" ADD CONSTRAINT "InterestAccrual_walletId_fkey" FOREIGN KEY ("walletId") REFERENCES "Wallet"("id") ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- AddForeignKey
+ALTER TABLE "InterestAccrual" ADD CONSTRAINT "InterestAccrual_vaultId_fkey" FOREIGN KEY ("vaultId") REFERENCES "Vault"("id") ON DELETE SET NULL ON UPDATE CASCADE;
@@ -0,0 +1,22 @@
+-- AlterEnum
+ALTER TYPE "TransactionType" ADD VALUE 'MOVE';
+
+-- CreateEnum
+CREATE TYPE "WalletKind" AS ENUM ('PERSONAL', 'BUSINESS', 'PROJECT');
+
+-- DropIndex
+DROP INDEX "Wallet_userId_key";
+
+-- AlterTable
+ALTER TABLE "Wallet" ADD COLUMN     "name" TEXT NOT NULL DEFAULT 'Personal',
+ADD COLUMN     "kind" "WalletKind" NOT NULL DEFAULT 'PERSONAL',
+ADD COLUMN     "isDefault" BOOLEAN NOT NULL DEFAULT false;
+
+-- Every existing wallet was its user's only one.
+UPDATE "Wallet" SET "isDefault" = true;
+
+-- CreateIndex
+CREATE UNIQUE INDEX "Wallet_userId_name_key" ON "Wallet"("userId", "name");
+
+-- A user has exactly one default wallet.
+CREATE UNIQUE INDEX "Wallet_userId_default_key" ON "Wallet"("userId") WHERE "isDefault";